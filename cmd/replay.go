@@ -0,0 +1,87 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/config"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+)
+
+// replayCmd feeds a tap recording into a local engine
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay a tap recording against a local engine",
+	Long: `Replay starts the engine of the config on the in-process transport, sends it
+the requests recorded as received by the tap and compares its responses with
+the recorded ones.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, _ := cmd.Flags().GetString("config")
+		file, _ := cmd.Flags().GetString("file")
+		node, _ := cmd.Flags().GetString("node")
+		speed, _ := cmd.Flags().GetFloat64("speed")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		records, err := rpc.ReadTapFile(file)
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+		// the recording holds unsigned envelopes and must not grow while replayed
+		connString := rpc.LoopbackScheme + "://replay"
+		cfg.Engine.Nats = connString
+		cfg.Engine.Auth = config.AuthConfig{}
+		cfg.Tap = config.TapConfig{}
+
+		engine, err := config.BuildEngine(context.Background(), cfg)
+		if err != nil {
+			return err
+		}
+		engine.MustInit()
+		if err := engine.Start(); err != nil {
+			return err
+		}
+		defer engine.Stop()
+
+		target := concepts.GenServerAddress(cfg.Engine.Realm, cfg.Engine.Kind, cfg.Engine.Id)
+		replayer := rpc.NewReplayer(connString, target,
+			rpc.WithReplayNode(node),
+			rpc.WithReplaySpeed(speed),
+			rpc.WithReplayTimeout(timeout),
+		)
+		report, err := replayer.Replay(cmd.Context(), records)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		mismatches := report.Mismatches()
+		for _, result := range mismatches {
+			fmt.Fprintf(out, "%s opcode %d: %s\n", result.Request.Time.Format("15:04:05.000000"), result.Opcode, result.Diff)
+		}
+		fmt.Fprintf(out, "sent %d requests, %d of %d responses differ\n", report.Sent, len(mismatches), len(report.Results))
+		if len(mismatches) > 0 {
+			return fmt.Errorf("%d responses differ", len(mismatches))
+		}
+		return nil
+	},
+}
+
+func init() {
+	replayCmd.Flags().StringP("config", "c", "configs/api.yaml", "config file of the engine replaying (yaml, toml or json)")
+	replayCmd.Flags().StringP("file", "f", "", "tap recording")
+	replayCmd.Flags().String("node", "", "replay only the requests received by this server address")
+	replayCmd.Flags().Float64("speed", 1, "timing of the recording, 10 is ten times faster and 0 sends without waiting")
+	replayCmd.Flags().Duration("timeout", rpc.DefaultReplayTimeout, "wait for each response")
+	replayCmd.MarkFlagRequired("file")
+
+	rootCmd.AddCommand(replayCmd)
+}
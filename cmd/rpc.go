@@ -0,0 +1,192 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/config"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	// the message types named on the command line
+	_ "github.com/wuqunyong/file_storage/proto/common_msg"
+	_ "github.com/wuqunyong/file_storage/proto/nats_msg"
+)
+
+// rpcCmd groups the commands talking to the engines directly
+var rpcCmd = &cobra.Command{
+	Use:   "rpc",
+	Short: "Talk to the actors of running engines",
+}
+
+// rpcCallCmd sends one request built from JSON
+var rpcCallCmd = &cobra.Command{
+	Use:   "call",
+	Short: "Call an opcode of an actor",
+	Long: `Call encodes the JSON argument into the named protobuf type, sends it to the
+opcode of the target actor and prints the decoded response, e.g.
+
+  file_storage rpc call --target engine.0.1.1001.server/1 --opcode 1001 \
+    --type rpc_msg.RPC_EchoTestRequest --json '{"value1": 1, "value2": "hi"}'
+
+The response type defaults to the request type ending in Response instead
+of Request.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		targetFlag, _ := flags.GetString("target")
+		opcode, _ := flags.GetUint32("opcode")
+		typeName, _ := flags.GetString("type")
+		replyName, _ := flags.GetString("reply-type")
+		body, _ := flags.GetString("json")
+		stream, _ := flags.GetBool("stream")
+		notify, _ := flags.GetBool("notify")
+		timeout, _ := flags.GetDuration("timeout")
+
+		target, err := parseTarget(targetFlag)
+		if err != nil {
+			return err
+		}
+		request, err := newMessage(typeName)
+		if err != nil {
+			return err
+		}
+		if err := protojson.Unmarshal([]byte(body), request); err != nil {
+			return fmt.Errorf("json: %w", err)
+		}
+		data, err := proto.Marshal(request)
+		if err != nil {
+			return err
+		}
+		var reply proto.Message
+		if !notify {
+			if replyName == "" {
+				replyName = strings.TrimSuffix(typeName, "Request") + "Response"
+			}
+			// without the type the response is printed as bytes
+			reply, _ = newMessage(replyName)
+		}
+
+		caller, err := newCaller(cmd)
+		if err != nil {
+			return err
+		}
+		defer caller.Close()
+
+		if notify {
+			return caller.Notify(target, opcode, data)
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+		defer cancel()
+		return caller.Call(ctx, target, opcode, data, stream, func(response *rpc_msg.RPC_RESPONSE) error {
+			return printResponse(cmd.OutOrStdout(), response, reply)
+		})
+	},
+}
+
+func init() {
+	flags := rpcCallCmd.Flags()
+	flags.StringP("config", "c", "", "config file to take the nats url and credentials from")
+	flags.String("nats", "", "nats url, overrides the one of the config")
+	flags.String("target", "", "address/actorId of the actor, e.g. engine.0.1.1001.server/1")
+	flags.Uint32("opcode", 0, "opcode to call")
+	flags.String("type", "", "full name of the request type, e.g. rpc_msg.RPC_EchoTestRequest")
+	flags.String("reply-type", "", "full name of the response type")
+	flags.String("json", "{}", "request as protobuf JSON")
+	flags.Bool("stream", false, "ask for several responses, sent by handlers with actor.SendMore, and print them until the last one")
+	flags.Bool("notify", false, "send without waiting for a response")
+	flags.Duration("timeout", 5*time.Second, "wait for the responses")
+	rpcCallCmd.MarkFlagRequired("target")
+	rpcCallCmd.MarkFlagRequired("opcode")
+	rpcCallCmd.MarkFlagRequired("type")
+	rpcCallCmd.MarkFlagsMutuallyExclusive("stream", "notify")
+
+	rpcCmd.AddCommand(rpcCallCmd)
+	rootCmd.AddCommand(rpcCmd)
+}
+
+// newCaller connects with the settings of the config, if any.
+func newCaller(cmd *cobra.Command) (*rpc.Caller, error) {
+	configPath, _ := cmd.Flags().GetString("config")
+	natsURL, _ := cmd.Flags().GetString("nats")
+
+	var opts []rpc.CallerOption
+	if configPath != "" {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Engine.Compression.Apply()
+		cfg.Engine.NatsAuth.Apply()
+		auth, err := cfg.Engine.Auth.Authenticator()
+		if err != nil {
+			return nil, err
+		}
+		if auth != nil {
+			opts = append(opts, rpc.WithCallerAuthenticator(auth))
+		}
+		if natsURL == "" {
+			natsURL = cfg.Engine.Nats
+		}
+	}
+	if natsURL == "" {
+		return nil, errors.New("no nats url, set --nats or --config")
+	}
+	return rpc.NewCaller(natsURL, opts...)
+}
+
+// parseTarget splits address/actorId.
+func parseTarget(target string) (*concepts.ActorId, error) {
+	i := strings.LastIndex(target, "/")
+	if i <= 0 || i == len(target)-1 {
+		return nil, fmt.Errorf("target %q is not address/actorId", target)
+	}
+	return concepts.NewActorId(target[:i], target[i+1:]), nil
+}
+
+func newMessage(name string) (proto.Message, error) {
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("type %s: %w", name, err)
+	}
+	return messageType.New().Interface(), nil
+}
+
+func printResponse(w io.Writer, response *rpc_msg.RPC_RESPONSE, reply proto.Message) error {
+	status := response.GetStatus()
+	if status.GetCode() != 0 {
+		text, err := protojson.MarshalOptions{Multiline: true}.Marshal(status)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(text))
+		return fmt.Errorf("request failed with code %d", status.GetCode())
+	}
+
+	if reply == nil {
+		fmt.Fprintf(w, "%d bytes: %x\n", len(response.GetResultData()), response.GetResultData())
+		return nil
+	}
+	proto.Reset(reply)
+	if err := proto.Unmarshal(response.GetResultData(), reply); err != nil {
+		return fmt.Errorf("decode %s: %w", reply.ProtoReflect().Descriptor().FullName(), err)
+	}
+	text, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, string(text))
+	return nil
+}
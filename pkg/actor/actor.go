@@ -15,6 +15,7 @@ import (
 	"github.com/wuqunyong/file_storage/pkg/logger"
 	"github.com/wuqunyong/file_storage/pkg/msg"
 	"github.com/wuqunyong/file_storage/pkg/tick"
+	"github.com/wuqunyong/file_storage/pkg/trace"
 )
 
 type Actor struct {
@@ -105,23 +106,24 @@ func (a *Actor) GetTimerQueue() *tick.TimerQueue {
 	return a.timerQueue
 }
 
-func SendRequest[T any](actor concepts.IActor, target *concepts.ActorId, opcode uint32, args any, opts ...context.Context) (*T, errs.CodeError) {
+func SendRequest[T any](actor concepts.IActor, target *concepts.ActorId, opcode uint32, args any, opts ...concepts.RequestOption) (*T, errs.CodeError) {
 	request := actor.Request(target, opcode, args, opts...)
 	resp, err := msg.GetResult[T](request)
 	return resp, err
 }
 
-func SendNotify(actor concepts.IActor, target *concepts.ActorId, opcode uint32, args any, opts ...context.Context) error {
+func SendNotify(actor concepts.IActor, target *concepts.ActorId, opcode uint32, args any, opts ...concepts.RequestOption) error {
+	opts = append(opts, concepts.WithoutReply())
 	request := actor.Request(target, opcode, args, opts...)
 	return request.Error()
 }
 
-func (a *Actor) Request(target *concepts.ActorId, opcode uint32, args any, opts ...context.Context) concepts.IMsgReq {
-	var ctx context.Context
-	if len(opts) > 0 {
-		ctx = opts[0]
+func (a *Actor) Request(target *concepts.ActorId, opcode uint32, args any, opts ...concepts.RequestOption) concepts.IMsgReq {
+	options := concepts.NewRequestOptions(opts...)
+	if options.Codec == nil {
+		options.Codec = a.Codec()
 	}
-	request := msg.NewMsgReq(target, opcode, args, ctx, a.Codec())
+	request := msg.NewMsgReq(target, opcode, args, options)
 	if request.Err != nil {
 		return request
 	}
@@ -132,11 +134,20 @@ func (a *Actor) Request(target *concepts.ActorId, opcode uint32, args any, opts
 	}
 
 	request.Sender = a.ActorId()
+	parent := trace.SpanContextFromContext(options.Ctx)
+	if !parent.IsValid() {
+		parent = a.msgs.current()
+	}
+	request.StartSpan(parent)
 	err := a.context.engine.Request(request)
 	if err != nil {
 		request.Err = err
+		request.FinishSpan(err)
 		return request
 	}
+	if request.OneWay {
+		request.FinishSpan(nil)
+	}
 
 	return request
 }
@@ -177,10 +188,20 @@ func (a *Actor) waitForChildrenClosed() {
 	}
 }
 
+// MailboxLen is the number of messages waiting for the actor.
+func (a *Actor) MailboxLen() int {
+	return a.msgs.Len()
+}
+
 func (a *Actor) Register(opcode uint32, fun interface{}) error {
 	return a.msgs.Register(opcode, fun)
 }
 
+// Opcodes lists the opcodes the actor has a handler for.
+func (a *Actor) Opcodes() []uint32 {
+	return a.msgs.Opcodes()
+}
+
 func (a *Actor) Send(request concepts.IMsgReq) error {
 	return a.msgs.Send(request)
 }
@@ -189,6 +210,12 @@ func (a *Actor) PostTask(funObj func()) error {
 	return a.msgs.Send(funObj)
 }
 
+// PostTaskContext posts funObj like PostTask, traced as a child of the span
+// carried by ctx. The requests sent by funObj join the trace.
+func (a *Actor) PostTaskContext(ctx context.Context, funObj func()) error {
+	return a.msgs.Send(&tracedTask{fn: funObj, trace: trace.SpanContextFromContext(ctx)})
+}
+
 func (a *Actor) IsRoot() bool {
 	return a.context.Parent() == nil
 }
@@ -288,3 +315,13 @@ func (a *Actor) OnInit() error {
 
 func (a *Actor) OnShutdown() {
 }
+
+// ChildActor is embedded by actors spawned through SpawnChild, the parent
+// context creates the underlying *Actor and injects it with SetEmbeddingActor.
+type ChildActor struct {
+	*Actor
+}
+
+func (c *ChildActor) SetEmbeddingActor(actor concepts.IActor) {
+	c.Actor = actor.(*Actor)
+}
@@ -152,7 +152,10 @@ func Test(t *testing.T) {
 
 	time.Sleep(time.Duration(6) * time.Second)
 
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	<-sigs
+	// keep the process around for manual inspection
+	if os.Getenv("WAIT_SIGNAL") != "" {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		<-sigs
+	}
 }
@@ -0,0 +1,81 @@
+package actor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+type anyActor struct {
+	*Actor
+	handled atomic.Int32
+}
+
+func (a *anyActor) OnInit() error {
+	return a.Register(1, a.Echo)
+}
+
+func (a *anyActor) OnShutdown() {}
+
+func (a *anyActor) Echo(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	a.handled.Add(1)
+	response.Value1 = request.Value1 + 1
+	return nil
+}
+
+func TestAnyNode(t *testing.T) {
+	connString := "loopback://TestAnyNode"
+	var services []*anyActor
+	var engines []*Engine
+	for _, id := range []uint32{1001, 1003, 1004} {
+		engine := NewEngine(0, 1, id, connString)
+		engine.MustInit()
+		service := &anyActor{Actor: NewActor("1", engine)}
+		engine.MustSpawnActors(service)
+		if err := engine.Start(); err != nil {
+			t.Fatalf("start err:%v", err)
+		}
+		t.Cleanup(engine.Stop)
+		engines = append(engines, engine)
+		services = append(services, service)
+	}
+
+	engine := NewEngine(0, 2, 1002, connString)
+	engine.MustInit()
+	defer engine.Stop()
+	engine.Start()
+	client := NewActor("1", engine)
+
+	target := concepts.NewAnyActorId(0, 1, "1")
+	send := func(count int) {
+		for i := 0; i < count; i++ {
+			echo := &common_msg.EchoRequest{Value1: uint64(i), Value2: "any"}
+			response, err := SendRequest[common_msg.EchoResponse](client, target, 1, echo)
+			if err != nil {
+				t.Fatalf("request %d failure, err:%v", i, err)
+			}
+			if response.Value1 != echo.Value1+1 {
+				t.Fatalf("unexpected response:%v", response)
+			}
+		}
+	}
+
+	send(60)
+	for i, service := range services {
+		if service.handled.Load() == 0 {
+			t.Fatalf("node %d got no request", i)
+		}
+	}
+
+	// a stopped node leaves the queue group, the others take over
+	engines[0].Stop()
+	handled := services[0].handled.Load()
+	send(30)
+	if services[0].handled.Load() != handled {
+		t.Fatal("stopped node still gets requests")
+	}
+}
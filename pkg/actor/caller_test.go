@@ -0,0 +1,100 @@
+package actor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+	"google.golang.org/protobuf/proto"
+)
+
+type callerActor struct {
+	*Actor
+	notified chan *rpc_msg.RPC_EchoTestRequest
+}
+
+func (a *callerActor) OnInit() error {
+	a.Register(1, a.Echo)
+	return a.Register(2, a.Notify)
+}
+
+func (a *callerActor) OnShutdown() {}
+
+func (a *callerActor) Echo(ctx context.Context, request *rpc_msg.RPC_EchoTestRequest, response *rpc_msg.RPC_EchoTestResponse) errs.CodeError {
+	response.Value1 = request.Value1
+	response.Value2 = request.Value2 + "| Response"
+	return nil
+}
+
+func (a *callerActor) Notify(ctx context.Context, notify *rpc_msg.RPC_EchoTestRequest) {
+	a.notified <- notify
+}
+
+func TestCaller(t *testing.T) {
+	connString := "loopback://TestCaller"
+	engine := NewEngine(0, 1, 1001, connString)
+	engine.MustInit()
+	service := &callerActor{
+		Actor:    NewActor("1", engine),
+		notified: make(chan *rpc_msg.RPC_EchoTestRequest, 1),
+	}
+	engine.MustSpawnActors(service)
+	if err := engine.Start(); err != nil {
+		t.Fatalf("start err:%v", err)
+	}
+	defer engine.Stop()
+
+	caller, err := rpc.NewCaller(connString)
+	if err != nil {
+		t.Fatalf("caller err:%v", err)
+	}
+	defer caller.Close()
+	target := concepts.NewActorId("engine.0.1.1001.server", "1")
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args, _ := proto.Marshal(&rpc_msg.RPC_EchoTestRequest{Value1: 5, Value2: "hi"})
+	for _, stream := range []bool{false, true} {
+		var responses []*rpc_msg.RPC_RESPONSE
+		err = caller.Call(ctx, target, 1, args, stream, func(response *rpc_msg.RPC_RESPONSE) error {
+			responses = append(responses, response)
+			return nil
+		})
+		if err != nil || len(responses) != 1 {
+			t.Fatalf("call err:%v responses:%v", err, responses)
+		}
+		reply := &rpc_msg.RPC_EchoTestResponse{}
+		if err := proto.Unmarshal(responses[0].ResultData, reply); err != nil || reply.Value1 != 5 || reply.Value2 != "hi| Response" {
+			t.Fatalf("unexpected reply:%v err:%v", reply, err)
+		}
+	}
+
+	args, _ = proto.Marshal(&common_msg.EchoRequest{})
+	err = caller.Call(ctx, target, 999, args, false, func(response *rpc_msg.RPC_RESPONSE) error {
+		if response.GetStatus().GetCode() != errs.CODE_OpcodeUnregister {
+			t.Fatalf("unexpected status:%v", response.GetStatus())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("call err:%v", err)
+	}
+
+	args, _ = proto.Marshal(&rpc_msg.RPC_EchoTestRequest{Value1: 6})
+	if err := caller.Notify(target, 2, args); err != nil {
+		t.Fatalf("notify err:%v", err)
+	}
+	select {
+	case notify := <-service.notified:
+		if notify.Value1 != 6 {
+			t.Fatalf("unexpected notify:%v", notify)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("notify not delivered")
+	}
+}
@@ -0,0 +1,101 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+)
+
+type durableActor struct {
+	*Actor
+	attempts map[uint64]int
+	notified chan *rpc_msg.RPC_EchoTestRequest
+}
+
+func (a *durableActor) OnInit() error {
+	return a.Register(1, a.Notify)
+}
+
+func (a *durableActor) OnShutdown() {}
+
+// Notify fails the first delivery of every notify, and all of them when
+// Value2 is "fail".
+func (a *durableActor) Notify(ctx context.Context, notify *rpc_msg.RPC_EchoTestRequest) error {
+	a.attempts[notify.Value1]++
+	if a.attempts[notify.Value1] == 1 || notify.Value2 == "fail" {
+		return fmt.Errorf("attempt %d failed", a.attempts[notify.Value1])
+	}
+	a.notified <- notify
+	return nil
+}
+
+func TestDurableNotify(t *testing.T) {
+	connString := "loopback://TestDurableNotify"
+	stream := rpc.NewMemoryStream()
+	policy := rpc.DurablePolicy{MaxDeliver: 3, Backoff: 10 * time.Millisecond}
+
+	engine := NewEngine(0, 2, 1002, connString)
+	engine.SetDurable(stream, policy)
+	engine.MustInit()
+	defer engine.Stop()
+	if err := engine.Start(); err != nil {
+		t.Fatalf("start err:%v", err)
+	}
+	client := NewActor("1", engine)
+
+	// both notifies are stored while the target is still down
+	target := concepts.NewActorId("engine.0.1.1001.server", "1")
+	for _, value := range []*rpc_msg.RPC_EchoTestRequest{{Value1: 1, Value2: "ok"}, {Value1: 2, Value2: "fail"}} {
+		if err := SendNotify(client, target, 1, value, concepts.WithDurable()); err != nil {
+			t.Fatalf("durable notify err:%v", err)
+		}
+	}
+	if err := SendNotify(client, target, 1, &rpc_msg.RPC_EchoTestRequest{}); err != nil {
+		t.Fatalf("notify err:%v", err)
+	}
+	if _, err := SendRequest[rpc_msg.RPC_EchoTestResponse](client, target, 1, &rpc_msg.RPC_EchoTestRequest{}, concepts.WithDurable(), concepts.WithTimeout(100*time.Millisecond)); err == nil {
+		t.Fatal("a request can not be durable")
+	}
+
+	server := NewEngine(0, 1, 1001, connString)
+	server.SetDurable(stream, policy)
+	server.MustInit()
+	service := &durableActor{
+		Actor:    NewActor("1", server),
+		attempts: make(map[uint64]int),
+		notified: make(chan *rpc_msg.RPC_EchoTestRequest, 4),
+	}
+	server.MustSpawnActors(service)
+	if err := server.Start(); err != nil {
+		t.Fatalf("start err:%v", err)
+	}
+	defer server.Stop()
+
+	select {
+	case notify := <-service.notified:
+		if notify.Value1 != 1 {
+			t.Fatalf("unexpected notify:%v", notify)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("durable notify not redelivered")
+	}
+
+	deadLetter := rpc.DeadLetterSubject("engine.0.1.1001.server")
+	deadline := time.Now().Add(3 * time.Second)
+	for len(stream.Messages(deadLetter)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("failing notify not dead-lettered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case notify := <-service.notified:
+		t.Fatalf("dead-lettered notify handled:%v", notify)
+	default:
+	}
+}
@@ -1,19 +1,25 @@
 package actor
 
 import (
+	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/wuqunyong/file_storage/pkg/concepts"
 	"github.com/wuqunyong/file_storage/pkg/constants"
+	"github.com/wuqunyong/file_storage/pkg/dedup"
 	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/pkg/metrics"
 	"github.com/wuqunyong/file_storage/pkg/rpc"
 )
 
@@ -27,6 +33,25 @@ const (
 	STATE_SHUTDOWN
 )
 
+func (s ServerState) String() string {
+	switch s {
+	case STATE_UNINITIALIZED:
+		return "uninitialized"
+	case STATE_INITIALIZED:
+		return "initialized"
+	case STATE_RUNNING:
+		return "running"
+	case STATE_SHUTTING_DOWN:
+		return "shutting_down"
+	case STATE_SHUTDOWN:
+		return "shutdown"
+	}
+	return fmt.Sprintf("ServerState(%d)", int(s))
+}
+
+// DefaultHealthCheckTimeout bounds every single health check.
+var DefaultHealthCheckTimeout = 3 * time.Second
+
 type Engine struct {
 	registry   *Registry
 	address    string
@@ -38,14 +63,36 @@ type Engine struct {
 	lastError  error
 	mu         sync.Mutex
 	components map[string]concepts.IComponent
+	router     *serviceRouter
+	// unregisterMetrics removes the engine from metrics.Default
+	unregisterMetrics func()
 }
 
 type IComponentSlice []concepts.IComponent
 
-func (c IComponentSlice) Len() int           { return len(c) }
-func (c IComponentSlice) Less(i, j int) bool { return c[i].Priority() < c[j].Priority() }
-func (c IComponentSlice) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+// StartError is returned by Engine.Start when a component fails to start.
+// RolledBack lists the cleaned up components in the order of their cleanup.
+type StartError struct {
+	Component  string
+	Err        error
+	RolledBack []string
+}
+
+func (e *StartError) Error() string {
+	return fmt.Sprintf("component %s start: %v (rolled back: %s)", e.Component, e.Err, strings.Join(e.RolledBack, ", "))
+}
+
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
 
+// DefaultCleanupTimeout bounds the OnCleanup of every single component.
+var DefaultCleanupTimeout = 10 * time.Second
+
+// NewEngine creates the engine realm.kind.id. A connection string enables
+// remote requests, its scheme picks the rpc transport: nats://host:4222,
+// tcp://host:port for direct connections between engines or loopback://name
+// for engines of the same process.
 func NewEngine(realm, kind, id uint32, optConnString ...string) *Engine {
 	sServerAddress := concepts.GenServerAddress(realm, kind, id)
 	rpcFlag := false
@@ -60,6 +107,7 @@ func NewEngine(realm, kind, id uint32, optConnString ...string) *Engine {
 		address:    sServerAddress,
 		connString: connString,
 		components: make(map[string]concepts.IComponent),
+		router:     newServiceRouter(),
 	}
 	e.setState(STATE_UNINITIALIZED)
 	e.registry = newRegistry(e)
@@ -72,6 +120,117 @@ func NewEngine(realm, kind, id uint32, optConnString ...string) *Engine {
 	return e
 }
 
+// SetDedup enables request deduplication by idempotency key on the rpc server.
+func (e *Engine) SetDedup(cache *dedup.Cache) {
+	server, ok := e.rpcServer.(*rpc.RPCServer)
+	if ok {
+		server.SetDedup(cache)
+	}
+}
+
+// SetRPCTransport replaces the transports of the rpc client and server, e.g.
+// with a rpc.KindTransport sending some service kinds over direct TCP. It
+// must be called before MustInit.
+func (e *Engine) SetRPCTransport(client, server rpc.Transport) {
+	if rpcClient, ok := e.rpcClient.(*rpc.RPCClient); ok && client != nil {
+		rpcClient.SetTransport(client)
+	}
+	if rpcServer, ok := e.rpcServer.(*rpc.RPCServer); ok && server != nil {
+		rpcServer.SetTransport(server)
+	}
+}
+
+// SetDurable lets notifies sent with concepts.WithDurable go through stream,
+// and consumes the ones stored for this engine with policy. It must be
+// called before MustInit; a stream with a Close method is closed on Stop.
+func (e *Engine) SetDurable(stream rpc.DurableStream, policy rpc.DurablePolicy) {
+	if rpcClient, ok := e.rpcClient.(*rpc.RPCClient); ok {
+		rpcClient.SetDurableStream(stream)
+	}
+	if rpcServer, ok := e.rpcServer.(*rpc.RPCServer); ok {
+		rpcServer.SetDurable(stream, policy)
+	}
+}
+
+// SetAuthenticator signs the rpc messages sent by this engine and drops the
+// received ones that auth rejects. It must be called before MustInit.
+func (e *Engine) SetAuthenticator(auth *rpc.Authenticator) {
+	if rpcClient, ok := e.rpcClient.(*rpc.RPCClient); ok {
+		rpcClient.SetAuthenticator(auth)
+	}
+	if rpcServer, ok := e.rpcServer.(*rpc.RPCServer); ok {
+		rpcServer.SetAuthenticator(auth)
+	}
+}
+
+// SetPeerResolver makes the direct TCP transports of the engine find their
+// peers with resolver, usually a rpc.RegistryResolver.
+func (e *Engine) SetPeerResolver(resolver rpc.PeerResolver) {
+	if rpcClient, ok := e.rpcClient.(*rpc.RPCClient); ok {
+		rpcClient.SetPeerResolver(resolver)
+	}
+	if rpcServer, ok := e.rpcServer.(*rpc.RPCServer); ok {
+		rpcServer.SetPeerResolver(resolver)
+	}
+}
+
+// SigningKey is the ed25519 public key the rpc messages of this engine are
+// signed with, nil unless its authenticator uses a rpc.Ed25519Keyring.
+func (e *Engine) SigningKey() ed25519.PublicKey {
+	rpcServer, ok := e.rpcServer.(*rpc.RPCServer)
+	if !ok || rpcServer.Authenticator() == nil {
+		return nil
+	}
+	if keyring, ok := rpcServer.Authenticator().Keyring().(*rpc.Ed25519Keyring); ok {
+		return keyring.PublicKey()
+	}
+	return nil
+}
+
+// TCPAddress is the address the direct TCP transport of the engine listens
+// on once initialized, empty when it has none.
+func (e *Engine) TCPAddress() string {
+	if rpcServer, ok := e.rpcServer.(*rpc.RPCServer); ok {
+		return rpcServer.TCPAddr()
+	}
+	return ""
+}
+
+// SetTap records the rpc envelopes of this engine with tap, nil stops
+// recording. The tap replaced is closed.
+func (e *Engine) SetTap(tap *rpc.Tap) {
+	var previous *rpc.Tap
+	if rpcClient, ok := e.rpcClient.(*rpc.RPCClient); ok {
+		previous = rpcClient.Tap()
+		rpcClient.SetTap(tap)
+	}
+	if rpcServer, ok := e.rpcServer.(*rpc.RPCServer); ok {
+		rpcServer.SetTap(tap)
+	}
+	if previous != nil && previous != tap {
+		if err := previous.Close(); err != nil {
+			logger.Log(logger.WarnLevel, "Engine close tap", "err", err)
+		}
+	}
+}
+
+// SetBreakerPolicy sets the policy of the circuit breakers failing fast the
+// requests to unresponsive engines.
+func (e *Engine) SetBreakerPolicy(policy rpc.BreakerPolicy) {
+	if rpcClient, ok := e.rpcClient.(*rpc.RPCClient); ok {
+		rpcClient.Breakers().SetPolicy(policy)
+	}
+}
+
+// Ejected reports whether requests to the server address currently fail
+// fast, routing among nodes should skip it.
+func (e *Engine) Ejected(address string) bool {
+	if rpcClient, ok := e.rpcClient.(*rpc.RPCClient); ok {
+		return rpcClient.Breakers().Ejected(address)
+	}
+	return false
+}
+
 func (e *Engine) GetRegistry() concepts.IRegistry {
 	return e.registry
 }
@@ -102,6 +261,25 @@ func (e *Engine) GetComponent(name string) concepts.IComponent {
 	return nil
 }
 
+// Components lists the components added to the engine, sorted by name.
+func (e *Engine) Components() []concepts.IComponent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	components := make([]concepts.IComponent, 0, len(e.components))
+	for _, component := range e.components {
+		components = append(components, component)
+	}
+	slices.SortFunc(components, func(a, b concepts.IComponent) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return components
+}
+
+// Opcodes lists the opcodes handled by the actors of the engine.
+func (e *Engine) Opcodes() []uint32 {
+	return e.registry.opcodes()
+}
+
 func (e *Engine) GetAddress() string {
 	return e.address
 }
@@ -143,6 +321,7 @@ func (e *Engine) Request(request concepts.IMsgReq) error {
 		if err != nil {
 			return err
 		}
+		request.SetRPCClient(e.rpcClient)
 		return e.rpcClient.SendRequest(request)
 	}
 
@@ -155,6 +334,9 @@ func (e *Engine) Request(request concepts.IMsgReq) error {
 }
 
 func (e *Engine) MustInit() {
+	if e.unregisterMetrics == nil {
+		e.unregisterMetrics = metrics.Default.Register(e)
+	}
 	if e.rpcFlag {
 		err := e.rpcClient.Init()
 		if err != nil {
@@ -168,11 +350,13 @@ func (e *Engine) MustInit() {
 		}
 	}
 
-	components := e.GetComponentSlice(false)
-	for _, obj := range components {
-		obj.SetEngine(e)
-		err := obj.OnInit()
-		if err != nil {
+	levels, err := e.componentLevels()
+	if err != nil {
+		e.lastError = err
+		panic(err)
+	}
+	for _, level := range levels {
+		if err := e.initLevel(level); err != nil {
 			e.lastError = err
 			panic(err)
 		}
@@ -181,30 +365,171 @@ func (e *Engine) MustInit() {
 	e.setState(STATE_INITIALIZED)
 }
 
-func (e *Engine) Start() {
+// Start starts the components in dependency order. When one of them fails,
+// every initialized component is cleaned up in reverse level order, the rpc
+// connections are closed and a *StartError is returned.
+func (e *Engine) Start() error {
+	levels, err := e.componentLevels()
+	if err != nil {
+		e.lastError = err
+		return err
+	}
+
 	if e.rpcFlag {
 		go e.rpcClient.Run()
 		go e.rpcServer.Run()
 	}
 
-	components := e.GetComponentSlice(false)
-	for _, obj := range components {
-		obj.OnStart()
+	for _, level := range levels {
+		for _, obj := range level {
+			if err := obj.OnStart(); err != nil {
+				startErr := &StartError{Component: obj.Name(), Err: err}
+				for i := len(levels) - 1; i >= 0; i-- {
+					e.cleanupLevel(levels[i])
+					for _, cleaned := range levels[i] {
+						startErr.RolledBack = append(startErr.RolledBack, cleaned.Name())
+					}
+				}
+				if e.rpcFlag {
+					e.rpcClient.Stop()
+					e.rpcServer.Stop()
+				}
+				e.lastError = startErr
+				e.setState(STATE_SHUTDOWN)
+				return startErr
+			}
+		}
 	}
 	e.setState(STATE_RUNNING)
+
+	// only a running node takes requests sent to any node of its kind
+	if server, ok := e.rpcServer.(*rpc.RPCServer); ok {
+		if err := server.JoinAny(); err != nil {
+			logger.Log(logger.ErrorLevel, "Engine join any", "subject", server.AnySubject(), "err", err)
+		}
+	}
+	return nil
 }
 
-func (e *Engine) GetComponentSlice(reverse bool) IComponentSlice {
+// GetComponentSlice returns the components in dependency order, or in the
+// reverse of it.
+func (e *Engine) GetComponentSlice(reverse bool) (IComponentSlice, error) {
+	levels, err := e.componentLevels()
+	if err != nil {
+		return nil, err
+	}
 	var components IComponentSlice
-	for _, value := range e.components {
-		components = append(components, value)
+	for _, level := range levels {
+		components = append(components, level...)
 	}
 	if reverse {
-		sort.Sort(sort.Reverse(components))
-	} else {
-		sort.Sort(components)
+		slices.Reverse(components)
 	}
-	return components
+	return components, nil
+}
+
+// CheckDependencies reports missing dependencies and dependency cycles
+// between the components added so far.
+func (e *Engine) CheckDependencies() error {
+	_, err := e.componentLevels()
+	return err
+}
+
+// componentLevels sorts the components topologically. Every level only
+// depends on the levels before it, so the components of one level can be
+// initialized in parallel.
+func (e *Engine) componentLevels() ([]IComponentSlice, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	indegree := make(map[string]int, len(e.components))
+	dependents := make(map[string][]string)
+	for name, component := range e.components {
+		indegree[name] += 0
+		for _, dep := range component.Dependencies() {
+			if _, ok := e.components[dep]; !ok {
+				return nil, fmt.Errorf("component %s depends on missing component %s", name, dep)
+			}
+			indegree[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var levels []IComponentSlice
+	var ready []string
+	for name, degree := range indegree {
+		if degree == 0 {
+			ready = append(ready, name)
+		}
+	}
+	done := 0
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		level := make(IComponentSlice, 0, len(ready))
+		var next []string
+		for _, name := range ready {
+			level = append(level, e.components[name])
+			for _, dependent := range dependents[name] {
+				indegree[dependent]--
+				if indegree[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		levels = append(levels, level)
+		done += len(ready)
+		ready = next
+	}
+
+	if done != len(e.components) {
+		var cycle []string
+		for name, degree := range indegree {
+			if degree > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+		return nil, fmt.Errorf("component dependency cycle between %s", strings.Join(cycle, ", "))
+	}
+	return levels, nil
+}
+
+func (e *Engine) initLevel(level IComponentSlice) error {
+	errs := make([]error, len(level))
+	var wg sync.WaitGroup
+	for i, obj := range level {
+		obj.SetEngine(e)
+		wg.Add(1)
+		go func(i int, obj concepts.IComponent) {
+			defer wg.Done()
+			if err := obj.OnInit(); err != nil {
+				errs[i] = fmt.Errorf("component %s init: %w", obj.Name(), err)
+			}
+		}(i, obj)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (e *Engine) cleanupLevel(level IComponentSlice) {
+	var wg sync.WaitGroup
+	for _, obj := range level {
+		wg.Add(1)
+		go func(obj concepts.IComponent) {
+			defer wg.Done()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				obj.OnCleanup()
+			}()
+			select {
+			case <-done:
+			case <-time.After(DefaultCleanupTimeout):
+				logger.Log(logger.ErrorLevel, "Engine component cleanup timeout", "name", obj.Name(), "timeout", DefaultCleanupTimeout)
+			}
+		}(obj)
+	}
+	wg.Wait()
 }
 
 func (e *Engine) waitForRootClosed() {
@@ -218,7 +543,13 @@ func (e *Engine) waitForRootClosed() {
 }
 
 func (e *Engine) Stop() {
+	if e.GetState() == STATE_SHUTDOWN {
+		return
+	}
 	e.setState(STATE_SHUTTING_DOWN)
+	if server, ok := e.rpcServer.(*rpc.RPCServer); ok {
+		server.LeaveAny()
+	}
 	rootIds := e.registry.GetRootID()
 	for _, id := range rootIds {
 		actor := e.registry.GetByID(id)
@@ -228,21 +559,114 @@ func (e *Engine) Stop() {
 	}
 	e.waitForRootClosed()
 
-	components := e.GetComponentSlice(true)
-	for _, obj := range components {
-		obj.OnCleanup()
+	levels, err := e.componentLevels()
+	if err != nil {
+		logger.Log(logger.ErrorLevel, "Engine Stop", "err", err)
+	}
+	for i := len(levels) - 1; i >= 0; i-- {
+		e.cleanupLevel(levels[i])
 	}
 
 	if e.rpcFlag {
 		e.rpcClient.Stop()
 		e.rpcServer.Stop()
 	}
+	if e.unregisterMetrics != nil {
+		e.unregisterMetrics()
+	}
 	e.setState(STATE_SHUTDOWN)
 }
 
 func (e *Engine) setState(state ServerState) {
+	e.mu.Lock()
 	e.state = state
-	logger.Log(logger.WarnLevel, "Engine ChangeState", "state", e.state)
+	e.mu.Unlock()
+	logger.Log(logger.WarnLevel, "Engine ChangeState", "state", state)
+}
+
+func (e *Engine) GetState() ServerState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state
+}
+
+// CheckHealth runs the checks of the rpc connections and of every component
+// implementing concepts.IHealthChecker concurrently, each bounded by
+// DefaultHealthCheckTimeout.
+func (e *Engine) CheckHealth(ctx context.Context) *concepts.HealthReport {
+	checkers := make(map[string]concepts.IHealthChecker)
+	if e.rpcFlag {
+		if checker, ok := e.rpcClient.(concepts.IHealthChecker); ok {
+			checkers["rpc_client"] = checker
+		}
+		if checker, ok := e.rpcServer.(concepts.IHealthChecker); ok {
+			checkers["rpc_server"] = checker
+		}
+	}
+	e.mu.Lock()
+	for name, component := range e.components {
+		if checker, ok := component.(concepts.IHealthChecker); ok {
+			checkers[name] = checker
+		}
+	}
+	e.mu.Unlock()
+
+	state := e.GetState()
+	report := &concepts.HealthReport{
+		State:   state.String(),
+		Healthy: true,
+		Checks:  make([]concepts.HealthStatus, 0, len(checkers)),
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan concepts.HealthStatus, len(checkers))
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker concepts.IHealthChecker) {
+			defer wg.Done()
+			results <- runHealthCheck(ctx, name, checker)
+		}(name, checker)
+	}
+	wg.Wait()
+	close(results)
+
+	for status := range results {
+		if !status.Healthy {
+			report.Healthy = false
+		}
+		report.Checks = append(report.Checks, status)
+	}
+	sort.Slice(report.Checks, func(i, j int) bool { return report.Checks[i].Name < report.Checks[j].Name })
+	report.Ready = report.Healthy && state == STATE_RUNNING
+	return report
+}
+
+func runHealthCheck(ctx context.Context, name string, checker concepts.IHealthChecker) concepts.HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, DefaultHealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.HealthCheck(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	status := concepts.HealthStatus{
+		Name:    name,
+		Healthy: err == nil,
+		Latency: time.Since(start).String(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
 }
 
 func (e *Engine) isLocalMessage(actor *concepts.ActorId) bool {
@@ -0,0 +1,214 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+)
+
+type healthComponent struct {
+	name   string
+	engine concepts.IEngine
+	check  func(ctx context.Context) error
+}
+
+func (c *healthComponent) Name() string                      { return c.name }
+func (c *healthComponent) Dependencies() []string            { return nil }
+func (c *healthComponent) SetEngine(engine concepts.IEngine) { c.engine = engine }
+func (c *healthComponent) GetEngine() concepts.IEngine       { return c.engine }
+func (c *healthComponent) OnInit() error                     { return nil }
+func (c *healthComponent) OnStart() error                    { return nil }
+func (c *healthComponent) OnCleanup()                        {}
+
+func (c *healthComponent) HealthCheck(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+func TestEngineCheckHealth(t *testing.T) {
+	healthy := true
+	engine := NewEngine(0, 1, 1001)
+	engine.MustAddComponent(&healthComponent{name: "ok", check: func(ctx context.Context) error { return nil }})
+	engine.MustAddComponent(&healthComponent{name: "flaky", check: func(ctx context.Context) error {
+		if healthy {
+			return nil
+		}
+		return errors.New("down")
+	}})
+	engine.MustInit()
+
+	report := engine.CheckHealth(context.Background())
+	if !report.Healthy || report.Ready || len(report.Checks) != 2 {
+		t.Fatalf("initialized engine must be healthy but not ready: %+v", report)
+	}
+
+	engine.Start()
+	if report = engine.CheckHealth(context.Background()); !report.Ready {
+		t.Fatalf("running engine must be ready: %+v", report)
+	}
+
+	healthy = false
+	report = engine.CheckHealth(context.Background())
+	if report.Healthy || report.Ready || report.Checks[0].Name != "flaky" || report.Checks[0].Error != "down" {
+		t.Fatalf("failed check must fail readiness: %+v", report)
+	}
+
+	healthy = true
+	engine.setState(STATE_SHUTTING_DOWN)
+	if report = engine.CheckHealth(context.Background()); !report.Healthy || report.Ready {
+		t.Fatalf("shutting down engine must not be ready: %+v", report)
+	}
+}
+
+func TestEngineCheckHealthTimeout(t *testing.T) {
+	timeout := DefaultHealthCheckTimeout
+	DefaultHealthCheckTimeout = 50 * time.Millisecond
+	defer func() { DefaultHealthCheckTimeout = timeout }()
+
+	engine := NewEngine(0, 1, 1001)
+	engine.MustAddComponent(&healthComponent{name: "hang", check: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}})
+
+	start := time.Now()
+	report := engine.CheckHealth(context.Background())
+	if report.Healthy || report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("hanging check must time out: %+v", report)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("check took %v", time.Since(start))
+	}
+}
+
+type orderComponent struct {
+	name   string
+	deps   []string
+	engine concepts.IEngine
+	events chan string
+	delay  time.Duration
+}
+
+func (c *orderComponent) Name() string                      { return c.name }
+func (c *orderComponent) Dependencies() []string            { return c.deps }
+func (c *orderComponent) SetEngine(engine concepts.IEngine) { c.engine = engine }
+func (c *orderComponent) GetEngine() concepts.IEngine       { return c.engine }
+func (c *orderComponent) OnStart() error                    { return nil }
+
+func (c *orderComponent) OnInit() error {
+	time.Sleep(c.delay)
+	c.events <- "init " + c.name
+	return nil
+}
+
+func (c *orderComponent) OnCleanup() {
+	time.Sleep(c.delay)
+	c.events <- "cleanup " + c.name
+}
+
+func TestEngineComponentOrder(t *testing.T) {
+	events := make(chan string, 16)
+	engine := NewEngine(0, 1, 1001)
+	engine.MustAddComponent(&orderComponent{name: "wsserver", deps: []string{"mongodb", "storage"}, events: events})
+	engine.MustAddComponent(&orderComponent{name: "mongodb", events: events, delay: 100 * time.Millisecond})
+	engine.MustAddComponent(&orderComponent{name: "storage", events: events, delay: 100 * time.Millisecond})
+
+	start := time.Now()
+	engine.MustInit()
+	if elapsed := time.Since(start); elapsed > 190*time.Millisecond {
+		t.Fatalf("independent components must init in parallel, took %v", elapsed)
+	}
+	engine.Start()
+	engine.Stop()
+	close(events)
+
+	var order []string
+	for event := range events {
+		order = append(order, event)
+	}
+	if len(order) != 6 || order[2] != "init wsserver" || order[3] != "cleanup wsserver" {
+		t.Fatalf("unexpected order %v", order)
+	}
+}
+
+func TestEngineComponentDependencyErrors(t *testing.T) {
+	engine := NewEngine(0, 1, 1001)
+	engine.MustAddComponent(&orderComponent{name: "a", deps: []string{"b"}})
+	engine.MustAddComponent(&orderComponent{name: "b", deps: []string{"c"}})
+	engine.MustAddComponent(&orderComponent{name: "c", deps: []string{"a"}})
+	engine.MustAddComponent(&orderComponent{name: "d"})
+	if err := engine.CheckDependencies(); err == nil || err.Error() != "component dependency cycle between a, b, c" {
+		t.Fatalf("cycle not detected: %v", err)
+	}
+
+	engine = NewEngine(0, 1, 1001)
+	engine.MustAddComponent(&orderComponent{name: "wsserver", deps: []string{"storage"}})
+	if err := engine.CheckDependencies(); err == nil {
+		t.Fatal("missing dependency not detected")
+	}
+}
+
+func TestEngineCleanupTimeout(t *testing.T) {
+	timeout := DefaultCleanupTimeout
+	DefaultCleanupTimeout = 50 * time.Millisecond
+	defer func() { DefaultCleanupTimeout = timeout }()
+
+	slow := &orderComponent{name: "slow", events: make(chan string, 2)}
+	engine := NewEngine(0, 1, 1001)
+	engine.MustAddComponent(slow)
+	engine.MustInit()
+	slow.delay = time.Second
+
+	start := time.Now()
+	engine.Stop()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("cleanup must give up after its timeout, took %v", elapsed)
+	}
+}
+
+type startComponent struct {
+	orderComponent
+	err error
+}
+
+func (c *startComponent) OnStart() error {
+	return c.err
+}
+
+func TestEngineStartRollback(t *testing.T) {
+	events := make(chan string, 16)
+	engine := NewEngine(0, 1, 1001)
+	engine.MustAddComponent(&startComponent{orderComponent: orderComponent{name: "a", events: events}})
+	engine.MustAddComponent(&startComponent{orderComponent: orderComponent{name: "b", deps: []string{"a"}, events: events}})
+	engine.MustAddComponent(&startComponent{orderComponent: orderComponent{name: "c", deps: []string{"b"}, events: events}, err: errors.New("bind: address already in use")})
+	engine.MustAddComponent(&startComponent{orderComponent: orderComponent{name: "d", deps: []string{"c"}, events: events}})
+	engine.MustInit()
+
+	err := engine.Start()
+	var startErr *StartError
+	if !errors.As(err, &startErr) || startErr.Component != "c" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !slices.Equal(startErr.RolledBack, []string{"d", "c", "b", "a"}) {
+		t.Fatalf("unexpected rollback %v", startErr.RolledBack)
+	}
+	if engine.GetState() != STATE_SHUTDOWN {
+		t.Fatalf("unexpected state %v", engine.GetState())
+	}
+
+	engine.Stop()
+	close(events)
+	var cleanups []string
+	for event := range events {
+		if strings.HasPrefix(event, "cleanup") {
+			cleanups = append(cleanups, event)
+		}
+	}
+	if !slices.Equal(cleanups, []string{"cleanup d", "cleanup c", "cleanup b", "cleanup a"}) {
+		t.Fatalf("unexpected cleanups %v", cleanups)
+	}
+}
@@ -6,14 +6,20 @@ import (
 	"fmt"
 	"reflect"
 	"runtime/debug"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/wuqunyong/file_storage/pkg/constants"
 	"github.com/wuqunyong/file_storage/pkg/encoders"
 	"github.com/wuqunyong/file_storage/pkg/errs"
 	"github.com/wuqunyong/file_storage/pkg/funcutils"
 	"github.com/wuqunyong/file_storage/pkg/logger"
 	"github.com/wuqunyong/file_storage/pkg/msg"
 	"github.com/wuqunyong/file_storage/pkg/queue"
+	"github.com/wuqunyong/file_storage/pkg/trace"
 )
 
 type Inbox struct {
@@ -23,6 +29,15 @@ type Inbox struct {
 	pending   *queue.MsgQueue
 	pendingCh chan struct{}
 	ctx       context.Context
+	// span of the handler running, see current
+	handling atomic.Pointer[trace.SpanContext]
+}
+
+// tracedTask is a task posted with Actor.PostTaskContext.
+type tracedTask struct {
+	fn     func()
+	trace  trace.SpanContext
+	queued time.Time
 }
 
 func NewInbox() *Inbox {
@@ -53,6 +68,18 @@ func (inbox *Inbox) Register(opcode uint32, fun interface{}) error {
 	return nil
 }
 
+// Opcodes lists the registered opcodes in ascending order.
+func (inbox *Inbox) Opcodes() []uint32 {
+	inbox.lock.Lock()
+	defer inbox.lock.Unlock()
+	opcodes := make([]uint32, 0, len(inbox.method))
+	for opcode := range inbox.method {
+		opcodes = append(opcodes, opcode)
+	}
+	slices.Sort(opcodes)
+	return opcodes
+}
+
 func (inbox *Inbox) SetContext(ctx context.Context) error {
 	if ctx == nil {
 		return errors.New("ctx is nil")
@@ -65,9 +92,13 @@ func (inbox *Inbox) SetContext(ctx context.Context) error {
 func (inbox *Inbox) Send(args any) error {
 	switch message := args.(type) {
 	case *msg.MsgReq:
-		inbox.SendMsgReq(args)
+		message.Queued = time.Now()
+		inbox.SendMsgReq(args, message.Priority)
+	case *tracedTask:
+		message.queued = time.Now()
+		inbox.SendMsgReq(args, 0)
 	case func():
-		inbox.SendMsgReq(args)
+		inbox.SendMsgReq(args, 0)
 	default:
 		sError := fmt.Sprintf("unexpected type:%T", message)
 		return errors.New(sError)
@@ -76,8 +107,8 @@ func (inbox *Inbox) Send(args any) error {
 	return nil
 }
 
-func (inbox *Inbox) SendMsgReq(args any) {
-	inbox.pending.Push(args)
+func (inbox *Inbox) SendMsgReq(args any, priority int32) {
+	inbox.pending.PushWithLevel(args, priority)
 	select {
 	case inbox.pendingCh <- struct{}{}:
 		// ok
@@ -106,6 +137,8 @@ func (inbox *Inbox) Run() {
 			inbox.handleMsgReq(message)
 		case func():
 			inbox.handleFuncObj(message)
+		case *tracedTask:
+			inbox.handleTracedTask(message)
 		default:
 			continue
 		}
@@ -116,9 +149,30 @@ func (inbox *Inbox) handleMsgReq(message *msg.MsgReq) {
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Println("handleMsgReq have panic err:", r, string(debug.Stack()))
+			message.Ack(fmt.Errorf("handler panic: %v", r))
 		}
 	}()
-	response := inbox.callFunc(message)
+	start := time.Now()
+	opcode := strconv.FormatUint(uint64(message.FuncName), 10)
+	span, handler := inbox.startSpans(message.Trace, "actor "+opcode, message.Queued, start)
+	defer inbox.finishSpans(span, handler)
+	if message.Sender != nil {
+		span.SetAttribute("sender", message.Sender.String())
+	}
+
+	response := inbox.callFunc(trace.ContextWithSpanContext(inbox.ctx, handler.Context), message)
+	observeRequest(message, response, start)
+	var code errs.CodeError
+	if response != nil {
+		code = response.CodeError()
+	}
+	if code != nil {
+		handler.SetError(code)
+	}
+	if message.OneWay {
+		message.Ack(code)
+		return
+	}
 	if response != nil {
 		go message.Send(response)
 	}
@@ -133,52 +187,90 @@ func (inbox *Inbox) handleFuncObj(funcObj func()) {
 	funcObj()
 }
 
-func (inbox *Inbox) callFunc(message *msg.MsgReq) *msg.MsgResp {
+func (inbox *Inbox) handleTracedTask(task *tracedTask) {
+	span, handler := inbox.startSpans(task.trace, "task", task.queued, time.Now())
+	defer inbox.finishSpans(span, handler)
+	inbox.handleFuncObj(task.fn)
+}
+
+// startSpans starts the span of a message as a child of parent, covering it
+// from queued on, with a child for the wait in the inbox and another for the
+// handler. Requests sent by the handler become children of the handler span.
+func (inbox *Inbox) startSpans(parent trace.SpanContext, name string, queued, start time.Time) (span, handler *trace.Span) {
+	if queued.IsZero() {
+		queued = start
+	}
+	span = trace.StartAt(parent, name, queued)
+	trace.StartAt(span.Context, "inbox.wait", queued).FinishAt(start)
+	handler = trace.StartAt(span.Context, "handler", start)
+	inbox.handling.Store(&handler.Context)
+	return span, handler
+}
+
+func (inbox *Inbox) finishSpans(span, handler *trace.Span) {
+	inbox.handling.Store(nil)
+	handler.Finish()
+	if err := handler.Err(); err != "" {
+		span.SetError(errors.New(err))
+	}
+	span.FinishAt(handler.End)
+}
+
+// current is the span of the handler running on the actor, if any.
+func (inbox *Inbox) current() trace.SpanContext {
+	if sc := inbox.handling.Load(); sc != nil {
+		return *sc
+	}
+	return trace.SpanContext{}
+}
+
+func (inbox *Inbox) callFunc(ctx context.Context, message *msg.MsgReq) *msg.MsgResp {
 	inbox.lock.Lock()
 	defer inbox.lock.Unlock()
 
 	ptrMethod, ok := inbox.method[message.FuncName]
 	if !ok {
 		sError := fmt.Sprintf("unregister name:%d", message.FuncName)
-		reply := msg.NewMsgResp(message.SeqId, 1, sError, message.Codec)
-		return reply
+		return msg.NewErrorResp(message.SeqId, errs.New(errs.CODE_OpcodeUnregister, sError), message.Codec)
 	}
 
 	var (
 		code    errs.CodeError
 		err     error
-		decoder = encoders.NewProtobufEncoder()
+		decoder = message.Codec
 	)
+	if decoder == nil {
+		decoder = encoders.NewProtobufEncoder()
+	}
 
 	if message.Remote {
+		if message.Stream && !message.OneWay {
+			ctx = context.WithValue(ctx, streamKey{}, message)
+		}
 		args := reflect.New(ptrMethod.ArgType[1].Elem()).Interface()
 		err := decoder.Decode(message.ArgsData, args)
 		if err != nil {
 			sError := fmt.Sprintf("Decode err:%v", err)
-			reply := msg.NewMsgResp(message.SeqId, 1, sError, message.Codec)
-			return reply
+			return msg.NewErrorResp(message.SeqId, errs.New(errs.CODE_ParseError, sError), message.Codec)
 		}
 
 		switch ptrMethod.NumIn {
 		case 3:
 			var response = reflect.New(ptrMethod.ReplyType.Elem()).Interface()
-			code, err = funcutils.CallPRCReflectRequestFunc(ptrMethod, inbox.ctx, args, response)
+			code, err = funcutils.CallPRCReflectRequestFunc(ptrMethod, ctx, args, response)
 			if err != nil {
-				sError := fmt.Sprintf("err:%s" + err.Error())
-				reply := msg.NewMsgResp(message.SeqId, 1, sError, message.Codec)
-				return reply
+				sError := fmt.Sprintf("err:%s", err.Error())
+				return msg.NewErrorResp(message.SeqId, errs.New(errs.CODE_ServerInternalError, sError), message.Codec)
 			}
 
 			if code != nil {
-				reply := msg.NewMsgResp(message.SeqId, uint32(code.Code()), code.Msg(), message.Codec)
-				return reply
+				return msg.NewErrorResp(message.SeqId, code, message.Codec)
 			}
 
 			replyData, err := decoder.Encode(response)
 			if err != nil {
 				sError := fmt.Sprintf("Encode err:%v", err)
-				reply := msg.NewMsgResp(message.SeqId, 1, sError, message.Codec)
-				return reply
+				return msg.NewErrorResp(message.SeqId, errs.New(errs.CODE_CreateMsgError, sError), message.Codec)
 			}
 
 			reply := msg.NewMsgResp(message.SeqId, 0, "", message.Codec)
@@ -186,10 +278,10 @@ func (inbox *Inbox) callFunc(message *msg.MsgReq) *msg.MsgResp {
 			reply.ReplyData = replyData
 			return reply
 		case 2:
-			err = funcutils.CallPRCReflectNotifyFunc(ptrMethod, inbox.ctx, args)
+			err = funcutils.CallPRCReflectNotifyFunc(ptrMethod, ctx, args)
 			if err != nil {
-				sError := fmt.Sprintf("err:%s", err.Error())
-				logger.Log(logger.InfoLevel, "callFunc", "Error", sError)
+				logger.Log(logger.InfoLevel, "callFunc", "Error", err.Error())
+				return msg.NewErrorResp(message.SeqId, errs.NewCodeError(err), message.Codec)
 			}
 
 			return nil
@@ -203,26 +295,65 @@ func (inbox *Inbox) callFunc(message *msg.MsgReq) *msg.MsgResp {
 	switch ptrMethod.NumIn {
 	case 3:
 		var response = reflect.New(ptrMethod.ReplyType.Elem()).Interface()
-		code, err = funcutils.CallPRCReflectRequestFunc(ptrMethod, inbox.ctx, message.Args, response)
+		code, err = funcutils.CallPRCReflectRequestFunc(ptrMethod, ctx, message.Args, response)
 		if err != nil {
-			sError := fmt.Sprintf("err:%s" + err.Error())
-			reply := msg.NewMsgResp(message.SeqId, 1, sError, message.Codec)
-			return reply
+			sError := fmt.Sprintf("err:%s", err.Error())
+			return msg.NewErrorResp(message.SeqId, errs.New(errs.CODE_ServerInternalError, sError), message.Codec)
 		}
 		if code != nil {
-			reply := msg.NewMsgResp(message.SeqId, uint32(code.Code()), code.Msg(), message.Codec)
-			return reply
+			return msg.NewErrorResp(message.SeqId, code, message.Codec)
 		}
 
 		reply := msg.NewMsgResp(message.SeqId, 0, "", message.Codec)
 		reply.Remote = message.Remote
 		reply.Reply = response
 		return reply
+	case 2:
+		err = funcutils.CallPRCReflectNotifyFunc(ptrMethod, ctx, message.Args)
+		if err != nil {
+			logger.Log(logger.InfoLevel, "callFunc", "Error", err.Error())
+			return msg.NewErrorResp(message.SeqId, errs.NewCodeError(err), message.Codec)
+		}
+		return nil
 	}
 
 	return nil
 }
 
+// streamKey holds in the context of a handler the request it answers, when
+// the sender asked for several responses.
+type streamKey struct{}
+
+// SendMore sends reply to the request handled with ctx ahead of the response
+// of the handler, which ends the stream. The request must be a remote one
+// sent with stream, e.g. by rpc call --stream, otherwise it fails with
+// constants.ErrRPCNotStreamed.
+func SendMore(ctx context.Context, reply any) error {
+	message, ok := ctx.Value(streamKey{}).(*msg.MsgReq)
+	if !ok {
+		return constants.ErrRPCNotStreamed
+	}
+	encoder := message.Codec
+	if encoder == nil {
+		encoder = encoders.NewProtobufEncoder()
+	}
+	replyData, err := encoder.Encode(reply)
+	if err != nil {
+		return err
+	}
+
+	response := msg.NewMsgResp(message.SeqId, 0, "", message.Codec)
+	response.Remote = true
+	response.ReplyData = replyData
+	response.HasMore = true
+	return message.RPCServer.SendResponse(message, response)
+}
+
+// Len is the number of queued messages.
+func (inbox *Inbox) Len() int {
+	return inbox.pending.Len()
+}
+
 func (inbox *Inbox) Stop() {
 
 }
@@ -7,10 +7,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/wuqunyong/file_storage/pkg/concepts"
 	"github.com/wuqunyong/file_storage/pkg/encoders"
 	"github.com/wuqunyong/file_storage/pkg/errs"
 	"github.com/wuqunyong/file_storage/pkg/msg"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
 	"github.com/wuqunyong/file_storage/proto/common_msg"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+	"google.golang.org/protobuf/proto"
 )
 
 type Reply1 struct {
@@ -90,3 +94,77 @@ func Test1(t *testing.T) {
 	time.Sleep(time.Duration(60) * time.Second)
 	time.Sleep(time.Duration(60) * time.Second)
 }
+
+type streamActor struct {
+	*Actor
+}
+
+func (a *streamActor) OnInit() error {
+	return a.Register(1, a.Count)
+}
+
+func (a *streamActor) OnShutdown() {}
+
+// Count answers 1 to request.Value1, one response each.
+func (a *streamActor) Count(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	for i := uint64(1); i < request.Value1; i++ {
+		if err := SendMore(ctx, &common_msg.EchoResponse{Value1: i}); err != nil {
+			return errs.NewCodeError(err)
+		}
+	}
+	response.Value1 = request.Value1
+	return nil
+}
+
+func TestSendMore(t *testing.T) {
+	connString := "loopback://TestSendMore"
+	engine := NewEngine(0, 1, 1001, connString)
+	engine.MustInit()
+	engine.MustSpawnActors(&streamActor{Actor: NewActor("1", engine)})
+	if err := engine.Start(); err != nil {
+		t.Fatalf("start err:%v", err)
+	}
+	defer engine.Stop()
+
+	caller, err := rpc.NewCaller(connString)
+	if err != nil {
+		t.Fatalf("caller err:%v", err)
+	}
+	defer caller.Close()
+	args, _ := proto.Marshal(&common_msg.EchoRequest{Value1: 3})
+	target := concepts.NewActorId("engine.0.1.1001.server", "1")
+
+	call := func(stream bool) []*rpc_msg.RPC_RESPONSE {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		var responses []*rpc_msg.RPC_RESPONSE
+		err := caller.Call(ctx, target, 1, args, stream, func(response *rpc_msg.RPC_RESPONSE) error {
+			responses = append(responses, response)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("call err:%v", err)
+		}
+		return responses
+	}
+
+	responses := call(true)
+	if len(responses) != 3 {
+		t.Fatalf("unexpected responses:%v", responses)
+	}
+	for i, response := range responses {
+		reply := &common_msg.EchoResponse{}
+		if err := proto.Unmarshal(response.ResultData, reply); err != nil {
+			t.Fatalf("response %d err:%v", i, err)
+		}
+		if reply.Value1 != uint64(i+1) || response.HasMore != (i < 2) {
+			t.Fatalf("response %d unexpected:%v has_more:%v", i, reply, response.HasMore)
+		}
+	}
+
+	// a request without stream gets its single response only
+	responses = call(false)
+	if len(responses) != 1 || responses[0].GetStatus().GetCode() == 0 || responses[0].HasMore {
+		t.Fatalf("unexpected responses without stream:%v", responses)
+	}
+}
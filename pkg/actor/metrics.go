@@ -0,0 +1,77 @@
+package actor
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/metrics"
+	"github.com/wuqunyong/file_storage/pkg/msg"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+)
+
+var (
+	requestsTotal = metrics.NewCounterVec("actor_requests_total",
+		"Requests handled by the actors, by opcode and origin (local or remote).", "opcode", "origin")
+	requestErrors = metrics.NewCounterVec("actor_request_errors_total",
+		"Requests whose handler answered with an error code.", "opcode", "code")
+	requestDuration = metrics.NewHistogramVec("actor_request_duration_seconds",
+		"Time spent in the handler of the requests.", nil, "opcode", "origin")
+)
+
+func observeRequest(message *msg.MsgReq, response *msg.MsgResp, start time.Time) {
+	opcode := strconv.FormatUint(uint64(message.FuncName), 10)
+	origin := "local"
+	if message.Remote {
+		origin = "remote"
+	}
+	requestsTotal.With(opcode, origin).Inc()
+	requestDuration.With(opcode, origin).ObserveSince(start)
+	if response != nil && response.ErrCode != 0 {
+		requestErrors.With(opcode, strconv.FormatUint(uint64(response.ErrCode), 10)).Inc()
+	}
+}
+
+// Collect exports the gauges of the engine, labeled with its address.
+func (e *Engine) Collect(emit func(metrics.Family)) {
+	labels := []string{"engine", e.address}
+	gauge := func(name, help string, value float64) {
+		emit(metrics.Family{Name: name, Help: help, Type: metrics.GaugeType, Samples: []metrics.Sample{{Labels: labels, Value: value}}})
+	}
+	counter := func(name, help string, value uint64) {
+		emit(metrics.Family{Name: name, Help: help, Type: metrics.CounterType, Samples: []metrics.Sample{{Labels: labels, Value: float64(value)}}})
+	}
+
+	actors, queued, deepest := e.registry.mailboxStats()
+	gauge("actor_count", "Actors spawned on the engine.", float64(actors))
+	gauge("actor_mailbox_depth", "Messages queued in the mailboxes of all actors.", float64(queued))
+	gauge("actor_mailbox_max_depth", "Messages queued in the fullest mailbox.", float64(deepest))
+
+	if client, ok := e.rpcClient.(*rpc.RPCClient); ok {
+		pending := client.PendingStats()
+		gauge("rpc_pending_requests", "Remote requests waiting for their response.", float64(pending.InFlight))
+		counter("rpc_requests_completed_total", "Remote requests that got their response.", pending.Completed)
+		counter("rpc_requests_timed_out_total", "Remote requests removed at their deadline.", pending.TimedOut)
+		counter("rpc_requests_failed_total", "Remote requests failed by a lost connection.", pending.Failed)
+		counter("rpc_responses_stale_total", "Responses that arrived for no pending request.", pending.Stale)
+		gauge("rpc_circuits_open", "Target addresses whose circuit breaker is not closed.", float64(len(client.Breakers().Open())))
+	}
+	if server, ok := e.rpcServer.(*rpc.RPCServer); ok && server.Authenticator() != nil {
+		stats := server.Authenticator().Stats()
+		f := metrics.Family{Name: "rpc_auth_messages_total", Help: "Received rpc messages by authentication result.", Type: metrics.CounterType}
+		for _, result := range []struct {
+			name  string
+			value uint64
+		}{
+			{"accepted", stats.Accepted},
+			{"unsigned", stats.Unsigned},
+			{"bad_signature", stats.BadSignature},
+			{"unknown_key", stats.UnknownKey},
+			{"expired", stats.Expired},
+			{"replayed", stats.Replayed},
+		} {
+			f.Samples = append(f.Samples, metrics.Sample{Labels: append(append([]string(nil), labels...), "result", result.name), Value: float64(result.value)})
+		}
+		emit(f)
+		counter("rpc_auth_rejected_total", "Received rpc messages dropped by the authenticator.", stats.Rejected())
+	}
+}
@@ -2,6 +2,7 @@ package actor
 
 import (
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/wuqunyong/file_storage/pkg/concepts"
@@ -66,6 +67,39 @@ func (r *Registry) GetByID(id string) concepts.IActor {
 	return r.lookup[id]
 }
 
+// mailboxStats counts the actors, the messages queued for all of them and
+// for the fullest mailbox.
+func (r *Registry) mailboxStats() (actors, queued, deepest int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, actor := range r.lookup {
+		mailbox, ok := actor.(interface{ MailboxLen() int })
+		if !ok {
+			continue
+		}
+		n := mailbox.MailboxLen()
+		queued += n
+		deepest = max(deepest, n)
+	}
+	return len(r.lookup), queued, deepest
+}
+
+// opcodes lists the opcodes handled by any of the actors, in ascending order.
+func (r *Registry) opcodes() []uint32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var opcodes []uint32
+	for _, actor := range r.lookup {
+		handler, ok := actor.(interface{ Opcodes() []uint32 })
+		if !ok {
+			continue
+		}
+		opcodes = append(opcodes, handler.Opcodes()...)
+	}
+	slices.Sort(opcodes)
+	return slices.Compact(opcodes)
+}
+
 func (r *Registry) GetRootID() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -0,0 +1,246 @@
+package actor
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/pkg/msg"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+)
+
+// LoadBalance picks the node of a service a request is sent to.
+type LoadBalance int
+
+const (
+	RoundRobin LoadBalance = iota
+	Random
+	// LeastPending picks the node with the fewest requests of this engine
+	// waiting for a response.
+	LeastPending
+	// ConsistentHash picks the node by the concepts.WithHashKey key of the
+	// request, the actor id without one.
+	ConsistentHash
+)
+
+func (lb LoadBalance) String() string {
+	switch lb {
+	case RoundRobin:
+		return "round-robin"
+	case Random:
+		return "random"
+	case LeastPending:
+		return "least-pending"
+	case ConsistentHash:
+		return "consistent-hash"
+	default:
+		return fmt.Sprintf("LoadBalance(%d)", int(lb))
+	}
+}
+
+// Resolver lists the server addresses of the live nodes of realm.kind.
+type Resolver interface {
+	Resolve(realm, kind uint32) ([]string, error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(realm, kind uint32) ([]string, error)
+
+func (f ResolverFunc) Resolve(realm, kind uint32) ([]string, error) {
+	return f(realm, kind)
+}
+
+type registryResolver struct {
+	registry registry.Registry
+}
+
+// NewRegistryResolver resolves the nodes registered in r under
+// concepts.GenServiceName, their id being the server address. r is usually
+// a registry.Cache, the resolver is asked on every request.
+func NewRegistryResolver(r registry.Registry) Resolver {
+	return &registryResolver{registry: r}
+}
+
+func (r *registryResolver) Resolve(realm, kind uint32) ([]string, error) {
+	services, err := r.registry.GetService(concepts.GenServiceName(realm, kind))
+	if err != nil && !errors.Is(err, registry.ErrNotFound) {
+		return nil, err
+	}
+	var nodes []string
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			if _, _, _, err := concepts.DecodeAddress(node.Id); err != nil {
+				continue
+			}
+			if !slices.Contains(nodes, node.Id) {
+				nodes = append(nodes, node.Id)
+			}
+		}
+	}
+	// the same order on every node keeps round robin fair
+	slices.Sort(nodes)
+	return nodes, nil
+}
+
+type serviceRouter struct {
+	mu       sync.Mutex
+	resolver Resolver
+	next     map[uint32]uint64
+}
+
+func newServiceRouter() *serviceRouter {
+	return &serviceRouter{next: make(map[uint32]uint64)}
+}
+
+// SetResolver sets where RequestService finds the nodes of a service kind.
+func (e *Engine) SetResolver(resolver Resolver) {
+	e.router.mu.Lock()
+	defer e.router.mu.Unlock()
+	e.router.resolver = resolver
+}
+
+func (e *Engine) Resolver() Resolver {
+	e.router.mu.Lock()
+	defer e.router.mu.Unlock()
+	return e.router.resolver
+}
+
+// resolve lists the nodes of kind in the realm of the engine.
+func (e *Engine) resolve(kind uint32) ([]string, error) {
+	resolver := e.Resolver()
+	if resolver == nil {
+		return nil, errors.New("engine has no service resolver")
+	}
+	realm, _, _, err := concepts.DecodeAddress(e.address)
+	if err != nil {
+		return nil, err
+	}
+	return resolver.Resolve(realm, kind)
+}
+
+// pickNode chooses among the nodes not tried yet with lb, skipping the
+// ejected ones unless no other is left. It returns "" when every node was
+// tried.
+func (e *Engine) pickNode(kind uint32, nodes []string, lb LoadBalance, key string, tried map[string]bool) string {
+	var candidates, ejected []string
+	for _, node := range nodes {
+		if tried[node] {
+			continue
+		}
+		if e.Ejected(node) {
+			ejected = append(ejected, node)
+		} else {
+			candidates = append(candidates, node)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = ejected
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	switch lb {
+	case Random:
+		return candidates[rand.IntN(len(candidates))]
+	case LeastPending:
+		client, ok := e.rpcClient.(*rpc.RPCClient)
+		if !ok {
+			return candidates[0]
+		}
+		// ties go round robin
+		start := e.router.advance(kind)
+		best, fewest := "", 0
+		for i := range candidates {
+			node := candidates[(start+i)%len(candidates)]
+			if pending := client.PendingTo(node); best == "" || pending < fewest {
+				best, fewest = node, pending
+			}
+		}
+		return best
+	case ConsistentHash:
+		// rendezvous hashing, only the keys of a node leaving move
+		var best string
+		var highest uint64
+		for _, node := range candidates {
+			h := fnv.New64a()
+			h.Write([]byte(key))
+			h.Write([]byte{0})
+			h.Write([]byte(node))
+			if score := h.Sum64(); best == "" || score > highest {
+				best, highest = node, score
+			}
+		}
+		return best
+	default:
+		return candidates[e.router.advance(kind)%len(candidates)]
+	}
+}
+
+func (r *serviceRouter) advance(kind uint32) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next[kind]
+	r.next[kind] = n + 1
+	return int(n % (1 << 31))
+}
+
+// RequestService sends a request to actor actorId on one of the live nodes
+// of kind, in the realm of the engine of a, chosen with lb. It fails over to
+// another node when the request could not be delivered to the chosen one.
+func RequestService[T any](a concepts.IActor, kind uint32, actorId string, opcode uint32, args any, lb LoadBalance, opts ...concepts.RequestOption) (*T, errs.CodeError) {
+	holder, ok := a.(interface{ GetEngine() concepts.IEngine })
+	if !ok {
+		return nil, errs.New(errs.CODE_NotSend, fmt.Sprintf("actor %T has no engine", a))
+	}
+	engine, ok := holder.GetEngine().(*Engine)
+	if !ok {
+		return nil, errs.New(errs.CODE_NotSend, fmt.Sprintf("engine %T cannot route services", holder.GetEngine()))
+	}
+	nodes, err := engine.resolve(kind)
+	if err != nil {
+		return nil, errs.New(errs.CODE_NotSend, fmt.Sprintf("resolve kind %d: %v", kind, err))
+	}
+
+	key := concepts.NewRequestOptions(opts...).HashKey
+	if key == "" {
+		key = actorId
+	}
+	tried := make(map[string]bool)
+	var lastErr errs.CodeError
+	for {
+		node := engine.pickNode(kind, nodes, lb, key, tried)
+		if node == "" {
+			break
+		}
+		tried[node] = true
+		request := a.Request(concepts.NewActorId(node, actorId), opcode, args, opts...)
+		resp, err := msg.GetResult[T](request)
+		if err == nil || !undelivered(err) {
+			return resp, err
+		}
+		logger.Log(logger.WarnLevel, "RequestService failover", "kind", kind, "node", node, "opcode", opcode, "err", err)
+		lastErr = err
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, errs.New(errs.CODE_NotSend, fmt.Sprintf("no live node of kind %d", kind))
+}
+
+// undelivered reports whether the request never left for the node, another
+// node may serve it. A request whose reply was lost may have run already.
+func undelivered(err errs.CodeError) bool {
+	switch err.Code() {
+	case errs.CODE_NotSend, errs.CODE_CircuitOpen:
+		return true
+	}
+	return false
+}
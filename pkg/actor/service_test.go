@@ -0,0 +1,206 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+type staticRegistry struct {
+	registry.Registry
+	services map[string][]*registry.Service
+}
+
+func (r *staticRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	services, ok := r.services[name]
+	if !ok {
+		return nil, registry.ErrNotFound
+	}
+	return services, nil
+}
+
+func TestRegistryResolver(t *testing.T) {
+	resolver := NewRegistryResolver(&staticRegistry{services: map[string][]*registry.Service{
+		"engine.0.1": {
+			{Name: "engine.0.1", Version: "1", Nodes: []*registry.Node{{Id: "engine.0.1.1002.server"}, {Id: "10.0.0.1:10001"}}},
+			{Name: "engine.0.1", Version: "2", Nodes: []*registry.Node{{Id: "engine.0.1.1001.server"}, {Id: "engine.0.1.1002.server"}}},
+		},
+	}})
+
+	nodes, err := resolver.Resolve(0, 1)
+	if err != nil {
+		t.Fatalf("resolve err:%v", err)
+	}
+	if len(nodes) != 2 || nodes[0] != "engine.0.1.1001.server" || nodes[1] != "engine.0.1.1002.server" {
+		t.Fatalf("unexpected nodes:%v", nodes)
+	}
+	if nodes, err := resolver.Resolve(0, 2); err != nil || len(nodes) != 0 {
+		t.Fatalf("unknown kind, nodes:%v, err:%v", nodes, err)
+	}
+}
+
+func TestPickNode(t *testing.T) {
+	engine := NewEngine(0, 2, 1001)
+	nodes := []string{"engine.0.1.1001.server", "engine.0.1.1002.server", "engine.0.1.1003.server"}
+
+	seen := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		seen[engine.pickNode(1, nodes, RoundRobin, "", nil)]++
+	}
+	for _, node := range nodes {
+		if seen[node] != 2 {
+			t.Fatalf("round robin uneven:%v", seen)
+		}
+	}
+
+	first := engine.pickNode(1, nodes, ConsistentHash, "player-42", nil)
+	for i := 0; i < 10; i++ {
+		if node := engine.pickNode(1, nodes, ConsistentHash, "player-42", nil); node != first {
+			t.Fatalf("consistent hash moved from %s to %s", first, node)
+		}
+	}
+	// only the keys of the node left move
+	var others []string
+	for _, node := range nodes {
+		if node != first {
+			others = append(others, node)
+		}
+	}
+	moved := 0
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		before := engine.pickNode(1, nodes, ConsistentHash, key, nil)
+		after := engine.pickNode(1, others, ConsistentHash, key, nil)
+		if before != first && before != after {
+			moved++
+		}
+	}
+	if moved != 0 {
+		t.Fatalf("%d keys of the remaining nodes moved", moved)
+	}
+
+	tried := map[string]bool{nodes[0]: true, nodes[1]: true}
+	for _, lb := range []LoadBalance{RoundRobin, Random, LeastPending, ConsistentHash} {
+		if node := engine.pickNode(1, nodes, lb, "key", tried); node != nodes[2] {
+			t.Fatalf("%s picked a tried node:%s", lb, node)
+		}
+	}
+	tried[nodes[2]] = true
+	if node := engine.pickNode(1, nodes, RoundRobin, "", tried); node != "" {
+		t.Fatalf("every node tried, picked:%s", node)
+	}
+}
+
+func TestUndelivered(t *testing.T) {
+	for code, want := range map[int32]bool{
+		errs.CODE_NotSend:          true,
+		errs.CODE_CircuitOpen:      true,
+		errs.CODE_NotReceivedReply: false,
+		errs.CODE_Timeout:          false,
+	} {
+		if got := undelivered(errs.New(code, "err")); got != want {
+			t.Fatalf("code %d undelivered:%v", code, got)
+		}
+	}
+}
+
+type nodeActor struct {
+	*Actor
+	handled atomic.Int32
+}
+
+func (a *nodeActor) OnInit() error {
+	return a.Register(1, a.Echo)
+}
+
+func (a *nodeActor) OnShutdown() {}
+
+func (a *nodeActor) Echo(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	a.handled.Add(1)
+	response.Value1 = request.Value1 + 1
+	return nil
+}
+
+// unreachableTransport fails every publish to subject, as a connection to a
+// crashed node would.
+type unreachableTransport struct {
+	rpc.Transport
+	subject string
+}
+
+func (t *unreachableTransport) PublishRequest(subject, reply string, data []byte) error {
+	if subject == t.subject {
+		return errors.New("connection refused")
+	}
+	return t.Transport.PublishRequest(subject, reply, data)
+}
+
+func TestRequestService(t *testing.T) {
+	connString := "loopback://TestRequestService"
+	var services []*nodeActor
+	for _, id := range []uint32{1001, 1003} {
+		engine := NewEngine(0, 1, id, connString)
+		engine.MustInit()
+		service := &nodeActor{Actor: NewActor("1", engine)}
+		engine.MustSpawnActors(service)
+		if err := engine.Start(); err != nil {
+			t.Fatalf("start err:%v", err)
+		}
+		t.Cleanup(engine.Stop)
+		services = append(services, service)
+	}
+	service1, service3 := services[0], services[1]
+
+	nodes := ResolverFunc(func(realm, kind uint32) ([]string, error) {
+		return []string{"engine.0.1.1001.server", "engine.0.1.1003.server"}, nil
+	})
+	newClient := func(id uint32, transport rpc.Transport) *Actor {
+		engine := NewEngine(0, 2, id, connString)
+		engine.SetRPCTransport(transport, nil)
+		engine.SetResolver(nodes)
+		engine.MustInit()
+		t.Cleanup(engine.Stop)
+		engine.Start()
+		return NewActor("1", engine)
+	}
+
+	client := newClient(1002, nil)
+	echo := &common_msg.EchoRequest{Value1: 1, Value2: "hello"}
+	for i := 0; i < 4; i++ {
+		resp, err := RequestService[common_msg.EchoResponse](client, 1, "1", 1, echo, RoundRobin)
+		if err != nil || resp.Value1 != 2 {
+			t.Fatalf("round robin request %d, response:%v, err:%v", i, resp, err)
+		}
+	}
+	if service1.handled.Load() != 2 || service3.handled.Load() != 2 {
+		t.Fatalf("round robin uneven: %d, %d", service1.handled.Load(), service3.handled.Load())
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := RequestService[common_msg.EchoResponse](client, 1, "1", 1, echo, ConsistentHash, concepts.WithHashKey("player-42")); err != nil {
+			t.Fatalf("consistent hash request %d, err:%v", i, err)
+		}
+	}
+	if h1, h3 := service1.handled.Load(), service3.handled.Load(); h1 != 6 && h3 != 6 {
+		t.Fatalf("consistent hash spread the key: %d, %d", h1, h3)
+	}
+
+	// node 1003 cannot be reached, every request fails over to 1001
+	handled1, handled3 := service1.handled.Load(), service3.handled.Load()
+	failing := newClient(1004, &unreachableTransport{Transport: rpc.NewTransport(connString), subject: "engine.0.1.1003.server"})
+	for i := 0; i < 4; i++ {
+		resp, err := RequestService[common_msg.EchoResponse](failing, 1, "1", 1, echo, RoundRobin)
+		if err != nil || resp.Value1 != 2 {
+			t.Fatalf("failover request %d, response:%v, err:%v", i, resp, err)
+		}
+	}
+	if service1.handled.Load()-handled1 != 4 || service3.handled.Load() != handled3 {
+		t.Fatalf("requests not failed over: %d, %d", service1.handled.Load()-handled1, service3.handled.Load()-handled3)
+	}
+}
@@ -0,0 +1,87 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+type statusActor struct {
+	*Actor
+	// busy is the number of calls failing with a retryable error
+	busy atomic.Int32
+}
+
+func (a *statusActor) OnInit() error {
+	return a.Register(1, a.Func1)
+}
+
+func (a *statusActor) OnShutdown() {}
+
+func (a *statusActor) Func1(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	if request.Value1 == 0 {
+		return errs.New(errs.CODE_ArgsError, "invalid", errs.WithDetail("field", "value1"))
+	}
+	if a.busy.Add(-1) >= 0 {
+		return errs.New(errs.CODE_ServerInternalError, "busy", errs.WithRetryable(true))
+	}
+
+	response.Value1 = request.Value1 + 1
+	return nil
+}
+
+func TestStatusCodes(t *testing.T) {
+	connString := "loopback://TestStatusCodes"
+	server := NewEngine(0, 1, 1001, connString)
+	server.MustInit()
+	service := &statusActor{Actor: NewActor("1", server)}
+	server.MustSpawnActors(service)
+	if err := server.Start(); err != nil {
+		t.Fatalf("start err:%v", err)
+	}
+	defer server.Stop()
+
+	engine := NewEngine(0, 1, 1002, connString)
+	engine.MustInit()
+	defer engine.Stop()
+	engine.Start()
+	client := NewActor("1", engine)
+	target := concepts.NewActorId("engine.0.1.1001.server", "1")
+
+	_, err := SendRequest[common_msg.EchoResponse](client, target, 999, &common_msg.EchoRequest{})
+	if !errors.Is(err, errs.ErrOpcodeUnregister) {
+		t.Fatalf("unexpected err:%v", err)
+	}
+
+	_, err = SendRequest[common_msg.EchoResponse](client, target, 1, &common_msg.EchoRequest{})
+	if err == nil || err.Code() != errs.CODE_ArgsError || err.Details()["field"] != "value1" || err.Retryable() {
+		t.Fatalf("unexpected err:%v details:%v", err, err.Details())
+	}
+
+	// a retryable failure is returned as such without a retry policy
+	service.busy.Store(1)
+	_, err = SendRequest[common_msg.EchoResponse](client, target, 1, &common_msg.EchoRequest{Value1: 1})
+	if !errs.IsRetryable(err) || err.Msg() != "busy" {
+		t.Fatalf("unexpected err:%v", err)
+	}
+
+	// and resent with one, whatever its code
+	service.busy.Store(1)
+	response, err := SendRequest[common_msg.EchoResponse](client, target, 1, &common_msg.EchoRequest{Value1: 1},
+		concepts.WithRetry(1, time.Millisecond))
+	if err != nil || response.Value1 != 2 {
+		t.Fatalf("unexpected response:%v err:%v", response, err)
+	}
+
+	_, err = SendRequest[common_msg.EchoResponse](client, concepts.NewActorId("engine.0.1.1009.server", "1"), 1, &common_msg.EchoRequest{},
+		concepts.WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, errs.ErrTimeout) || !errs.IsRetryable(err) {
+		t.Fatalf("unexpected err:%v", err)
+	}
+}
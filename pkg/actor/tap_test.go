@@ -0,0 +1,113 @@
+package actor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+type tapActor struct {
+	*Actor
+	// broken makes opcode 3 fail, as a changed node would
+	broken atomic.Bool
+}
+
+func (a *tapActor) OnInit() error {
+	a.Register(1, a.Echo)
+	a.Register(2, a.Fail)
+	return a.Register(3, a.Check)
+}
+
+func (a *tapActor) OnShutdown() {}
+
+func (a *tapActor) Echo(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	response.Value1 = request.Value1 + 1
+	response.Value2 = request.Value2
+	return nil
+}
+
+func (a *tapActor) Fail(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	return errs.NewCodeError(errors.New("invalid"), 123)
+}
+
+func (a *tapActor) Check(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	if a.broken.Load() {
+		return errs.New(errs.CODE_ServerInternalError, "broken")
+	}
+	response.Value1 = request.Value1 + 1
+	return nil
+}
+
+func newTapServer(t *testing.T, connString string) (*Engine, *tapActor) {
+	server := NewEngine(0, 1, 1001, connString)
+	server.MustInit()
+	service := &tapActor{Actor: NewActor("1", server)}
+	server.MustSpawnActors(service)
+	if err := server.Start(); err != nil {
+		t.Fatalf("start err:%v", err)
+	}
+	t.Cleanup(server.Stop)
+	return server, service
+}
+
+func TestTapReplay(t *testing.T) {
+	connString := "loopback://TestTapReplay"
+	server, _ := newTapServer(t, connString)
+	var recording bytes.Buffer
+	server.SetTap(rpc.NewTap(&recording, rpc.WithTapOpcodes(1, 2, 3)))
+
+	engine := NewEngine(0, 1, 1002, connString)
+	engine.MustInit()
+	defer engine.Stop()
+	engine.Start()
+	client := NewActor("1", engine)
+	target := concepts.NewActorId("engine.0.1.1001.server", "1")
+
+	if _, err := SendRequest[common_msg.EchoResponse](client, target, 1, &common_msg.EchoRequest{Value1: 7, Value2: "a"}); err != nil {
+		t.Fatalf("opcode 1 err:%v", err)
+	}
+	if _, err := SendRequest[common_msg.EchoResponse](client, target, 2, &common_msg.EchoRequest{}); err == nil {
+		t.Fatal("opcode 2 must fail")
+	}
+	if _, err := SendRequest[common_msg.EchoResponse](client, target, 3, &common_msg.EchoRequest{Value1: 1}); err != nil {
+		t.Fatalf("opcode 3 err:%v", err)
+	}
+	// not recorded
+	SendRequest[common_msg.EchoResponse](client, target, 1001, &common_msg.EchoRequest{})
+	server.SetTap(nil)
+
+	records, err := rpc.ReadTap(&recording)
+	if err != nil || len(records) != 6 {
+		t.Fatalf("unexpected records:%d err:%v", len(records), err)
+	}
+
+	replayString := "loopback://TestTapReplay2"
+	_, service := newTapServer(t, replayString)
+	replayer := rpc.NewReplayer(replayString, "engine.0.1.1001.server", rpc.WithReplaySpeed(0), rpc.WithReplayNode("engine.0.1.1001.server"))
+	report, err := replayer.Replay(context.Background(), records)
+	if err != nil {
+		t.Fatalf("replay err:%v", err)
+	}
+	if report.Sent != 3 || len(report.Results) != 3 || len(report.Mismatches()) != 0 {
+		t.Fatalf("unexpected report:%+v", report)
+	}
+
+	// the replayed node now behaves differently
+	service.broken.Store(true)
+	report, err = replayer.Replay(context.Background(), records)
+	if err != nil {
+		t.Fatalf("replay err:%v", err)
+	}
+	mismatches := report.Mismatches()
+	if len(mismatches) != 1 || mismatches[0].Opcode != 3 || !strings.Contains(mismatches[0].Diff, "code 0 != 500") {
+		t.Fatalf("unexpected mismatches:%+v", mismatches)
+	}
+}
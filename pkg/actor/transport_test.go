@@ -0,0 +1,93 @@
+package actor
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+)
+
+type peerActor struct {
+	*Actor
+	notified chan *rpc_msg.RPC_EchoTestRequest
+}
+
+func (a *peerActor) OnInit() error {
+	a.Register(1, a.Echo)
+	return a.Register(2, a.Notify)
+}
+
+func (a *peerActor) OnShutdown() {}
+
+func (a *peerActor) Echo(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	response.Value1 = request.Value1 + 1
+	return nil
+}
+
+func (a *peerActor) Notify(ctx context.Context, notify *rpc_msg.RPC_EchoTestRequest) {
+	a.notified <- notify
+}
+
+func TestKindTransportTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err:%v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	server := NewEngine(0, 1, 1001, "tcp://"+addr)
+	server.MustInit()
+	service := &peerActor{
+		Actor:    NewActor("1", server),
+		notified: make(chan *rpc_msg.RPC_EchoTestRequest, 1),
+	}
+	server.MustSpawnActors(service)
+	if err := server.Start(); err != nil {
+		t.Fatalf("start err:%v", err)
+	}
+	defer server.Stop()
+
+	// kind 1 goes straight to the server, everything else over loopback
+	connString := "loopback://TestKindTransportTCP"
+	engine := NewEngine(0, 2, 1002, connString)
+	direct := rpc.NewTCPTransport("tcp://", rpc.StaticResolver{"engine.0.1.1001": addr})
+	engine.SetRPCTransport(rpc.NewKindTransport(rpc.NewLoopbackTransport(connString), direct, 1), nil)
+	engine.MustInit()
+	defer engine.Stop()
+	if err := engine.Start(); err != nil {
+		t.Fatalf("start err:%v", err)
+	}
+
+	client := NewActor("1", engine)
+	target := concepts.NewActorId("engine.0.1.1001.server", "1")
+	for i := uint64(0); i < 10; i++ {
+		echo := &common_msg.EchoRequest{Value1: i + 1, Value2: "tcp"}
+		response, err := SendRequest[common_msg.EchoResponse](client, target, 1, echo)
+		if err != nil {
+			t.Fatalf("opcode 1 failure, err:%v", err)
+		}
+		if response.Value1 != echo.Value1+1 {
+			t.Fatalf("unexpected response:%v", response)
+		}
+	}
+
+	echoObj := &rpc_msg.RPC_EchoTestRequest{Value1: 12345678, Value2: "tcp"}
+	if err := SendNotify(client, target, 2, echoObj); err != nil {
+		t.Fatalf("opcode 2 failure, err:%v", err)
+	}
+	select {
+	case notify := <-service.notified:
+		if notify.Value1 != echoObj.Value1 {
+			t.Fatalf("unexpected notify:%v", notify)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("notify not delivered")
+	}
+}
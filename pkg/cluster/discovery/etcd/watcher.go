@@ -2,7 +2,6 @@ package etcd
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
@@ -50,7 +49,7 @@ func (ew *etcdWatcher) Next() (*registry.Result, error) {
 			return nil, wresp.Err()
 		}
 		if wresp.Canceled {
-			return nil, errors.New("could not get next")
+			return nil, registry.ErrWatcherStopped
 		}
 		for _, ev := range wresp.Events {
 			service := decode(ev.Kv.Value)
@@ -79,7 +78,7 @@ func (ew *etcdWatcher) Next() (*registry.Result, error) {
 			}, nil
 		}
 	}
-	return nil, errors.New("could not get next")
+	return nil, registry.ErrWatcherStopped
 }
 
 func (ew *etcdWatcher) Stop() {
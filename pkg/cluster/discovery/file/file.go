@@ -0,0 +1,179 @@
+// Package file provides a service registry read from a static file
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/memory"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPollInterval is how often the registry checks its file.
+var DefaultPollInterval = 5 * time.Second
+
+// Registry serves the services listed in a JSON or YAML file, e.g.
+//
+//	[{"name": "engine.0.1", "version": "1.0.0", "nodes": [
+//	  {"id": "engine.0.1.1001.server", "address": "10.0.0.1:9000"}]}]
+//
+// The nodes of the file never expire; when the file changes they are
+// created, updated and deleted with the same events as with etcd. Nodes
+// registered at runtime live in memory only, with their TTL.
+type Registry struct {
+	registry.Registry
+	path     string
+	interval time.Duration
+
+	mu     sync.Mutex
+	last   []byte
+	loaded map[string]*registry.Service
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type Option func(*Registry)
+
+// WithPollInterval sets how often the file is checked, DefaultPollInterval
+// when zero.
+func WithPollInterval(interval time.Duration) Option {
+	return func(r *Registry) {
+		r.interval = interval
+	}
+}
+
+// NewRegistry loads path, ending in .json, .yaml or .yml, and follows its
+// changes until Close.
+func NewRegistry(path string, opts ...Option) (*Registry, error) {
+	r := &Registry{
+		Registry: memory.NewRegistry(),
+		path:     path,
+		interval: DefaultPollInterval,
+		loaded:   make(map[string]*registry.Service),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.interval <= 0 {
+		r.interval = DefaultPollInterval
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.poll(ctx)
+	return r, nil
+}
+
+func (r *Registry) poll(ctx context.Context) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				logger.Log(logger.WarnLevel, "reload registry file failed", "path", r.path, "err", err)
+			}
+		}
+	}
+}
+
+// Reload reads the file now and applies its changes. A file that does not
+// parse changes nothing.
+func (r *Registry) Reload() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last != nil && bytes.Equal(data, r.last) {
+		return nil
+	}
+	services, err := parse(data, filepath.Ext(r.path))
+	if err != nil {
+		return fmt.Errorf("registry file %s: %w", r.path, err)
+	}
+
+	next := make(map[string]*registry.Service)
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			next[service.Name+"/"+node.Id] = &registry.Service{
+				Name:      service.Name,
+				Version:   service.Version,
+				Metadata:  service.Metadata,
+				Endpoints: service.Endpoints,
+				Nodes:     []*registry.Node{node},
+			}
+		}
+	}
+	for key, service := range r.loaded {
+		if _, ok := next[key]; !ok {
+			if err := r.Registry.Deregister(service); err != nil {
+				return err
+			}
+		}
+	}
+	// unchanged nodes are registered again without an event
+	for _, service := range next {
+		if err := r.Registry.Register(service); err != nil {
+			return err
+		}
+	}
+	r.loaded = next
+	r.last = data
+	return nil
+}
+
+func parse(data []byte, format string) ([]*registry.Service, error) {
+	var services []*registry.Service
+	var err error
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &services)
+	case "json":
+		err = json.Unmarshal(data, &services)
+	default:
+		return nil, fmt.Errorf("unsupported registry file format:%q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, service := range services {
+		if service == nil || service.Name == "" {
+			return nil, fmt.Errorf("service %d has no name", i)
+		}
+		for _, node := range service.Nodes {
+			if node == nil || node.Id == "" {
+				return nil, fmt.Errorf("service %s has a node without id", service.Name)
+			}
+		}
+	}
+	return services, nil
+}
+
+// Close stops following the file.
+func (r *Registry) Close() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *Registry) String() string {
+	return "file"
+}
@@ -0,0 +1,97 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+)
+
+const servicesYAML = `
+- name: engine.0.1
+  version: 1.0.0
+  nodes:
+    - id: engine.0.1.1001.server
+      address: 10.0.0.1:9000
+    - id: engine.0.1.1002.server
+      address: 10.0.0.2:9000
+`
+
+const movedYAML = `
+- name: engine.0.1
+  version: 1.0.0
+  nodes:
+    - id: engine.0.1.1001.server
+      address: 10.0.0.1:9001
+`
+
+func TestFileRegistry(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "services.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(servicesYAML), 0o644))
+
+	r, err := NewRegistry(path, WithPollInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer r.Close()
+
+	services, err := r.GetService("engine.0.1")
+	require.NoError(t, err)
+	require.Len(t, services, 1)
+	assert.Equal(t, "1.0.0", services[0].Version)
+	assert.Len(t, services[0].Nodes, 2)
+
+	w, err := r.Watch(registry.WatchService("engine.0.1"))
+	require.NoError(t, err)
+	defer w.Stop()
+
+	// 1001 moves, 1002 leaves
+	require.NoError(t, os.WriteFile(path, []byte(movedYAML), 0o644))
+
+	actions := map[string]string{}
+	for len(actions) < 2 {
+		result, err := w.Next()
+		require.NoError(t, err)
+		actions[result.Service.Nodes[0].Id] = result.Action
+	}
+	assert.Equal(t, map[string]string{
+		"engine.0.1.1001.server": "update",
+		"engine.0.1.1002.server": "delete",
+	}, actions)
+	services, err = r.GetService("engine.0.1")
+	require.NoError(t, err)
+	require.Len(t, services[0].Nodes, 1)
+	assert.Equal(t, "10.0.0.1:9001", services[0].Nodes[0].Address)
+
+	// a broken file keeps the last services
+	require.NoError(t, os.WriteFile(path, []byte("- name: ["), 0o644))
+	assert.Error(t, r.Reload())
+	_, err = r.GetService("engine.0.1")
+	assert.NoError(t, err)
+
+	// runtime registrations expire like etcd leases
+	require.NoError(t, r.Register(&registry.Service{Name: "engine.0.2", Nodes: []*registry.Node{{Id: "engine.0.2.1001.server"}}}, registry.RegisterTTL(20*time.Millisecond)))
+	assert.Eventually(t, func() bool {
+		_, err := r.GetService("engine.0.2")
+		return err == registry.ErrNotFound
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFileRegistryInvalid(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewRegistry(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+
+	path := filepath.Join(dir, "services.toml")
+	require.NoError(t, os.WriteFile(path, []byte(""), 0o644))
+	_, err = NewRegistry(path)
+	assert.Error(t, err)
+
+	path = filepath.Join(dir, "services.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "engine.0.1", "nodes": [{"address": "10.0.0.1:9000"}]}]`), 0o644))
+	_, err = NewRegistry(path)
+	assert.Error(t, err)
+}
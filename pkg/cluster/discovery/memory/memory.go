@@ -0,0 +1,274 @@
+// Package memory provides an in-process service registry
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+)
+
+// record is a registered node, held like the etcd registry does as a
+// service with that single node.
+type record struct {
+	service *registry.Service
+	// expires is zero for nodes registered without TTL
+	expires time.Time
+	timer   *time.Timer
+}
+
+type memoryRegistry struct {
+	options registry.Options
+
+	sync.RWMutex
+	// service name -> node id -> record
+	records  map[string]map[string]*record
+	watchers map[*memoryWatcher]struct{}
+}
+
+// NewRegistry keeps the services in memory, for tests and single process
+// deployments. Nodes registered with a TTL expire unless registered again
+// within it, as with etcd leases. registry.Services preloads nodes without
+// TTL.
+func NewRegistry(opts ...registry.Option) registry.Registry {
+	m := &memoryRegistry{
+		records:  make(map[string]map[string]*record),
+		watchers: make(map[*memoryWatcher]struct{}),
+	}
+	m.Init(opts...)
+	return m
+}
+
+func (m *memoryRegistry) Init(opts ...registry.Option) error {
+	for _, o := range opts {
+		o(&m.options)
+	}
+
+	services, ok := registry.ServicesFrom(m.options.Context)
+	if !ok {
+		return nil
+	}
+	for _, list := range services {
+		for _, service := range list {
+			if err := m.Register(service); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memoryRegistry) Options() registry.Options {
+	return m.options
+}
+
+func (m *memoryRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("Require at least one node")
+	}
+
+	var options registry.RegisterOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	for _, node := range s.Nodes {
+		m.registerNode(s, node, options.TTL)
+	}
+	return nil
+}
+
+func (m *memoryRegistry) registerNode(s *registry.Service, node *registry.Node, ttl time.Duration) {
+	service := copyService(&registry.Service{
+		Name:      s.Name,
+		Version:   s.Version,
+		Metadata:  s.Metadata,
+		Endpoints: s.Endpoints,
+		Nodes:     []*registry.Node{node},
+	})
+
+	nodes, ok := m.records[s.Name]
+	if !ok {
+		nodes = make(map[string]*record)
+		m.records[s.Name] = nodes
+	}
+	previous, exists := nodes[node.Id]
+	if exists && previous.timer != nil {
+		previous.timer.Stop()
+	}
+
+	r := &record{service: service}
+	if ttl > 0 {
+		r.expires = time.Now().Add(ttl)
+		r.timer = time.AfterFunc(ttl, func() { m.expire(r) })
+	}
+	nodes[node.Id] = r
+
+	// the lease is renewed, but an unchanged node is no event
+	switch {
+	case !exists:
+		m.notify(registry.Create, service)
+	case !reflect.DeepEqual(previous.service, service):
+		m.notify(registry.Update, service)
+	}
+}
+
+// expire removes the node of r unless it was registered again meanwhile.
+func (m *memoryRegistry) expire(r *record) {
+	m.Lock()
+	defer m.Unlock()
+	name, id := r.service.Name, r.service.Nodes[0].Id
+	if m.records[name][id] != r {
+		return
+	}
+	m.removeNode(name, id)
+}
+
+// removeNode deletes the node, m held.
+func (m *memoryRegistry) removeNode(name, id string) {
+	nodes := m.records[name]
+	r, ok := nodes[id]
+	if !ok {
+		return
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	delete(nodes, id)
+	if len(nodes) == 0 {
+		delete(m.records, name)
+	}
+	m.notify(registry.Delete, r.service)
+}
+
+func (m *memoryRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("Require at least one node")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	for _, node := range s.Nodes {
+		m.removeNode(s.Name, node.Id)
+	}
+	return nil
+}
+
+func (m *memoryRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	nodes, ok := m.records[name]
+	if !ok || len(nodes) == 0 {
+		return nil, registry.ErrNotFound
+	}
+
+	serviceMap := map[string]*registry.Service{}
+	for _, r := range sortedRecords(nodes) {
+		sn := copyService(r.service)
+		s, ok := serviceMap[sn.Version]
+		if !ok {
+			s = &registry.Service{
+				Name:      sn.Name,
+				Version:   sn.Version,
+				Metadata:  sn.Metadata,
+				Endpoints: sn.Endpoints,
+			}
+			serviceMap[s.Version] = s
+		}
+		s.Nodes = append(s.Nodes, sn.Nodes...)
+	}
+
+	services := make([]*registry.Service, 0, len(serviceMap))
+	for _, service := range serviceMap {
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+func (m *memoryRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	versions := make(map[string]*registry.Service)
+	for _, nodes := range m.records {
+		for _, r := range sortedRecords(nodes) {
+			sn := copyService(r.service)
+			v, ok := versions[sn.Name+sn.Version]
+			if !ok {
+				versions[sn.Name+sn.Version] = sn
+				continue
+			}
+			// append to service:version nodes
+			v.Nodes = append(v.Nodes, sn.Nodes...)
+		}
+	}
+
+	services := make([]*registry.Service, 0, len(versions))
+	for _, service := range versions {
+		services = append(services, service)
+	}
+
+	// sort the services
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	return services, nil
+}
+
+func (m *memoryRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	var wo registry.WatchOptions
+	for _, o := range opts {
+		o(&wo)
+	}
+
+	w := newMemoryWatcher(wo, func(w *memoryWatcher) {
+		m.Lock()
+		defer m.Unlock()
+		delete(m.watchers, w)
+	})
+	m.Lock()
+	m.watchers[w] = struct{}{}
+	m.Unlock()
+	return w, nil
+}
+
+// notify queues the change of a node for the watchers, m held.
+func (m *memoryRegistry) notify(event registry.EventType, service *registry.Service) {
+	for w := range m.watchers {
+		if len(w.wo.Service) > 0 && w.wo.Service != service.Name {
+			continue
+		}
+		w.push(&registry.Result{Action: event.String(), Service: copyService(service)})
+	}
+}
+
+func (m *memoryRegistry) String() string {
+	return "memory"
+}
+
+// sortedRecords orders the nodes of a service by id, for stable results.
+func sortedRecords(nodes map[string]*record) []*record {
+	records := make([]*record, 0, len(nodes))
+	for _, r := range nodes {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].service.Nodes[0].Id < records[j].service.Nodes[0].Id
+	})
+	return records
+}
+
+// copyService detaches the service from the caller as the etcd registry
+// does by storing it encoded.
+func copyService(s *registry.Service) *registry.Service {
+	b, _ := json.Marshal(s)
+	var c *registry.Service
+	json.Unmarshal(b, &c)
+	return c
+}
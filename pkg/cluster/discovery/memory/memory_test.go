@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+)
+
+func newService(name, version, id string) *registry.Service {
+	return &registry.Service{
+		Name:    name,
+		Version: version,
+		Nodes:   []*registry.Node{{Id: id, Address: id, Metadata: map[string]string{"version": version}}},
+	}
+}
+
+func next(t *testing.T, w registry.Watcher) *registry.Result {
+	t.Helper()
+	type outcome struct {
+		result *registry.Result
+		err    error
+	}
+	ch := make(chan outcome, 1)
+	go func() {
+		result, err := w.Next()
+		ch <- outcome{result, err}
+	}()
+	select {
+	case o := <-ch:
+		require.NoError(t, o.err)
+		return o.result
+	case <-time.After(time.Second):
+		t.Fatal("no watch result")
+		return nil
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	all, err := r.Watch()
+	require.NoError(t, err)
+	defer all.Stop()
+	kind2, err := r.Watch(registry.WatchService("engine.0.2"))
+	require.NoError(t, err)
+	defer kind2.Stop()
+
+	_, err = r.GetService("engine.0.1")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	assert.Error(t, r.Register(&registry.Service{Name: "engine.0.1"}))
+
+	require.NoError(t, r.Register(newService("engine.0.1", "1", "engine.0.1.1001.server")))
+	require.NoError(t, r.Register(newService("engine.0.1", "2", "engine.0.1.1002.server")))
+	require.NoError(t, r.Register(newService("engine.0.2", "1", "engine.0.2.1001.server")))
+	// unchanged, no event
+	require.NoError(t, r.Register(newService("engine.0.1", "1", "engine.0.1.1001.server")))
+	require.NoError(t, r.Register(newService("engine.0.1", "3", "engine.0.1.1002.server")))
+
+	for _, expected := range []struct{ action, id string }{
+		{"create", "engine.0.1.1001.server"},
+		{"create", "engine.0.1.1002.server"},
+		{"create", "engine.0.2.1001.server"},
+		{"update", "engine.0.1.1002.server"},
+	} {
+		result := next(t, all)
+		assert.Equal(t, expected.action, result.Action)
+		assert.Equal(t, expected.id, result.Service.Nodes[0].Id)
+	}
+	result := next(t, kind2)
+	assert.Equal(t, "create", result.Action)
+	assert.Equal(t, "engine.0.2", result.Service.Name)
+
+	services, err := r.GetService("engine.0.1")
+	require.NoError(t, err)
+	assert.Len(t, services, 2)
+	list, err := r.ListServices()
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	assert.Equal(t, "engine.0.1", list[0].Name)
+	assert.Equal(t, "engine.0.2", list[2].Name)
+
+	// the registry keeps its own copy
+	services[0].Nodes[0].Id = "changed"
+	services, err = r.GetService("engine.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, "engine.0.2.1001.server", services[0].Nodes[0].Id)
+
+	require.NoError(t, r.Deregister(newService("engine.0.2", "1", "engine.0.2.1001.server")))
+	result = next(t, kind2)
+	assert.Equal(t, "delete", result.Action)
+	assert.Equal(t, "engine.0.2.1001.server", result.Service.Nodes[0].Id)
+	_, err = r.GetService("engine.0.2")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+
+	kind2.Stop()
+	_, err = kind2.Next()
+	assert.ErrorIs(t, err, registry.ErrWatcherStopped)
+}
+
+func TestRegistryTTL(t *testing.T) {
+	r := NewRegistry()
+	w, err := r.Watch()
+	require.NoError(t, err)
+	defer w.Stop()
+
+	service := newService("engine.0.1", "1", "engine.0.1.1001.server")
+	require.NoError(t, r.Register(service, registry.RegisterTTL(100*time.Millisecond)))
+	assert.Equal(t, "create", next(t, w).Action)
+
+	// registering again renews the lease
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, r.Register(service, registry.RegisterTTL(100*time.Millisecond)))
+	}
+	_, err = r.GetService("engine.0.1")
+	require.NoError(t, err)
+
+	result := next(t, w)
+	assert.Equal(t, "delete", result.Action)
+	assert.Equal(t, "engine.0.1.1001.server", result.Service.Nodes[0].Id)
+	_, err = r.GetService("engine.0.1")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestRegistryPreload(t *testing.T) {
+	r := NewRegistry(registry.Services(map[string][]*registry.Service{
+		"engine.0.1": {newService("engine.0.1", "1", "engine.0.1.1001.server")},
+	}))
+	services, err := r.GetService("engine.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "engine.0.1.1001.server", services[0].Nodes[0].Id)
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+)
+
+// memoryWatcher queues the results without bound, so that a slow reader
+// never blocks the registry.
+type memoryWatcher struct {
+	wo     registry.WatchOptions
+	mu     sync.Mutex
+	queue  []*registry.Result
+	ready  chan struct{}
+	exit   chan struct{}
+	once   sync.Once
+	onStop func(*memoryWatcher)
+}
+
+func newMemoryWatcher(wo registry.WatchOptions, onStop func(*memoryWatcher)) *memoryWatcher {
+	return &memoryWatcher{
+		wo:     wo,
+		ready:  make(chan struct{}, 1),
+		exit:   make(chan struct{}),
+		onStop: onStop,
+	}
+}
+
+func (w *memoryWatcher) push(result *registry.Result) {
+	w.mu.Lock()
+	w.queue = append(w.queue, result)
+	w.mu.Unlock()
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (w *memoryWatcher) Next() (*registry.Result, error) {
+	for {
+		w.mu.Lock()
+		if len(w.queue) > 0 {
+			result := w.queue[0]
+			w.queue[0] = nil
+			w.queue = w.queue[1:]
+			w.mu.Unlock()
+			return result, nil
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-w.ready:
+		case <-w.exit:
+			return nil, registry.ErrWatcherStopped
+		}
+	}
+}
+
+func (w *memoryWatcher) Stop() {
+	w.once.Do(func() {
+		close(w.exit)
+		w.onStop(w)
+	})
+}
@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long the cache serves a service before asking the
+// registry again.
+var DefaultCacheTTL = 10 * time.Second
+
+// Cache is a Registry answering GetService from memory. Entries expire
+// after the ttl or when Invalidate is called, usually on a watcher result,
+// and are served stale while the registry fails.
+type Cache struct {
+	Registry
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	services []*Service
+	// err is ErrNotFound when the registry has no such service
+	err     error
+	expires time.Time
+}
+
+type CacheOption func(*Cache)
+
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+func NewCache(r Registry, opts ...CacheOption) *Cache {
+	c := &Cache{
+		Registry: r,
+		ttl:      DefaultCacheTTL,
+		entries:  make(map[string]*cacheEntry),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Cache) GetService(name string, opts ...GetOption) ([]*Service, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[name]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.services, entry.err
+	}
+
+	services, err := c.Registry.GetService(name, opts...)
+	if err != nil && err != ErrNotFound {
+		if ok && entry.err == nil {
+			return entry.services, nil
+		}
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[name] = &cacheEntry{services: services, err: err, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return services, err
+}
+
+// Invalidate makes the next GetService of name ask the registry, the entry
+// is kept in case it fails.
+func (c *Cache) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[name]; ok {
+		c.entries[name] = &cacheEntry{services: entry.services, err: entry.err}
+	}
+}
+
+// Register and Deregister invalidate the service they change.
+func (c *Cache) Register(s *Service, opts ...RegisterOption) error {
+	defer c.Invalidate(s.Name)
+	return c.Registry.Register(s, opts...)
+}
+
+func (c *Cache) Deregister(s *Service, opts ...DeregisterOption) error {
+	defer c.Invalidate(s.Name)
+	return c.Registry.Deregister(s, opts...)
+}
+
+func (c *Cache) String() string {
+	return "cache(" + c.Registry.String() + ")"
+}
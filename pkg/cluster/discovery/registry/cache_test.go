@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingRegistry struct {
+	Registry
+	services map[string][]*Service
+	err      error
+	gets     int
+}
+
+func (r *countingRegistry) GetService(name string, opts ...GetOption) ([]*Service, error) {
+	r.gets++
+	if r.err != nil {
+		return nil, r.err
+	}
+	services, ok := r.services[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return services, nil
+}
+
+func TestCache(t *testing.T) {
+	r := &countingRegistry{services: map[string][]*Service{
+		"engine.0.1": {{Name: "engine.0.1", Nodes: []*Node{{Id: "engine.0.1.1001.server"}}}},
+	}}
+	cache := NewCache(r, WithCacheTTL(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		services, err := cache.GetService("engine.0.1")
+		require.NoError(t, err)
+		assert.Len(t, services, 1)
+	}
+	assert.Equal(t, 1, r.gets)
+
+	_, err := cache.GetService("engine.0.2")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = cache.GetService("engine.0.2")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, r.gets)
+
+	// stale entries are served while the registry fails
+	cache.Invalidate("engine.0.1")
+	r.err = errors.New("unavailable")
+	services, err := cache.GetService("engine.0.1")
+	require.NoError(t, err)
+	assert.Len(t, services, 1)
+	_, err = cache.GetService("engine.0.3")
+	assert.Error(t, err)
+}
@@ -137,3 +137,12 @@ func Services(s map[string][]*Service) Option {
 		o.Context = context.WithValue(o.Context, servicesKey{}, s)
 	}
 }
+
+// ServicesFrom returns the services preloaded with the Services option.
+func ServicesFrom(ctx context.Context) (map[string][]*Service, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	services, ok := ctx.Value(servicesKey{}).(map[string][]*Service)
+	return services, ok
+}
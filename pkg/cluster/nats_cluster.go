@@ -3,15 +3,33 @@ package cluster
 import (
 	"github.com/nats-io/nats.go"
 	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/pkg/metrics"
 )
 
+var (
+	natsDisconnects   = metrics.NewCounterVec("nats_disconnects_total", "Connections lost to the nats server.")
+	natsReconnects    = metrics.NewCounterVec("nats_reconnects_total", "Connections restored to the nats server.")
+	natsSlowConsumers = metrics.NewCounterVec("nats_slow_consumer_total",
+		"Slow consumer errors, the server or client dropped messages of the subject.", "subject")
+	natsDropped = metrics.NewGaugeVec("nats_dropped_messages", "Messages dropped by the subscription of the subject.", "subject")
+)
+
+// SetupNatsConn connects to connectString with the default credentials,
+// see SetDefaultCredentials, followed by options.
 func SetupNatsConn(id, connectString string, appDieChan chan bool, options ...nats.Option) (*nats.Conn, error) {
-	natsOptions := append(
-		options,
+	natsOptions, err := DefaultCredentials().Options()
+	if err != nil {
+		return nil, err
+	}
+	natsOptions = append(natsOptions, options...)
+	natsOptions = append(
+		natsOptions,
 		nats.DisconnectHandler(func(_ *nats.Conn) {
+			natsDisconnects.With().Inc()
 			logger.Log(logger.WarnLevel, "disconnected from nats!", "id", id)
 		}),
 		nats.ReconnectHandler(func(nc *nats.Conn) {
+			natsReconnects.With().Inc()
 			logger.Log(logger.WarnLevel, "reconnected to nats", "server", nc.ConnectedServerName(), "id", id, "address", nc.ConnectedAddr(), "cluster", nc.ConnectedClusterName())
 		}),
 		nats.ClosedHandler(func(nc *nats.Conn) {
@@ -29,6 +47,8 @@ func SetupNatsConn(id, connectString string, appDieChan chan bool, options ...na
 		nats.ErrorHandler(func(nc *nats.Conn, sub *nats.Subscription, err error) {
 			if err == nats.ErrSlowConsumer {
 				dropped, _ := sub.Dropped()
+				natsSlowConsumers.With(sub.Subject).Inc()
+				natsDropped.With(sub.Subject).Set(float64(dropped))
 				logger.Log(logger.ErrorLevel, "nats slow consumer",
 					"subject", sub.Subject, "dropped", dropped, "id", id)
 			} else {
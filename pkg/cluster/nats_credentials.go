@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NatsCredentials authenticate the nats connections of this process. The
+// fields left empty are not used.
+type NatsCredentials struct {
+	User     string
+	Password string
+	Token    string
+	// NkeySeedFile holds the nkey seed the connection signs its nonce with.
+	NkeySeedFile string
+	// CredsFile is a decentralized auth credentials file, a user JWT and its
+	// nkey seed.
+	CredsFile string
+	// CAFile verifies the server, CertFile and KeyFile are the client
+	// certificate for mutual TLS.
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// Options returns the nats options of the credentials.
+func (c NatsCredentials) Options() ([]nats.Option, error) {
+	var options []nats.Option
+	if c.User != "" {
+		options = append(options, nats.UserInfo(c.User, c.Password))
+	}
+	if c.Token != "" {
+		options = append(options, nats.Token(c.Token))
+	}
+	if c.NkeySeedFile != "" {
+		option, err := nats.NkeyOptionFromSeed(c.NkeySeedFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	if c.CredsFile != "" {
+		options = append(options, nats.UserCredentials(c.CredsFile))
+	}
+	if c.CAFile != "" {
+		options = append(options, nats.RootCAs(c.CAFile))
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		options = append(options, nats.ClientCert(c.CertFile, c.KeyFile))
+	}
+	return options, nil
+}
+
+var (
+	credentialsMu      sync.RWMutex
+	defaultCredentials NatsCredentials
+)
+
+// SetDefaultCredentials makes SetupNatsConn authenticate with credentials,
+// the options passed to it take precedence.
+func SetDefaultCredentials(credentials NatsCredentials) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+	defaultCredentials = credentials
+}
+
+func DefaultCredentials() NatsCredentials {
+	credentialsMu.RLock()
+	defer credentialsMu.RUnlock()
+	return defaultCredentials
+}
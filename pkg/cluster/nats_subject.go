@@ -14,7 +14,7 @@ func NodeSubject(sRealm, sType, sId string) string {
 type NatsSubject struct {
 	Ch           chan *nats.Msg
 	Subject      string
-	Subscription *nats.Subscription
+	Subscription interface{ Unsubscribe() error }
 }
 
 func NewNatsSubject(subject string, size int) *NatsSubject {
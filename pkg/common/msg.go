@@ -5,6 +5,8 @@ type Req struct {
 	Opcode    int32  `json:"opcode"`
 	Flag      int32  `json:"flag"`
 	Data      []byte `json:"data"` // 在序列化和反序列化时，[]byte 会被自动转换为 Base64 编码的字符串（JSON 格式要求）
+	// TraceParent continues the trace of the caller, W3C traceparent format
+	TraceParent string `json:"traceparent,omitempty"`
 }
 
 type Resp struct {
@@ -12,6 +14,8 @@ type Resp struct {
 	ErrCode   int32  `json:"errCode"`
 	ErrMsg    string `json:"errMsg"`
 	Data      []byte `json:"data"`
+	// Opcode is set on the replies and pushes of the backend services
+	Opcode int32 `json:"opcode,omitempty"`
 }
 
 func NewResp(requestId int32, errCode int32, errMsg string) *Resp {
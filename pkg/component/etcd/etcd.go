@@ -1,64 +1,122 @@
 package etcd
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/wuqunyong/file_storage/pkg/actor"
 	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/etcd"
 	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
 	"github.com/wuqunyong/file_storage/pkg/concepts"
 	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
 )
 
 var (
 	DefaultRegisterInterval    = time.Second * 30
 	DefaultRegisterTTL         = time.Second * 90
 	DefaultSyncServersInterval = time.Second * 60 * 5
-	serviceName                = "test1"
+	DefaultVersion             = "latest"
 )
 
+// Metadata keys of the registered node, besides one per listening
+// component keyed by its protocol, e.g. tcp or ws, and the rpc.MetadataEngine,
+// rpc.MetadataTCPAddress and rpc.MetadataPublicKey of its rpc transport.
+const (
+	MetadataVersion = "version"
+	MetadataSubject = "subject"
+	MetadataOpcodes = "opcodes"
+)
+
+// EtcdServiceDiscovery registers the engine as a node of the service
+// concepts.GenServiceName(realm, kind) with the server address as node id,
+// and lets actor.RequestService resolve the nodes of other kinds.
 type EtcdServiceDiscovery struct {
-	registry           registry.Registry
-	watcher            registry.Watcher
-	engine             concepts.IEngine
-	registerTTL        time.Duration
-	registerInterval   time.Duration
-	service            *registry.Service
-	exit               chan chan error
-	syncServersRunning chan bool
-	running            bool
-}
-
-// etcdctl.exe put /micro/registry/test2/2 {\"name\":\"hello\"}
+	registry         registry.Registry
+	cache            *registry.Cache
+	watcher          registry.Watcher
+	engine           concepts.IEngine
+	registerTTL      time.Duration
+	registerInterval time.Duration
+	version          string
+	host             string
+	watched          []string
+	mu               sync.Mutex
+	service          *registry.Service
+	exit             chan chan error
+	running          bool
+}
+
+type Option func(*EtcdServiceDiscovery)
+
+// WithVersion sets the version the engine registers with.
+func WithVersion(version string) Option {
+	return func(sd *EtcdServiceDiscovery) {
+		sd.version = version
+	}
+}
+
+// WithAdvertiseHost is the host published for listeners bound to all
+// interfaces, the hostname by default.
+func WithAdvertiseHost(host string) Option {
+	return func(sd *EtcdServiceDiscovery) {
+		sd.host = host
+	}
+}
+
+// WithWatch names the services whose nodes are loaded when the component
+// starts and logged when they change.
+func WithWatch(services ...string) Option {
+	return func(sd *EtcdServiceDiscovery) {
+		sd.watched = append(sd.watched, services...)
+	}
+}
+
+func WithRegisterTTL(ttl, interval time.Duration) Option {
+	return func(sd *EtcdServiceDiscovery) {
+		sd.registerTTL = ttl
+		sd.registerInterval = interval
+	}
+}
+
+// etcdctl.exe get --prefix /micro/registry/engine.0.1
 func NewEtcvServiceDiscovery(opts ...registry.Option) *EtcdServiceDiscovery {
-	r := etcd.NewRegistry(opts...)
-	return &EtcdServiceDiscovery{
-		registry:           r,
-		registerTTL:        DefaultRegisterTTL,
-		registerInterval:   DefaultRegisterInterval,
-		exit:               make(chan chan error),
-		syncServersRunning: make(chan bool),
-		service: &registry.Service{
-			Name:    "test1",
-			Version: "1.0.1",
-			Nodes: []*registry.Node{
-				{
-					Id:      "test1-1",
-					Address: "10.0.0.1:10001",
-					Metadata: map[string]string{
-						"foo": "bar",
-					},
-				},
-			},
-		},
+	return NewEtcdServiceDiscovery(etcd.NewRegistry(opts...))
+}
+
+// NewEtcdServiceDiscovery registers in r, which needs not be etcd, e.g. a
+// memory or file registry.
+func NewEtcdServiceDiscovery(r registry.Registry, opts ...Option) *EtcdServiceDiscovery {
+	sd := &EtcdServiceDiscovery{
+		registry:         r,
+		cache:            registry.NewCache(r),
+		registerTTL:      DefaultRegisterTTL,
+		registerInterval: DefaultRegisterInterval,
+		version:          DefaultVersion,
+		exit:             make(chan chan error),
+	}
+	for _, opt := range opts {
+		opt(sd)
 	}
+	return sd
 }
 
 func (sd *EtcdServiceDiscovery) Name() string {
 	return "etcd"
 }
 
-func (sd *EtcdServiceDiscovery) Priority() int32 {
-	return 1
+func (sd *EtcdServiceDiscovery) Dependencies() []string {
+	return nil
 }
 
 func (sd *EtcdServiceDiscovery) SetEngine(engine concepts.IEngine) {
@@ -70,8 +128,8 @@ func (sd *EtcdServiceDiscovery) GetEngine() concepts.IEngine {
 }
 
 func (sd *EtcdServiceDiscovery) OnInit() error {
-	if err := sd.Register(); err != nil {
-		return err
+	if _, _, _, err := concepts.DecodeAddress(sd.engine.GetAddress()); err != nil {
+		return fmt.Errorf("engine address %s: %w", sd.engine.GetAddress(), err)
 	}
 
 	watcher, err := sd.registry.Watch()
@@ -81,20 +139,137 @@ func (sd *EtcdServiceDiscovery) OnInit() error {
 	sd.watcher = watcher
 	go sd.watch()
 
-	err = sd.SyncServers()
-	if err != nil {
-		return err
+	// actor.RequestService finds the nodes of a kind in the cached registry
+	if engine, ok := sd.engine.(interface{ SetResolver(actor.Resolver) }); ok {
+		engine.SetResolver(actor.NewRegistryResolver(sd.cache))
 	}
-	return nil
+	// and the direct TCP transports dial the MetadataTCPAddress of the peers
+	if engine, ok := sd.engine.(interface{ SetPeerResolver(rpc.PeerResolver) }); ok {
+		engine.SetPeerResolver(rpc.NewRegistryResolver(sd.cache))
+	}
+	return sd.SyncServers()
+}
+
+// Registry is the cached registry the component registers in and resolves
+// from.
+func (sd *EtcdServiceDiscovery) Registry() registry.Registry {
+	return sd.cache
 }
 
+// Service is what the engine registers, nil before the first Register.
+func (sd *EtcdServiceDiscovery) Service() *registry.Service {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	return sd.service
+}
+
+// Register publishes the engine as it is now, the opcodes of actors spawned
+// since the last call included.
 func (sd *EtcdServiceDiscovery) Register() error {
-	rOpts := []registry.RegisterOption{registry.RegisterTTL(sd.registerTTL)}
-	if err := sd.registry.Register(sd.service, rOpts...); err != nil {
+	service, err := sd.buildService()
+	if err != nil {
 		return err
 	}
+	sd.mu.Lock()
+	sd.service = service
+	sd.mu.Unlock()
 
-	return nil
+	rOpts := []registry.RegisterOption{registry.RegisterTTL(sd.registerTTL)}
+	return sd.cache.Register(service, rOpts...)
+}
+
+func (sd *EtcdServiceDiscovery) buildService() (*registry.Service, error) {
+	address := sd.engine.GetAddress()
+	realm, kind, id, err := concepts.DecodeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &registry.Node{
+		Id: address,
+		// nodes reached through the message bus only have their subject
+		Address: address,
+		Metadata: map[string]string{
+			MetadataVersion:    sd.version,
+			MetadataSubject:    address,
+			rpc.MetadataEngine: concepts.GenEngineName(realm, kind, id),
+		},
+	}
+	// the direct TCP transport, dialed by rpc.RegistryResolver
+	if engine, ok := sd.engine.(interface{ TCPAddress() string }); ok && engine.TCPAddress() != "" {
+		node.Metadata[rpc.MetadataTCPAddress] = sd.advertise(engine.TCPAddress())
+	}
+	// read by rpc.RegistryPublicKeys
+	if engine, ok := sd.engine.(interface{ SigningKey() ed25519.PublicKey }); ok && engine.SigningKey() != nil {
+		node.Metadata[rpc.MetadataPublicKey] = base64.StdEncoding.EncodeToString(engine.SigningKey())
+	}
+	if engine, ok := sd.engine.(interface{ Components() []concepts.IComponent }); ok {
+		for _, component := range engine.Components() {
+			listener, ok := component.(concepts.IListener)
+			if !ok {
+				continue
+			}
+			protocol, listen := listener.Listen()
+			if listen == "" {
+				continue
+			}
+			advertised := sd.advertise(listen)
+			if node.Address == address {
+				node.Address = advertised
+			}
+			node.Metadata[protocol] = advertised
+		}
+	}
+	if engine, ok := sd.engine.(interface{ Opcodes() []uint32 }); ok {
+		opcodes := make([]string, 0)
+		for _, opcode := range engine.Opcodes() {
+			opcodes = append(opcodes, strconv.FormatUint(uint64(opcode), 10))
+		}
+		node.Metadata[MetadataOpcodes] = strings.Join(opcodes, ",")
+	}
+
+	return &registry.Service{
+		Name:    concepts.GenServiceName(realm, kind),
+		Version: sd.version,
+		Nodes:   []*registry.Node{node},
+	}, nil
+}
+
+// advertise fills the host of a listen address bound to all interfaces.
+func (sd *EtcdServiceDiscovery) advertise(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return listen
+	}
+	host = sd.host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// HealthCheck reports whether the own node is still registered, i.e.
+// whether its lease is alive.
+func (sd *EtcdServiceDiscovery) HealthCheck(ctx context.Context) error {
+	own := sd.Service()
+	if own == nil {
+		return errors.New("not registered yet")
+	}
+	services, err := sd.registry.GetService(own.Name)
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			if node.Id == own.Nodes[0].Id {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("node %s not registered", own.Nodes[0].Id)
 }
 
 func (sd *EtcdServiceDiscovery) registrar() {
@@ -102,10 +277,10 @@ func (sd *EtcdServiceDiscovery) registrar() {
 	ticker := new(time.Ticker)
 	if sd.registerInterval > time.Duration(0) {
 		ticker = time.NewTicker(sd.registerInterval)
+		defer ticker.Stop()
 	}
 
 	for {
-		bExit := false
 		select {
 		// Register self on interval
 		case <-ticker.C:
@@ -113,46 +288,44 @@ func (sd *EtcdServiceDiscovery) registrar() {
 				logger.Log(logger.ErrorLevel, "EtcdServiceDiscovery", "err", err)
 			}
 		case ch := <-sd.exit:
-			err := sd.registry.Deregister(sd.service)
-			ch <- err
-			bExit = true
-		}
-
-		if bExit {
-			break
+			ch <- sd.cache.Deregister(sd.Service())
+			return
 		}
 	}
 }
 
 func (sd *EtcdServiceDiscovery) watch() {
-	syncServersState := <-sd.syncServersRunning
-	for syncServersState {
-		syncServersState = <-sd.syncServersRunning
-	}
-
 	for {
 		res, err := sd.watcher.Next()
 		if err != nil {
-			logger.Log(logger.ErrorLevel, "EtcdServiceDiscovery", "Next", res)
+			if err == registry.ErrWatcherStopped {
+				return
+			}
+			logger.Log(logger.ErrorLevel, "EtcdServiceDiscovery", "Next", err)
+			continue
+		}
+		if res.Service == nil {
+			continue
 		}
 
-		logger.Log(logger.InfoLevel, "EtcdServiceDiscovery", "Next", res, "Service", res.Service)
+		sd.cache.Invalidate(res.Service.Name)
+		if slices.Contains(sd.watched, res.Service.Name) {
+			logger.Log(logger.InfoLevel, "EtcdServiceDiscovery", "action", res.Action, "service", res.Service.Name, "nodes", nodeIds(res.Service))
+		}
 	}
 }
 
+// SyncServers loads the nodes of the watched services into the cache.
 func (sd *EtcdServiceDiscovery) SyncServers() error {
-	sd.syncServersRunning <- true
-	defer func() {
-		sd.syncServersRunning <- false
-	}()
 	start := time.Now()
-
-	s, err := sd.registry.GetService(serviceName)
-	if err != nil {
-		return err
-	}
-	for _, service := range s {
-		logger.Log(logger.InfoLevel, "SyncServers", "value", service)
+	for _, name := range sd.watched {
+		services, err := sd.cache.GetService(name)
+		if err != nil && err != registry.ErrNotFound {
+			return err
+		}
+		for _, service := range services {
+			logger.Log(logger.InfoLevel, "SyncServers", "service", name, "version", service.Version, "nodes", nodeIds(service))
+		}
 	}
 
 	elapsed := time.Since(start)
@@ -160,17 +333,39 @@ func (sd *EtcdServiceDiscovery) SyncServers() error {
 	return nil
 }
 
-func (sd *EtcdServiceDiscovery) OnStart() {
+func nodeIds(service *registry.Service) []string {
+	ids := make([]string, 0, len(service.Nodes))
+	for _, node := range service.Nodes {
+		ids = append(ids, node.Id)
+	}
+	return ids
+}
+
+// OnStart registers once the actors are spawned, so that their opcodes are
+// published.
+func (sd *EtcdServiceDiscovery) OnStart() error {
 	if sd.running {
-		return
+		return nil
+	}
+	if err := sd.Register(); err != nil {
+		return err
 	}
 	sd.running = true
 
 	go sd.registrar()
-	go sd.watch()
+	return nil
 }
 
 func (sd *EtcdServiceDiscovery) OnCleanup() {
+	if sd.watcher != nil {
+		sd.watcher.Stop()
+	}
+	// e.g. the file registry following its file
+	defer func() {
+		if closer, ok := sd.registry.(interface{ Close() }); ok {
+			closer.Close()
+		}
+	}()
 	if !sd.running {
 		return
 	}
@@ -180,5 +375,5 @@ func (sd *EtcdServiceDiscovery) OnCleanup() {
 	ch := make(chan error)
 	sd.exit <- ch
 	err := <-ch
-	logger.Log(logger.ErrorLevel, "EtcdServiceDiscovery OnCleanup", "err", err)
+	logger.Log(logger.InfoLevel, "EtcdServiceDiscovery OnCleanup", "err", err)
 }
@@ -0,0 +1,237 @@
+package etcd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/file"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/memory"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+	"github.com/wuqunyong/file_storage/pkg/component/tcpserver"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+// recordingRegistry keeps the last registration of every node.
+type recordingRegistry struct {
+	registry.Registry
+	mu    sync.Mutex
+	nodes map[string]*registry.Service
+}
+
+func (r *recordingRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[s.Nodes[0].Id] = s
+	return nil
+}
+
+func (r *recordingRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.nodes, s.Nodes[0].Id)
+	return nil
+}
+
+func (r *recordingRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var services []*registry.Service
+	for _, s := range r.nodes {
+		if s.Name == name {
+			services = append(services, s)
+		}
+	}
+	if len(services) == 0 {
+		return nil, registry.ErrNotFound
+	}
+	return services, nil
+}
+
+func (r *recordingRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	return &stoppedWatcher{stop: make(chan struct{})}, nil
+}
+
+type stoppedWatcher struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+func (w *stoppedWatcher) Next() (*registry.Result, error) {
+	<-w.stop
+	return nil, registry.ErrWatcherStopped
+}
+
+func (w *stoppedWatcher) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+type echoActor struct {
+	*actor.Actor
+}
+
+func (a *echoActor) OnInit() error {
+	return a.Register(7, a.Echo)
+}
+
+func (a *echoActor) OnShutdown() {}
+
+func (a *echoActor) Echo(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	return nil
+}
+
+func TestRegisterEngineIdentity(t *testing.T) {
+	r := &recordingRegistry{nodes: make(map[string]*registry.Service)}
+	engine := actor.NewEngine(0, 3, 1001)
+	sd := NewEtcdServiceDiscovery(r, WithVersion("1.2.0"), WithAdvertiseHost("10.0.0.1"), WithWatch("engine.0.1", "engine.0.2"))
+	engine.MustAddComponent(sd)
+	engine.MustAddComponent(tcpserver.NewTCPServer(tcpserver.NewPBServerOption(), "127.0.0.1:0"))
+	engine.MustInit()
+	engine.MustSpawnActors(&echoActor{Actor: actor.NewActor("1", engine)})
+	require.NoError(t, engine.Start())
+
+	require.NotNil(t, engine.Resolver())
+	services, err := r.GetService("engine.0.3")
+	require.NoError(t, err)
+	require.Len(t, services, 1)
+	service := services[0]
+	assert.Equal(t, "1.2.0", service.Version)
+	node := service.Nodes[0]
+	assert.Equal(t, "engine.0.3.1001.server", node.Id)
+	assert.Equal(t, "127.0.0.1:0", node.Address)
+	assert.Equal(t, map[string]string{
+		MetadataVersion:    "1.2.0",
+		MetadataSubject:    "engine.0.3.1001.server",
+		MetadataOpcodes:    "7",
+		rpc.MetadataEngine: "engine.0.3.1001",
+		"tcp":              "127.0.0.1:0",
+	}, node.Metadata)
+	require.NoError(t, sd.HealthCheck(context.Background()))
+
+	nodes, err := engine.Resolver().Resolve(0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"engine.0.3.1001.server"}, nodes)
+
+	engine.Stop()
+	_, err = r.GetService("engine.0.3")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestPublishRPCEndpoint(t *testing.T) {
+	r := &recordingRegistry{nodes: make(map[string]*registry.Service)}
+	engine := actor.NewEngine(0, 3, 1004, "tcp://127.0.0.1:0")
+	seed := make([]byte, ed25519.SeedSize)
+	private := ed25519.NewKeyFromSeed(seed)
+	engine.SetAuthenticator(rpc.NewAuthenticator(rpc.NewEd25519Keyring("engine.0.3.1004", private, rpc.StaticPublicKeys{})))
+	engine.MustAddComponent(NewEtcdServiceDiscovery(r))
+	engine.MustInit()
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	services, err := r.GetService("engine.0.3")
+	require.NoError(t, err)
+	metadata := services[0].Nodes[0].Metadata
+	assert.Equal(t, "engine.0.3.1004", metadata[rpc.MetadataEngine])
+	require.NotEmpty(t, engine.TCPAddress())
+	assert.NotEqual(t, "127.0.0.1:0", engine.TCPAddress())
+	assert.Equal(t, engine.TCPAddress(), metadata[rpc.MetadataTCPAddress])
+	assert.Equal(t, base64.StdEncoding.EncodeToString(private.Public().(ed25519.PublicKey)), metadata[rpc.MetadataPublicKey])
+}
+
+func TestResolvePeerOverTCP(t *testing.T) {
+	r := memory.NewRegistry()
+	server := actor.NewEngine(0, 1, 1001, "tcp://127.0.0.1:0")
+	server.MustAddComponent(NewEtcdServiceDiscovery(r))
+	server.MustInit()
+	server.MustSpawnActors(&echoActor{Actor: actor.NewActor("1", server)})
+	require.NoError(t, server.Start())
+	defer server.Stop()
+
+	addr, err := rpc.NewRegistryResolver(r).ResolvePeer("engine.0.1.1001")
+	require.NoError(t, err)
+	assert.Equal(t, server.TCPAddress(), addr)
+
+	// the client only dials out, to the address the server registered
+	client := actor.NewEngine(0, 2, 1002, "tcp://")
+	client.MustAddComponent(NewEtcdServiceDiscovery(r))
+	client.MustInit()
+	caller := &echoActor{Actor: actor.NewActor("1", client)}
+	client.MustSpawnActors(caller)
+	require.NoError(t, client.Start())
+	defer client.Stop()
+
+	target := concepts.NewActorId("engine.0.1.1001.server", "1")
+	_, err = actor.SendRequest[common_msg.EchoResponse](caller, target, 7, &common_msg.EchoRequest{Value1: 1})
+	require.NoError(t, err)
+}
+
+func TestRegisterInMemoryRegistry(t *testing.T) {
+	r := memory.NewRegistry()
+	watcher, err := r.Watch(registry.WatchService("engine.0.3"))
+	require.NoError(t, err)
+	defer watcher.Stop()
+
+	engine := actor.NewEngine(0, 3, 1002)
+	engine.MustAddComponent(NewEtcdServiceDiscovery(r))
+	engine.MustInit()
+	require.NoError(t, engine.Start())
+
+	result, err := watcher.Next()
+	require.NoError(t, err)
+	assert.Equal(t, registry.Create.String(), result.Action)
+	node := result.Service.Nodes[0]
+	assert.Equal(t, "engine.0.3.1002.server", node.Id)
+	// without listener the node is reached through its subject
+	assert.Equal(t, "engine.0.3.1002.server", node.Address)
+
+	nodes, err := engine.Resolver().Resolve(0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"engine.0.3.1002.server"}, nodes)
+
+	engine.Stop()
+	result, err = watcher.Next()
+	require.NoError(t, err)
+	assert.Equal(t, registry.Delete.String(), result.Action)
+}
+
+func TestResolveFromFileRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "engine.0.1", "version": "1.0.0", "nodes": [
+		{"id": "engine.0.1.1001.server", "address": "10.0.0.1:9000"},
+		{"id": "engine.0.1.1002.server", "address": "10.0.0.2:9000"}]}]`), 0o644))
+	r, err := file.NewRegistry(path)
+	require.NoError(t, err)
+
+	engine := actor.NewEngine(0, 3, 1003)
+	engine.MustAddComponent(NewEtcdServiceDiscovery(r, WithWatch("engine.0.1")))
+	engine.MustInit()
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	nodes, err := engine.Resolver().Resolve(0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"engine.0.1.1001.server", "engine.0.1.1002.server"}, nodes)
+
+	// the own node lives in memory next to the ones of the file
+	nodes, err = engine.Resolver().Resolve(0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"engine.0.3.1003.server"}, nodes)
+}
+
+func TestAdvertise(t *testing.T) {
+	sd := NewEtcdServiceDiscovery(nil, WithAdvertiseHost("10.0.0.1"))
+	assert.Equal(t, "10.0.0.1:9000", sd.advertise(":9000"))
+	assert.Equal(t, "10.0.0.1:9000", sd.advertise("0.0.0.0:9000"))
+	assert.Equal(t, "192.168.1.2:9000", sd.advertise("192.168.1.2:9000"))
+	assert.Equal(t, "gateway:9000", sd.advertise("gateway:9000"))
+}
@@ -0,0 +1,48 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/wuqunyong/file_storage/pkg/dedup"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DedupStore persists dedup records, mongo removes them once expireAt passes.
+type DedupStore struct {
+	coll *mongo.Collection
+}
+
+func NewDedupStore(ctx context.Context, db *mongo.Database, collection string) (*DedupStore, error) {
+	coll := db.Collection(collection)
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expireAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s %s", err, "mongo create dedup index")
+	}
+	return &DedupStore{coll: coll}, nil
+}
+
+func (s *DedupStore) Load(ctx context.Context, key string) (*dedup.Record, error) {
+	cur := s.coll.FindOne(ctx, bson.M{"_id": key})
+	if err := cur.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s %s", err, "mongo find dedup record")
+	}
+	return DecodeOne[*dedup.Record](cur.Decode)
+}
+
+func (s *DedupStore) Save(ctx context.Context, record *dedup.Record) error {
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": record.Key}, record, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("%s %s", err, "mongo save dedup record")
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/wuqunyong/file_storage/pkg/metrics"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var commandDuration = metrics.NewHistogramVec("mongo_command_duration_seconds",
+	"Latency of the mongo commands, by command and result (ok or error).", nil, "command", "result")
+
+// commandMonitor observes the latency of every command sent to mongo.
+var commandMonitor = &event.CommandMonitor{
+	Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+		commandDuration.With(e.CommandName, "ok").Observe(e.Duration.Seconds())
+	},
+	Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+		commandDuration.With(e.CommandName, "error").Observe(e.Duration.Seconds())
+	},
+}
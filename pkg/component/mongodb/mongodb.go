@@ -2,8 +2,10 @@ package mongodb
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/dedup"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -12,6 +14,14 @@ type MongoComponent struct {
 	engine  concepts.IEngine
 	configs map[string]*Config
 	dbs     map[string][]*mongo.Database
+	dedup   *dedupTarget
+}
+
+// dedupTarget is where the records of a dedup cache are kept.
+type dedupTarget struct {
+	cache      *dedup.Cache
+	database   string
+	collection string
 }
 
 type DBHashFunc func([]*mongo.Database) int
@@ -28,8 +38,8 @@ func (component *MongoComponent) Name() string {
 	return ComponentName
 }
 
-func (component *MongoComponent) Priority() int32 {
-	return 1
+func (component *MongoComponent) Dependencies() []string {
+	return nil
 }
 
 func (component *MongoComponent) SetEngine(engine concepts.IEngine) {
@@ -52,17 +62,47 @@ func (component *MongoComponent) OnInit() error {
 		}
 		component.dbs[key] = clients
 	}
+
+	if component.dedup != nil {
+		store, err := NewDedupStore(component.ctx, component.GetDatabase(component.dedup.database), component.dedup.collection)
+		if err != nil {
+			return err
+		}
+		component.dedup.cache.SetStore(store)
+	}
 	return nil
 }
 
-func (component *MongoComponent) OnStart() {
+// EnableDedup keeps the records of cache in collection of database once it
+// is connected, the cache holds them in memory only until then.
+func (component *MongoComponent) EnableDedup(cache *dedup.Cache, database, collection string) error {
+	if _, ok := component.configs[database]; !ok {
+		return fmt.Errorf("unknown database:%s", database)
+	}
+	component.dedup = &dedupTarget{cache: cache, database: database, collection: collection}
+	return nil
+}
 
+func (component *MongoComponent) OnStart() error {
+	return nil
 }
 
 func (component *MongoComponent) OnCleanup() {
 
 }
 
+// HealthCheck pings every connection of every configured database.
+func (component *MongoComponent) HealthCheck(ctx context.Context) error {
+	for key, clients := range component.dbs {
+		for _, db := range clients {
+			if err := db.Client().Ping(ctx, nil); err != nil {
+				return fmt.Errorf("ping %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
 func (component *MongoComponent) GetDatabase(name string, funObj ...DBHashFunc) *mongo.Database {
 	clients, ok := component.dbs[name]
 	if !ok {
@@ -15,7 +15,7 @@ func NewMongoDB(ctx context.Context, config *Config) (*mongo.Database, error) {
 	if err := config.ValidateAndSetDefaults(); err != nil {
 		return nil, err
 	}
-	opts := options.Client().ApplyURI(config.Uri).SetMaxPoolSize(uint64(config.MaxPoolSize))
+	opts := options.Client().ApplyURI(config.Uri).SetMaxPoolSize(uint64(config.MaxPoolSize)).SetMonitor(commandMonitor)
 	var (
 		cli *mongo.Client
 		err error
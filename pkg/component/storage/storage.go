@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/storage/minio"
+)
+
+const ComponentName = "storage"
+
+type StorageComponent struct {
+	ctx    context.Context
+	engine concepts.IEngine
+	config minio.Config
+	minio  *minio.Minio
+}
+
+func NewStorageComponent(ctx context.Context, config minio.Config) *StorageComponent {
+	return &StorageComponent{
+		ctx:    ctx,
+		config: config,
+	}
+}
+
+func (component *StorageComponent) Name() string {
+	return ComponentName
+}
+
+func (component *StorageComponent) Dependencies() []string {
+	return nil
+}
+
+func (component *StorageComponent) SetEngine(engine concepts.IEngine) {
+	component.engine = engine
+}
+
+func (component *StorageComponent) GetEngine() concepts.IEngine {
+	return component.engine
+}
+
+func (component *StorageComponent) OnInit() error {
+	client, err := minio.NewMinio(component.ctx, component.config)
+	if err != nil {
+		return err
+	}
+	component.minio = client
+	return nil
+}
+
+func (component *StorageComponent) OnStart() error {
+	return nil
+}
+
+func (component *StorageComponent) OnCleanup() {
+
+}
+
+func (component *StorageComponent) GetMinio() *minio.Minio {
+	return component.minio
+}
+
+func (component *StorageComponent) HealthCheck(ctx context.Context) error {
+	if component.minio == nil {
+		return errors.New("minio not initialized")
+	}
+	return component.minio.HealthCheck(ctx)
+}
+
+func (component *StorageComponent) ValidateReload(section concepts.IConfigSection) error {
+	var config minio.Config
+	if err := section.Decode(&config); err != nil {
+		return err
+	}
+	if config.Endpoint != component.config.Endpoint || config.Bucket != component.config.Bucket {
+		return errors.New("endpoint and bucket can not be reloaded")
+	}
+	if config.AccessKeyID != component.config.AccessKeyID ||
+		config.SecretAccessKey != component.config.SecretAccessKey ||
+		config.SessionToken != component.config.SessionToken {
+		return errors.New("credentials can not be reloaded")
+	}
+	return nil
+}
+
+// OnReload applies a new bucket policy.
+func (component *StorageComponent) OnReload(section concepts.IConfigSection) error {
+	var config minio.Config
+	if err := section.Decode(&config); err != nil {
+		return err
+	}
+	if component.minio != nil && config.PublicRead != component.config.PublicRead {
+		if err := component.minio.SetPublicRead(component.ctx, config.PublicRead); err != nil {
+			return fmt.Errorf("set bucket policy of %s: %w", config.Bucket, err)
+		}
+	}
+	component.config = config
+	return nil
+}
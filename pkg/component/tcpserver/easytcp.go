@@ -2,9 +2,11 @@ package tcpserver
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/wuqunyong/file_storage/pkg/concepts"
 	"github.com/wuqunyong/file_storage/pkg/easytcp"
+	"github.com/wuqunyong/file_storage/pkg/gateway"
 	"github.com/wuqunyong/file_storage/pkg/logger"
 	"github.com/wuqunyong/file_storage/proto/common_msg"
 )
@@ -13,6 +15,7 @@ type TCPServer struct {
 	server  *easytcp.Server
 	engine  concepts.IEngine
 	address string
+	routes  []gateway.Route
 }
 
 func NewTCPServer(opt *easytcp.ServerOption, address string) *TCPServer {
@@ -27,8 +30,12 @@ func (s *TCPServer) Name() string {
 	return "tcpserver"
 }
 
-func (s *TCPServer) Priority() int32 {
-	return 1
+func (s *TCPServer) Dependencies() []string {
+	return nil
+}
+
+func (s *TCPServer) Listen() (protocol, address string) {
+	return "tcp", s.address
 }
 
 func (s *TCPServer) SetEngine(engine concepts.IEngine) {
@@ -39,7 +46,24 @@ func (s *TCPServer) GetEngine() concepts.IEngine {
 	return s.engine
 }
 
+// SetRoutes forwards the messages without a route of their own to the
+// backend services, see gateway.RouteTable.
+func (s *TCPServer) SetRoutes(routes []gateway.Route) {
+	s.routes = routes
+}
+
 func (s *TCPServer) OnInit() error {
+	if len(s.routes) > 0 {
+		routes, err := gateway.NewRouteTable(s.routes...)
+		if err != nil {
+			return err
+		}
+		mux := gateway.NewMultiplexer(s.engine, MultiplexerActor, routes)
+		if _, err := s.engine.SpawnActor(mux); err != nil {
+			return err
+		}
+		(&gatewayHooks{mux: mux}).install(s.server)
+	}
 	s.server.AddRoute(1001, func(c easytcp.Context) {
 		var reqData common_msg.AccountLoginRequest
 		err := c.Bind(&reqData)
@@ -68,17 +92,29 @@ func (s *TCPServer) OnInit() error {
 	return nil
 }
 
-func (s *TCPServer) OnStart() {
+// OnStart binds the address before returning, a port already in use fails
+// the start instead of panicking in the accept goroutine.
+func (s *TCPServer) OnStart() error {
+	lis, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+	lis = countingListener{lis}
+	s.server.Listener = lis
 	go func() {
-		var err error
-		if err = s.server.Run(s.address); err != nil && err != easytcp.ErrServerStopped {
-			panic(fmt.Sprintf("serve error:%s", err.Error()))
+		err := s.server.Serve(lis)
+		if err != nil && err != easytcp.ErrServerStopped {
+			logger.Log(logger.ErrorLevel, "tcpserver serve error", "err", err)
 		}
 		logger.Log(logger.InfoLevel, "Stopped tcpserver", "err", err)
 	}()
+	return nil
 }
 
 func (s *TCPServer) OnCleanup() {
+	if s.server.Listener == nil {
+		return
+	}
 	s.server.Stop()
 }
 
@@ -0,0 +1,83 @@
+package tcpserver
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/spf13/cast"
+	"github.com/wuqunyong/file_storage/pkg/easytcp"
+	"github.com/wuqunyong/file_storage/pkg/gateway"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+)
+
+// MultiplexerActor is the id of the actor forwarding the client messages.
+const MultiplexerActor = "gateway.tcp"
+
+var errSessionClosed = errors.New("session closed")
+
+// tcpSession writes the replies and pushes of the backend services. The
+// packet has no room for an error code, failed replies are only logged.
+type tcpSession struct {
+	easytcp.Session
+}
+
+func (s tcpSession) Deliver(message *rpc_msg.PRC_DeMultiplexer_Forward) error {
+	info := message.GetInfo()
+	if status := message.GetStatus(); status.GetCode() != 0 {
+		logger.Log(logger.WarnLevel, "tcpserver drop failed reply", "opcode", info.GetOpcode(), "code", status.GetCode(), "msg", status.GetMsg())
+		return nil
+	}
+	ctx := s.AllocateContext().SetResponseMessage(easytcp.NewMessage(int(info.GetOpcode()), message.GetBodyMsg()))
+	if !s.Send(ctx) {
+		return errSessionClosed
+	}
+	return nil
+}
+
+// gatewayHooks attaches the sessions of the server to mux and forwards the
+// messages without a route of their own.
+type gatewayHooks struct {
+	mux      *gateway.Multiplexer
+	sessions sync.Map // easytcp.Session -> gateway session id
+}
+
+func (g *gatewayHooks) install(server *easytcp.Server) {
+	onCreate, onClose := server.OnSessionCreate, server.OnSessionClose
+	server.OnSessionCreate = func(sess easytcp.Session) {
+		g.sessions.Store(sess, g.mux.Attach(tcpSession{sess}))
+		if onCreate != nil {
+			onCreate(sess)
+		}
+	}
+	server.OnSessionClose = func(sess easytcp.Session) {
+		if id, ok := g.sessions.LoadAndDelete(sess); ok {
+			g.mux.Detach(id.(uint64))
+		}
+		if onClose != nil {
+			onClose(sess)
+		}
+	}
+	server.NotFoundHandler(g.forward)
+}
+
+func (g *gatewayHooks) forward(c easytcp.Context) {
+	request := c.Request()
+	opcode, err := cast.ToUint32E(request.ID())
+	if err != nil {
+		logger.Log(logger.WarnLevel, "tcpserver invalid message id", "id", request.ID(), "err", err)
+		return
+	}
+	id, ok := g.sessions.Load(c.Session())
+	if !ok {
+		return
+	}
+	info := &rpc_msg.ClientMessageInfo{
+		SessionId:     id.(uint64),
+		Opcode:        opcode,
+		ConnetionType: gateway.ConnectionTCP,
+	}
+	if err := g.mux.Forward(nil, 0, info, request.Data()); err != nil {
+		logger.Log(logger.WarnLevel, "tcpserver forward", "opcode", opcode, "err", err)
+	}
+}
@@ -0,0 +1,51 @@
+package tcpserver
+
+import (
+	"net"
+	"sync"
+
+	"github.com/wuqunyong/file_storage/pkg/metrics"
+)
+
+var (
+	tcpSessions = metrics.NewGaugeVec("tcp_sessions", "Open tcp sessions.")
+	tcpBytes    = metrics.NewCounterVec("tcp_bytes_total", "Tcp bytes, by direction (in or out).", "direction")
+)
+
+// countingListener counts the sessions it accepted and their bytes.
+type countingListener struct {
+	net.Listener
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tcpSessions.With().Inc()
+	return &countingConn{Conn: conn}, nil
+}
+
+type countingConn struct {
+	net.Conn
+	once sync.Once
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	tcpBytes.With("in").Add(float64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	tcpBytes.With("out").Add(float64(n))
+	return n, err
+}
+
+func (c *countingConn) Close() error {
+	c.once.Do(func() {
+		tcpSessions.With().Dec()
+	})
+	return c.Conn.Close()
+}
@@ -8,12 +8,14 @@ import (
 type WSServer struct {
 	server *ws.WsServer
 	engine concepts.IEngine
+	config ws.Config
 }
 
 func NewWSServer(config ws.Config) *WSServer {
 	server := ws.NewWsServer(config, nil)
 	return &WSServer{
 		server: server,
+		config: config,
 	}
 }
 
@@ -21,8 +23,16 @@ func (s *WSServer) Name() string {
 	return "wsserver"
 }
 
-func (s *WSServer) Priority() int32 {
-	return 1
+func (s *WSServer) Dependencies() []string {
+	return nil
+}
+
+// Listen is the https port when a certificate is set, as the server does.
+func (s *WSServer) Listen() (protocol, address string) {
+	if s.config.ServerCertificate != "" && s.config.ServerPrivateKey != "" {
+		return "wss", s.config.HttpsPort
+	}
+	return "ws", s.config.HttpPort
 }
 
 func (s *WSServer) SetEngine(engine concepts.IEngine) {
@@ -35,11 +45,11 @@ func (s *WSServer) GetEngine() concepts.IEngine {
 }
 
 func (s *WSServer) OnInit() error {
-	return nil
+	return s.server.Init()
 }
 
-func (s *WSServer) OnStart() {
-	s.server.Run()
+func (s *WSServer) OnStart() error {
+	return s.server.Run()
 }
 
 func (s *WSServer) OnCleanup() {
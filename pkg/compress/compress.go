@@ -0,0 +1,235 @@
+// Package compress shrinks the args and result blobs of rpc messages. The
+// algorithm travels with every message, so a receiver can always tell how to
+// restore the payload.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/wuqunyong/file_storage/pkg/metrics"
+)
+
+// Algorithms, their values are part of the wire format.
+const (
+	None   uint32 = 0
+	Gzip   uint32 = 1
+	Snappy uint32 = 2
+	Zstd   uint32 = 3
+)
+
+const (
+	// DefaultThreshold is the payload size from which compression pays off.
+	DefaultThreshold = 4096
+	// MaxDecodedSize protects the receiver from payloads that inflate
+	// without bounds.
+	MaxDecodedSize = 64 << 20
+)
+
+var errTooLarge = fmt.Errorf("decompressed payload exceeds %d bytes", MaxDecodedSize)
+
+var names = map[uint32]string{
+	None:   "none",
+	Gzip:   "gzip",
+	Snappy: "snappy",
+	Zstd:   "zstd",
+}
+
+// Name returns the configuration name of algorithm.
+func Name(algorithm uint32) string {
+	if name, ok := names[algorithm]; ok {
+		return name
+	}
+	return fmt.Sprintf("compression(%d)", algorithm)
+}
+
+// Parse returns the algorithm named name, "" meaning None.
+func Parse(name string) (uint32, error) {
+	if name == "" {
+		return None, nil
+	}
+	for algorithm, value := range names {
+		if strings.EqualFold(value, name) {
+			return algorithm, nil
+		}
+	}
+	return None, fmt.Errorf("unknown compression:%q", name)
+}
+
+var (
+	algorithm atomic.Uint32
+	threshold atomic.Int64
+)
+
+func init() {
+	threshold.Store(DefaultThreshold)
+	metrics.Default.Register(metrics.CollectorFunc(collect))
+}
+
+// Configure sets the algorithm used for the payloads of at least size
+// bytes sent by this process. None, the default, sends everything as is.
+func Configure(alg uint32, size int) error {
+	if _, ok := names[alg]; !ok {
+		return fmt.Errorf("unknown compression:%d", alg)
+	}
+	if size <= 0 {
+		size = DefaultThreshold
+	}
+	algorithm.Store(alg)
+	threshold.Store(int64(size))
+	return nil
+}
+
+// Algorithm is the configured algorithm, also the one a client accepts for
+// its responses.
+func Algorithm() uint32 {
+	return algorithm.Load()
+}
+
+// Encode compresses data with the configured algorithm, see EncodeWith.
+func Encode(data []byte) ([]byte, uint32, error) {
+	return EncodeWith(Algorithm(), data)
+}
+
+// EncodeWith compresses data with alg when it reaches the threshold and
+// actually gets smaller, it returns the algorithm the result is in.
+func EncodeWith(alg uint32, data []byte) ([]byte, uint32, error) {
+	if alg == None || int64(len(data)) < threshold.Load() {
+		return data, None, nil
+	}
+	var (
+		out []byte
+		err error
+	)
+	switch alg {
+	case Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err = w.Write(data); err == nil {
+			err = w.Close()
+		}
+		out = buf.Bytes()
+	case Snappy:
+		out = snappy.Encode(nil, data)
+	case Zstd:
+		out = getZstdEncoder().EncodeAll(data, nil)
+	default:
+		err = fmt.Errorf("unknown compression:%d", alg)
+	}
+	if err != nil {
+		return nil, None, err
+	}
+
+	if len(out) >= len(data) {
+		stats.skipped.Add(1)
+		return data, None, nil
+	}
+	stats.messages.Add(1)
+	stats.rawBytes.Add(uint64(len(data)))
+	stats.compressedBytes.Add(uint64(len(out)))
+	return out, alg, nil
+}
+
+// Decode restores data compressed with alg.
+func Decode(alg uint32, data []byte) ([]byte, error) {
+	switch alg {
+	case None:
+		return data, nil
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		out, err := io.ReadAll(io.LimitReader(r, MaxDecodedSize+1))
+		if err == nil && len(out) > MaxDecodedSize {
+			return nil, errTooLarge
+		}
+		return out, err
+	case Snappy:
+		if n, err := snappy.DecodedLen(data); err != nil || n > MaxDecodedSize {
+			if err == nil {
+				err = errTooLarge
+			}
+			return nil, err
+		}
+		return snappy.Decode(nil, data)
+	case Zstd:
+		return getZstdDecoder().DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unknown compression:%d", alg)
+	}
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+// the zstd coders are safe for concurrent EncodeAll and DecodeAll calls
+func initZstd() {
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDecodedSize))
+}
+
+func getZstdEncoder() *zstd.Encoder {
+	zstdOnce.Do(initZstd)
+	return zstdEncoder
+}
+
+func getZstdDecoder() *zstd.Decoder {
+	zstdOnce.Do(initZstd)
+	return zstdDecoder
+}
+
+// Stats counts the payloads compressed by this process.
+type Stats struct {
+	Messages        uint64
+	Skipped         uint64
+	RawBytes        uint64
+	CompressedBytes uint64
+}
+
+// Ratio is the compressed size relative to the raw size, 1 when nothing
+// was compressed.
+func (s Stats) Ratio() float64 {
+	if s.RawBytes == 0 {
+		return 1
+	}
+	return float64(s.CompressedBytes) / float64(s.RawBytes)
+}
+
+var stats struct {
+	messages        atomic.Uint64
+	skipped         atomic.Uint64
+	rawBytes        atomic.Uint64
+	compressedBytes atomic.Uint64
+}
+
+func GetStats() Stats {
+	return Stats{
+		Messages:        stats.messages.Load(),
+		Skipped:         stats.skipped.Load(),
+		RawBytes:        stats.rawBytes.Load(),
+		CompressedBytes: stats.compressedBytes.Load(),
+	}
+}
+
+func collect(emit func(metrics.Family)) {
+	s := GetStats()
+	counter := func(name, help string, value uint64) {
+		emit(metrics.Family{Name: name, Help: help, Type: metrics.CounterType, Samples: []metrics.Sample{{Value: float64(value)}}})
+	}
+	counter("compress_messages_total", "Payloads sent compressed.", s.Messages)
+	counter("compress_skipped_total", "Payloads sent as is because compressing did not shrink them.", s.Skipped)
+	counter("compress_raw_bytes_total", "Size of the compressed payloads before compression.", s.RawBytes)
+	counter("compress_compressed_bytes_total", "Size of the compressed payloads after compression.", s.CompressedBytes)
+}
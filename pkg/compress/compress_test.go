@@ -0,0 +1,63 @@
+package compress
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("file manifest entry;"), 1000)
+	for _, algorithm := range []uint32{Gzip, Snappy, Zstd} {
+		out, used, err := EncodeWith(algorithm, data)
+		require.NoError(t, err, Name(algorithm))
+		assert.Equal(t, algorithm, used)
+		assert.Less(t, len(out), len(data))
+
+		restored, err := Decode(used, out)
+		require.NoError(t, err, Name(algorithm))
+		assert.Equal(t, data, restored)
+	}
+}
+
+func TestThresholdAndStats(t *testing.T) {
+	require.NoError(t, Configure(Snappy, 100))
+	t.Cleanup(func() { Configure(None, 0) })
+
+	out, used, err := Encode([]byte("small"))
+	require.NoError(t, err)
+	assert.Equal(t, None, used)
+	assert.Equal(t, []byte("small"), out)
+
+	// random bytes do not shrink, they are sent as is
+	random := make([]byte, 1024)
+	rand.Read(random)
+	before := GetStats()
+	out, used, err = Encode(random)
+	require.NoError(t, err)
+	assert.Equal(t, None, used)
+	assert.Equal(t, random, out)
+	assert.Equal(t, before.Skipped+1, GetStats().Skipped)
+
+	_, used, err = Encode(bytes.Repeat([]byte{'a'}, 1024))
+	require.NoError(t, err)
+	assert.Equal(t, Snappy, used)
+	stats := GetStats()
+	assert.Equal(t, before.Messages+1, stats.Messages)
+	assert.Less(t, stats.Ratio(), 1.0)
+}
+
+func TestParse(t *testing.T) {
+	algorithm, err := Parse("ZSTD")
+	require.NoError(t, err)
+	assert.Equal(t, Zstd, algorithm)
+	algorithm, err = Parse("")
+	require.NoError(t, err)
+	assert.Equal(t, None, algorithm)
+	_, err = Parse("lz4")
+	assert.Error(t, err)
+	assert.Error(t, Configure(42, 0))
+}
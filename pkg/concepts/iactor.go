@@ -23,8 +23,9 @@ type IActor interface {
 	Shutdown()
 
 	GetTimerQueue() *tick.TimerQueue
-	Request(target *ActorId, opcode uint32, args any, opts ...context.Context) IMsgReq
+	Request(target *ActorId, opcode uint32, args any, opts ...RequestOption) IMsgReq
 	PostTask(funObj func()) error
+	PostTaskContext(ctx context.Context, funObj func()) error
 	Send(request IMsgReq) error
 	IsRoot() bool
 	Codec() encoders.IEncoder
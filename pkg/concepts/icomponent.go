@@ -2,10 +2,34 @@ package concepts
 
 type IComponent interface {
 	Name() string
-	Priority() int32
+	// Dependencies names the components that must be initialized before
+	// and cleaned up after this one.
+	Dependencies() []string
 	SetEngine(engine IEngine)
 	GetEngine() IEngine
 	OnInit() error
-	OnStart()
+	OnStart() error
 	OnCleanup()
 }
+
+type IConfigSection interface {
+	Decode(v any) error
+}
+
+// IReloadable is implemented by components whose configuration section can
+// change without a restart. ValidateReload is called on every changed
+// component before any OnReload, so one invalid section rejects the reload.
+// OnReload fails when the section could not be applied, e.g. its backend
+// refused it, the component must then keep its previous configuration.
+type IReloadable interface {
+	ValidateReload(section IConfigSection) error
+	OnReload(section IConfigSection) error
+}
+
+// IListener is implemented by components accepting client connections,
+// service discovery publishes where.
+type IListener interface {
+	// Listen returns the protocol, e.g. tcp or ws, and the address listened
+	// on, host:port or :port.
+	Listen() (protocol, address string)
+}
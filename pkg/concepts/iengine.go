@@ -1,5 +1,7 @@
 package concepts
 
+import "context"
+
 type IEngine interface {
 	Request(request IMsgReq) error
 	GetAddress() string
@@ -14,5 +16,7 @@ type IEngine interface {
 	HasComponent(name string) bool
 	GetComponent(name string) IComponent
 
+	CheckHealth(ctx context.Context) *HealthReport
+
 	WaitForShutdown()
 }
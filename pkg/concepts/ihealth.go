@@ -0,0 +1,25 @@
+package concepts
+
+import "context"
+
+// IHealthChecker is implemented by components that depend on an external
+// service. HealthCheck must give up once ctx is done.
+type IHealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+type HealthStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency"`
+}
+
+// HealthReport aggregates the checks of the engine and its components.
+// Ready is only true while the engine is running and every check passes.
+type HealthReport struct {
+	State   string         `json:"state"`
+	Healthy bool           `json:"healthy"`
+	Ready   bool           `json:"ready"`
+	Checks  []HealthStatus `json:"checks"`
+}
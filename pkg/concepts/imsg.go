@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/wuqunyong/file_storage/pkg/constants"
 )
@@ -48,6 +49,58 @@ func GenClientAddress(realm, kind, id uint32) string {
 	return sAddress
 }
 
+// GenEngineName names the engine of realm.kind.id regardless of its role,
+// engine.r.k.i, as published in the service registry.
+func GenEngineName(realm, kind, id uint32) string {
+	return fmt.Sprintf("engine.%d.%d.%d", realm, kind, id)
+}
+
+// GenServiceName is the name the nodes of realm.kind register under in the
+// service registry, engine.r.k.
+func GenServiceName(realm, kind uint32) string {
+	return fmt.Sprintf("engine.%d.%d", realm, kind)
+}
+
+// AnyNode ends the service level address of a kind, requests sent to it
+// are load balanced across the running nodes of that kind.
+const AnyNode = "any"
+
+// GenAnyAddress is the address of any node of realm.kind, engine.r.k.any.
+func GenAnyAddress(realm, kind uint32) string {
+	return fmt.Sprintf("engine.%d.%d.%s", realm, kind, AnyNode)
+}
+
+// NewAnyActorId addresses actor id on whichever node of realm.kind gets the
+// request.
+func NewAnyActorId(realm, kind uint32, id string) *ActorId {
+	return NewActorId(GenAnyAddress(realm, kind), id)
+}
+
+func (actorId *ActorId) IsAny() bool {
+	return strings.HasSuffix(actorId.Address, "."+AnyNode)
+}
+
+func DecodeAnyAddress(address string) (realm, kind uint32, err error) {
+	r := strings.Split(address, ".")
+	if len(r) != 4 || r[0] != "engine" || r[3] != AnyNode {
+		err = constants.ErrInvalidAddress
+		return
+	}
+
+	value, err := strconv.ParseUint(r[1], 10, 32)
+	if err != nil {
+		return
+	}
+	realm = uint32(value)
+
+	value, err = strconv.ParseUint(r[2], 10, 32)
+	if err != nil {
+		return
+	}
+	kind = uint32(value)
+	return
+}
+
 func DecodeAddress(address string) (realm, kind, id uint32, err error) {
 	r := strings.Split(address, ".")
 	for _, s := range r {
@@ -91,7 +144,13 @@ type IMsgReq interface {
 	SetRemote(value bool) error
 	Send(resp IMsgResp)
 	SetSeqId(value uint64)
+	GetSeqId() uint64
+	GetTimeout() time.Duration
+	SetRPCClient(client IRPCClient)
+	IsRequiredReply() bool
+	IsDurable() bool
 	HandleResponse(resp IMsgResp)
+	Fail(seqId uint64, err error)
 }
 
 type IMsgResp interface {
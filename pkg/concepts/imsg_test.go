@@ -0,0 +1,22 @@
+package concepts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnyAddress(t *testing.T) {
+	actorId := NewAnyActorId(1, 4, "player")
+	assert.Equal(t, "engine.1.4.any", actorId.Address)
+	assert.True(t, actorId.IsAny())
+	assert.False(t, NewActorId(GenServerAddress(1, 4, 1), "player").IsAny())
+
+	realm, kind, err := DecodeAnyAddress(actorId.Address)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), realm)
+	assert.Equal(t, uint32(4), kind)
+
+	_, _, err = DecodeAnyAddress(GenServerAddress(1, 4, 1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package concepts
+
+import (
+	"context"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/encoders"
+)
+
+// RetryPolicy controls how a remote request is resent after a timeout or
+// after a response carrying one of RetryableCodes. Backoff doubles on every
+// attempt and is capped by MaxBackoff when it is set.
+type RetryPolicy struct {
+	Count          int
+	Backoff        time.Duration
+	MaxBackoff     time.Duration
+	RetryableCodes []uint32
+}
+
+func (p *RetryPolicy) IsRetryableCode(code uint32) bool {
+	for _, v := range p.RetryableCodes {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) GetBackoff(attempt int) time.Duration {
+	backoff := p.Backoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+type RequestOptions struct {
+	Ctx            context.Context
+	Timeout        time.Duration // per attempt
+	Retry          RetryPolicy
+	Priority       int32
+	IdempotencyKey string
+	Codec          encoders.IEncoder
+	RequiredReply  bool
+	Durable        bool
+	HashKey        string
+}
+
+type RequestOption func(*RequestOptions)
+
+func NewRequestOptions(opts ...RequestOption) *RequestOptions {
+	options := &RequestOptions{
+		RequiredReply: true,
+	}
+
+	for _, o := range opts {
+		o(options)
+	}
+
+	return options
+}
+
+// WithContext sets the parent context, its deadline bounds every attempt.
+func WithContext(ctx context.Context) RequestOption {
+	return func(o *RequestOptions) {
+		o.Ctx = ctx
+	}
+}
+
+// WithTimeout sets how long a single attempt waits for its reply.
+func WithTimeout(t time.Duration) RequestOption {
+	return func(o *RequestOptions) {
+		o.Timeout = t
+	}
+}
+
+// WithRetry resends a remote request up to count times.
+func WithRetry(count int, backoff time.Duration, codes ...uint32) RequestOption {
+	return func(o *RequestOptions) {
+		o.Retry.Count = count
+		o.Retry.Backoff = backoff
+		o.Retry.RetryableCodes = codes
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) RequestOption {
+	return func(o *RequestOptions) {
+		o.Retry = policy
+	}
+}
+
+// WithPriority lets the target inbox handle the request before lower ones.
+func WithPriority(priority int32) RequestOption {
+	return func(o *RequestOptions) {
+		o.Priority = priority
+	}
+}
+
+func WithIdempotencyKey(key string) RequestOption {
+	return func(o *RequestOptions) {
+		o.IdempotencyKey = key
+	}
+}
+
+// WithCodec overrides the actor codec used to encode the args.
+func WithCodec(codec encoders.IEncoder) RequestOption {
+	return func(o *RequestOptions) {
+		o.Codec = codec
+	}
+}
+
+// WithoutReply sends the request fire-and-forget.
+func WithoutReply() RequestOption {
+	return func(o *RequestOptions) {
+		o.RequiredReply = false
+	}
+}
+
+// WithDurable delivers a notify at least once through the durable stream of
+// the target node instead of fire-and-forget.
+func WithDurable() RequestOption {
+	return func(o *RequestOptions) {
+		o.Durable = true
+	}
+}
+
+// WithHashKey picks the node of a consistent hash balanced request, the
+// requests with the same key go to the same node while it is alive.
+func WithHashKey(key string) RequestOption {
+	return func(o *RequestOptions) {
+		o.HashKey = key
+	}
+}
@@ -0,0 +1,41 @@
+package concepts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wuqunyong/file_storage/pkg/encoders"
+)
+
+func TestNewRequestOptions(t *testing.T) {
+	opts := NewRequestOptions()
+	assert.True(t, opts.RequiredReply)
+	assert.Nil(t, opts.Codec)
+
+	codec := encoders.NewJsonEncoder()
+	opts = NewRequestOptions(
+		WithTimeout(time.Second),
+		WithRetry(3, 10*time.Millisecond, 100, 109),
+		WithPriority(5),
+		WithIdempotencyKey("upload-1"),
+		WithCodec(codec),
+		WithoutReply(),
+	)
+	assert.Equal(t, time.Second, opts.Timeout)
+	assert.Equal(t, 3, opts.Retry.Count)
+	assert.True(t, opts.Retry.IsRetryableCode(109))
+	assert.False(t, opts.Retry.IsRetryableCode(1))
+	assert.Equal(t, int32(5), opts.Priority)
+	assert.Equal(t, "upload-1", opts.IdempotencyKey)
+	assert.Equal(t, codec, opts.Codec)
+	assert.False(t, opts.RequiredReply)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{Count: 5, Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, policy.GetBackoff(1))
+	assert.Equal(t, 20*time.Millisecond, policy.GetBackoff(2))
+	assert.Equal(t, 40*time.Millisecond, policy.GetBackoff(3))
+	assert.Equal(t, 50*time.Millisecond, policy.GetBackoff(4))
+}
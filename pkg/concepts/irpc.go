@@ -14,6 +14,6 @@ type IRPCServer interface {
 	Init() error
 	Run()
 	HandleRequest(request IMsgReq) error
-	SendResponse(subj string, response IMsgResp) error
+	SendResponse(request IMsgReq, response IMsgResp) error
 	Stop()
 }
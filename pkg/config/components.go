@@ -0,0 +1,181 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/etcd"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/file"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+	etcdcomp "github.com/wuqunyong/file_storage/pkg/component/etcd"
+	"github.com/wuqunyong/file_storage/pkg/component/mongodb"
+	"github.com/wuqunyong/file_storage/pkg/component/storage"
+	"github.com/wuqunyong/file_storage/pkg/component/tcpserver"
+	"github.com/wuqunyong/file_storage/pkg/component/wsserver"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/gateway"
+	"github.com/wuqunyong/file_storage/pkg/storage/minio"
+	"github.com/wuqunyong/file_storage/pkg/ws"
+)
+
+type MongoConfig struct {
+	Databases map[string]*mongodb.Config `json:"databases"`
+}
+
+type TCPServerConfig struct {
+	Address string          `json:"address"`
+	Routes  []gateway.Route `json:"routes"`
+}
+
+type EtcdConfig struct {
+	Addrs    []string `json:"addrs"`
+	Timeout  Duration `json:"timeout"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	// Version is registered with the engine, etcd.DefaultVersion if empty.
+	Version string `json:"version"`
+	// AdvertiseHost replaces the host of listeners bound to all interfaces.
+	AdvertiseHost string `json:"advertiseHost"`
+	// Watch names the services to load and follow, e.g. engine.0.2.
+	Watch []string `json:"watch"`
+	// File replaces etcd by a static JSON or YAML list of services for
+	// small installs, addrs are then ignored.
+	File string `json:"file"`
+}
+
+// Duration accepts both "5s" style strings and nanoseconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return errors.New("invalid duration")
+	}
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func init() {
+	RegisterFactory(mongodb.ComponentName, newMongoComponent)
+	RegisterFactory("tcpserver", newTCPServer)
+	RegisterFactory("wsserver", newWSServer)
+	RegisterFactory("etcd", newEtcdServiceDiscovery)
+	RegisterFactory(storage.ComponentName, newStorageComponent)
+}
+
+func newMongoComponent(ctx context.Context, section Section) (concepts.IComponent, error) {
+	var cfg MongoConfig
+	if err := section.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Databases) == 0 {
+		return nil, errors.New("databases is required")
+	}
+	for _, db := range cfg.Databases {
+		if err := db.ValidateAndSetDefaults(); err != nil {
+			return nil, err
+		}
+		if db.ConnectNum == 0 {
+			db.ConnectNum = 1
+		}
+	}
+	return mongodb.NewMongoComponent(ctx, cfg.Databases), nil
+}
+
+func newTCPServer(ctx context.Context, section Section) (concepts.IComponent, error) {
+	var cfg TCPServerConfig
+	if err := section.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Address == "" {
+		return nil, errors.New("address is required")
+	}
+	if _, err := gateway.NewRouteTable(cfg.Routes...); err != nil {
+		return nil, err
+	}
+	server := tcpserver.NewTCPServer(tcpserver.NewPBServerOption(), cfg.Address)
+	server.SetRoutes(cfg.Routes)
+	return server, nil
+}
+
+func newWSServer(ctx context.Context, section Section) (concepts.IComponent, error) {
+	var cfg ws.Config
+	if err := section.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.HttpPort == "" && cfg.HttpsPort == "" {
+		return nil, errors.New("httpPort or httpsPort is required")
+	}
+	if (cfg.ServerCertificate == "") != (cfg.ServerPrivateKey == "") {
+		return nil, errors.New("serverCertificate and serverPrivateKey must be set together")
+	}
+	if _, err := gateway.NewRouteTable(cfg.Routes...); err != nil {
+		return nil, err
+	}
+	return wsserver.NewWSServer(cfg), nil
+}
+
+func newEtcdServiceDiscovery(ctx context.Context, section Section) (concepts.IComponent, error) {
+	var cfg EtcdConfig
+	if err := section.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Addrs) == 0 && cfg.File == "" {
+		return nil, errors.New("addrs or file is required")
+	}
+
+	var r registry.Registry
+	if cfg.File != "" {
+		fileRegistry, err := file.NewRegistry(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		r = fileRegistry
+	} else {
+		opts := []registry.Option{registry.Addrs(cfg.Addrs...)}
+		if cfg.Timeout > 0 {
+			opts = append(opts, registry.Timeout(time.Duration(cfg.Timeout)))
+		}
+		if cfg.Username != "" {
+			opts = append(opts, etcd.Auth(cfg.Username, cfg.Password))
+		}
+		r = etcd.NewRegistry(opts...)
+	}
+	var compOpts []etcdcomp.Option
+	if cfg.Version != "" {
+		compOpts = append(compOpts, etcdcomp.WithVersion(cfg.Version))
+	}
+	if cfg.AdvertiseHost != "" {
+		compOpts = append(compOpts, etcdcomp.WithAdvertiseHost(cfg.AdvertiseHost))
+	}
+	compOpts = append(compOpts, etcdcomp.WithWatch(cfg.Watch...))
+	return etcdcomp.NewEtcdServiceDiscovery(r, compOpts...), nil
+}
+
+func newStorageComponent(ctx context.Context, section Section) (concepts.IComponent, error) {
+	var cfg minio.Config
+	if err := section.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("endpoint and bucket are required")
+	}
+	return storage.NewStorageComponent(ctx, cfg), nil
+}
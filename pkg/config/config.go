@@ -0,0 +1,561 @@
+// Package config loads the node configuration from a YAML, TOML or JSON file,
+// applies environment overrides and builds the engine with its components.
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/cluster"
+	"github.com/wuqunyong/file_storage/pkg/compress"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/dedup"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/pkg/trace"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to the upper-cased key path of an override, e.g.
+// FILE_STORAGE_ENGINE_NATS or FILE_STORAGE_COMPONENTS_TCPSERVER_ADDRESS.
+const EnvPrefix = "FILE_STORAGE"
+
+type Config struct {
+	Engine     EngineConfig       `json:"engine"`
+	Log        LogConfig          `json:"log"`
+	Trace      TraceConfig        `json:"trace"`
+	Tap        TapConfig          `json:"tap"`
+	RPC        RPCConfig          `json:"rpc"`
+	Components map[string]Section `json:"components"`
+}
+
+// TraceConfig appends the spans of the sampled traces, one JSON object per
+// line, to file. Without a file the trace context is only propagated.
+type TraceConfig struct {
+	File string `json:"file"`
+}
+
+// TapConfig records the rpc envelopes of the node to file for the replay
+// command, rotated past maxSize megabytes. With opcodes only these requests
+// and their responses are recorded.
+type TapConfig struct {
+	File       string   `json:"file"`
+	Opcodes    []uint32 `json:"opcodes"`
+	MaxSize    int      `json:"maxSize"`
+	MaxBackups int      `json:"maxBackups"`
+}
+
+type RPCConfig struct {
+	Durable DurableConfig `json:"durable"`
+}
+
+// DurableConfig keeps the notifies sent with concepts.WithDurable in stream,
+// a JetStream stream of the engine nats server, until they are handled. A
+// failed notify is delivered again after nakDelay, doubled on every attempt,
+// and moved to deadLetter after maxDeliver deliveries.
+type DurableConfig struct {
+	Enabled    bool     `json:"enabled"`
+	Stream     string   `json:"stream"`
+	MaxDeliver int      `json:"maxDeliver"`
+	NakDelay   Duration `json:"nakDelay"`
+	DeadLetter string   `json:"deadLetter"`
+}
+
+// LogConfig is the only part of the node itself that can be reloaded.
+type LogConfig struct {
+	Level string `json:"level"`
+}
+
+type EngineConfig struct {
+	Realm       uint32            `json:"realm"`
+	Kind        uint32            `json:"kind"`
+	Id          uint32            `json:"id"`
+	Nats        string            `json:"nats"`
+	Compression CompressionConfig `json:"compression"`
+	NatsAuth    NatsAuthConfig    `json:"natsAuth"`
+	Auth        AuthConfig        `json:"auth"`
+	Dedup       DedupConfig       `json:"dedup"`
+}
+
+// DedupConfig replays the response of a request resent with the same
+// idempotency key instead of running it again. With database set the records
+// are also kept in that database of the mongodb component, in collection, for
+// windows longer than capacity holds. At most maxWaiters duplicates wait on
+// a request still running.
+type DedupConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Capacity     int      `json:"capacity"`
+	TTL          Duration `json:"ttl"`
+	MaxWaiters   int      `json:"maxWaiters"`
+	StoreTimeout Duration `json:"storeTimeout"`
+	Database     string   `json:"database"`
+	Collection   string   `json:"collection"`
+}
+
+// DefaultDedupCollection holds the dedup records when no collection is set.
+const DefaultDedupCollection = "dedup"
+
+// CompressionConfig picks the algorithm for the rpc payloads of at least
+// threshold bytes: none, gzip, snappy or zstd.
+type CompressionConfig struct {
+	Algorithm string `json:"algorithm"`
+	Threshold int    `json:"threshold"`
+}
+
+// NatsAuthConfig holds the credentials of the nats connections, see
+// cluster.NatsCredentials.
+type NatsAuthConfig struct {
+	User         string `json:"user"`
+	Password     string `json:"password"`
+	Token        string `json:"token"`
+	NkeySeedFile string `json:"nkeySeedFile"`
+	CredsFile    string `json:"credsFile"`
+	CAFile       string `json:"caFile"`
+	CertFile     string `json:"certFile"`
+	KeyFile      string `json:"keyFile"`
+}
+
+// AuthConfig signs the rpc messages between nodes. With mode hmac the keys
+// are shared secrets, with mode ed25519 privateKey is the base64 seed of this
+// node and publicKeys the base64 keys of the others, all by key id. With
+// publicKeysFrom registry the keys of the others are the ones they publish in
+// the service registry of the etcd component instead, keyId then being the
+// engine name engine.realm.kind.id.
+type AuthConfig struct {
+	Mode           string            `json:"mode"`
+	KeyId          string            `json:"keyId"`
+	Keys           map[string]string `json:"keys"`
+	PrivateKey     string            `json:"privateKey"`
+	PublicKeys     map[string]string `json:"publicKeys"`
+	PublicKeysFrom string            `json:"publicKeysFrom"`
+	Window         Duration          `json:"window"`
+	AllowUnsigned  bool              `json:"allowUnsigned"`
+}
+
+// PublicKeysFromRegistry selects the ed25519 public keys published in the
+// service registry.
+const PublicKeysFromRegistry = "registry"
+
+// Section is the raw configuration of one component.
+type Section json.RawMessage
+
+func (s Section) Decode(v any) error {
+	if len(s) == 0 {
+		return nil
+	}
+	return json.Unmarshal(s, v)
+}
+
+func (s Section) MarshalJSON() ([]byte, error) {
+	if len(s) == 0 {
+		return []byte("null"), nil
+	}
+	return s, nil
+}
+
+func (s *Section) UnmarshalJSON(data []byte) error {
+	*s = append((*s)[:0], data...)
+	return nil
+}
+
+// Load reads path, picking the format from its extension.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, filepath.Ext(path))
+}
+
+// Parse decodes data in the given format (".yaml", ".yml", ".toml" or
+// ".json"), applies the environment overrides and validates the result.
+func Parse(data []byte, format string) (*Config, error) {
+	tree := map[string]any{}
+	var err error
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &tree)
+	case "toml":
+		err = toml.Unmarshal(data, &tree)
+	case "json":
+		err = json.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config format:%q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config err:%w", err)
+	}
+
+	applyEnv(tree, reflect.TypeOf(Config{}), []string{EnvPrefix})
+
+	normalized, err := json.Marshal(tree)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(normalized, cfg); err != nil {
+		return nil, fmt.Errorf("decode config err:%w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	if err := c.Engine.Validate(); err != nil {
+		return err
+	}
+	if err := c.Log.Validate(); err != nil {
+		return err
+	}
+	if err := c.RPC.Durable.Validate(c.Engine.Nats); err != nil {
+		return err
+	}
+	for name := range c.Components {
+		if _, ok := getFactory(name); !ok {
+			return fmt.Errorf("components.%s: unknown component", name)
+		}
+	}
+	return nil
+}
+
+func (c *EngineConfig) Validate() error {
+	if c.Kind == 0 {
+		return errors.New("engine.kind is required")
+	}
+	if c.Id == 0 {
+		return errors.New("engine.id is required")
+	}
+	if c.Nats != "" {
+		u, err := url.Parse(c.Nats)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("engine.nats: invalid url %q", c.Nats)
+		}
+	}
+	if _, err := compress.Parse(c.Compression.Algorithm); err != nil {
+		return fmt.Errorf("engine.compression.algorithm: %w", err)
+	}
+	if c.Compression.Threshold < 0 {
+		return errors.New("engine.compression.threshold must not be negative")
+	}
+	if _, err := c.Auth.Authenticator(); err != nil {
+		return fmt.Errorf("engine.auth: %w", err)
+	}
+	if c.Dedup.Capacity < 0 {
+		return errors.New("engine.dedup.capacity must not be negative")
+	}
+	if c.Dedup.MaxWaiters < 0 {
+		return errors.New("engine.dedup.maxWaiters must not be negative")
+	}
+	if c.Dedup.Database != "" && !c.Dedup.Enabled {
+		return errors.New("engine.dedup.database needs enabled")
+	}
+	if c.Auth.PublicKeysFrom == PublicKeysFromRegistry {
+		if engine := concepts.GenEngineName(c.Realm, c.Kind, c.Id); c.Auth.KeyId != engine {
+			return fmt.Errorf("engine.auth.keyId must be %s to be found in the registry", engine)
+		}
+	}
+	return nil
+}
+
+func (c *DurableConfig) Validate(nats string) error {
+	if !c.Enabled {
+		if c.Stream != "" || c.MaxDeliver != 0 || c.NakDelay != 0 || c.DeadLetter != "" {
+			return errors.New("rpc.durable needs enabled")
+		}
+		return nil
+	}
+	if !strings.HasPrefix(nats, "nats://") {
+		return errors.New("rpc.durable needs a nats:// engine.nats")
+	}
+	if strings.ContainsAny(c.Stream, " \t.*>/") {
+		return fmt.Errorf("rpc.durable.stream: invalid name %q", c.Stream)
+	}
+	if c.MaxDeliver < 0 {
+		return errors.New("rpc.durable.maxDeliver must not be negative")
+	}
+	if c.NakDelay < 0 {
+		return errors.New("rpc.durable.nakDelay must not be negative")
+	}
+	if c.DeadLetter != "" {
+		if strings.ContainsAny(c.DeadLetter, " \t*>") || slices.Contains(strings.Split(c.DeadLetter, "."), "") {
+			return fmt.Errorf("rpc.durable.deadLetter: invalid subject %q", c.DeadLetter)
+		}
+		if strings.HasPrefix(c.DeadLetter, rpc.DurableSubjectPrefix) {
+			return fmt.Errorf("rpc.durable.deadLetter: %q would be delivered again", c.DeadLetter)
+		}
+	}
+	return nil
+}
+
+// Policy is rpc.DefaultDurablePolicy with the configured values.
+func (c *DurableConfig) Policy() rpc.DurablePolicy {
+	policy := rpc.DefaultDurablePolicy
+	if c.MaxDeliver > 0 {
+		policy.MaxDeliver = c.MaxDeliver
+	}
+	if c.NakDelay > 0 {
+		policy.Backoff = time.Duration(c.NakDelay)
+	}
+	policy.DeadLetter = c.DeadLetter
+	return policy
+}
+
+// Apply sets the compression of the rpc payloads sent by this process.
+func (c *CompressionConfig) Apply() {
+	algorithm, _ := compress.Parse(c.Algorithm)
+	compress.Configure(algorithm, c.Threshold)
+}
+
+// Apply makes the nats connections of this process use the credentials.
+func (c *NatsAuthConfig) Apply() {
+	cluster.SetDefaultCredentials(cluster.NatsCredentials{
+		User:         c.User,
+		Password:     c.Password,
+		Token:        c.Token,
+		NkeySeedFile: c.NkeySeedFile,
+		CredsFile:    c.CredsFile,
+		CAFile:       c.CAFile,
+		CertFile:     c.CertFile,
+		KeyFile:      c.KeyFile,
+	})
+}
+
+// Cache builds the dedup cache, nil when not enabled.
+func (c *DedupConfig) Cache() *dedup.Cache {
+	if !c.Enabled {
+		return nil
+	}
+	var opts []dedup.Option
+	if c.Capacity > 0 {
+		opts = append(opts, dedup.WithCapacity(c.Capacity))
+	}
+	if c.TTL > 0 {
+		opts = append(opts, dedup.WithTTL(time.Duration(c.TTL)))
+	}
+	if c.MaxWaiters > 0 {
+		opts = append(opts, dedup.WithMaxWaiters(c.MaxWaiters))
+	}
+	if c.StoreTimeout > 0 {
+		opts = append(opts, dedup.WithStoreTimeout(time.Duration(c.StoreTimeout)))
+	}
+	return dedup.NewCache(opts...)
+}
+
+// Authenticator builds the rpc authenticator, nil when no mode is set.
+func (c *AuthConfig) Authenticator() (*rpc.Authenticator, error) {
+	var keyring rpc.Keyring
+	if c.PublicKeysFrom != "" && strings.ToLower(c.Mode) != "ed25519" {
+		return nil, errors.New("publicKeysFrom needs mode ed25519")
+	}
+	switch strings.ToLower(c.Mode) {
+	case "":
+		return nil, nil
+	case "hmac":
+		keys := make(map[string][]byte, len(c.Keys))
+		for id, key := range c.Keys {
+			keys[id] = []byte(key)
+		}
+		hmacKeyring, err := rpc.NewHMACKeyring(c.KeyId, keys)
+		if err != nil {
+			return nil, err
+		}
+		keyring = hmacKeyring
+	case "ed25519":
+		seed, err := base64.StdEncoding.DecodeString(c.PrivateKey)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("privateKey must be a base64 ed25519 seed")
+		}
+		publicKeys := rpc.StaticPublicKeys{}
+		for id, key := range c.PublicKeys {
+			decoded, err := base64.StdEncoding.DecodeString(key)
+			if err != nil || len(decoded) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("publicKeys.%s must be a base64 ed25519 key", id)
+			}
+			publicKeys[id] = ed25519.PublicKey(decoded)
+		}
+		switch c.PublicKeysFrom {
+		case "", PublicKeysFromRegistry:
+		default:
+			return nil, fmt.Errorf("unknown publicKeysFrom:%q", c.PublicKeysFrom)
+		}
+		if c.KeyId == "" {
+			return nil, errors.New("keyId is required")
+		}
+		keyring = rpc.NewEd25519Keyring(c.KeyId, ed25519.NewKeyFromSeed(seed), publicKeys)
+	default:
+		return nil, fmt.Errorf("unknown mode:%q", c.Mode)
+	}
+
+	var opts []rpc.AuthOption
+	if c.Window > 0 {
+		opts = append(opts, rpc.WithReplayWindow(time.Duration(c.Window)))
+	}
+	if c.AllowUnsigned {
+		opts = append(opts, rpc.WithAllowUnsigned())
+	}
+	return rpc.NewAuthenticator(keyring, opts...), nil
+}
+
+func (c *LogConfig) Validate() error {
+	if c.Level == "" {
+		return nil
+	}
+	if _, err := logger.GetLevel(c.Level); err != nil {
+		return fmt.Errorf("log.level: %w", err)
+	}
+	return nil
+}
+
+// Apply sets the global log level, leaving it untouched when none is given.
+func (c *LogConfig) Apply() {
+	if c.Level == "" {
+		return
+	}
+	level, _ := logger.GetLevel(c.Level)
+	logger.SetLevel(level)
+}
+
+// Apply sets the span exporter of this process.
+func (c *TraceConfig) Apply() error {
+	if c.File == "" {
+		trace.SetExporter(nil)
+		return nil
+	}
+	exporter, err := trace.NewFileExporter(c.File)
+	if err != nil {
+		return fmt.Errorf("trace.file: %w", err)
+	}
+	trace.SetExporter(exporter)
+	return nil
+}
+
+// Tap builds the tap, nil when no file is set.
+func (c *TapConfig) Tap() *rpc.Tap {
+	if c.File == "" {
+		return nil
+	}
+	return rpc.NewFileTap(c.File, c.MaxSize, c.MaxBackups, rpc.WithTapOpcodes(c.Opcodes...))
+}
+
+// applyEnv overrides the values of tree from the environment. The keys of
+// schema, the type tree decodes into, can be set even when the file leaves
+// them out; the component sections have no schema here, only the keys they
+// contain can be overridden.
+func applyEnv(tree map[string]any, schema reflect.Type, path []string) {
+	fields := schemaFields(schema)
+	for key, value := range tree {
+		keyPath := envPath(path, key)
+		if child, ok := value.(map[string]any); ok {
+			applyEnv(child, fields[key], keyPath)
+			continue
+		}
+
+		env, ok := os.LookupEnv(strings.Join(keyPath, "_"))
+		if !ok {
+			continue
+		}
+		tree[key] = parseEnv(env, value)
+	}
+
+	for key, field := range fields {
+		if _, ok := tree[key]; ok {
+			continue
+		}
+		keyPath := envPath(path, key)
+		if schemaFields(field) != nil {
+			child := map[string]any{}
+			applyEnv(child, field, keyPath)
+			if len(child) > 0 {
+				tree[key] = child
+			}
+			continue
+		}
+		if env, ok := os.LookupEnv(strings.Join(keyPath, "_")); ok {
+			tree[key] = parseEnv(env, zeroValue(field))
+		}
+	}
+}
+
+func envPath(path []string, key string) []string {
+	return append(slices.Clip(path), strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key)))
+}
+
+// schemaFields maps the json keys of a struct to the types of their fields,
+// nil for anything decoded as a single value.
+func schemaFields(schema reflect.Type) map[string]reflect.Type {
+	if schema == nil || schema.Kind() != reflect.Struct || reflect.PointerTo(schema).Implements(jsonUnmarshaler) {
+		return nil
+	}
+	fields := make(map[string]reflect.Type, schema.NumField())
+	for i := 0; i < schema.NumField(); i++ {
+		field := schema.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// zeroValue stands for a key missing from the file when parsing its
+// override.
+func zeroValue(field reflect.Type) any {
+	switch field.Kind() {
+	case reflect.String:
+		return ""
+	case reflect.Slice:
+		if field.Elem().Kind() == reflect.String {
+			return []any{}
+		}
+		return []any{false}
+	default:
+		return false
+	}
+}
+
+func parseEnv(env string, origin any) any {
+	switch origin := origin.(type) {
+	case []any:
+		// the items are strings unless the ones replaced are not
+		typed := len(origin) > 0
+		if typed {
+			_, isString := origin[0].(string)
+			typed = !isString
+		}
+		var items []any
+		for _, item := range strings.Split(env, ",") {
+			var value any = strings.TrimSpace(item)
+			if typed {
+				value = parseEnv(strings.TrimSpace(item), false)
+			}
+			items = append(items, value)
+		}
+		return items
+	case string, nil:
+		return env
+	default:
+		var value any
+		if err := json.Unmarshal([]byte(env), &value); err != nil {
+			return env
+		}
+		return value
+	}
+}
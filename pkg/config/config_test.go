@@ -0,0 +1,253 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+)
+
+const yamlConfig = `
+engine:
+  realm: 0
+  kind: 1
+  id: 1001
+  nats: nats://127.0.0.1:4222
+components:
+  tcpserver:
+    address: ":16007"
+  etcd:
+    addrs: ["127.0.0.1:2379"]
+    timeout: 5s
+`
+
+const tomlConfig = `
+[engine]
+realm = 0
+kind = 1
+id = 1001
+
+[components.tcpserver]
+address = ":16007"
+`
+
+const jsonConfig = `{"engine":{"kind":1,"id":1001},"components":{"tcpserver":{"address":":16007"}}}`
+
+func TestParseFormats(t *testing.T) {
+	for format, data := range map[string]string{".yaml": yamlConfig, "toml": tomlConfig, "json": jsonConfig} {
+		cfg, err := Parse([]byte(data), format)
+		require.NoError(t, err, format)
+		assert.Equal(t, uint32(1), cfg.Engine.Kind, format)
+		assert.Equal(t, uint32(1001), cfg.Engine.Id, format)
+
+		var tcp TCPServerConfig
+		require.NoError(t, cfg.Components["tcpserver"].Decode(&tcp), format)
+		assert.Equal(t, ":16007", tcp.Address, format)
+	}
+}
+
+func TestParseEnvOverride(t *testing.T) {
+	t.Setenv("FILE_STORAGE_ENGINE_ID", "2002")
+	t.Setenv("FILE_STORAGE_ENGINE_NATS", "nats://10.0.0.2:4222")
+	t.Setenv("FILE_STORAGE_COMPONENTS_ETCD_ADDRS", "10.0.0.3:2379, 10.0.0.4:2379")
+
+	cfg, err := Parse([]byte(yamlConfig), "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2002), cfg.Engine.Id)
+	assert.Equal(t, "nats://10.0.0.2:4222", cfg.Engine.Nats)
+
+	var etcdCfg EtcdConfig
+	require.NoError(t, cfg.Components["etcd"].Decode(&etcdCfg))
+	assert.Equal(t, []string{"10.0.0.3:2379", "10.0.0.4:2379"}, etcdCfg.Addrs)
+	assert.Equal(t, "5s", etcdCfg.Timeout.String())
+}
+
+func TestParseEnvOnly(t *testing.T) {
+	t.Setenv("FILE_STORAGE_ENGINE_NATS", "nats://10.0.0.2:4222")
+	t.Setenv("FILE_STORAGE_ENGINE_COMPRESSION_THRESHOLD", "1024")
+	t.Setenv("FILE_STORAGE_LOG_LEVEL", "debug")
+	t.Setenv("FILE_STORAGE_TAP_OPCODES", "1, 2")
+
+	// none of these keys is in the file
+	cfg, err := Parse([]byte(tomlConfig), "toml")
+	require.NoError(t, err)
+	assert.Equal(t, "nats://10.0.0.2:4222", cfg.Engine.Nats)
+	assert.Equal(t, 1024, cfg.Engine.Compression.Threshold)
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, []uint32{1, 2}, cfg.Tap.Opcodes)
+	assert.Empty(t, cfg.Engine.Auth.Mode)
+}
+
+func TestParseValidate(t *testing.T) {
+	_, err := Parse([]byte(`{"engine":{"kind":1}}`), "json")
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"engine":{"kind":1,"id":1,"nats":"127.0.0.1"}}`), "json")
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"engine":{"kind":1,"id":1},"components":{"unknown":{}}}`), "json")
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"engine":{"kind":1,"id":1,"compression":{"algorithm":"lz4"}}}`), "json")
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"engine":{"kind":1,"id":1,"auth":{"mode":"hmac","keyId":"k1","keys":{"k2":"secret"}}}}`), "json")
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"engine":{"kind":1,"id":1,"auth":{"mode":"ed25519","keyId":"k1","privateKey":"c2hvcnQ="}}}`), "json")
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(jsonConfig), "ini")
+	assert.Error(t, err)
+}
+
+func TestBuildEngine(t *testing.T) {
+	cfg, err := Parse([]byte(jsonConfig), "json")
+	require.NoError(t, err)
+
+	engine, err := BuildEngine(context.Background(), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "engine.0.1.1001.server", engine.GetAddress())
+	assert.True(t, engine.HasComponent("tcpserver"))
+
+	cfg.Components["wsserver"] = Section(`{}`)
+	_, err = BuildEngine(context.Background(), cfg)
+	assert.Error(t, err)
+
+	// the ws server looks the storage component up per request only
+	cfg.Components["wsserver"] = Section(`{"httpPort":":18080"}`)
+	engine, err = BuildEngine(context.Background(), cfg)
+	require.NoError(t, err)
+	assert.True(t, engine.HasComponent("wsserver"))
+}
+
+func TestBuildEngineRoutes(t *testing.T) {
+	cfg, err := Parse([]byte(`{"engine":{"kind":2,"id":1002},"components":{"tcpserver":{"address":":16007","routes":[{"min":2000,"max":2999,"kind":1}]}}}`), "json")
+	require.NoError(t, err)
+	_, err = BuildEngine(context.Background(), cfg)
+	require.NoError(t, err)
+
+	cfg.Components["tcpserver"] = Section(`{"address":":16007","routes":[{"min":2000,"max":2999,"kind":1},{"min":2500,"max":3000,"kind":3}]}`)
+	_, err = BuildEngine(context.Background(), cfg)
+	assert.ErrorContains(t, err, "overlaps")
+}
+
+func TestBuildEngineRegistryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name":"engine.0.2","nodes":[{"id":"engine.0.2.1001.server"}]}]`), 0o644))
+	cfg, err := Parse([]byte(`{"engine":{"kind":1,"id":1001},"components":{"etcd":{"file":"`+path+`","watch":["engine.0.2"]}}}`), "json")
+	require.NoError(t, err)
+	engine, err := BuildEngine(context.Background(), cfg)
+	require.NoError(t, err)
+	engine.MustInit()
+	require.NoError(t, engine.Start())
+	defer engine.Stop()
+
+	nodes, err := engine.Resolver().Resolve(0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"engine.0.2.1001.server"}, nodes)
+	nodes, err = engine.Resolver().Resolve(0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"engine.0.1.1001.server"}, nodes)
+
+	cfg.Components["etcd"] = Section(`{"watch":["engine.0.2"]}`)
+	_, err = BuildEngine(context.Background(), cfg)
+	assert.Error(t, err)
+}
+
+func TestBuildEngineRegistryKeys(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	peer := ed25519.NewKeyFromSeed(append(make([]byte, ed25519.SeedSize-1), 1))
+	path := filepath.Join(t.TempDir(), "services.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name":"engine.0.2","nodes":[{"id":"engine.0.2.1001.server",
+		"metadata":{"engine":"engine.0.2.1001","ed25519_pub":"`+base64.StdEncoding.EncodeToString(peer.Public().(ed25519.PublicKey))+`"}}]}]`), 0o644))
+	auth := `"auth":{"mode":"ed25519","keyId":"engine.0.1.1001","privateKey":"` + base64.StdEncoding.EncodeToString(seed) + `","publicKeysFrom":"registry"}`
+
+	cfg, err := Parse([]byte(`{"engine":{"kind":1,"id":1001,`+auth+`},"components":{"etcd":{"file":"`+path+`"}}}`), "json")
+	require.NoError(t, err)
+	authenticator, err := cfg.Engine.Auth.Authenticator()
+	require.NoError(t, err)
+	components, err := NewComponents(context.Background(), cfg)
+	require.NoError(t, err)
+	defer components[0].OnCleanup()
+	require.NoError(t, usePublishedKeys(authenticator, components))
+
+	sealed, err := rpc.NewAuthenticator(rpc.NewEd25519Keyring("engine.0.2.1001", peer, rpc.StaticPublicKeys{})).Seal("engine.0.1.1001.server", []byte("payload"))
+	require.NoError(t, err)
+	opened, err := authenticator.Open("engine.0.1.1001.server", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), opened)
+
+	// the key id others look up is the engine name
+	_, err = Parse([]byte(`{"engine":{"kind":1,"id":1002,`+auth+`}}`), "json")
+	assert.ErrorContains(t, err, "engine.0.1.1002")
+
+	cfg, err = Parse([]byte(`{"engine":{"kind":1,"id":1001,`+auth+`}}`), "json")
+	require.NoError(t, err)
+	_, err = BuildEngine(context.Background(), cfg)
+	assert.ErrorContains(t, err, "needs the etcd component")
+}
+
+func TestBuildEngineDedup(t *testing.T) {
+	cfg, err := Parse([]byte(`{"engine":{"kind":1,"id":1001,"dedup":{"enabled":true,"capacity":16,"ttl":"1m","maxWaiters":4}}}`), "json")
+	require.NoError(t, err)
+	require.NotNil(t, cfg.Engine.Dedup.Cache())
+	_, err = BuildEngine(context.Background(), cfg)
+	require.NoError(t, err)
+
+	_, err = Parse([]byte(`{"engine":{"kind":1,"id":1001,"dedup":{"enabled":true,"maxWaiters":-1}}}`), "json")
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"engine":{"kind":1,"id":1001,"dedup":{"database":"test"}}}`), "json")
+	assert.ErrorContains(t, err, "needs enabled")
+
+	// the records go to a database of the mongodb component
+	dedup := `"dedup":{"enabled":true,"database":"other"}`
+	cfg, err = Parse([]byte(`{"engine":{"kind":1,"id":1001,`+dedup+`}}`), "json")
+	require.NoError(t, err)
+	_, err = BuildEngine(context.Background(), cfg)
+	assert.ErrorContains(t, err, "needs the mongodb component")
+
+	cfg, err = Parse([]byte(`{"engine":{"kind":1,"id":1001,`+dedup+`},"components":{"mongodb":{"databases":{"test":{"uri":"mongodb://127.0.0.1:27017","database":"vcity"}}}}}`), "json")
+	require.NoError(t, err)
+	_, err = BuildEngine(context.Background(), cfg)
+	assert.ErrorContains(t, err, "unknown database:other")
+}
+
+func TestBuildEngineDurable(t *testing.T) {
+	durable := `"rpc":{"durable":{"enabled":true,"stream":"NOTIFY","maxDeliver":3,"nakDelay":"200ms","deadLetter":"failed.notify"}}`
+	cfg, err := Parse([]byte(`{"engine":{"kind":1,"id":1001,"nats":"nats://127.0.0.1:1"},`+durable+`}`), "json")
+	require.NoError(t, err)
+	policy := cfg.RPC.Durable.Policy()
+	assert.Equal(t, 3, policy.MaxDeliver)
+	assert.Equal(t, 200*time.Millisecond, policy.Backoff)
+	assert.Equal(t, "failed.notify", policy.DeadLetter)
+	assert.Equal(t, rpc.DefaultDurablePolicy.AckWait, policy.AckWait)
+
+	// the stream is opened on the nats server of the engine
+	_, err = BuildEngine(context.Background(), cfg)
+	assert.ErrorContains(t, err, "rpc.durable")
+
+	_, err = Parse([]byte(`{"engine":{"kind":1,"id":1001},`+durable+`}`), "json")
+	assert.ErrorContains(t, err, "needs a nats://")
+
+	for _, section := range []string{
+		`{"maxDeliver":3}`,
+		`{"enabled":true,"stream":"a.b"}`,
+		`{"enabled":true,"maxDeliver":-1}`,
+		`{"enabled":true,"nakDelay":"-1s"}`,
+		`{"enabled":true,"deadLetter":"failed.>"}`,
+		`{"enabled":true,"deadLetter":"failed..notify"}`,
+		`{"enabled":true,"deadLetter":"durable.engine.0.1.1001.server"}`,
+	} {
+		_, err = Parse([]byte(`{"engine":{"kind":1,"id":1001,"nats":"nats://127.0.0.1:4222"},"rpc":{"durable":`+section+`}}`), "json")
+		assert.Error(t, err, section)
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/cluster"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+	"github.com/wuqunyong/file_storage/pkg/component/mongodb"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/dedup"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+)
+
+// ComponentFactory creates a component from its configuration section.
+type ComponentFactory func(ctx context.Context, section Section) (concepts.IComponent, error)
+
+var (
+	factoryMu sync.RWMutex
+	factories = make(map[string]ComponentFactory)
+)
+
+// RegisterFactory makes a component available under name in the components
+// section of the configuration.
+func RegisterFactory(name string, factory ComponentFactory) {
+	factoryMu.Lock()
+	defer factoryMu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("duplicate component factory:%s", name))
+	}
+	factories[name] = factory
+}
+
+func getFactory(name string) (ComponentFactory, bool) {
+	factoryMu.RLock()
+	defer factoryMu.RUnlock()
+	factory, ok := factories[name]
+	return factory, ok
+}
+
+// NewComponents instantiates the configured components in name order. A
+// factory registered under name must create a component whose Name() is
+// the same, so that reloads can find it again on the engine.
+func NewComponents(ctx context.Context, cfg *Config) ([]concepts.IComponent, error) {
+	names := make([]string, 0, len(cfg.Components))
+	for name := range cfg.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	components := make([]concepts.IComponent, 0, len(names))
+	for _, name := range names {
+		factory, ok := getFactory(name)
+		if !ok {
+			return nil, fmt.Errorf("components.%s: unknown component", name)
+		}
+		component, err := factory(ctx, cfg.Components[name])
+		if err != nil {
+			return nil, fmt.Errorf("components.%s: %w", name, err)
+		}
+		components = append(components, component)
+	}
+	return components, nil
+}
+
+// BuildEngine creates the engine described by cfg and adds its components.
+func BuildEngine(ctx context.Context, cfg *Config) (*actor.Engine, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	components, err := NewComponents(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Log.Apply()
+	if err := cfg.Trace.Apply(); err != nil {
+		return nil, err
+	}
+	cfg.Engine.Compression.Apply()
+	cfg.Engine.NatsAuth.Apply()
+	auth, err := cfg.Engine.Auth.Authenticator()
+	if err != nil {
+		return nil, err
+	}
+	engine := actor.NewEngine(cfg.Engine.Realm, cfg.Engine.Kind, cfg.Engine.Id, cfg.Engine.Nats)
+	if auth != nil {
+		engine.SetAuthenticator(auth)
+	}
+	if tap := cfg.Tap.Tap(); tap != nil {
+		engine.SetTap(tap)
+	}
+	for _, component := range components {
+		if engine.HasComponent(component.Name()) {
+			return nil, fmt.Errorf("duplicate component name:%s", component.Name())
+		}
+		engine.MustAddComponent(component)
+	}
+	if err := engine.CheckDependencies(); err != nil {
+		return nil, err
+	}
+	if cfg.Engine.Auth.PublicKeysFrom == PublicKeysFromRegistry {
+		if err := usePublishedKeys(auth, components); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RPC.Durable.Enabled {
+		if err := useDurable(engine, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cache := cfg.Engine.Dedup.Cache(); cache != nil {
+		engine.SetDedup(cache)
+		if err := useDedupStore(cache, &cfg.Engine.Dedup, components); err != nil {
+			return nil, err
+		}
+	}
+	return engine, nil
+}
+
+// useDurable stores the durable notifies of the engine in a JetStream stream
+// of its nats server.
+func useDurable(engine *actor.Engine, cfg *Config) error {
+	options, err := cluster.DefaultCredentials().Options()
+	if err != nil {
+		return fmt.Errorf("rpc.durable: %w", err)
+	}
+	stream, err := rpc.ConnectJetStream(cfg.Engine.Nats, cfg.RPC.Durable.Stream, options...)
+	if err != nil {
+		return fmt.Errorf("rpc.durable: %w", err)
+	}
+	if cfg.RPC.Durable.DeadLetter != "" {
+		if err := stream.AddSubjects(cfg.RPC.Durable.DeadLetter); err != nil {
+			stream.Close()
+			return fmt.Errorf("rpc.durable.deadLetter: %w", err)
+		}
+	}
+	engine.SetDurable(stream, cfg.RPC.Durable.Policy())
+	return nil
+}
+
+// useDedupStore keeps the dedup records in the configured database of the
+// mongodb component.
+func useDedupStore(cache *dedup.Cache, cfg *DedupConfig, components []concepts.IComponent) error {
+	if cfg.Database == "" {
+		return nil
+	}
+	collection := cfg.Collection
+	if collection == "" {
+		collection = DefaultDedupCollection
+	}
+	for _, component := range components {
+		if mongo, ok := component.(*mongodb.MongoComponent); ok {
+			if err := mongo.EnableDedup(cache, cfg.Database, collection); err != nil {
+				return fmt.Errorf("engine.dedup.database: %w", err)
+			}
+			return nil
+		}
+	}
+	return errors.New("engine.dedup.database needs the mongodb component")
+}
+
+// usePublishedKeys verifies the signatures of the other engines with the
+// public keys they publish in the registry of the discovery component.
+func usePublishedKeys(auth *rpc.Authenticator, components []concepts.IComponent) error {
+	keyring, ok := auth.Keyring().(*rpc.Ed25519Keyring)
+	if !ok {
+		return errors.New("engine.auth.publicKeysFrom needs mode ed25519")
+	}
+	for _, component := range components {
+		if discovery, ok := component.(interface{ Registry() registry.Registry }); ok {
+			keyring.SetResolver(rpc.NewRegistryPublicKeys(discovery.Registry()))
+			return nil
+		}
+	}
+	return errors.New("engine.auth.publicKeysFrom registry needs the etcd component")
+}
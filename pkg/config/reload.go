@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultPollInterval is how often a FileSource checks its file.
+var DefaultPollInterval = 5 * time.Second
+
+// Source delivers new revisions of the configuration until ctx is done.
+type Source interface {
+	Watch(ctx context.Context, update func(data []byte, format string)) error
+}
+
+// Reloader pushes changed component sections to the running engine. Only
+// the log and tap sections and components implementing concepts.IReloadable
+// can change; anything else rejects the whole revision.
+type Reloader struct {
+	mu      sync.Mutex
+	engine  concepts.IEngine
+	current *Config
+}
+
+func NewReloader(engine concepts.IEngine, current *Config) *Reloader {
+	return &Reloader{
+		engine:  engine,
+		current: current,
+	}
+}
+
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Watch applies every revision delivered by source, logging the rejected
+// ones, until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, source Source) error {
+	return source.Watch(ctx, func(data []byte, format string) {
+		if err := r.Reload(data, format); err != nil {
+			logger.Log(logger.ErrorLevel, "config reload rejected", "err", err)
+			return
+		}
+		logger.Log(logger.InfoLevel, "config reloaded")
+	})
+}
+
+// Reload parses data and applies it.
+func (r *Reloader) Reload(data []byte, format string) error {
+	next, err := Parse(data, format)
+	if err != nil {
+		return err
+	}
+	return r.Apply(next)
+}
+
+// Apply validates every changed section before reloading any of them, so
+// that the node either switches to next entirely or keeps its current
+// configuration.
+func (r *Reloader) Apply(next *Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !reflect.DeepEqual(r.current.Engine, next.Engine) {
+		return errors.New("engine: section can not be reloaded")
+	}
+	if r.current.Trace != next.Trace {
+		return errors.New("trace: section can not be reloaded")
+	}
+	if err := next.Log.Validate(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(next.Components))
+	for name := range next.Components {
+		if _, ok := r.current.Components[name]; !ok {
+			return fmt.Errorf("components.%s: component can not be added", name)
+		}
+		names = append(names, name)
+	}
+	for name := range r.current.Components {
+		if _, ok := next.Components[name]; !ok {
+			return fmt.Errorf("components.%s: component can not be removed", name)
+		}
+	}
+	sort.Strings(names)
+
+	var changed []string
+	reloadables := make(map[string]concepts.IReloadable)
+	for _, name := range names {
+		if bytes.Equal(r.current.Components[name], next.Components[name]) {
+			continue
+		}
+		reloadable, ok := r.engine.GetComponent(name).(concepts.IReloadable)
+		if !ok {
+			return fmt.Errorf("components.%s: component is not reloadable", name)
+		}
+		if err := reloadable.ValidateReload(next.Components[name]); err != nil {
+			return fmt.Errorf("components.%s: %w", name, err)
+		}
+		changed = append(changed, name)
+		reloadables[name] = reloadable
+	}
+
+	for i, name := range changed {
+		if err := reloadables[name].OnReload(next.Components[name]); err != nil {
+			// the components reloaded so far go back to the current sections
+			for j := i - 1; j >= 0; j-- {
+				done := changed[j]
+				if undoErr := reloadables[done].OnReload(r.current.Components[done]); undoErr != nil {
+					logger.Log(logger.ErrorLevel, "component reload rollback failed", "name", done, "err", undoErr)
+				}
+			}
+			return fmt.Errorf("components.%s: %w", name, err)
+		}
+		logger.Log(logger.InfoLevel, "component reloaded", "name", name)
+	}
+	next.Log.Apply()
+	if !reflect.DeepEqual(r.current.Tap, next.Tap) {
+		if tapper, ok := r.engine.(interface{ SetTap(*rpc.Tap) }); ok {
+			tapper.SetTap(next.Tap.Tap())
+			logger.Log(logger.InfoLevel, "tap reloaded", "file", next.Tap.File)
+		}
+	}
+	r.current = next
+	return nil
+}
+
+// FileSource polls a configuration file for changes.
+type FileSource struct {
+	path     string
+	interval time.Duration
+}
+
+func NewFileSource(path string, interval time.Duration) *FileSource {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &FileSource{
+		path:     path,
+		interval: interval,
+	}
+}
+
+func (s *FileSource) Watch(ctx context.Context, update func(data []byte, format string)) error {
+	last, _ := os.ReadFile(s.path)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			data, err := os.ReadFile(s.path)
+			if err != nil {
+				logger.Log(logger.WarnLevel, "read config file failed", "path", s.path, "err", err)
+				continue
+			}
+			if bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			update(data, filepath.Ext(s.path))
+		}
+	}
+}
+
+// EtcdSource watches a single etcd key holding the whole configuration.
+type EtcdSource struct {
+	client *clientv3.Client
+	key    string
+	format string
+}
+
+func NewEtcdSource(client *clientv3.Client, key string, format string) *EtcdSource {
+	return &EtcdSource{
+		client: client,
+		key:    key,
+		format: format,
+	}
+}
+
+func (s *EtcdSource) Watch(ctx context.Context, update func(data []byte, format string)) error {
+	for resp := range s.client.Watch(ctx, s.key) {
+		if err := resp.Err(); err != nil {
+			logger.Log(logger.WarnLevel, "watch config key failed", "key", s.key, "err", err)
+			continue
+		}
+		for _, event := range resp.Events {
+			if event.Type != clientv3.EventTypePut {
+				continue
+			}
+			update(event.Kv.Value, s.format)
+		}
+	}
+	return ctx.Err()
+}
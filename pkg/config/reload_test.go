@@ -0,0 +1,156 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+)
+
+type limitConfig struct {
+	Limit int `json:"limit"`
+	// Refuse fails OnReload, as a backend refusing the change would
+	Refuse bool `json:"refuse"`
+}
+
+type limitComponent struct {
+	name   string
+	engine concepts.IEngine
+	limit  int
+}
+
+func (c *limitComponent) Name() string                      { return c.name }
+func (c *limitComponent) Dependencies() []string            { return nil }
+func (c *limitComponent) SetEngine(engine concepts.IEngine) { c.engine = engine }
+func (c *limitComponent) GetEngine() concepts.IEngine       { return c.engine }
+func (c *limitComponent) OnInit() error                     { return nil }
+func (c *limitComponent) OnStart() error                    { return nil }
+func (c *limitComponent) OnCleanup()                        {}
+
+func (c *limitComponent) ValidateReload(section concepts.IConfigSection) error {
+	var cfg limitConfig
+	if err := section.Decode(&cfg); err != nil {
+		return err
+	}
+	if cfg.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	return nil
+}
+
+func (c *limitComponent) OnReload(section concepts.IConfigSection) error {
+	var cfg limitConfig
+	if err := section.Decode(&cfg); err != nil {
+		return err
+	}
+	if cfg.Refuse {
+		return errors.New("refused")
+	}
+	c.limit = cfg.Limit
+	return nil
+}
+
+func init() {
+	for _, name := range []string{"limita", "limitb"} {
+		RegisterFactory(name, func(ctx context.Context, section Section) (concepts.IComponent, error) {
+			var cfg limitConfig
+			if err := section.Decode(&cfg); err != nil {
+				return nil, err
+			}
+			return &limitComponent{name: name, limit: cfg.Limit}, nil
+		})
+	}
+}
+
+const reloadConfig = `{"engine":{"kind":1,"id":1001},"log":{"level":"info"},"components":{"tcpserver":{"address":":16007"},"limita":{"limit":1},"limitb":{"limit":1}}}`
+
+func newReloader(t *testing.T) (*Reloader, *limitComponent, *limitComponent) {
+	cfg, err := Parse([]byte(reloadConfig), "json")
+	require.NoError(t, err)
+	engine, err := BuildEngine(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { logger.SetLevel(logger.InfoLevel) })
+	return NewReloader(engine, cfg), engine.GetComponent("limita").(*limitComponent), engine.GetComponent("limitb").(*limitComponent)
+}
+
+func TestReloadApply(t *testing.T) {
+	reloader, a, b := newReloader(t)
+
+	err := reloader.Reload([]byte(`{"engine":{"kind":1,"id":1001},"log":{"level":"debug"},"components":{"tcpserver":{"address":":16007"},"limita":{"limit":5},"limitb":{"limit":1}}}`), "json")
+	require.NoError(t, err)
+	assert.Equal(t, 5, a.limit)
+	assert.Equal(t, 1, b.limit)
+	assert.Equal(t, logger.DebugLevel, logger.GetCurrentLevel())
+	assert.Equal(t, "debug", reloader.Current().Log.Level)
+}
+
+func TestReloadTap(t *testing.T) {
+	reloader, _, _ := newReloader(t)
+
+	file := filepath.Join(t.TempDir(), "tap.jsonl")
+	err := reloader.Reload([]byte(`{"engine":{"kind":1,"id":1001},"log":{"level":"info"},"tap":{"file":"`+file+`","opcodes":[1,2]},"components":{"tcpserver":{"address":":16007"},"limita":{"limit":1},"limitb":{"limit":1}}}`), "json")
+	require.NoError(t, err)
+	assert.Equal(t, TapConfig{File: file, Opcodes: []uint32{1, 2}}, reloader.Current().Tap)
+
+	require.NoError(t, reloader.Reload([]byte(reloadConfig), "json"))
+	assert.Empty(t, reloader.Current().Tap.File)
+}
+
+func TestReloadRejectAtomically(t *testing.T) {
+	reloader, a, b := newReloader(t)
+
+	rejected := []string{
+		// limitb is invalid, so neither limit nor the level may change
+		`{"engine":{"kind":1,"id":1001},"log":{"level":"debug"},"components":{"tcpserver":{"address":":16007"},"limita":{"limit":5},"limitb":{"limit":-1}}}`,
+		// tcpserver is not reloadable
+		`{"engine":{"kind":1,"id":1001},"log":{"level":"debug"},"components":{"tcpserver":{"address":":16008"},"limita":{"limit":5},"limitb":{"limit":1}}}`,
+		// engine identity is fixed
+		`{"engine":{"kind":1,"id":1002},"log":{"level":"debug"},"components":{"tcpserver":{"address":":16007"},"limita":{"limit":5},"limitb":{"limit":1}}}`,
+		// components can not come and go
+		`{"engine":{"kind":1,"id":1001},"log":{"level":"debug"},"components":{"tcpserver":{"address":":16007"},"limita":{"limit":5}}}`,
+		`{"engine":{"kind":1,"id":1001},"log":{"level":"verbose"},"components":{"tcpserver":{"address":":16007"},"limita":{"limit":5},"limitb":{"limit":1}}}`,
+	}
+	for _, data := range rejected {
+		assert.Error(t, reloader.Reload([]byte(data), "json"), data)
+		assert.Equal(t, 1, a.limit)
+		assert.Equal(t, 1, b.limit)
+		assert.Equal(t, logger.InfoLevel, logger.GetCurrentLevel())
+		assert.Equal(t, "info", reloader.Current().Log.Level)
+	}
+}
+
+func TestReloadRollback(t *testing.T) {
+	reloader, a, b := newReloader(t)
+
+	// limita is reloaded before limitb refuses its section
+	err := reloader.Reload([]byte(`{"engine":{"kind":1,"id":1001},"log":{"level":"debug"},"components":{"tcpserver":{"address":":16007"},"limita":{"limit":5},"limitb":{"limit":5,"refuse":true}}}`), "json")
+	assert.ErrorContains(t, err, "components.limitb: refused")
+	assert.Equal(t, 1, a.limit)
+	assert.Equal(t, 1, b.limit)
+	assert.Equal(t, logger.InfoLevel, logger.GetCurrentLevel())
+	assert.Equal(t, `{"limit":1}`, string(reloader.Current().Components["limita"]))
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.json")
+	require.NoError(t, os.WriteFile(path, []byte(reloadConfig), 0o644))
+
+	reloader, a, _ := newReloader(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, NewFileSource(path, 10*time.Millisecond))
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte(`{"engine":{"kind":1,"id":1001},"log":{"level":"info"},"components":{"tcpserver":{"address":":16007"},"limita":{"limit":7},"limitb":{"limit":1}}}`), 0o644))
+	assert.Eventually(t, func() bool {
+		return reloader.Current().Components["limita"] != nil && string(reloader.Current().Components["limita"]) == `{"limit":7}`
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 7, a.limit)
+}
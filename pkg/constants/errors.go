@@ -23,7 +23,18 @@ var (
 	ErrRPCFlagIsFalse                 = errors.New("FlagIsFalse")
 	ErrRPCArgsEncodeFailure           = errors.New("RPCArgsEncodeFailure")
 	ErrRPCClientHasClosed             = errors.New("RPCClientHasClosed")
+	ErrRPCConnectionLost              = errors.New("rpc client: nats connection lost")
+	ErrRPCNoReply                     = errors.New("request was sent without waiting for a reply")
 	ErrRPCServerNotInitialized        = errors.New("RPC server is not running")
+	ErrRPCDurableNotConfigured        = errors.New("rpc: no durable stream configured")
+	ErrRPCDurableRequest              = errors.New("rpc: only notifies can be durable")
+	ErrRPCUnsigned                    = errors.New("rpc: message is not signed")
+	ErrRPCBadSignature                = errors.New("rpc: invalid message signature")
+	ErrRPCUnknownKey                  = errors.New("rpc: unknown signing key")
+	ErrRPCExpired                     = errors.New("rpc: message timestamp outside the replay window")
+	ErrRPCReplayed                    = errors.New("rpc: message nonce already seen")
+	ErrRPCNotStreamed                 = errors.New("rpc: request does not take several responses")
+	ErrRPCCircuitOpen                 = errors.New("rpc: circuit open, target ejected")
 	ErrReplyShouldBeNotNull           = errors.New("reply must not be null")
 	ErrReplyShouldBePtr               = errors.New("reply must be a pointer")
 	ErrRequestOnNotify                = errors.New("tried to request a notify route")
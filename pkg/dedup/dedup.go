@@ -0,0 +1,314 @@
+// Package dedup keeps the responses of idempotent requests so that a resent
+// request replays the first response instead of running the handler again.
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/logger"
+)
+
+const (
+	DefaultCapacity = 10240
+	DefaultTTL      = 10 * time.Minute
+	// DefaultMaxWaiters caps the duplicates waiting on one in-flight key.
+	DefaultMaxWaiters = 64
+)
+
+// DefaultStoreTimeout bounds every Load and Save of the store.
+var DefaultStoreTimeout = 3 * time.Second
+
+// Record is the cached outcome of a request.
+type Record struct {
+	Key       string            `bson:"_id"`
+	ErrCode   uint32            `bson:"errCode"`
+	ErrMsg    string            `bson:"errMsg"`
+	Details   map[string]string `bson:"details,omitempty"`
+	ReplyData []byte            `bson:"replyData"`
+	ExpireAt  time.Time         `bson:"expireAt"`
+}
+
+// Store persists records beyond the in-memory window, Load returns nil when
+// the key is unknown.
+type Store interface {
+	Load(ctx context.Context, key string) (*Record, error)
+	Save(ctx context.Context, record *Record) error
+}
+
+type entry struct {
+	key      string
+	record   *Record
+	expireAt time.Time
+	waiters  []*waiter
+}
+
+// waiter is a duplicate waiting for the first request of its key, done once
+// onDone has been claimed by the completion or the expiry.
+type waiter struct {
+	onDone func(*Record)
+	timer  *time.Timer
+	done   bool
+}
+
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	waiters  int
+	store    Store
+	timeout  time.Duration
+	entries  map[string]*list.Element
+	lru      *list.List
+}
+
+type Option func(*Cache)
+
+func WithCapacity(capacity int) Option {
+	return func(c *Cache) {
+		c.capacity = capacity
+	}
+}
+
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// WithMaxWaiters caps the duplicates waiting on one in-flight key, the
+// ones beyond are answered at once.
+func WithMaxWaiters(waiters int) Option {
+	return func(c *Cache) {
+		c.waiters = waiters
+	}
+}
+
+// WithStore keeps a copy of every record in store, used for windows longer
+// than the in-memory cache can hold.
+func WithStore(store Store) Option {
+	return func(c *Cache) {
+		c.store = store
+	}
+}
+
+// WithStoreTimeout bounds the calls to the store, DefaultStoreTimeout when
+// zero.
+func WithStoreTimeout(timeout time.Duration) Option {
+	return func(c *Cache) {
+		c.timeout = timeout
+	}
+}
+
+func NewCache(opts ...Option) *Cache {
+	c := &Cache{
+		capacity: DefaultCapacity,
+		ttl:      DefaultTTL,
+		waiters:  DefaultMaxWaiters,
+		timeout:  DefaultStoreTimeout,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.timeout <= 0 {
+		c.timeout = DefaultStoreTimeout
+	}
+
+	return c
+}
+
+// SetStore is WithStore for a store available after the cache is in use,
+// e.g. once its database is connected.
+func (c *Cache) SetStore(store Store) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+}
+
+// HasStore reports whether Begin may wait for the store.
+func (c *Cache) HasStore() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store != nil
+}
+
+// Key builds the cache key of a request from the caller identity and the
+// idempotency key it supplied.
+func Key(client, idempotencyKey string) string {
+	return client + "|" + idempotencyKey
+}
+
+// Begin registers a request for key.
+//
+// run is true when the caller is the first one for key and must run the
+// handler and call Complete. Otherwise record is the cached response to
+// replay, or nil when the first request is still in flight, in which case
+// onDone is called once it completes. onDone gets nil instead when the
+// first request is aborted, when it is still running after wait (bounded by
+// the entry TTL, which alone applies when wait is zero), or at once when
+// too many duplicates are waiting already.
+func (c *Cache) Begin(key string, wait time.Duration, onDone func(*Record)) (record *Record, run bool) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*entry)
+		if now.Before(e.expireAt) {
+			c.lru.MoveToFront(elem)
+			if e.record != nil {
+				c.mu.Unlock()
+				return e.record, false
+			}
+			if onDone == nil {
+				c.mu.Unlock()
+				return nil, false
+			}
+			if c.waiters > 0 && len(e.waiters) >= c.waiters {
+				c.mu.Unlock()
+				logger.Log(logger.WarnLevel, "dedup too many waiters", "key", key)
+				onDone(nil)
+				return nil, false
+			}
+			if left := e.expireAt.Sub(now); wait <= 0 || wait > left {
+				wait = left
+			}
+			w := &waiter{onDone: onDone}
+			e.waiters = append(e.waiters, w)
+			w.timer = time.AfterFunc(wait, func() {
+				c.expire(e, w)
+			})
+			c.mu.Unlock()
+			return nil, false
+		}
+		// the waiters of an expired key expire with it
+		c.removeElement(elem)
+	}
+	c.add(&entry{key: key, expireAt: now.Add(c.ttl)})
+	store := c.store
+	c.mu.Unlock()
+
+	if store == nil {
+		return nil, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	stored, err := store.Load(ctx, key)
+	if err != nil {
+		logger.Log(logger.ErrorLevel, "dedup Load", "key", key, "err", err)
+		return nil, true
+	}
+	if stored == nil || !now.Before(stored.ExpireAt) {
+		return nil, true
+	}
+
+	c.finish(key, stored)
+	return stored, false
+}
+
+// Complete caches the response of the request that Begin let run and replays
+// it to the duplicates that arrived meanwhile.
+func (c *Cache) Complete(key string, record *Record) {
+	record.Key = key
+	record.ExpireAt = time.Now().Add(c.ttl)
+	c.finish(key, record)
+
+	c.mu.Lock()
+	store := c.store
+	c.mu.Unlock()
+	if store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	if err := store.Save(ctx, record); err != nil {
+		logger.Log(logger.ErrorLevel, "dedup Save", "key", key, "err", err)
+	}
+}
+
+// Abort forgets an in-flight key whose request never reached its handler, so
+// the next resend runs it. Waiting duplicates get nil.
+func (c *Cache) Abort(key string) {
+	c.mu.Lock()
+	var waiters []func(*Record)
+	if elem, ok := c.entries[key]; ok && elem.Value.(*entry).record == nil {
+		waiters = c.release(elem.Value.(*entry))
+		c.removeElement(elem)
+	}
+	c.mu.Unlock()
+
+	for _, fn := range waiters {
+		fn(nil)
+	}
+}
+
+func (c *Cache) finish(key string, record *Record) {
+	c.mu.Lock()
+	var waiters []func(*Record)
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*entry)
+		e.record = record
+		e.expireAt = record.ExpireAt
+		waiters = c.release(e)
+	} else {
+		c.add(&entry{key: key, record: record, expireAt: record.ExpireAt})
+	}
+	c.mu.Unlock()
+
+	for _, fn := range waiters {
+		fn(record)
+	}
+}
+
+// release takes the waiters of e not yet expired, the caller holds c.mu.
+func (c *Cache) release(e *entry) []func(*Record) {
+	var waiters []func(*Record)
+	for _, w := range e.waiters {
+		if !w.done {
+			w.done = true
+			w.timer.Stop()
+			waiters = append(waiters, w.onDone)
+		}
+	}
+	e.waiters = nil
+	return waiters
+}
+
+// expire gives up on w, still waiting on e after its wait.
+func (c *Cache) expire(e *entry, w *waiter) {
+	c.mu.Lock()
+	if w.done {
+		c.mu.Unlock()
+		return
+	}
+	w.done = true
+	if i := slices.Index(e.waiters, w); i >= 0 {
+		e.waiters = slices.Delete(e.waiters, i, i+1)
+	}
+	c.mu.Unlock()
+	w.onDone(nil)
+}
+
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) add(e *entry) {
+	c.entries[e.key] = c.lru.PushFront(e)
+	for c.capacity > 0 && c.lru.Len() > c.capacity {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	e := c.lru.Remove(elem).(*entry)
+	delete(c.entries, e.key)
+}
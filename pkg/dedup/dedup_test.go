@@ -0,0 +1,164 @@
+package dedup
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func (s *memStore) Load(ctx context.Context, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], nil
+}
+
+func (s *memStore) Save(ctx context.Context, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Key] = record
+	return nil
+}
+
+func TestCacheReplay(t *testing.T) {
+	cache := NewCache()
+	key := Key("engine.0.1.1.client.1", "complete-upload")
+
+	record, run := cache.Begin(key, 0, nil)
+	assert.Nil(t, record)
+	assert.True(t, run)
+
+	var replayed *Record
+	record, run = cache.Begin(key, 0, func(r *Record) {
+		replayed = r
+	})
+	assert.Nil(t, record)
+	assert.False(t, run)
+
+	cache.Complete(key, &Record{ReplyData: []byte("ok")})
+	assert.Equal(t, []byte("ok"), replayed.ReplyData)
+
+	record, run = cache.Begin(key, 0, nil)
+	assert.False(t, run)
+	assert.Equal(t, []byte("ok"), record.ReplyData)
+}
+
+func TestCacheExpireAndEvict(t *testing.T) {
+	cache := NewCache(WithTTL(20*time.Millisecond), WithCapacity(2))
+
+	cache.Begin("a", 0, nil)
+	cache.Complete("a", &Record{})
+	time.Sleep(30 * time.Millisecond)
+	_, run := cache.Begin("a", 0, nil)
+	assert.True(t, run)
+
+	cache.Begin("b", 0, nil)
+	cache.Begin("c", 0, nil)
+	assert.Equal(t, 2, cache.Len())
+	_, run = cache.Begin("a", 0, nil)
+	assert.True(t, run)
+}
+
+func TestCacheAbort(t *testing.T) {
+	cache := NewCache()
+	cache.Begin("a", 0, nil)
+	cache.Abort("a")
+	_, run := cache.Begin("a", 0, nil)
+	assert.True(t, run)
+}
+
+func TestCacheStore(t *testing.T) {
+	store := &memStore{records: make(map[string]*Record)}
+	first := NewCache(WithStore(store))
+	first.Begin("a", 0, nil)
+	first.Complete("a", &Record{ErrCode: 123, ErrMsg: "quota"})
+
+	second := NewCache(WithStore(store))
+	record, run := second.Begin("a", 0, nil)
+	assert.False(t, run)
+	assert.Equal(t, uint32(123), record.ErrCode)
+}
+
+// blockingStore answers only once ctx is done.
+type blockingStore struct{}
+
+func (blockingStore) Load(ctx context.Context, key string) (*Record, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingStore) Save(ctx context.Context, record *Record) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCacheStoreTimeout(t *testing.T) {
+	cache := NewCache(WithStoreTimeout(20 * time.Millisecond))
+	assert.False(t, cache.HasStore())
+	cache.SetStore(blockingStore{})
+	assert.True(t, cache.HasStore())
+
+	start := time.Now()
+	record, run := cache.Begin("a", 0, nil)
+	assert.Nil(t, record)
+	assert.True(t, run)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestCacheWaiters(t *testing.T) {
+	cache := NewCache(WithMaxWaiters(2), WithTTL(time.Hour))
+	_, run := cache.Begin("a", 0, nil)
+	assert.True(t, run)
+
+	done := make(chan string, 4)
+	wait := func(name string) func(*Record) {
+		return func(r *Record) {
+			if r == nil {
+				done <- name + ":nil"
+				return
+			}
+			done <- name + ":" + string(r.ReplyData)
+		}
+	}
+	cache.Begin("a", 20*time.Millisecond, wait("short"))
+	cache.Begin("a", time.Minute, wait("long"))
+	// beyond the cap the duplicate is answered at once
+	cache.Begin("a", time.Minute, wait("rejected"))
+	assert.Equal(t, "rejected:nil", <-done)
+
+	select {
+	case got := <-done:
+		assert.Equal(t, "short:nil", got)
+	case <-time.After(time.Second):
+		t.Fatal("waiter not expired")
+	}
+	// the expired waiter made room for another one
+	cache.Begin("a", time.Minute, wait("late"))
+
+	cache.Complete("a", &Record{ReplyData: []byte("ok")})
+	assert.ElementsMatch(t, []string{"long:ok", "late:ok"}, []string{<-done, <-done})
+	assert.Empty(t, done)
+
+	// the waiters of an aborted key learn it at once, as those of an
+	// in-flight key when its ttl ends first
+	cache = NewCache(WithTTL(20 * time.Millisecond))
+	cache.Begin("b", 0, nil)
+	cache.Begin("b", time.Minute, wait("expired"))
+	cache.Begin("c", 0, nil)
+	cache.Begin("c", time.Minute, wait("aborted"))
+	cache.Abort("c")
+	assert.Equal(t, "aborted:nil", <-done)
+	select {
+	case got := <-done:
+		assert.Equal(t, "expired:nil", got)
+	case <-time.After(time.Second):
+		t.Fatal("waiter outlived the ttl")
+	}
+}
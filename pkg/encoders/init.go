@@ -1,6 +1,35 @@
 package encoders
 
+import "fmt"
+
 type IEncoder interface {
 	Encode(data any) ([]byte, error)
 	Decode(encodeData []byte, decodeData any) error
 }
+
+const (
+	CodecProtobuf uint32 = 0
+	CodecJson     uint32 = 1
+)
+
+// GetCodecType returns the wire identifier of the given encoder.
+func GetCodecType(codec IEncoder) uint32 {
+	switch codec.(type) {
+	case *JsonEncoder:
+		return CodecJson
+	default:
+		return CodecProtobuf
+	}
+}
+
+// NewEncoder creates the encoder identified by codecType.
+func NewEncoder(codecType uint32) (IEncoder, error) {
+	switch codecType {
+	case CodecProtobuf:
+		return NewProtobufEncoder(), nil
+	case CodecJson:
+		return NewJsonEncoder(), nil
+	default:
+		return nil, fmt.Errorf("unknown codec type:%d", codecType)
+	}
+}
@@ -3,8 +3,17 @@ package errs
 const (
 	CODE_OK = 0
 
+	// Framework error codes, the values of rpc_msg.RPC_CODE.
+	CODE_Timeout          = 100 // No reply within the request timeout
+	CODE_ParseError       = 102 // Arguments or reply could not be decoded
+	CODE_OpcodeUnregister = 106 // No handler registered for the opcode
+	CODE_CreateMsgError   = 107 // Reply could not be encoded
+	CODE_NotReceivedReply = 109 // Connection lost before the reply arrived
+	CODE_NotSend          = 110 // Request could not be sent
+
 	// General error codes.
 	CODE_ServerInternalError = 500  // Server internal error
+	CODE_CircuitOpen         = 503  // Target node ejected by its circuit breaker
 	CODE_ArgsError           = 1001 // Input parameter error
 	CODE_NoPermissionError   = 1002 // Insufficient permission
 	CODE_DuplicateKeyError   = 1003
@@ -20,3 +29,15 @@ const (
 	CODE_TokenKickedError      = 1506
 	CODE_TokenNotExistError    = 1507
 )
+
+// Framework errors, errors.Is matches them against any CodeError with the
+// same code.
+var (
+	ErrTimeout          = New(CODE_Timeout, "request timeout", WithRetryable(true))
+	ErrParseError       = New(CODE_ParseError, "parse error")
+	ErrOpcodeUnregister = New(CODE_OpcodeUnregister, "opcode unregister")
+	ErrCreateMsgError   = New(CODE_CreateMsgError, "create msg error")
+	ErrNotReceivedReply = New(CODE_NotReceivedReply, "reply not received", WithRetryable(true))
+	ErrNotSend          = New(CODE_NotSend, "request not sent")
+	ErrCircuitOpen      = New(CODE_CircuitOpen, "circuit open")
+)
@@ -1,13 +1,64 @@
 package errs
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type CodeError interface {
 	Code() int32
 	Msg() string
+	// Details are key/value pairs describing the error, they are sent to the
+	// caller along with the code.
+	Details() map[string]string
+	// Retryable reports whether the caller may send the request again.
+	Retryable() bool
 	error
 }
 
+// Option sets the structured fields of a CodeError.
+type Option func(*codeError)
+
+// WithDetail adds the detail key with value.
+func WithDetail(key, value string) Option {
+	return func(e *codeError) {
+		if e.details == nil {
+			e.details = make(map[string]string)
+		}
+		e.details[key] = value
+	}
+}
+
+// WithDetails adds the details, the map is copied.
+func WithDetails(details map[string]string) Option {
+	return func(e *codeError) {
+		for key, value := range details {
+			WithDetail(key, value)(e)
+		}
+	}
+}
+
+// WithRetryable marks the error as one the caller may retry.
+func WithRetryable(retryable bool) Option {
+	return func(e *codeError) {
+		e.retryable = retryable
+	}
+}
+
+// New returns the error with code and msg.
+func New(code int32, msg string, opts ...Option) CodeError {
+	e := &codeError{
+		code: code,
+		msg:  msg,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// NewCodeError wraps err with code, CODE_ServerInternalError by default. An
+// err that already is a CodeError keeps its code when none is given.
 func NewCodeError(err error, code ...int32) CodeError {
 	if err == nil {
 		return &codeError{
@@ -19,6 +70,10 @@ func NewCodeError(err error, code ...int32) CodeError {
 	if len(code) > 0 {
 		iCode = code[0]
 	} else {
+		var codeErr CodeError
+		if errors.As(err, &codeErr) {
+			return codeErr
+		}
 		iCode = CODE_ServerInternalError
 	}
 
@@ -28,9 +83,30 @@ func NewCodeError(err error, code ...int32) CodeError {
 	}
 }
 
+// CodeOf is the code of err, CODE_OK for nil and CODE_ServerInternalError
+// when err is not a CodeError.
+func CodeOf(err error) int32 {
+	if err == nil {
+		return CODE_OK
+	}
+	var codeErr CodeError
+	if errors.As(err, &codeErr) {
+		return codeErr.Code()
+	}
+	return CODE_ServerInternalError
+}
+
+// IsRetryable reports whether err is a CodeError marked retryable.
+func IsRetryable(err error) bool {
+	var codeErr CodeError
+	return errors.As(err, &codeErr) && codeErr.Retryable()
+}
+
 type codeError struct {
-	code int32
-	msg  string
+	code      int32
+	msg       string
+	details   map[string]string
+	retryable bool
 }
 
 func (e *codeError) Code() int32 {
@@ -41,7 +117,22 @@ func (e *codeError) Msg() string {
 	return e.msg
 }
 
+func (e *codeError) Details() map[string]string {
+	return e.details
+}
+
+func (e *codeError) Retryable() bool {
+	return e.retryable
+}
+
 func (e *codeError) Error() string {
 	sError := fmt.Sprintf("{code:%d,msg:%q}", e.code, e.msg)
 	return sError
 }
+
+// Is matches any CodeError with the same code, so that errors.Is(err,
+// ErrTimeout) holds whatever the message and details of err.
+func (e *codeError) Is(target error) bool {
+	t, ok := target.(CodeError)
+	return ok && t.Code() == e.code
+}
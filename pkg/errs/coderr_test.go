@@ -0,0 +1,46 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCodeError(t *testing.T) {
+	err := NewCodeError(errors.New("boom"))
+	assert.Equal(t, int32(CODE_ServerInternalError), err.Code())
+	assert.Equal(t, "boom", err.Msg())
+	assert.Equal(t, int32(CODE_OK), NewCodeError(nil).Code())
+	assert.Equal(t, int32(CODE_ArgsError), NewCodeError(errors.New("boom"), CODE_ArgsError).Code())
+
+	// a CodeError keeps its code and details
+	codeErr := New(CODE_ArgsError, "invalid", WithDetail("field", "name"))
+	assert.Same(t, codeErr, NewCodeError(fmt.Errorf("wrapped: %w", codeErr)))
+}
+
+func TestCodeErrorDetails(t *testing.T) {
+	err := New(CODE_NoPermissionError, "denied",
+		WithDetails(map[string]string{"role": "guest"}),
+		WithDetail("action", "delete"),
+		WithRetryable(true),
+	)
+	assert.Equal(t, map[string]string{"role": "guest", "action": "delete"}, err.Details())
+	assert.True(t, err.Retryable())
+	assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", err)))
+	assert.False(t, IsRetryable(errors.New("boom")))
+	assert.Equal(t, `{code:1002,msg:"denied"}`, err.Error())
+}
+
+func TestCodeErrorIs(t *testing.T) {
+	err := New(CODE_Timeout, "no reply after 3s")
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.ErrorIs(t, fmt.Errorf("wrapped: %w", err), ErrTimeout)
+	assert.NotErrorIs(t, err, ErrNotReceivedReply)
+	assert.NotErrorIs(t, err, errors.New("no reply after 3s"))
+
+	assert.Equal(t, int32(CODE_Timeout), CodeOf(err))
+	assert.Equal(t, int32(CODE_OK), CodeOf(nil))
+	assert.Equal(t, int32(CODE_ServerInternalError), CodeOf(errors.New("boom")))
+}
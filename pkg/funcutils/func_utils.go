@@ -153,10 +153,11 @@ func GetRPCReflectFunc(fun any, reportErr bool) *MethodType {
 			return nil
 		}
 
-		// Method needs no return
-		if mtype.NumOut() != 0 {
+		// Method returns nothing, or an error to have a durable notify
+		// redelivered
+		if mtype.NumOut() > 1 || (mtype.NumOut() == 1 && mtype.Out(0) != typeOfError) {
 			if reportErr {
-				log.Printf("rpc.Register: method %q has %d output parameters; needs exactly zero", mname, mtype.NumOut())
+				log.Printf("rpc.Register: method %q must return nothing or error", mname)
 			}
 			return nil
 		}
@@ -218,11 +219,15 @@ func CallPRCReflectNotifyFunc[T1 any](method *MethodType, ctx context.Context, a
 	args[1] = reflect.ValueOf(arg1)
 
 	rets := method.Func.Call(args)
-	if len(rets) != 0 {
+	switch len(rets) {
+	case 0:
+		return nil
+	case 1:
+		err, _ := rets[0].Interface().(error)
+		return err
+	default:
 		return errors.New("invalid rets len")
 	}
-
-	return nil
 }
 
 // func(client *Client, request *PB, response *PB) errs.CodeError
@@ -0,0 +1,153 @@
+// Package gateway forwards the opaque messages of the clients connected to a
+// gateway node to the backend service owning their opcode, and routes the
+// replies and pushes of the services back to the client sessions.
+//
+// The gateway sends RPC_Multiplexer_Forward, tagged with the user id and the
+// session, to the service picked by the RouteTable. The service answers, or
+// pushes later on, with PRC_DeMultiplexer_Forward to the gateway actor named
+// in the role, which writes it to the session.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+)
+
+const (
+	OpcodeForward = uint32(rpc_msg.RPC_OPCODES_RPC_MultiplexerForward)
+	OpcodeDeliver = uint32(rpc_msg.RPC_OPCODES_RPC_DeMultiplexerForward)
+)
+
+// Connection types of ClientMessageInfo.
+const (
+	ConnectionWS  uint32 = 1
+	ConnectionTCP uint32 = 2
+)
+
+// DefaultServiceActor is the id of the Service actor of the backend nodes.
+var DefaultServiceActor = "gateway.service"
+
+var ErrNoRoute = errors.New("gateway: no route for opcode")
+
+// Session is a client connection of a gateway node.
+type Session interface {
+	// Deliver writes a reply or a push of a backend service to the client.
+	Deliver(message *rpc_msg.PRC_DeMultiplexer_Forward) error
+}
+
+// Multiplexer is the actor of a gateway node forwarding the client messages
+// of its sessions.
+type Multiplexer struct {
+	*actor.Actor
+	routes *RouteTable
+
+	mu       sync.RWMutex
+	sessions map[uint64]Session
+	nextId   atomic.Uint64
+}
+
+// NewMultiplexer returns the actor named id forwarding by routes, it must be
+// spawned on engine before forwarding.
+func NewMultiplexer(engine concepts.IEngine, id string, routes *RouteTable) *Multiplexer {
+	return &Multiplexer{
+		Actor:    actor.NewActor(id, engine),
+		routes:   routes,
+		sessions: make(map[uint64]Session),
+	}
+}
+
+func (m *Multiplexer) OnInit() error {
+	return m.Register(OpcodeDeliver, m.deliver)
+}
+
+func (m *Multiplexer) OnShutdown() {
+}
+
+func (m *Multiplexer) Routes() *RouteTable {
+	return m.routes
+}
+
+// Routed reports whether the messages with opcode are forwarded.
+func (m *Multiplexer) Routed(opcode uint32) bool {
+	_, ok := m.routes.Lookup(opcode)
+	return ok
+}
+
+// Attach adds session, the returned id tags its forwarded messages.
+func (m *Multiplexer) Attach(session Session) uint64 {
+	id := m.nextId.Add(1)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id] = session
+	return id
+}
+
+// Detach removes the session, late replies to it are dropped.
+func (m *Multiplexer) Detach(id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+}
+
+// Session returns the session attached with id.
+func (m *Multiplexer) Session(id uint64) (Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	session, ok := m.sessions[id]
+	return session, ok
+}
+
+// Forward sends body, the client message described by info, to the service
+// owning info.Opcode. It is sent by sender, e.g. the actor of the session,
+// or by the multiplexer itself when sender is nil.
+func (m *Multiplexer) Forward(sender concepts.IActor, userId uint64, info *rpc_msg.ClientMessageInfo, body []byte) error {
+	route, ok := m.routes.Lookup(info.Opcode)
+	if !ok {
+		return ErrNoRoute
+	}
+	realm, kind, id, err := concepts.DecodeAddress(m.ActorId().Address)
+	if err != nil {
+		return err
+	}
+	if sender == nil {
+		sender = m
+	}
+
+	forward := &rpc_msg.RPC_Multiplexer_Forward{
+		Role: &rpc_msg.RoleIdentifier{
+			UserId: userId,
+			GwId: &rpc_msg.CHANNEL{
+				Realm:   realm,
+				Type:    kind,
+				Id:      id,
+				ActorId: m.ActorId().ID,
+			},
+			Info: &rpc_msg.ClientMessageInfo{
+				SessionId:     info.SessionId,
+				ConnetionType: info.ConnetionType,
+			},
+		},
+		Info:    info,
+		BodyMsg: body,
+	}
+	return actor.SendNotify(sender, route.Target(realm), OpcodeForward, forward)
+}
+
+func (m *Multiplexer) deliver(ctx context.Context, message *rpc_msg.PRC_DeMultiplexer_Forward) {
+	sessionId := message.GetInfo().GetSessionId()
+	session, ok := m.Session(sessionId)
+	if !ok {
+		logger.Log(logger.DebugLevel, "gateway drop message of closed session", "session", sessionId, "opcode", message.GetInfo().GetOpcode())
+		return
+	}
+	if err := session.Deliver(message); err != nil {
+		logger.Log(logger.WarnLevel, "gateway deliver", "session", sessionId, "opcode", message.GetInfo().GetOpcode(), "err", err)
+	}
+}
@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+)
+
+// Route sends the client messages with an opcode in [Min, Max] to the actor
+// Actor of any node of kind Kind, DefaultServiceActor when Actor is empty.
+type Route struct {
+	Min   uint32 `json:"min"`
+	Max   uint32 `json:"max"`
+	Kind  uint32 `json:"kind"`
+	Actor string `json:"actor"`
+}
+
+func (r Route) String() string {
+	return fmt.Sprintf("[%d,%d]->kind %d", r.Min, r.Max, r.Kind)
+}
+
+// Target is the actor handling the routed messages in realm, the queue group
+// of the kind picks one of its nodes.
+func (r Route) Target(realm uint32) *concepts.ActorId {
+	actor := r.Actor
+	if actor == "" {
+		actor = DefaultServiceActor
+	}
+	return concepts.NewAnyActorId(realm, r.Kind, actor)
+}
+
+func (r Route) validate() error {
+	if r.Min > r.Max {
+		return fmt.Errorf("route %s: min is greater than max", r)
+	}
+	if r.Kind == 0 {
+		return fmt.Errorf("route %s: kind is required", r)
+	}
+	return nil
+}
+
+// RouteTable maps opcode ranges to the service kinds owning them, the ranges
+// do not overlap.
+type RouteTable struct {
+	mu     sync.RWMutex
+	routes []Route // sorted by Min
+}
+
+func NewRouteTable(routes ...Route) (*RouteTable, error) {
+	t := &RouteTable{}
+	if err := t.Replace(routes); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Add inserts route, failing when it overlaps an existing one.
+func (t *RouteTable) Add(route Route) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	routes, err := insert(append([]Route(nil), t.routes...), route)
+	if err != nil {
+		return err
+	}
+	t.routes = routes
+	return nil
+}
+
+// Replace swaps every route at once, leaving the table untouched when one of
+// them is invalid.
+func (t *RouteTable) Replace(routes []Route) error {
+	var (
+		sorted []Route
+		err    error
+	)
+	for _, route := range routes {
+		if sorted, err = insert(sorted, route); err != nil {
+			return err
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes = sorted
+	return nil
+}
+
+func insert(routes []Route, route Route) ([]Route, error) {
+	if err := route.validate(); err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(routes), func(i int) bool { return routes[i].Min > route.Min })
+	if i > 0 && routes[i-1].Max >= route.Min {
+		return nil, fmt.Errorf("route %s overlaps %s", route, routes[i-1])
+	}
+	if i < len(routes) && routes[i].Min <= route.Max {
+		return nil, fmt.Errorf("route %s overlaps %s", route, routes[i])
+	}
+	return append(routes[:i], append([]Route{route}, routes[i:]...)...), nil
+}
+
+// Lookup returns the route of opcode.
+func (t *RouteTable) Lookup(opcode uint32) (Route, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	i := sort.Search(len(t.routes), func(i int) bool { return t.routes[i].Min > opcode })
+	if i == 0 || t.routes[i-1].Max < opcode {
+		return Route{}, false
+	}
+	return t.routes[i-1], true
+}
+
+// Routes returns the routes sorted by opcode.
+func (t *RouteTable) Routes() []Route {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]Route(nil), t.routes...)
+}
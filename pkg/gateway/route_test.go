@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteTable(t *testing.T) {
+	table, err := NewRouteTable(
+		Route{Min: 3000, Max: 3999, Kind: 3, Actor: "chat"},
+		Route{Min: 1000, Max: 1999, Kind: 1},
+		Route{Min: 2000, Max: 2000, Kind: 2},
+	)
+	require.NoError(t, err)
+
+	for opcode, kind := range map[uint32]uint32{1000: 1, 1500: 1, 1999: 1, 2000: 2, 3000: 3, 3999: 3} {
+		route, ok := table.Lookup(opcode)
+		require.True(t, ok, opcode)
+		assert.Equal(t, kind, route.Kind, opcode)
+	}
+	for _, opcode := range []uint32{0, 999, 2001, 2999, 4000} {
+		_, ok := table.Lookup(opcode)
+		assert.False(t, ok, opcode)
+	}
+
+	route, _ := table.Lookup(3001)
+	assert.Equal(t, "engine.0.3.any", route.Target(0).Address)
+	assert.Equal(t, "chat", route.Target(0).ID)
+	route, _ = table.Lookup(1001)
+	assert.Equal(t, DefaultServiceActor, route.Target(7).ID)
+	assert.Equal(t, "engine.7.1.any", route.Target(7).Address)
+
+	require.NoError(t, table.Add(Route{Min: 2001, Max: 2999, Kind: 2}))
+	route, ok := table.Lookup(2500)
+	require.True(t, ok)
+	assert.Equal(t, uint32(2), route.Kind)
+	assert.Len(t, table.Routes(), 4)
+}
+
+func TestRouteTableInvalid(t *testing.T) {
+	table, err := NewRouteTable(Route{Min: 100, Max: 199, Kind: 1})
+	require.NoError(t, err)
+
+	for _, route := range []Route{
+		{Min: 150, Max: 250, Kind: 2},
+		{Min: 50, Max: 100, Kind: 2},
+		{Min: 0, Max: 1000, Kind: 2},
+		{Min: 120, Max: 130, Kind: 2},
+		{Min: 300, Max: 200, Kind: 2},
+		{Min: 300, Max: 400},
+	} {
+		assert.Error(t, table.Add(route), route.String())
+	}
+
+	// a failed replace keeps the current routes
+	assert.Error(t, table.Replace([]Route{{Min: 1, Max: 10, Kind: 1}, {Min: 5, Max: 20, Kind: 1}}))
+	assert.Equal(t, []Route{{Min: 100, Max: 199, Kind: 1}}, table.Routes())
+}
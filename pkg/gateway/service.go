@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+)
+
+// Message is a client message forwarded to a backend service.
+type Message struct {
+	// Role identifies the user and its session, keep it to push later on.
+	Role *rpc_msg.RoleIdentifier
+	// Info describes the message, the reply is sent with Info.ResponseOpcode
+	// or Info.Opcode when it is zero.
+	Info *rpc_msg.ClientMessageInfo
+	Body []byte
+}
+
+func (m *Message) UserId() uint64 {
+	return m.Role.GetUserId()
+}
+
+// Handler handles the client messages of an opcode. Its result is the reply
+// written to the session, no reply is sent when both are nil.
+type Handler func(ctx context.Context, message *Message) ([]byte, errs.CodeError)
+
+// Service is the actor of a backend node handling the client messages
+// forwarded by the gateways.
+type Service struct {
+	*actor.Actor
+
+	mu       sync.RWMutex
+	handlers map[uint32]Handler
+}
+
+// NewService returns the actor DefaultServiceActor of engine, the routes of
+// the gateways address it.
+func NewService(engine concepts.IEngine) *Service {
+	return NewServiceWithId(engine, DefaultServiceActor)
+}
+
+// NewServiceWithId returns a service for the routes naming actor id.
+func NewServiceWithId(engine concepts.IEngine, id string) *Service {
+	return &Service{
+		Actor:    actor.NewActor(id, engine),
+		handlers: make(map[uint32]Handler),
+	}
+}
+
+func (s *Service) OnInit() error {
+	return s.Register(OpcodeForward, s.forward)
+}
+
+func (s *Service) OnShutdown() {
+}
+
+// Handle sets the handler of the client messages with opcode.
+func (s *Service) Handle(opcode uint32, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[opcode] = handler
+}
+
+func (s *Service) forward(ctx context.Context, request *rpc_msg.RPC_Multiplexer_Forward) {
+	message := &Message{Role: request.GetRole(), Info: request.GetInfo(), Body: request.GetBodyMsg()}
+	if message.Info == nil || message.Role.GetGwId() == nil {
+		logger.Log(logger.WarnLevel, "gateway service drop message without session", "role", message.Role)
+		return
+	}
+
+	s.mu.RLock()
+	handler, ok := s.handlers[message.Info.Opcode]
+	s.mu.RUnlock()
+	if !ok {
+		s.reply(message, nil, statusOf(errs.New(errs.CODE_OpcodeUnregister, "unregister opcode")))
+		return
+	}
+
+	body, code := handler(ctx, message)
+	if code != nil {
+		s.reply(message, body, statusOf(code))
+		return
+	}
+	if body != nil {
+		s.reply(message, body, nil)
+	}
+}
+
+// Reply answers message with body, the reply carries its sequence number.
+func (s *Service) Reply(message *Message, body []byte) error {
+	return s.reply(message, body, nil)
+}
+
+func (s *Service) reply(message *Message, body []byte, status *rpc_msg.STATUS) error {
+	opcode := message.Info.ResponseOpcode
+	if opcode == 0 {
+		opcode = message.Info.Opcode
+	}
+	info := &rpc_msg.ClientMessageInfo{
+		SessionId:     message.Info.SessionId,
+		SeqNum:        message.Info.SeqNum,
+		Opcode:        opcode,
+		ConnetionType: message.Info.ConnetionType,
+	}
+	err := s.deliver(message.Role, info, body, status)
+	if err != nil {
+		logger.Log(logger.WarnLevel, "gateway service reply", "user", message.UserId(), "opcode", opcode, "err", err)
+	}
+	return err
+}
+
+// Push sends body to the session of role as a message with opcode the
+// client did not ask for.
+func (s *Service) Push(role *rpc_msg.RoleIdentifier, opcode uint32, body []byte) error {
+	info := &rpc_msg.ClientMessageInfo{
+		SessionId:     role.GetInfo().GetSessionId(),
+		Opcode:        opcode,
+		ConnetionType: role.GetInfo().GetConnetionType(),
+	}
+	return s.deliver(role, info, body, nil)
+}
+
+func (s *Service) deliver(role *rpc_msg.RoleIdentifier, info *rpc_msg.ClientMessageInfo, body []byte, status *rpc_msg.STATUS) error {
+	return actor.SendNotify(s, GatewayOf(role), OpcodeDeliver, &rpc_msg.PRC_DeMultiplexer_Forward{
+		Role:    role,
+		Info:    info,
+		BodyMsg: body,
+		Status:  status,
+	})
+}
+
+// GatewayOf is the multiplexer actor the session of role is attached to.
+func GatewayOf(role *rpc_msg.RoleIdentifier) *concepts.ActorId {
+	gw := role.GetGwId()
+	return concepts.NewActorId(concepts.GenServerAddress(gw.GetRealm(), gw.GetType(), gw.GetId()), gw.GetActorId())
+}
+
+func statusOf(code errs.CodeError) *rpc_msg.STATUS {
+	return &rpc_msg.STATUS{
+		Code:      uint32(code.Code()),
+		Msg:       code.Msg(),
+		Details:   code.Details(),
+		Retryable: code.Retryable(),
+	}
+}
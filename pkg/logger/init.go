@@ -26,6 +26,7 @@ var (
 	showConsole                = true
 	showSource                 = true
 	showLogLevel               = InfoLevel
+	levelVar                   = new(slog.LevelVar)
 )
 
 func init() {
@@ -35,9 +36,10 @@ func init() {
 		showLogLevel = InfoLevel
 	}
 
+	levelVar.Set(slog.Level(showLogLevel))
 	options := &slog.HandlerOptions{
 		AddSource: showSource,
-		Level:     slog.Level(showLogLevel),
+		Level:     levelVar,
 	}
 	// 创建一个屏幕输出的 Handler
 	consoleHandler := slog.NewTextHandler(os.Stdout, options)
@@ -89,6 +91,15 @@ func GetLevel(levelStr string) (Level, error) {
 	return InfoLevel, fmt.Errorf("unknown Level String: '%s', defaulting to InfoLevel", levelStr)
 }
 
+// SetLevel changes the minimum level of both loggers at runtime.
+func SetLevel(level Level) {
+	levelVar.Set(slog.Level(level))
+}
+
+func GetCurrentLevel() Level {
+	return Level(levelVar.Level())
+}
+
 func Log(level Level, msg string, args ...any) {
 	ctx := context.Background()
 	logLevel := slog.Level(level)
@@ -0,0 +1,352 @@
+// Package metrics keeps counters, gauges and histograms and writes them in
+// the Prometheus text exposition format, without depending on the
+// Prometheus client.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Type string
+
+const (
+	CounterType   Type = "counter"
+	GaugeType     Type = "gauge"
+	HistogramType Type = "histogram"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of latency histograms.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// Sample is one line of a family. Suffix is appended to the family name,
+// e.g. _bucket, and Labels holds name, value pairs.
+type Sample struct {
+	Suffix string
+	Labels []string
+	Value  float64
+}
+
+// Family is a metric with all its label combinations.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Collector hands its current families to emit when the metrics are
+// written.
+type Collector interface {
+	Collect(emit func(Family))
+}
+
+// CollectorFunc adapts a function to a Collector.
+type CollectorFunc func(emit func(Family))
+
+func (f CollectorFunc) Collect(emit func(Family)) {
+	f(emit)
+}
+
+// Registry holds the collectors written together, families of the same name
+// emitted by several collectors are merged.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[*registration]struct{}
+}
+
+type registration struct {
+	collector Collector
+}
+
+// Default is the registry the package level constructors register with.
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[*registration]struct{})}
+}
+
+// Register adds c, the returned function removes it again.
+func (r *Registry) Register(c Collector) (unregister func()) {
+	entry := &registration{collector: c}
+	r.mu.Lock()
+	r.collectors[entry] = struct{}{}
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		delete(r.collectors, entry)
+		r.mu.Unlock()
+	}
+}
+
+// Gather collects the families of every collector sorted by name.
+func (r *Registry) Gather() []Family {
+	r.mu.RLock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for entry := range r.collectors {
+		collectors = append(collectors, entry.collector)
+	}
+	r.mu.RUnlock()
+
+	merged := make(map[string]*Family)
+	for _, c := range collectors {
+		c.Collect(func(f Family) {
+			if existing, ok := merged[f.Name]; ok {
+				existing.Samples = append(existing.Samples, f.Samples...)
+				return
+			}
+			copied := f
+			copied.Samples = append([]Sample(nil), f.Samples...)
+			merged[f.Name] = &copied
+		})
+	}
+
+	families := make([]Family, 0, len(merged))
+	for _, f := range merged {
+		families = append(families, *f)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families
+}
+
+// vec keeps a child per combination of label values.
+type vec[T any] struct {
+	name     string
+	help     string
+	labels   []string
+	newChild func() *T
+
+	mu       sync.RWMutex
+	children map[string]*child[T]
+}
+
+type child[T any] struct {
+	values []string
+	metric *T
+}
+
+func newVec[T any](name, help string, labels []string, newChild func() *T) *vec[T] {
+	return &vec[T]{
+		name:     name,
+		help:     help,
+		labels:   labels,
+		newChild: newChild,
+		children: make(map[string]*child[T]),
+	}
+}
+
+func (v *vec[T]) with(values ...string) *T {
+	if len(values) != len(v.labels) {
+		panic("metrics: " + v.name + " expects labels " + strings.Join(v.labels, ","))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return c.metric
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok = v.children[key]; !ok {
+		c = &child[T]{values: append([]string(nil), values...), metric: v.newChild()}
+		v.children[key] = c
+	}
+	return c.metric
+}
+
+func (v *vec[T]) each(fn func(labels []string, metric *T)) {
+	v.mu.RLock()
+	children := make([]*child[T], 0, len(v.children))
+	for _, c := range v.children {
+		children = append(children, c)
+	}
+	v.mu.RUnlock()
+	sort.Slice(children, func(i, j int) bool {
+		return strings.Join(children[i].values, "\xff") < strings.Join(children[j].values, "\xff")
+	})
+
+	for _, c := range children {
+		labels := make([]string, 0, 2*len(v.labels))
+		for i, name := range v.labels {
+			labels = append(labels, name, c.values[i])
+		}
+		fn(labels, c.metric)
+	}
+}
+
+// Counter only goes up.
+type Counter struct {
+	bits atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Add(delta float64) {
+	addFloat(&c.bits, delta)
+}
+
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// Gauge goes up and down.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+func (g *Gauge) Set(value float64) {
+	g.bits.Store(math.Float64bits(value))
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) Add(delta float64) {
+	addFloat(&g.bits, delta)
+}
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     atomic.Uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets))}
+}
+
+func (h *Histogram) Observe(value float64) {
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	addFloat(&h.sum, value)
+}
+
+// ObserveSince observes the seconds elapsed since start.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+func (h *Histogram) samples(labels []string) []Sample {
+	samples := make([]Sample, 0, len(h.buckets)+3)
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i].Load()
+		samples = append(samples, Sample{
+			Suffix: "_bucket",
+			Labels: append(append([]string(nil), labels...), "le", formatFloat(bound)),
+			Value:  float64(cumulative),
+		})
+	}
+	count := h.count.Load()
+	samples = append(samples,
+		Sample{Suffix: "_bucket", Labels: append(append([]string(nil), labels...), "le", "+Inf"), Value: float64(count)},
+		Sample{Suffix: "_sum", Labels: labels, Value: math.Float64frombits(h.sum.Load())},
+		Sample{Suffix: "_count", Labels: labels, Value: float64(count)},
+	)
+	return samples
+}
+
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// NewCounterVec registers a counter family with Default.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{newVec(name, help, labels, func() *Counter { return &Counter{} })}
+	Default.Register(v)
+	return v
+}
+
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.with(values...)
+}
+
+func (v *CounterVec) Collect(emit func(Family)) {
+	f := Family{Name: v.name, Help: v.help, Type: CounterType}
+	v.each(func(labels []string, c *Counter) {
+		f.Samples = append(f.Samples, Sample{Labels: labels, Value: c.Value()})
+	})
+	emit(f)
+}
+
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+// NewGaugeVec registers a gauge family with Default.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{newVec(name, help, labels, func() *Gauge { return &Gauge{} })}
+	Default.Register(v)
+	return v
+}
+
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.with(values...)
+}
+
+func (v *GaugeVec) Collect(emit func(Family)) {
+	f := Family{Name: v.name, Help: v.help, Type: GaugeType}
+	v.each(func(labels []string, g *Gauge) {
+		f.Samples = append(f.Samples, Sample{Labels: labels, Value: g.Value()})
+	})
+	emit(f)
+}
+
+type HistogramVec struct {
+	*vec[Histogram]
+}
+
+// NewHistogramVec registers a histogram family with Default, buckets nil
+// meaning DefaultBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	v := &HistogramVec{newVec(name, help, labels, func() *Histogram { return newHistogram(buckets) })}
+	Default.Register(v)
+	return v
+}
+
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.with(values...)
+}
+
+func (v *HistogramVec) Collect(emit func(Family)) {
+	f := Family{Name: v.name, Help: v.help, Type: HistogramType}
+	v.each(func(labels []string, h *Histogram) {
+		f.Samples = append(f.Samples, h.samples(labels)...)
+	})
+	emit(f)
+}
+
+func addFloat(bits *atomic.Uint64, delta float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteText(t *testing.T) {
+	registry := NewRegistry()
+	requests := &CounterVec{newVec("requests_total", "Requests.", []string{"opcode"}, func() *Counter { return &Counter{} })}
+	registry.Register(requests)
+	requests.With("2").Add(2)
+	requests.With("1").Inc()
+
+	latency := &HistogramVec{newVec("latency_seconds", "Latency.", nil, func() *Histogram { return newHistogram([]float64{0.1, 1}) })}
+	registry.Register(latency)
+	latency.With().Observe(0.05)
+	latency.With().Observe(0.5)
+	latency.With().Observe(5)
+
+	unregister := registry.Register(CollectorFunc(func(emit func(Family)) {
+		emit(Family{Name: "queue_depth", Type: GaugeType, Samples: []Sample{{Labels: []string{"name", `a"b`}, Value: 3}}})
+	}))
+
+	var buf strings.Builder
+	require.NoError(t, registry.WriteText(&buf))
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.55
+latency_seconds_count 3
+# TYPE queue_depth gauge
+queue_depth{name="a\"b"} 3
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{opcode="1"} 1
+requests_total{opcode="2"} 2
+`, buf.String())
+
+	unregister()
+	buf.Reset()
+	require.NoError(t, registry.WriteText(&buf))
+	assert.NotContains(t, buf.String(), "queue_depth")
+}
+
+func TestMergeFamilies(t *testing.T) {
+	registry := NewRegistry()
+	for _, engine := range []string{"engine.0.1.1001.server", "engine.0.1.1002.server"} {
+		engine := engine
+		registry.Register(CollectorFunc(func(emit func(Family)) {
+			emit(Family{Name: "actor_count", Type: GaugeType, Samples: []Sample{{Labels: []string{"engine", engine}, Value: 1}}})
+		}))
+	}
+	families := registry.Gather()
+	require.Len(t, families, 1)
+	assert.Len(t, families[0].Samples, 2)
+}
+
+func TestHandler(t *testing.T) {
+	gauge := NewGaugeVec("metrics_test_gauge", "Test gauge.")
+	gauge.With().Set(7)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, ContentType, recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "metrics_test_gauge 7\n")
+}
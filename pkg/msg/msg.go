@@ -5,58 +5,101 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/wuqunyong/file_storage/pkg/compress"
 	"github.com/wuqunyong/file_storage/pkg/concepts"
 	"github.com/wuqunyong/file_storage/pkg/constants"
 	"github.com/wuqunyong/file_storage/pkg/encoders"
 	"github.com/wuqunyong/file_storage/pkg/errs"
 	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/pkg/trace"
 	"github.com/wuqunyong/file_storage/proto/nats_msg"
 	"github.com/wuqunyong/file_storage/proto/rpc_msg"
 )
 
 type MsgReq struct {
-	TargetId  *concepts.ActorId
-	Remote    bool
-	SeqId     uint64
-	FuncName  uint32
-	Args      any
-	ArgsData  []byte
-	Done      chan *MsgResp
-	Err       error
-	NumCalls  int32
-	Ctx       context.Context
-	CtxCancel context.CancelFunc
+	TargetId       *concepts.ActorId
+	Remote         bool
+	SeqId          uint64
+	FuncName       uint32
+	Args           any
+	ArgsData       []byte
+	Done           chan *MsgResp
+	Err            error
+	NumCalls       int32
+	Ctx            context.Context
+	CtxCancel      context.CancelFunc
+	Timeout        time.Duration
+	Retry          concepts.RetryPolicy
+	Attempts       int
+	Priority       int32
+	IdempotencyKey string
+	OneWay         bool
+	Durable        bool
+	// Stream asks the server for several responses, the last one without
+	// has_more.
+	Stream bool
+	// AcceptCompression is the algorithm the sender can decompress its
+	// response with.
+	AcceptCompression uint32
+	// Trace is the span the request was sent under, the handler of the
+	// target runs as its child.
+	Trace trace.SpanContext
+	// Queued is when the request entered the inbox of the target.
+	Queued time.Time
 
 	Sender    *concepts.ActorId
 	Codec     encoders.IEncoder
 	RPCServer concepts.IRPCServer
+	RPCClient concepts.IRPCClient
+
+	aborted chan failure
+	ack     func(err error)
+	acked   atomic.Bool
+	span    *trace.Span
 }
 
-func NewMsgReq(target *concepts.ActorId, opcode uint32, args any, ctx context.Context, coder encoders.IEncoder) *MsgReq {
-	var cancel context.CancelFunc
+func NewMsgReq(target *concepts.ActorId, opcode uint32, args any, opts *concepts.RequestOptions) *MsgReq {
+	if opts == nil {
+		opts = concepts.NewRequestOptions()
+	}
+
+	ctx := opts.Ctx
 	if ctx == nil {
-		ctx, cancel = context.WithTimeout(context.Background(), constants.DefaultRPCTimeout)
-	} else {
-		_, ok := ctx.Deadline()
-		if ok {
-			ctx, cancel = context.WithCancel(ctx)
-		} else {
-			ctx, cancel = context.WithTimeout(ctx, constants.DefaultRPCTimeout)
-		}
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = constants.DefaultRPCTimeout
+	}
+
+	codec := opts.Codec
+	if codec == nil {
+		codec = encoders.NewProtobufEncoder()
 	}
 
 	req := &MsgReq{
-		TargetId:  target,
-		Remote:    false,
-		FuncName:  opcode,
-		Args:      args,
-		Ctx:       ctx,
-		CtxCancel: cancel,
-		Done:      make(chan *MsgResp),
-		Err:       nil,
-		Codec:     coder,
+		TargetId:       target,
+		Remote:         false,
+		FuncName:       opcode,
+		Args:           args,
+		Ctx:            ctx,
+		CtxCancel:      cancel,
+		Timeout:        timeout,
+		Retry:          opts.Retry,
+		Priority:       opts.Priority,
+		IdempotencyKey: opts.IdempotencyKey,
+		OneWay:         !opts.RequiredReply,
+		Durable:        opts.Durable,
+		Done:           make(chan *MsgResp),
+		Err:            nil,
+		Codec:          codec,
+		aborted:        make(chan failure, 1),
 	}
 	return req
 }
@@ -77,6 +120,58 @@ func (req *MsgReq) SetSeqId(value uint64) {
 	req.SeqId = value
 }
 
+func (req *MsgReq) GetSeqId() uint64 {
+	return req.SeqId
+}
+
+func (req *MsgReq) GetTimeout() time.Duration {
+	if req.Timeout <= 0 {
+		return constants.DefaultRPCTimeout
+	}
+	return req.Timeout
+}
+
+// failure is the error of the attempt sent with seqId.
+type failure struct {
+	seqId uint64
+	err   error
+}
+
+// Fail wakes up the caller waiting in Result with err when seqId is the
+// attempt it waits for. It never blocks, the first failure wins and a
+// caller that already gave up is not affected.
+func (req *MsgReq) Fail(seqId uint64, err error) {
+	select {
+	case req.aborted <- failure{seqId: seqId, err: err}:
+	default:
+	}
+}
+
+func (req *MsgReq) SetRPCClient(client concepts.IRPCClient) {
+	req.RPCClient = client
+}
+
+func (req *MsgReq) IsRequiredReply() bool {
+	return !req.OneWay
+}
+
+func (req *MsgReq) IsDurable() bool {
+	return req.Durable
+}
+
+// SetAck registers the callback told about the outcome of a notify, e.g. to
+// acknowledge a durable delivery.
+func (req *MsgReq) SetAck(ack func(err error)) {
+	req.ack = ack
+}
+
+// Ack reports the outcome of the handler, only the first call counts.
+func (req *MsgReq) Ack(err error) {
+	if req.ack != nil && !req.acked.Swap(true) {
+		req.ack(err)
+	}
+}
+
 func (req *MsgReq) SetRemote(value bool) error {
 	if value {
 		req.Remote = value
@@ -91,22 +186,119 @@ func (req *MsgReq) SetRemote(value bool) error {
 	return nil
 }
 
-func (req *MsgReq) Result() (*MsgResp, error) {
+func (req *MsgReq) Result() (resp *MsgResp, err error) {
 	defer func() {
-		req.CtxCancel()
+		if req.CtxCancel != nil {
+			req.CtxCancel()
+		}
+		if err == nil && resp != nil && resp.ErrCode != 0 {
+			req.FinishSpan(resp.CodeError())
+		} else {
+			req.FinishSpan(err)
+		}
 	}()
 
+	for {
+		resp, err := req.wait()
+		if !req.shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		if retryErr := req.resend(); retryErr != nil {
+			logger.Log(logger.ErrorLevel, "MsgReq Retry", "target", req.TargetId.String(), "opcode", req.FuncName, "attempts", req.Attempts, "err", retryErr)
+			return resp, err
+		}
+	}
+}
+
+// StartSpan starts the span covering the request until its result as a
+// child of parent, a new trace when parent is not valid.
+func (req *MsgReq) StartSpan(parent trace.SpanContext) {
+	req.span = trace.Start(parent, "request "+strconv.FormatUint(uint64(req.FuncName), 10))
+	req.span.SetAttribute("target", req.TargetId.String())
+	req.Trace = req.span.Context
+}
+
+// FinishSpan ends the span of the request, failed with err when it is not
+// nil.
+func (req *MsgReq) FinishSpan(err error) {
+	if req.span == nil {
+		return
+	}
+	req.span.SetError(err)
+	if req.Attempts > 0 {
+		req.span.SetAttribute("attempts", strconv.Itoa(req.Attempts+1))
+	}
+	req.span.Finish()
+}
+
+func (req *MsgReq) wait() (*MsgResp, error) {
+	timer := time.NewTimer(req.GetTimeout())
+	defer timer.Stop()
+
+	for {
+		select {
+		case resp := <-req.Done:
+			return resp, nil
+		case failed := <-req.aborted:
+			// a failure of an earlier attempt comes too late
+			if failed.seqId == req.SeqId {
+				return nil, failed.err
+			}
+		case <-req.Ctx.Done():
+			return nil, req.Ctx.Err()
+		case <-timer.C:
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// shouldRetry reports whether the attempt failed in a way the retry policy
+// covers. Only remote requests are retried, local delivery cannot get lost.
+func (req *MsgReq) shouldRetry(resp *MsgResp, err error) bool {
+	if !req.Remote || req.RPCClient == nil {
+		return false
+	}
+	if req.Attempts >= req.Retry.Count {
+		return false
+	}
+	if req.Ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, constants.ErrRPCRequestTimeout)
+	}
+	return resp != nil && resp.ErrCode != 0 && (resp.Retryable || req.Retry.IsRetryableCode(resp.ErrCode))
+}
+
+// resend waits for the backoff and publishes the request again, the rpc client
+// assigns it a fresh SeqId so late replies of the previous attempt are dropped.
+func (req *MsgReq) resend() error {
+	req.Attempts++
+
+	backoff := req.Retry.GetBackoff(req.Attempts)
+	if backoff > 0 {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-req.Ctx.Done():
+			timer.Stop()
+			return req.Ctx.Err()
+		}
+	}
+
+	// the failure of the attempt given up on must not fill the slot
 	select {
-	case resp := <-req.Done:
-		return resp, nil
-	case <-req.Ctx.Done():
-		return nil, req.Ctx.Err()
+	case <-req.aborted:
+	default:
 	}
+	return req.RPCClient.SendRequest(req)
 }
 
 func (req *MsgReq) Send(resp concepts.IMsgResp) {
 	if req.Remote {
-		req.RPCServer.SendResponse(req.Sender.Address, resp)
+		req.RPCServer.SendResponse(req, resp)
 		return
 	}
 
@@ -131,6 +323,11 @@ func (req *MsgReq) HandleResponse(resp concepts.IMsgResp) {
 		return
 	}
 
+	if response.SeqId != req.SeqId {
+		logger.Log(logger.WarnLevel, "MsgReq HandleResponse", "stale seqId", response.SeqId, "seqId", req.SeqId)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	select {
@@ -157,14 +354,28 @@ func (req *MsgReq) Marshal() ([]byte, error) {
 			ActorId: req.Sender.ID,
 		},
 		SeqId:         req.SeqId,
-		RequiredReply: true,
+		RequiredReply: !req.OneWay,
 		ReplyTopic:    req.Sender.Address,
 	}
-	request.ServerStream = false
+	request.ServerStream = req.Stream
 	request.Opcodes = req.FuncName
-	request.ArgsData = req.ArgsData
+	request.ArgsData, request.Compression, err = compress.Encode(req.ArgsData)
+	if err != nil {
+		return nil, err
+	}
+	request.AcceptCompression = compress.Algorithm()
+	request.Priority = req.Priority
+	request.IdempotencyKey = req.IdempotencyKey
+	request.CodecType = encoders.GetCodecType(req.Codec)
+	request.Traceparent = req.Trace.Traceparent()
 
-	realm, kind, id, err = concepts.DecodeAddress(req.TargetId.Address)
+	// any node of the kind fills in its own id when it receives the request
+	if req.TargetId.IsAny() {
+		id = 0
+		realm, kind, err = concepts.DecodeAnyAddress(req.TargetId.Address)
+	} else {
+		realm, kind, id, err = concepts.DecodeAddress(req.TargetId.Address)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -207,10 +418,31 @@ func RequestUnmarshal(data []byte) (*MsgReq, error) {
 		clientAddress = concepts.GenClientAddress(rpcRequest.Client.Stub.Realm, rpcRequest.Client.Stub.Type, rpcRequest.Client.Stub.Id)
 	}
 
-	request := NewMsgReq(concepts.NewActorId(serverAddress, rpcRequest.Server.Stub.ActorId), rpcRequest.Opcodes, nil, nil, encoders.NewProtobufEncoder())
+	codec, err := encoders.NewEncoder(rpcRequest.CodecType)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := concepts.NewRequestOptions(
+		concepts.WithCodec(codec),
+		concepts.WithPriority(rpcRequest.Priority),
+		concepts.WithIdempotencyKey(rpcRequest.IdempotencyKey),
+	)
+	if !rpcRequest.GetClient().RequiredReply {
+		opts.RequiredReply = false
+	}
+
+	request := NewMsgReq(concepts.NewActorId(serverAddress, rpcRequest.Server.Stub.ActorId), rpcRequest.Opcodes, nil, opts)
 	request.Remote = true
 	request.SeqId = rpcRequest.GetClient().SeqId
-	request.ArgsData = rpcRequest.ArgsData
+	request.ArgsData, err = compress.Decode(rpcRequest.Compression, rpcRequest.ArgsData)
+	if err != nil {
+		return nil, err
+	}
+	request.AcceptCompression = rpcRequest.AcceptCompression
+	request.Stream = rpcRequest.ServerStream
+	// a malformed traceparent starts a new trace on this node
+	request.Trace, _ = trace.ParseTraceparent(rpcRequest.Traceparent)
 	request.Sender = concepts.NewActorId(clientAddress, rpcRequest.Client.Stub.ActorId)
 	return request, nil
 }
@@ -233,18 +465,32 @@ func ResponseUnmarshal(data []byte) (*MsgResp, error) {
 		return nil, constants.ErrInvalidNatsMsgType
 	}
 
-	resp := NewMsgResp(response.Client.SeqId, response.Status.Code, response.Status.Msg, encoder)
-	resp.ReplyData = response.ResultData
+	resp := NewMsgResp(response.Client.SeqId, response.Status.GetCode(), response.Status.GetMsg(), encoder)
+	resp.Details = response.Status.GetDetails()
+	resp.Retryable = response.Status.GetRetryable()
+	resp.HasMore = response.HasMore
+	resp.ReplyData, err = compress.Decode(response.Compression, response.ResultData)
+	if err != nil {
+		return nil, err
+	}
 	return resp, nil
 }
 
 type MsgResp struct {
-	Remote    bool
-	SeqId     uint64
-	ErrCode   uint32
-	ErrMsg    string
+	Remote  bool
+	SeqId   uint64
+	ErrCode uint32
+	ErrMsg  string
+	// Details and Retryable are the structured fields of the error.
+	Details   map[string]string
+	Retryable bool
 	Reply     any
 	ReplyData []byte
+	// Compression is the algorithm ReplyData may be sent with, the one
+	// accepted by the caller.
+	Compression uint32
+	// HasMore marks a response to a Stream request followed by others.
+	HasMore bool
 
 	Codec encoders.IEncoder
 }
@@ -258,6 +504,22 @@ func NewMsgResp(seqId uint64, errCode uint32, errMsg string, codec encoders.IEnc
 	}
 }
 
+// NewErrorResp returns the response failing with err.
+func NewErrorResp(seqId uint64, err errs.CodeError, codec encoders.IEncoder) *MsgResp {
+	resp := NewMsgResp(seqId, uint32(err.Code()), err.Msg(), codec)
+	resp.Details = err.Details()
+	resp.Retryable = err.Retryable()
+	return resp
+}
+
+// CodeError is the error of the response, nil when it succeeded.
+func (resp *MsgResp) CodeError() errs.CodeError {
+	if resp.ErrCode == 0 {
+		return nil
+	}
+	return errs.New(int32(resp.ErrCode), resp.ErrMsg, errs.WithDetails(resp.Details), errs.WithRetryable(resp.Retryable))
+}
+
 func (resp *MsgResp) Marshal() ([]byte, error) {
 	encoder := encoders.NewProtobufEncoder()
 
@@ -266,10 +528,18 @@ func (resp *MsgResp) Marshal() ([]byte, error) {
 		SeqId: resp.SeqId,
 	}
 	response.Status = &rpc_msg.STATUS{
-		Code: resp.ErrCode,
-		Msg:  resp.ErrMsg,
+		Code:      resp.ErrCode,
+		Msg:       resp.ErrMsg,
+		Details:   resp.Details,
+		Retryable: resp.Retryable,
 	}
-	response.ResultData = resp.ReplyData
+	data, algorithm, err := compress.EncodeWith(resp.Compression, resp.ReplyData)
+	if err != nil {
+		return nil, err
+	}
+	response.ResultData = data
+	response.Compression = algorithm
+	response.HasMore = resp.HasMore
 
 	natsResponse := &nats_msg.NATS_MSG_PRXOY{}
 	natsResponse.Msg = &nats_msg.NATS_MSG_PRXOY_RpcResponse{
@@ -278,6 +548,29 @@ func (resp *MsgResp) Marshal() ([]byte, error) {
 	return encoder.Encode(natsResponse)
 }
 
+// requestError is the error of a request that failed before it got a
+// response.
+func requestError(err error) errs.CodeError {
+	var codeErr errs.CodeError
+	if errors.As(err, &codeErr) {
+		return codeErr
+	}
+
+	switch {
+	case errors.Is(err, constants.ErrRPCCircuitOpen):
+		return errs.New(errs.CODE_CircuitOpen, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, constants.ErrRPCRequestTimeout):
+		return errs.New(errs.CODE_Timeout, err.Error(), errs.WithRetryable(true))
+	case errors.Is(err, constants.ErrRPCConnectionLost):
+		return errs.New(errs.CODE_NotReceivedReply, err.Error(), errs.WithRetryable(true))
+	case errors.Is(err, constants.ErrRPCArgsEncodeFailure):
+		return errs.New(errs.CODE_CreateMsgError, err.Error())
+	case errors.Is(err, constants.ErrRPCClientHasClosed), errors.Is(err, constants.ErrNoConnectionToServer):
+		return errs.New(errs.CODE_NotSend, err.Error())
+	}
+	return errs.NewCodeError(err)
+}
+
 func GetResult[T any](req concepts.IMsgReq) (result *T, code errs.CodeError) {
 	params := reflect.ValueOf(result)
 	if params.Kind() != reflect.Ptr {
@@ -296,23 +589,27 @@ func GetResult[T any](req concepts.IMsgReq) (result *T, code errs.CodeError) {
 	}
 
 	if request.Err != nil {
-		return nil, errs.NewCodeError(request.Err)
+		return nil, requestError(request.Err)
+	}
+
+	if request.OneWay {
+		return nil, errs.NewCodeError(constants.ErrRPCNoReply)
 	}
 
 	response, err := request.Result()
 	if err != nil {
-		return nil, errs.NewCodeError(err)
+		return nil, requestError(err)
 	}
 
-	if response.ErrCode != 0 {
-		return nil, errs.NewCodeError(errors.New(response.ErrMsg), int32(response.ErrCode))
+	if code := response.CodeError(); code != nil {
+		return nil, code
 	}
 
 	if request.Remote {
 		var obj T
-		err = response.Codec.Decode(response.ReplyData, &obj)
+		err = request.Codec.Decode(response.ReplyData, &obj)
 		if err != nil {
-			return nil, errs.NewCodeError(err)
+			return nil, errs.NewCodeError(err, errs.CODE_ParseError)
 		}
 
 		return &obj, nil
@@ -8,6 +8,7 @@ import (
 
 // An Item is something we manage in a priority queue.
 type Item struct {
+	level    int32  // Items with a higher level are popped first.
 	priority uint64 // The priority of the item in the queue.
 	// The index is needed by update and is maintained by the heap.Interface methods.
 	index int // The index of the item in the heap.
@@ -22,6 +23,9 @@ type PriorityQueue []*Item
 func (pq PriorityQueue) Len() int { return len(pq) }
 
 func (pq PriorityQueue) Less(i, j int) bool {
+	if pq[i].level != pq[j].level {
+		return pq[i].level > pq[j].level
+	}
 	return pq[i].priority < pq[j].priority
 }
 
@@ -79,11 +83,18 @@ func (t *MsgQueue) Len() int {
 }
 
 func (t *MsgQueue) Push(args any) {
+	t.PushWithLevel(args, 0)
+}
+
+// PushWithLevel queues args ahead of every item with a lower level,
+// items of the same level keep their insertion order.
+func (t *MsgQueue) PushWithLevel(args any, level int32) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
 	id := t.GenId()
 	item := &Item{
+		level:    level,
 		priority: id,
 		id:       id,
 		Args:     args,
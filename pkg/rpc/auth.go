@@ -0,0 +1,385 @@
+package rpc
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+	"github.com/wuqunyong/file_storage/pkg/constants"
+	"github.com/wuqunyong/file_storage/proto/nats_msg"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// MetadataPublicKey is the registry node metadata holding the base64
+// ed25519 public key of the engine named by MetadataEngine.
+const MetadataPublicKey = "ed25519_pub"
+
+// signedField is the NATS_MSG_PRXOY field number of a signed envelope.
+const signedField = 105
+
+var (
+	// DefaultReplayWindow is how far the timestamp of a message may be off
+	// the clock of its receiver, nonces are remembered that long.
+	DefaultReplayWindow = 30 * time.Second
+
+	nonceSize = 16
+)
+
+// Keyring signs the messages of this node and checks the signatures of the
+// others.
+type Keyring interface {
+	// KeyId names the key Sign uses, it travels with every message.
+	KeyId() string
+	Sign(data []byte) ([]byte, error)
+	Verify(keyId string, data, signature []byte) error
+}
+
+// HMACKeyring signs with HMAC-SHA256 keys shared by the nodes, keyed by id
+// so that keys can be rotated one node at a time.
+type HMACKeyring struct {
+	keyId string
+	keys  map[string][]byte
+}
+
+func NewHMACKeyring(keyId string, keys map[string][]byte) (*HMACKeyring, error) {
+	if len(keys[keyId]) == 0 {
+		return nil, fmt.Errorf("no hmac key %q", keyId)
+	}
+	return &HMACKeyring{keyId: keyId, keys: keys}, nil
+}
+
+func (k *HMACKeyring) KeyId() string {
+	return k.keyId
+}
+
+func (k *HMACKeyring) Sign(data []byte) ([]byte, error) {
+	return k.sum(k.keys[k.keyId], data), nil
+}
+
+func (k *HMACKeyring) Verify(keyId string, data, signature []byte) error {
+	key, ok := k.keys[keyId]
+	if !ok {
+		return constants.ErrRPCUnknownKey
+	}
+	if !hmac.Equal(k.sum(key, data), signature) {
+		return constants.ErrRPCBadSignature
+	}
+	return nil
+}
+
+func (k *HMACKeyring) sum(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// PublicKeyResolver finds the ed25519 public key of a node.
+type PublicKeyResolver interface {
+	ResolvePublicKey(keyId string) (ed25519.PublicKey, error)
+}
+
+// StaticPublicKeys maps key ids to public keys.
+type StaticPublicKeys map[string]ed25519.PublicKey
+
+func (s StaticPublicKeys) ResolvePublicKey(keyId string) (ed25519.PublicKey, error) {
+	key, ok := s[keyId]
+	if !ok {
+		return nil, constants.ErrRPCUnknownKey
+	}
+	return key, nil
+}
+
+// RegistryPublicKeys looks keys up in the service registry, the key id being
+// the MetadataEngine of the node that registered its MetadataPublicKey.
+type RegistryPublicKeys struct {
+	registry registry.Registry
+	mu       sync.Mutex
+	cache    map[string]ed25519.PublicKey
+}
+
+func NewRegistryPublicKeys(r registry.Registry) *RegistryPublicKeys {
+	return &RegistryPublicKeys{
+		registry: r,
+		cache:    make(map[string]ed25519.PublicKey),
+	}
+}
+
+func (r *RegistryPublicKeys) ResolvePublicKey(keyId string) (ed25519.PublicKey, error) {
+	r.mu.Lock()
+	key, ok := r.cache[keyId]
+	r.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	services, err := r.registry.ListServices()
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			if node.Metadata[MetadataEngine] != keyId || node.Metadata[MetadataPublicKey] == "" {
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(node.Metadata[MetadataPublicKey])
+			if err != nil || len(decoded) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("invalid public key of %s", keyId)
+			}
+			key = ed25519.PublicKey(decoded)
+			r.mu.Lock()
+			r.cache[keyId] = key
+			r.mu.Unlock()
+			return key, nil
+		}
+	}
+	return nil, constants.ErrRPCUnknownKey
+}
+
+// Forget drops a cached key, e.g. after the node rotated it.
+func (r *RegistryPublicKeys) Forget(keyId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, keyId)
+}
+
+// Ed25519Keyring signs with the private key of this node, the others find
+// its public key under keyId, usually the engine address.
+type Ed25519Keyring struct {
+	keyId    string
+	private  ed25519.PrivateKey
+	resolver PublicKeyResolver
+}
+
+func NewEd25519Keyring(keyId string, private ed25519.PrivateKey, resolver PublicKeyResolver) *Ed25519Keyring {
+	return &Ed25519Keyring{keyId: keyId, private: private, resolver: resolver}
+}
+
+func (k *Ed25519Keyring) KeyId() string {
+	return k.keyId
+}
+
+// SetResolver replaces the resolver of the public keys of the others, it
+// must be called before the keyring is used.
+func (k *Ed25519Keyring) SetResolver(resolver PublicKeyResolver) {
+	k.resolver = resolver
+}
+
+// PublicKey is the key the others verify the signatures of this node with.
+func (k *Ed25519Keyring) PublicKey() ed25519.PublicKey {
+	return k.private.Public().(ed25519.PublicKey)
+}
+
+func (k *Ed25519Keyring) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(k.private, data), nil
+}
+
+func (k *Ed25519Keyring) Verify(keyId string, data, signature []byte) error {
+	var key ed25519.PublicKey
+	if keyId == k.keyId {
+		key = k.PublicKey()
+	} else {
+		resolved, err := k.resolver.ResolvePublicKey(keyId)
+		if err != nil {
+			return fmt.Errorf("%w: %s", constants.ErrRPCUnknownKey, err)
+		}
+		key = resolved
+	}
+	if !ed25519.Verify(key, data, signature) {
+		return constants.ErrRPCBadSignature
+	}
+	return nil
+}
+
+// AuthStats counts the messages checked by an Authenticator.
+type AuthStats struct {
+	Accepted     uint64
+	Unsigned     uint64
+	BadSignature uint64
+	UnknownKey   uint64
+	Expired      uint64
+	Replayed     uint64
+}
+
+// Rejected sums the messages that were dropped.
+func (s AuthStats) Rejected() uint64 {
+	return s.Unsigned + s.BadSignature + s.UnknownKey + s.Expired + s.Replayed
+}
+
+// Authenticator wraps the envelopes sent by the rpc client and server in a
+// signed RPC_SIGNED and checks the ones received. A signature covers the
+// subject, so a message can not be redirected to another node, and the
+// timestamp and nonce, so it can not be replayed.
+type Authenticator struct {
+	keyring       Keyring
+	window        time.Duration
+	allowUnsigned bool
+	now           func() time.Time
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	pruned time.Time
+
+	accepted     atomic.Uint64
+	unsigned     atomic.Uint64
+	badSignature atomic.Uint64
+	unknownKey   atomic.Uint64
+	expired      atomic.Uint64
+	replayed     atomic.Uint64
+}
+
+type AuthOption func(*Authenticator)
+
+// WithReplayWindow sets how old or early a message may be.
+func WithReplayWindow(window time.Duration) AuthOption {
+	return func(a *Authenticator) {
+		a.window = window
+	}
+}
+
+// WithAllowUnsigned still accepts unsigned messages, counting them, while
+// the nodes of a cluster are switched over to signing.
+func WithAllowUnsigned() AuthOption {
+	return func(a *Authenticator) {
+		a.allowUnsigned = true
+	}
+}
+
+func NewAuthenticator(keyring Keyring, opts ...AuthOption) *Authenticator {
+	a := &Authenticator{
+		keyring: keyring,
+		window:  DefaultReplayWindow,
+		now:     time.Now,
+		nonces:  make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *Authenticator) Keyring() Keyring {
+	return a.keyring
+}
+
+// Seal signs data, an encoded NATS_MSG_PRXOY, for subject.
+func (a *Authenticator) Seal(subject string, data []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	signed := &rpc_msg.RPC_SIGNED{
+		Payload:   data,
+		KeyId:     a.keyring.KeyId(),
+		Timestamp: a.now().UnixMilli(),
+		Nonce:     nonce,
+	}
+	signature, err := a.keyring.Sign(signingBytes(subject, signed))
+	if err != nil {
+		return nil, err
+	}
+	signed.Signature = signature
+	return proto.Marshal(&nats_msg.NATS_MSG_PRXOY{
+		Msg: &nats_msg.NATS_MSG_PRXOY_RpcSigned{RpcSigned: signed},
+	})
+}
+
+// Open checks data received on subject and returns the envelope it signs.
+func (a *Authenticator) Open(subject string, data []byte) ([]byte, error) {
+	if num, _, n := protowire.ConsumeTag(data); n <= 0 || num != signedField {
+		if a.allowUnsigned {
+			a.unsigned.Add(1)
+			return data, nil
+		}
+		return nil, a.reject(constants.ErrRPCUnsigned)
+	}
+	envelope := &nats_msg.NATS_MSG_PRXOY{}
+	if err := proto.Unmarshal(data, envelope); err != nil || envelope.GetRpcSigned() == nil {
+		return nil, a.reject(constants.ErrRPCBadSignature)
+	}
+	signed := envelope.GetRpcSigned()
+
+	if err := a.keyring.Verify(signed.KeyId, signingBytes(subject, signed), signed.Signature); err != nil {
+		return nil, a.reject(err)
+	}
+
+	now := a.now()
+	sent := time.UnixMilli(signed.Timestamp)
+	if sent.Before(now.Add(-a.window)) || sent.After(now.Add(a.window)) {
+		return nil, a.reject(constants.ErrRPCExpired)
+	}
+	if !a.remember(signed.KeyId+"\x00"+string(signed.Nonce), sent.Add(a.window), now) {
+		return nil, a.reject(constants.ErrRPCReplayed)
+	}
+
+	a.accepted.Add(1)
+	return signed.Payload, nil
+}
+
+// remember records a nonce until expiry, it reports false when the nonce was
+// already seen.
+func (a *Authenticator) remember(key string, expiry, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.pruned) > a.window {
+		for nonce, deadline := range a.nonces {
+			if now.After(deadline) {
+				delete(a.nonces, nonce)
+			}
+		}
+		a.pruned = now
+	}
+	if _, ok := a.nonces[key]; ok {
+		return false
+	}
+	a.nonces[key] = expiry
+	return true
+}
+
+func (a *Authenticator) reject(err error) error {
+	switch {
+	case errors.Is(err, constants.ErrRPCUnsigned):
+		a.unsigned.Add(1)
+	case errors.Is(err, constants.ErrRPCUnknownKey):
+		a.unknownKey.Add(1)
+	case errors.Is(err, constants.ErrRPCExpired):
+		a.expired.Add(1)
+	case errors.Is(err, constants.ErrRPCReplayed):
+		a.replayed.Add(1)
+	default:
+		a.badSignature.Add(1)
+	}
+	return err
+}
+
+func (a *Authenticator) Stats() AuthStats {
+	return AuthStats{
+		Accepted:     a.accepted.Load(),
+		Unsigned:     a.unsigned.Load(),
+		BadSignature: a.badSignature.Load(),
+		UnknownKey:   a.unknownKey.Load(),
+		Expired:      a.expired.Load(),
+		Replayed:     a.replayed.Load(),
+	}
+}
+
+// signingBytes lays out the signed fields with length prefixes, so that no
+// two different messages share the same bytes.
+func signingBytes(subject string, signed *rpc_msg.RPC_SIGNED) []byte {
+	buf := make([]byte, 0, len(subject)+len(signed.KeyId)+len(signed.Nonce)+len(signed.Payload)+32)
+	buf = protowire.AppendString(buf, signed.KeyId)
+	buf = protowire.AppendString(buf, subject)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(signed.Timestamp))
+	buf = protowire.AppendBytes(buf, signed.Nonce)
+	return append(buf, signed.Payload...)
+}
@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wuqunyong/file_storage/pkg/constants"
+)
+
+const authSubject = "engine.0.1.1001.server"
+
+func newHMACAuthenticator(t *testing.T, keyId string, opts ...AuthOption) *Authenticator {
+	keyring, err := NewHMACKeyring(keyId, map[string][]byte{"k1": []byte("secret1"), "k2": []byte("secret2")})
+	require.NoError(t, err)
+	return NewAuthenticator(keyring, opts...)
+}
+
+func TestAuthenticatorHMAC(t *testing.T) {
+	sender := newHMACAuthenticator(t, "k2")
+	receiver := newHMACAuthenticator(t, "k1")
+
+	sealed, err := sender.Seal(authSubject, []byte("payload"))
+	require.NoError(t, err)
+	data, err := receiver.Open(authSubject, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), data)
+
+	// the same frame again, and redirected to another node
+	_, err = receiver.Open(authSubject, sealed)
+	assert.ErrorIs(t, err, constants.ErrRPCReplayed)
+	_, err = receiver.Open("engine.0.1.1002.server", sealed)
+	assert.ErrorIs(t, err, constants.ErrRPCBadSignature)
+
+	_, err = receiver.Open(authSubject, []byte{0x0a, 0x00})
+	assert.ErrorIs(t, err, constants.ErrRPCUnsigned)
+
+	stranger, err := NewHMACKeyring("k3", map[string][]byte{"k3": []byte("secret3")})
+	require.NoError(t, err)
+	sealed, err = NewAuthenticator(stranger).Seal(authSubject, []byte("payload"))
+	require.NoError(t, err)
+	_, err = receiver.Open(authSubject, sealed)
+	assert.ErrorIs(t, err, constants.ErrRPCUnknownKey)
+
+	assert.Equal(t, AuthStats{Accepted: 1, Unsigned: 1, BadSignature: 1, UnknownKey: 1, Replayed: 1}, receiver.Stats())
+}
+
+func TestAuthenticatorWindow(t *testing.T) {
+	sender := newHMACAuthenticator(t, "k1")
+	receiver := newHMACAuthenticator(t, "k1", WithReplayWindow(time.Second))
+
+	sender.now = func() time.Time { return time.Now().Add(-2 * time.Second) }
+	sealed, err := sender.Seal(authSubject, []byte("payload"))
+	require.NoError(t, err)
+	_, err = receiver.Open(authSubject, sealed)
+	assert.ErrorIs(t, err, constants.ErrRPCExpired)
+
+	// nonces are forgotten once their timestamp left the window
+	sender.now = time.Now
+	sealed, err = sender.Seal(authSubject, []byte("payload"))
+	require.NoError(t, err)
+	_, err = receiver.Open(authSubject, sealed)
+	require.NoError(t, err)
+	receiver.now = func() time.Time { return time.Now().Add(3 * time.Second) }
+	_, err = receiver.Open(authSubject, sealed)
+	assert.ErrorIs(t, err, constants.ErrRPCExpired)
+	receiver.remember("probe", time.Now(), receiver.now())
+	receiver.mu.Lock()
+	assert.Len(t, receiver.nonces, 1)
+	receiver.mu.Unlock()
+}
+
+func TestAuthenticatorEd25519(t *testing.T) {
+	public1, private1, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	public2, private2, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keys := StaticPublicKeys{"engine.0.1.1001": public1, "engine.0.2.1002": public2}
+
+	sender := NewAuthenticator(NewEd25519Keyring("engine.0.2.1002", private2, keys))
+	receiver := NewAuthenticator(NewEd25519Keyring("engine.0.1.1001", private1, keys))
+
+	sealed, err := sender.Seal(authSubject, []byte("payload"))
+	require.NoError(t, err)
+	data, err := receiver.Open(authSubject, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), data)
+
+	// signed with a key that is not the one registered for its id
+	_, forged, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sealed, err = NewAuthenticator(NewEd25519Keyring("engine.0.2.1002", forged, keys)).Seal(authSubject, []byte("payload"))
+	require.NoError(t, err)
+	_, err = receiver.Open(authSubject, sealed)
+	assert.ErrorIs(t, err, constants.ErrRPCBadSignature)
+}
+
+func TestAuthenticatorAllowUnsigned(t *testing.T) {
+	receiver := newHMACAuthenticator(t, "k1", WithAllowUnsigned())
+	data, err := receiver.Open(authSubject, []byte{0x0a, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x0a, 0x00}, data)
+	assert.Equal(t, uint64(1), receiver.Stats().Unsigned)
+}
@@ -0,0 +1,229 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of the circuit breaker of a remote address.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through, counting the outcomes.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails requests at once, the address is ejected.
+	BreakerOpen
+	// BreakerHalfOpen lets a few probe requests through to test whether the
+	// address recovered.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerPolicy trips the breaker of an address when at least MinRequests
+// requests completed within Window and FailureRatio of them failed or timed
+// out. After OpenTimeout, HalfOpenMax probes are let through; the breaker
+// closes once they all succeeded and opens again on the first failure.
+type BreakerPolicy struct {
+	Window       time.Duration
+	MinRequests  int
+	FailureRatio float64
+	OpenTimeout  time.Duration
+	HalfOpenMax  int
+}
+
+var DefaultBreakerPolicy = BreakerPolicy{
+	Window:       10 * time.Second,
+	MinRequests:  10,
+	FailureRatio: 0.5,
+	OpenTimeout:  5 * time.Second,
+	HalfOpenMax:  1,
+}
+
+// Enabled reports whether the policy trips at all.
+func (p BreakerPolicy) Enabled() bool {
+	return p.MinRequests > 0 && p.FailureRatio > 0
+}
+
+type breaker struct {
+	state       BreakerState
+	windowStart time.Time
+	successes   int
+	failures    int
+	// when the breaker opened, or the last probe was let through
+	since   time.Time
+	probes  int
+	settled int
+}
+
+// BreakerSet keeps a circuit breaker per remote address.
+type BreakerSet struct {
+	mu       sync.Mutex
+	policy   BreakerPolicy
+	breakers map[string]*breaker
+	now      func() time.Time
+}
+
+func NewBreakerSet(policy BreakerPolicy) *BreakerSet {
+	return &BreakerSet{
+		policy:   policy,
+		breakers: make(map[string]*breaker),
+		now:      time.Now,
+	}
+}
+
+// SetPolicy replaces the policy and resets every breaker.
+func (s *BreakerSet) SetPolicy(policy BreakerPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+	s.breakers = make(map[string]*breaker)
+}
+
+// Allow reports whether a request to address may be sent, it takes a probe
+// slot when the breaker is half-open.
+func (s *BreakerSet) Allow(address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.policy.Enabled() {
+		return true
+	}
+	b, ok := s.breakers[address]
+	if !ok {
+		return true
+	}
+	now := s.now()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.since) < s.policy.OpenTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probes = 0
+		b.settled = 0
+	case BreakerHalfOpen:
+		// a probe whose outcome never came, e.g. lost with the connection,
+		// must not hold the breaker half-open forever
+		if b.probes >= s.halfOpenMax() && now.Sub(b.since) < s.policy.OpenTimeout {
+			return false
+		}
+	default:
+		return true
+	}
+	b.probes++
+	b.since = now
+	return true
+}
+
+// Success records a request to address that got its response.
+func (s *BreakerSet) Success(address string) {
+	s.record(address, true)
+}
+
+// Failure records a request to address that could not be sent or timed out.
+func (s *BreakerSet) Failure(address string) {
+	s.record(address, false)
+}
+
+func (s *BreakerSet) record(address string, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.policy.Enabled() {
+		return
+	}
+	now := s.now()
+	b, ok := s.breakers[address]
+	if !ok {
+		b = &breaker{windowStart: now}
+		s.breakers[address] = b
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		// the outcome of a request sent before the breaker opened
+	case BreakerHalfOpen:
+		if !success {
+			b.state = BreakerOpen
+			b.since = now
+			return
+		}
+		b.settled++
+		if b.settled >= s.halfOpenMax() {
+			s.breakers[address] = &breaker{windowStart: now}
+		}
+	default:
+		if now.Sub(b.windowStart) > s.policy.Window {
+			b.windowStart = now
+			b.successes = 0
+			b.failures = 0
+		}
+		if success {
+			b.successes++
+		} else {
+			b.failures++
+		}
+		total := b.successes + b.failures
+		if total >= s.policy.MinRequests && float64(b.failures) >= s.policy.FailureRatio*float64(total) {
+			b.state = BreakerOpen
+			b.since = now
+		}
+	}
+}
+
+func (s *BreakerSet) halfOpenMax() int {
+	return max(s.policy.HalfOpenMax, 1)
+}
+
+// State returns the state of the breaker of address.
+func (s *BreakerSet) State(address string) BreakerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.breakers[address]; ok {
+		return b.state
+	}
+	return BreakerClosed
+}
+
+// Ejected reports whether requests to address currently fail fast, routing
+// should pick another node.
+func (s *BreakerSet) Ejected(address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.breakers[address]
+	return ok && b.state == BreakerOpen && s.now().Sub(b.since) < s.policy.OpenTimeout
+}
+
+// Healthy returns the addresses that are not ejected, keeping their order.
+func (s *BreakerSet) Healthy(addresses []string) []string {
+	healthy := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if !s.Ejected(address) {
+			healthy = append(healthy, address)
+		}
+	}
+	return healthy
+}
+
+// Open lists the addresses whose breaker is not closed.
+func (s *BreakerSet) Open() map[string]BreakerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make(map[string]BreakerState)
+	for address, b := range s.breakers {
+		if b.state != BreakerClosed {
+			states[address] = b.state
+		}
+	}
+	return states
+}
@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBreakers() (*BreakerSet, *time.Time) {
+	now := time.Now()
+	breakers := NewBreakerSet(BreakerPolicy{
+		Window:       time.Minute,
+		MinRequests:  4,
+		FailureRatio: 0.5,
+		OpenTimeout:  time.Second,
+		HalfOpenMax:  1,
+	})
+	breakers.now = func() time.Time { return now }
+	return breakers, &now
+}
+
+func TestBreakerTrips(t *testing.T) {
+	breakers, _ := newTestBreakers()
+	const address = "engine.0.1.1001.server"
+
+	breakers.Success(address)
+	breakers.Success(address)
+	breakers.Failure(address)
+	assert.Equal(t, BreakerClosed, breakers.State(address))
+	assert.True(t, breakers.Allow(address))
+
+	breakers.Failure(address)
+	assert.Equal(t, BreakerOpen, breakers.State(address))
+	assert.False(t, breakers.Allow(address))
+	assert.True(t, breakers.Ejected(address))
+	assert.Equal(t, []string{"engine.0.1.1002.server"}, breakers.Healthy([]string{address, "engine.0.1.1002.server"}))
+	assert.Equal(t, map[string]BreakerState{address: BreakerOpen}, breakers.Open())
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	breakers, now := newTestBreakers()
+	const address = "engine.0.1.1001.server"
+	for i := 0; i < 4; i++ {
+		breakers.Failure(address)
+	}
+	assert.False(t, breakers.Allow(address))
+
+	// one probe after the open timeout, failing it opens the breaker again
+	*now = now.Add(time.Second)
+	assert.False(t, breakers.Ejected(address))
+	assert.True(t, breakers.Allow(address))
+	assert.Equal(t, BreakerHalfOpen, breakers.State(address))
+	assert.False(t, breakers.Allow(address))
+	breakers.Failure(address)
+	assert.Equal(t, BreakerOpen, breakers.State(address))
+
+	// a successful probe closes it
+	*now = now.Add(time.Second)
+	assert.True(t, breakers.Allow(address))
+	breakers.Success(address)
+	assert.Equal(t, BreakerClosed, breakers.State(address))
+	assert.True(t, breakers.Allow(address))
+
+	// a probe that never settles frees its slot after the open timeout
+	for i := 0; i < 4; i++ {
+		breakers.Failure(address)
+	}
+	*now = now.Add(time.Second)
+	assert.True(t, breakers.Allow(address))
+	assert.False(t, breakers.Allow(address))
+	*now = now.Add(time.Second)
+	assert.True(t, breakers.Allow(address))
+}
+
+func TestBreakerWindow(t *testing.T) {
+	breakers, now := newTestBreakers()
+	const address = "engine.0.1.1001.server"
+	for i := 0; i < 3; i++ {
+		breakers.Failure(address)
+	}
+	*now = now.Add(2 * time.Minute)
+	breakers.Failure(address)
+	assert.Equal(t, BreakerClosed, breakers.State(address))
+
+	breakers.SetPolicy(BreakerPolicy{})
+	for i := 0; i < 10; i++ {
+		breakers.Failure(address)
+	}
+	assert.True(t, breakers.Allow(address))
+}
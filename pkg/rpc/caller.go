@@ -0,0 +1,172 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/nats-io/nats.go"
+	"github.com/wuqunyong/file_storage/pkg/compress"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/pkg/msg"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+)
+
+// Caller sends requests whose arguments are already encoded, e.g. built
+// from the command line, and hands back the raw responses. It needs no
+// engine of its own.
+type Caller struct {
+	transport Transport
+	auth      *Authenticator
+	address   string
+	ch        chan *nats.Msg
+	sub       Subscription
+	dieChan   chan bool
+	seqId     uint64
+}
+
+type CallerOption func(*Caller)
+
+// WithCallerTransport replaces the transport picked from the connection
+// string.
+func WithCallerTransport(transport Transport) CallerOption {
+	return func(c *Caller) {
+		c.transport = transport
+	}
+}
+
+// WithCallerAuthenticator signs the requests and checks the responses.
+func WithCallerAuthenticator(auth *Authenticator) CallerOption {
+	return func(c *Caller) {
+		c.auth = auth
+	}
+}
+
+// NewCaller connects to connString and listens for responses on a client
+// address of its own.
+func NewCaller(connString string, opts ...CallerOption) (*Caller, error) {
+	c := &Caller{
+		address: concepts.GenClientAddress(0, 0, rand.Uint32()),
+		ch:      make(chan *nats.Msg, 64),
+		dieChan: make(chan bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.transport == nil {
+		c.transport = NewTransport(connString)
+	}
+
+	if err := c.transport.Connect(fmt.Sprintf("Caller:%s", c.address), c.dieChan, nil); err != nil {
+		return nil, err
+	}
+	sub, err := c.transport.ChanSubscribe(c.address, c.ch)
+	if err != nil {
+		c.transport.Close()
+		return nil, err
+	}
+	c.sub = sub
+	return c, nil
+}
+
+// Address is the subject the responses are sent to.
+func (c *Caller) Address() string {
+	return c.address
+}
+
+// Call sends args to opcode of target and hands the responses to handle, with
+// their result data decompressed. With stream it waits for more until one
+// without has_more arrives. It fails when ctx is done first.
+func (c *Caller) Call(ctx context.Context, target *concepts.ActorId, opcode uint32, args []byte, stream bool, handle func(*rpc_msg.RPC_RESPONSE) error) error {
+	request := c.newRequest(target, opcode, args, true)
+	request.Stream = stream
+	if err := c.send(request); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case m := <-c.ch:
+			response, err := c.receive(m, request.SeqId)
+			if err != nil {
+				logger.Log(logger.WarnLevel, "Caller drop response", "subject", m.Subject, "err", err)
+				continue
+			}
+			if response == nil {
+				continue
+			}
+			if err := handle(response); err != nil {
+				return err
+			}
+			if !stream || !response.GetHasMore() {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Notify sends args to opcode of target without waiting for a response.
+func (c *Caller) Notify(target *concepts.ActorId, opcode uint32, args []byte) error {
+	return c.send(c.newRequest(target, opcode, args, false))
+}
+
+func (c *Caller) Close() {
+	if c.sub != nil {
+		c.sub.Unsubscribe()
+	}
+	c.transport.Close()
+}
+
+func (c *Caller) newRequest(target *concepts.ActorId, opcode uint32, args []byte, requiredReply bool) *msg.MsgReq {
+	opts := concepts.NewRequestOptions()
+	opts.RequiredReply = requiredReply
+	request := msg.NewMsgReq(target, opcode, nil, opts)
+	request.Remote = true
+	request.ArgsData = args
+	request.Sender = concepts.NewActorId(c.address, "caller")
+	c.seqId++
+	request.SeqId = c.seqId
+	return request
+}
+
+func (c *Caller) send(request *msg.MsgReq) error {
+	defer request.CtxCancel()
+	data, err := request.Marshal()
+	if err != nil {
+		return err
+	}
+	subject := request.TargetId.Address
+	if c.auth != nil {
+		if data, err = c.auth.Seal(subject, data); err != nil {
+			return err
+		}
+	}
+	return c.transport.PublishRequest(subject, c.address, data)
+}
+
+// receive decodes the response in m, nil when it answers another request.
+func (c *Caller) receive(m *nats.Msg, seqId uint64) (*rpc_msg.RPC_RESPONSE, error) {
+	data := m.Data
+	if c.auth != nil {
+		var err error
+		if data, err = c.auth.Open(m.Subject, data); err != nil {
+			return nil, err
+		}
+	}
+	response, err := unmarshalResponse(data)
+	if err != nil {
+		return nil, err
+	}
+	if response.GetClient().GetSeqId() != seqId {
+		return nil, nil
+	}
+	response.ResultData, err = compress.Decode(response.GetCompression(), response.GetResultData())
+	if err != nil {
+		return nil, err
+	}
+	response.Compression = 0
+	return response, nil
+}
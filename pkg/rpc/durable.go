@@ -0,0 +1,259 @@
+package rpc
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+)
+
+const (
+	// DurableSubjectPrefix prefixes the server address of the node a durable
+	// notify is stored for, e.g. durable.engine.0.1.1001.server.
+	DurableSubjectPrefix = "durable."
+	// DeadLetterSubjectPrefix prefixes the server address of the node that
+	// gave up on a durable notify.
+	DeadLetterSubjectPrefix = "deadletter."
+
+	// DefaultDurableStream is the JetStream stream holding both.
+	DefaultDurableStream = "ENGINE_NOTIFY"
+)
+
+// DurablePolicy controls the redelivery of durable notifies whose handler
+// failed. The delay doubles on every delivery up to MaxBackoff; the last
+// failed delivery goes to the dead-letter subject.
+type DurablePolicy struct {
+	MaxDeliver int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// AckWait is how long a delivery may stay unacknowledged, e.g. while
+	// its node restarts, before it is delivered again.
+	AckWait time.Duration
+	// DeadLetter is the subject given up notifies are moved to, the
+	// DeadLetterSubject of the node when empty.
+	DeadLetter string
+}
+
+var DefaultDurablePolicy = DurablePolicy{
+	MaxDeliver: 5,
+	Backoff:    time.Second,
+	MaxBackoff: 30 * time.Second,
+	AckWait:    30 * time.Second,
+}
+
+func (p DurablePolicy) delay(delivered uint64) time.Duration {
+	backoff := p.Backoff
+	for i := uint64(1); i < delivered; i++ {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+// DurableSubject is the subject durable notifies for address are stored on.
+func DurableSubject(address string) string {
+	return DurableSubjectPrefix + address
+}
+
+// DeadLetterSubject is the subject notifies address gave up on are moved to.
+func DeadLetterSubject(address string) string {
+	return DeadLetterSubjectPrefix + address
+}
+
+// durableName names the consumer of a node, consumer names can not hold dots.
+func durableName(address string) string {
+	return strings.ReplaceAll(address, ".", "_")
+}
+
+// DurableMsg is a stored notify handed to a consumer.
+type DurableMsg interface {
+	Subject() string
+	Data() []byte
+	// NumDelivered counts the deliveries including this one.
+	NumDelivered() uint64
+	Ack() error
+	NakWithDelay(delay time.Duration) error
+	// Term stops the redelivery without acknowledging.
+	Term() error
+}
+
+// DurableStream stores notifies until the durable consumer of their node
+// acknowledged them.
+type DurableStream interface {
+	// Publish returns once data is stored.
+	Publish(subject string, data []byte) error
+	// Consume delivers the messages of subject not yet acknowledged by the
+	// durable consumer name, including those stored while it was away.
+	Consume(name, subject string, policy DurablePolicy, handler func(m DurableMsg)) (Subscription, error)
+}
+
+// JetStream keeps durable notifies in a NATS JetStream stream.
+type JetStream struct {
+	js     nats.JetStreamContext
+	conn   *nats.Conn
+	owned  bool
+	stream string
+}
+
+// ConnectJetStream opens its own connection to url, see NewJetStream.
+func ConnectJetStream(url, stream string, opts ...nats.Option) (*JetStream, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	js, err := NewJetStream(conn, stream)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	js.owned = true
+	return js, nil
+}
+
+// NewJetStream uses stream, creating it for the durable and dead-letter
+// subjects when it does not exist yet.
+func NewJetStream(conn *nats.Conn, stream string) (*JetStream, error) {
+	if stream == "" {
+		stream = DefaultDurableStream
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	_, err = js.StreamInfo(stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     stream,
+			Subjects: []string{DurableSubjectPrefix + ">", DeadLetterSubjectPrefix + ">"},
+			Storage:  nats.FileStorage,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &JetStream{js: js, conn: conn, stream: stream}, nil
+}
+
+// AddSubjects makes the stream also store subjects, e.g. a dead-letter
+// subject outside DeadLetterSubjectPrefix.
+func (s *JetStream) AddSubjects(subjects ...string) error {
+	info, err := s.js.StreamInfo(s.stream)
+	if err != nil {
+		return err
+	}
+	config := info.Config
+	for _, subject := range subjects {
+		if !slices.Contains(config.Subjects, subject) {
+			config.Subjects = append(config.Subjects, subject)
+		}
+	}
+	if len(config.Subjects) == len(info.Config.Subjects) {
+		return nil
+	}
+	_, err = s.js.UpdateStream(&config)
+	return err
+}
+
+func (s *JetStream) Publish(subject string, data []byte) error {
+	_, err := s.js.Publish(subject, data)
+	return err
+}
+
+// Consume binds a pull subscription to the durable consumer name, which
+// outlives the subscription so that a restarted node resumes where it
+// stopped.
+func (s *JetStream) Consume(name, subject string, policy DurablePolicy, handler func(m DurableMsg)) (Subscription, error) {
+	_, err := s.js.AddConsumer(s.stream, &nats.ConsumerConfig{
+		Durable:       name,
+		FilterSubject: subject,
+		AckPolicy:     nats.AckExplicitPolicy,
+		DeliverPolicy: nats.DeliverAllPolicy,
+		AckWait:       policy.AckWait,
+		MaxDeliver:    policy.MaxDeliver,
+	})
+	if err != nil && !errors.Is(err, nats.ErrConsumerNameAlreadyInUse) {
+		return nil, err
+	}
+	sub, err := s.js.PullSubscribe(subject, name, nats.Bind(s.stream, name))
+	if err != nil {
+		return nil, err
+	}
+
+	consumer := &jetStreamConsumer{sub: sub, done: make(chan struct{})}
+	consumer.wg.Add(1)
+	go consumer.fetch(handler)
+	return consumer, nil
+}
+
+func (s *JetStream) Close() {
+	if s.owned {
+		s.conn.Close()
+	}
+}
+
+type jetStreamConsumer struct {
+	sub  *nats.Subscription
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+func (c *jetStreamConsumer) fetch(handler func(m DurableMsg)) {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		msgs, err := c.sub.Fetch(16, nats.MaxWait(time.Second))
+		if err != nil && !errors.Is(err, nats.ErrTimeout) {
+			if errors.Is(err, nats.ErrBadSubscription) || errors.Is(err, nats.ErrConnectionClosed) {
+				return
+			}
+			logger.Log(logger.WarnLevel, "jetstream fetch", "subject", c.sub.Subject, "err", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, m := range msgs {
+			handler(jetStreamMsg{m})
+		}
+	}
+}
+
+func (c *jetStreamConsumer) Unsubscribe() error {
+	var err error
+	c.once.Do(func() {
+		close(c.done)
+		c.wg.Wait()
+		err = c.sub.Unsubscribe()
+	})
+	return err
+}
+
+type jetStreamMsg struct {
+	msg *nats.Msg
+}
+
+func (m jetStreamMsg) Subject() string { return m.msg.Subject }
+func (m jetStreamMsg) Data() []byte    { return m.msg.Data }
+func (m jetStreamMsg) Ack() error      { return m.msg.Ack() }
+func (m jetStreamMsg) Term() error     { return m.msg.Term() }
+
+func (m jetStreamMsg) NakWithDelay(delay time.Duration) error {
+	return m.msg.NakWithDelay(delay)
+}
+
+func (m jetStreamMsg) NumDelivered() uint64 {
+	meta, err := m.msg.Metadata()
+	if err != nil {
+		return 1
+	}
+	return meta.NumDelivered
+}
@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryStream is an in-process stand-in for JetStream with the same
+// delivery guarantees while the process lives: durable consumers keep
+// their position and unacknowledged messages across Consume calls.
+type MemoryStream struct {
+	mu        sync.Mutex
+	msgs      []*memoryEntry
+	consumers map[string]*memoryConsumer
+}
+
+type memoryEntry struct {
+	subject string
+	data    []byte
+}
+
+func NewMemoryStream() *MemoryStream {
+	return &MemoryStream{
+		consumers: make(map[string]*memoryConsumer),
+	}
+}
+
+func (s *MemoryStream) Publish(subject string, data []byte) error {
+	s.mu.Lock()
+	entry := &memoryEntry{subject: subject, data: append([]byte(nil), data...)}
+	s.msgs = append(s.msgs, entry)
+	var ready []*memoryMsg
+	for _, consumer := range s.consumers {
+		ready = append(ready, consumer.poll(s.msgs)...)
+	}
+	s.mu.Unlock()
+
+	for _, m := range ready {
+		m.deliver()
+	}
+	return nil
+}
+
+// Messages returns the data stored on subject, e.g. a dead-letter subject.
+func (s *MemoryStream) Messages(subject string) [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result [][]byte
+	for _, entry := range s.msgs {
+		if entry.subject == subject {
+			result = append(result, entry.data)
+		}
+	}
+	return result
+}
+
+func (s *MemoryStream) Consume(name, subject string, policy DurablePolicy, handler func(m DurableMsg)) (Subscription, error) {
+	s.mu.Lock()
+	consumer, ok := s.consumers[name]
+	if !ok {
+		consumer = &memoryConsumer{
+			stream:  s,
+			subject: subject,
+			unacked: make(map[*memoryMsg]struct{}),
+		}
+		s.consumers[name] = consumer
+	}
+	consumer.policy = policy
+	consumer.generation++
+	consumer.handler = handler
+
+	// whatever the previous subscriber left unacknowledged comes again
+	var ready []*memoryMsg
+	for m := range consumer.unacked {
+		if m.timer != nil {
+			m.timer.Stop()
+		}
+		ready = append(ready, m)
+	}
+	ready = append(ready, consumer.poll(s.msgs)...)
+	s.mu.Unlock()
+
+	for _, m := range ready {
+		m.deliver()
+	}
+	return &memorySubscription{consumer: consumer, generation: consumer.generation}, nil
+}
+
+type memoryConsumer struct {
+	stream     *MemoryStream
+	subject    string
+	policy     DurablePolicy
+	handler    func(m DurableMsg)
+	generation int
+	next       int
+	unacked    map[*memoryMsg]struct{}
+}
+
+// poll takes the messages stored since the last call, with the stream lock
+// held.
+func (c *memoryConsumer) poll(msgs []*memoryEntry) []*memoryMsg {
+	if c.handler == nil {
+		return nil
+	}
+	var ready []*memoryMsg
+	for ; c.next < len(msgs); c.next++ {
+		if msgs[c.next].subject != c.subject {
+			continue
+		}
+		m := &memoryMsg{consumer: c, entry: msgs[c.next]}
+		c.unacked[m] = struct{}{}
+		ready = append(ready, m)
+	}
+	return ready
+}
+
+type memorySubscription struct {
+	consumer   *memoryConsumer
+	generation int
+}
+
+func (s *memorySubscription) Unsubscribe() error {
+	stream := s.consumer.stream
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if s.consumer.generation == s.generation {
+		s.consumer.handler = nil
+	}
+	return nil
+}
+
+type memoryMsg struct {
+	consumer  *memoryConsumer
+	entry     *memoryEntry
+	delivered atomic.Uint64
+	timer     *time.Timer
+}
+
+// deliver hands m to the current handler, arming the ack wait timer.
+func (m *memoryMsg) deliver() {
+	stream := m.consumer.stream
+	stream.mu.Lock()
+	handler := m.consumer.handler
+	_, pending := m.consumer.unacked[m]
+	if handler == nil || !pending {
+		stream.mu.Unlock()
+		return
+	}
+	if policy := m.consumer.policy; policy.MaxDeliver > 0 && m.delivered.Load() >= uint64(policy.MaxDeliver) {
+		delete(m.consumer.unacked, m)
+		stream.mu.Unlock()
+		return
+	}
+	m.delivered.Add(1)
+	if wait := m.consumer.policy.AckWait; wait > 0 {
+		m.timer = time.AfterFunc(wait, m.deliver)
+	}
+	stream.mu.Unlock()
+
+	go handler(m)
+}
+
+// settle removes m from the unacknowledged set, it reports false when m was
+// already settled.
+func (m *memoryMsg) settle() bool {
+	stream := m.consumer.stream
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if _, ok := m.consumer.unacked[m]; !ok {
+		return false
+	}
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	delete(m.consumer.unacked, m)
+	return true
+}
+
+func (m *memoryMsg) Subject() string      { return m.entry.subject }
+func (m *memoryMsg) Data() []byte         { return m.entry.data }
+func (m *memoryMsg) NumDelivered() uint64 { return m.delivered.Load() }
+
+func (m *memoryMsg) Ack() error {
+	m.settle()
+	return nil
+}
+
+func (m *memoryMsg) Term() error {
+	m.settle()
+	return nil
+}
+
+func (m *memoryMsg) NakWithDelay(delay time.Duration) error {
+	stream := m.consumer.stream
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if _, ok := m.consumer.unacked[m]; !ok {
+		return nil
+	}
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.timer = time.AfterFunc(delay, m.deliver)
+	return nil
+}
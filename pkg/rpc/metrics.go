@@ -0,0 +1,41 @@
+package rpc
+
+import (
+	"strconv"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/metrics"
+	"github.com/wuqunyong/file_storage/pkg/msg"
+)
+
+var (
+	clientDuration = metrics.NewHistogramVec("rpc_client_request_duration_seconds",
+		"Time from sending a remote request to its response.", nil, "opcode")
+	clientResponses = metrics.NewCounterVec("rpc_client_responses_total",
+		"Responses to remote requests, by opcode and error code.", "opcode", "code")
+	clientTimeouts = metrics.NewCounterVec("rpc_client_timeouts_total",
+		"Remote requests that got no response before their deadline.", "opcode")
+	circuitRejections = metrics.NewCounterVec("rpc_client_circuit_rejections_total",
+		"Remote requests failed at once because the circuit of their target was open.", "target")
+)
+
+func opcodeOf(request concepts.IMsgReq) string {
+	if req, ok := request.(*msg.MsgReq); ok {
+		return strconv.FormatUint(uint64(req.FuncName), 10)
+	}
+	return ""
+}
+
+func observeResponse(call *pendingCall, resp concepts.IMsgResp) {
+	opcode := opcodeOf(call.request)
+	clientDuration.With(opcode).ObserveSince(call.sent)
+	code := "0"
+	if response, ok := resp.(*msg.MsgResp); ok {
+		code = strconv.FormatUint(uint64(response.ErrCode), 10)
+	}
+	clientResponses.With(opcode, code).Inc()
+}
+
+func observeTimeout(request concepts.IMsgReq) {
+	clientTimeouts.With(opcodeOf(request)).Inc()
+}
@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+)
+
+// DefaultSweepInterval is how often expired pending requests are removed.
+var DefaultSweepInterval = time.Second
+
+// PendingStats is a snapshot of the pending table gauges and counters.
+type PendingStats struct {
+	InFlight  int64
+	Completed uint64
+	TimedOut  uint64
+	Failed    uint64
+	Stale     uint64
+}
+
+type pendingCall struct {
+	request  concepts.IMsgReq
+	sent     time.Time
+	deadline time.Time
+}
+
+// pendingTable correlates responses with requests by the SeqId they were
+// sent with. Entries leave the table on response, deadline or failure.
+type pendingTable struct {
+	mu    sync.Mutex
+	calls map[uint64]*pendingCall
+	// in flight requests by target address
+	targets map[string]int
+	// onExpire is told about every request removed by sweep
+	onExpire func(request concepts.IMsgReq)
+
+	inFlight  atomic.Int64
+	completed atomic.Uint64
+	timedOut  atomic.Uint64
+	failed    atomic.Uint64
+	stale     atomic.Uint64
+}
+
+func newPendingTable() *pendingTable {
+	return &pendingTable{
+		calls:   make(map[uint64]*pendingCall),
+		targets: make(map[string]int),
+	}
+}
+
+func (t *pendingTable) add(seqId uint64, request concepts.IMsgReq, deadline time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if previous, ok := t.calls[seqId]; ok {
+		t.release(previous)
+	} else {
+		t.inFlight.Add(1)
+	}
+	t.calls[seqId] = &pendingCall{request: request, sent: time.Now(), deadline: deadline}
+	t.targets[request.GetTarget().Address]++
+}
+
+// release forgets call in the count of its target, t.mu held.
+func (t *pendingTable) release(call *pendingCall) {
+	address := call.request.GetTarget().Address
+	if t.targets[address] <= 1 {
+		delete(t.targets, address)
+	} else {
+		t.targets[address]--
+	}
+}
+
+// pendingTo is the number of requests to address waiting for a response.
+func (t *pendingTable) pendingTo(address string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.targets[address]
+}
+
+// remove drops seqId without touching the request, e.g. when it could not
+// be published.
+func (t *pendingTable) remove(seqId uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if call, ok := t.calls[seqId]; ok {
+		delete(t.calls, seqId)
+		t.release(call)
+		t.inFlight.Add(-1)
+	}
+}
+
+// expire drops seqId as timed out, e.g. when the caller gave up waiting and
+// resends the request before sweep came across it.
+func (t *pendingTable) expire(seqId uint64) {
+	t.mu.Lock()
+	call, ok := t.calls[seqId]
+	if ok {
+		delete(t.calls, seqId)
+		t.release(call)
+		t.inFlight.Add(-1)
+		t.timedOut.Add(1)
+	}
+	t.mu.Unlock()
+
+	if ok && t.onExpire != nil {
+		t.onExpire(call.request)
+	}
+}
+
+// complete takes the call waiting for seqId out of the table.
+func (t *pendingTable) complete(seqId uint64) (*pendingCall, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	call, ok := t.calls[seqId]
+	if !ok {
+		t.stale.Add(1)
+		return nil, false
+	}
+	delete(t.calls, seqId)
+	t.release(call)
+	t.inFlight.Add(-1)
+	t.completed.Add(1)
+	return call, true
+}
+
+// sweep fails every request whose deadline passed before now.
+func (t *pendingTable) sweep(now time.Time, err error) int {
+	t.mu.Lock()
+	expired := make(map[uint64]concepts.IMsgReq)
+	for seqId, call := range t.calls {
+		if now.After(call.deadline) {
+			delete(t.calls, seqId)
+			t.release(call)
+			expired[seqId] = call.request
+		}
+	}
+	t.inFlight.Add(-int64(len(expired)))
+	t.timedOut.Add(uint64(len(expired)))
+	t.mu.Unlock()
+
+	for seqId, request := range expired {
+		if t.onExpire != nil {
+			t.onExpire(request)
+		}
+		request.Fail(seqId, err)
+	}
+	return len(expired)
+}
+
+// failAll empties the table, failing every request with err.
+func (t *pendingTable) failAll(err error) int {
+	t.mu.Lock()
+	calls := t.calls
+	t.calls = make(map[uint64]*pendingCall)
+	t.targets = make(map[string]int)
+	t.inFlight.Add(-int64(len(calls)))
+	t.failed.Add(uint64(len(calls)))
+	t.mu.Unlock()
+
+	for seqId, call := range calls {
+		call.request.Fail(seqId, err)
+	}
+	return len(calls)
+}
+
+func (t *pendingTable) stats() PendingStats {
+	return PendingStats{
+		InFlight:  t.inFlight.Load(),
+		Completed: t.completed.Load(),
+		TimedOut:  t.timedOut.Load(),
+		Failed:    t.failed.Load(),
+		Stale:     t.stale.Load(),
+	}
+}
@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/constants"
+	"github.com/wuqunyong/file_storage/pkg/msg"
+)
+
+func newPendingRequest(timeout time.Duration) *msg.MsgReq {
+	target := concepts.NewActorId("engine.0.1.1002.server", "test")
+	return msg.NewMsgReq(target, 1, nil, concepts.NewRequestOptions(concepts.WithTimeout(timeout)))
+}
+
+func TestPendingCorrelation(t *testing.T) {
+	client := NewRPCClient(nil, "", "engine.0.1.1001.client")
+
+	requests := []*msg.MsgReq{newPendingRequest(time.Second), newPendingRequest(time.Second)}
+	for i, request := range requests {
+		request.SetSeqId(uint64(i + 1))
+		client.pending.add(request.GetSeqId(), request, time.Now().Add(time.Second))
+	}
+	assert.Equal(t, int64(2), client.PendingStats().InFlight)
+	assert.Equal(t, 2, client.PendingTo("engine.0.1.1002.server"))
+
+	// answer in reverse order, each caller must get its own response
+	for i := len(requests) - 1; i >= 0; i-- {
+		go func(seqId uint64) {
+			resp := msg.NewMsgResp(seqId, uint32(seqId), "", nil)
+			assert.NoError(t, client.HandleResponse(seqId, resp))
+		}(requests[i].GetSeqId())
+	}
+	for _, request := range requests {
+		resp, err := request.Result()
+		require.NoError(t, err)
+		assert.Equal(t, request.GetSeqId(), resp.SeqId)
+		assert.Equal(t, uint32(request.GetSeqId()), resp.ErrCode)
+	}
+
+	assert.Error(t, client.HandleResponse(1, msg.NewMsgResp(1, 0, "", nil)))
+	stats := client.PendingStats()
+	assert.Equal(t, int64(0), stats.InFlight)
+	assert.Equal(t, uint64(2), stats.Completed)
+	assert.Equal(t, uint64(1), stats.Stale)
+	assert.Equal(t, 0, client.PendingTo("engine.0.1.1002.server"))
+}
+
+func TestPendingSweep(t *testing.T) {
+	table := newPendingTable()
+	expired := newPendingRequest(time.Minute)
+	alive := newPendingRequest(time.Minute)
+	now := time.Now()
+	expired.SetSeqId(1)
+	alive.SetSeqId(2)
+	table.add(1, expired, now.Add(-time.Millisecond))
+	table.add(2, alive, now.Add(time.Minute))
+
+	assert.Equal(t, 1, table.sweep(now, constants.ErrRPCRequestTimeout))
+	_, err := expired.Result()
+	assert.True(t, errors.Is(err, constants.ErrRPCRequestTimeout))
+
+	stats := table.stats()
+	assert.Equal(t, int64(1), stats.InFlight)
+	assert.Equal(t, uint64(1), stats.TimedOut)
+	assert.Equal(t, 1, table.pendingTo("engine.0.1.1002.server"))
+}
+
+func TestPendingFailAll(t *testing.T) {
+	client := NewRPCClient(nil, "", "engine.0.1.1001.client")
+	requests := []*msg.MsgReq{newPendingRequest(time.Minute), newPendingRequest(time.Minute)}
+	for i, request := range requests {
+		request.SetSeqId(uint64(i + 1))
+		client.pending.add(request.GetSeqId(), request, time.Now().Add(time.Minute))
+	}
+
+	client.onDisconnect(errors.New("broken pipe"))
+	for _, request := range requests {
+		_, err := request.Result()
+		assert.True(t, errors.Is(err, constants.ErrRPCConnectionLost))
+	}
+	stats := client.PendingStats()
+	assert.Equal(t, int64(0), stats.InFlight)
+	assert.Equal(t, uint64(2), stats.Failed)
+}
+
+func TestPendingStaleFailure(t *testing.T) {
+	table := newPendingTable()
+	request := newPendingRequest(50 * time.Millisecond)
+	request.SetSeqId(1)
+	table.add(1, request, time.Now().Add(-time.Millisecond))
+	table.sweep(time.Now(), constants.ErrRPCRequestTimeout)
+
+	// the failure of the first attempt must not fail the resent one
+	request.SetSeqId(2)
+	_, err := request.Result()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestPendingRetryOpensBreaker(t *testing.T) {
+	client := NewRPCClient(nil, "loopback://TestPendingRetryOpensBreaker", "engine.0.1.1001.client",
+		WithSweepInterval(time.Hour),
+		WithBreakerPolicy(BreakerPolicy{Window: time.Minute, MinRequests: 2, FailureRatio: 0.5, OpenTimeout: time.Minute}))
+	require.NoError(t, client.Init())
+	defer client.Stop()
+
+	// nobody serves the target, every attempt times out before any sweep
+	target := concepts.NewActorId("engine.0.1.1009.server", "test")
+	request := msg.NewMsgReq(target, 1, nil, concepts.NewRequestOptions(concepts.WithTimeout(20*time.Millisecond), concepts.WithRetry(3, 0)))
+	request.Sender = concepts.NewActorId("engine.0.1.1001.server", "test")
+	request.Remote = true
+	request.SetRPCClient(client)
+	require.NoError(t, client.SendRequest(request))
+
+	_, err := request.Result()
+	assert.Error(t, err)
+	assert.Equal(t, BreakerOpen, client.Breakers().State(target.Address))
+	assert.Equal(t, uint64(2), client.PendingStats().TimedOut)
+}
@@ -0,0 +1,306 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/wuqunyong/file_storage/pkg/compress"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/constants"
+	"github.com/wuqunyong/file_storage/proto/nats_msg"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultReplayTimeout is how long the replayer waits for each response.
+var DefaultReplayTimeout = 5 * time.Second
+
+// Replayer sends the requests of a recording to the server of an engine and
+// compares its responses with the recorded ones.
+type Replayer struct {
+	connString string
+	target     string
+	node       string
+	speed      float64
+	timeout    time.Duration
+}
+
+type ReplayOption func(*Replayer)
+
+// WithReplayNode replays only the requests received by the server node.
+func WithReplayNode(node string) ReplayOption {
+	return func(r *Replayer) {
+		r.node = node
+	}
+}
+
+// WithReplaySpeed scales the recorded timing, 1 sends the requests as they
+// were received, 10 ten times faster and 0 without waiting.
+func WithReplaySpeed(speed float64) ReplayOption {
+	return func(r *Replayer) {
+		r.speed = speed
+	}
+}
+
+func WithReplayTimeout(timeout time.Duration) ReplayOption {
+	return func(r *Replayer) {
+		r.timeout = timeout
+	}
+}
+
+// NewReplayer sends to the server address target over the transport of
+// connString, usually the loopback one of a local engine.
+func NewReplayer(connString, target string, opts ...ReplayOption) *Replayer {
+	r := &Replayer{
+		connString: connString,
+		target:     target,
+		speed:      1,
+		timeout:    DefaultReplayTimeout,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// ReplayResult is the outcome of a request expecting a response.
+type ReplayResult struct {
+	Request *TapRecord
+	Opcode  uint32
+	// Expected is nil when the recording has no response to the request,
+	// Actual when none arrived in time.
+	Expected *rpc_msg.RPC_RESPONSE
+	Actual   *rpc_msg.RPC_RESPONSE
+	// Diff describes how Actual differs from Expected, empty when they match.
+	Diff string
+}
+
+type ReplayReport struct {
+	Sent    int
+	Results []ReplayResult
+}
+
+// Mismatches are the results whose response differs from the recorded one.
+func (r *ReplayReport) Mismatches() []ReplayResult {
+	var mismatches []ReplayResult
+	for _, result := range r.Results {
+		if result.Diff != "" {
+			mismatches = append(mismatches, result)
+		}
+	}
+	return mismatches
+}
+
+type replayCall struct {
+	result   *ReplayResult
+	deadline time.Time
+}
+
+// Replay sends the requests recorded as received, in order, and waits for
+// their responses.
+func (r *Replayer) Replay(ctx context.Context, records []TapRecord) (*ReplayReport, error) {
+	realm, kind, id, err := concepts.DecodeAddress(r.target)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := NewTransport(r.connString)
+	dieChan := make(chan bool)
+	if err := transport.Connect("Replayer", dieChan, nil); err != nil {
+		return nil, err
+	}
+	defer transport.Close()
+	subject := "replay." + strconv.FormatUint(rand.Uint64(), 36)
+	ch := make(chan *nats.Msg, 1024)
+	sub, err := transport.ChanSubscribe(subject, ch)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	var (
+		mu    sync.Mutex
+		calls = make(map[uint64]*replayCall)
+		order []*ReplayResult
+	)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case m := <-ch:
+				response, err := unmarshalResponse(m.Data)
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				if call, ok := calls[response.GetClient().GetSeqId()]; ok && call.result.Actual == nil {
+					call.result.Actual = response
+				}
+				mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	report := &ReplayReport{}
+	var start, first time.Time
+	for i := range records {
+		record := &records[i]
+		request, ok := r.replayable(record)
+		if !ok {
+			continue
+		}
+		if first.IsZero() {
+			first, start = record.Time, time.Now()
+		} else if r.speed > 0 {
+			wait := time.Until(start.Add(time.Duration(float64(record.Time.Sub(first)) / r.speed)))
+			if err := sleepContext(ctx, wait); err != nil {
+				return report, err
+			}
+		}
+
+		client := request.GetClient()
+		seqId := uint64(report.Sent + 1)
+		if client.GetRequiredReply() {
+			result := &ReplayResult{
+				Request:  record,
+				Opcode:   request.GetOpcodes(),
+				Expected: findResponse(records[i+1:], replySubject(client), client.GetSeqId()),
+			}
+			mu.Lock()
+			calls[seqId] = &replayCall{result: result, deadline: time.Now().Add(r.timeout)}
+			mu.Unlock()
+			order = append(order, result)
+		}
+
+		client.SeqId = seqId
+		client.ReplyTopic = subject
+		request.Server.Stub = &rpc_msg.CHANNEL{Realm: realm, Type: kind, Id: id, ActorId: request.GetServer().GetStub().GetActorId()}
+		data, err := proto.Marshal(&nats_msg.NATS_MSG_PRXOY{Msg: &nats_msg.NATS_MSG_PRXOY_RpcRequest{RpcRequest: request}})
+		if err != nil {
+			return report, err
+		}
+		if err := transport.PublishRequest(r.target, subject, data); err != nil {
+			return report, err
+		}
+		report.Sent++
+	}
+
+	// the responses are compared once every call got one or timed out
+	for {
+		mu.Lock()
+		waiting := false
+		for _, call := range calls {
+			if call.result.Actual == nil && time.Now().Before(call.deadline) {
+				waiting = true
+				break
+			}
+		}
+		mu.Unlock()
+		if !waiting {
+			break
+		}
+		if err := sleepContext(ctx, 10*time.Millisecond); err != nil {
+			return report, err
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, result := range order {
+		result.Diff = diffResponses(result.Expected, result.Actual)
+		report.Results = append(report.Results, *result)
+	}
+	return report, nil
+}
+
+// replayable returns the request of record when it is one to replay.
+func (r *Replayer) replayable(record *TapRecord) (*rpc_msg.RPC_REQUEST, bool) {
+	if record.Direction != TapIn || (r.node != "" && record.Node != r.node) {
+		return nil, false
+	}
+	envelope := &nats_msg.NATS_MSG_PRXOY{}
+	if err := proto.Unmarshal(record.Data, envelope); err != nil {
+		return nil, false
+	}
+	request := envelope.GetRpcRequest()
+	if request.GetClient() == nil || request.GetServer() == nil {
+		return nil, false
+	}
+	return request, true
+}
+
+// findResponse is the first response recorded on subject for seqId.
+func findResponse(records []TapRecord, subject string, seqId uint64) *rpc_msg.RPC_RESPONSE {
+	for _, record := range records {
+		if record.Subject != subject {
+			continue
+		}
+		response, err := unmarshalResponse(record.Data)
+		if err == nil && response.GetClient().GetSeqId() == seqId {
+			return response
+		}
+	}
+	return nil
+}
+
+func unmarshalResponse(data []byte) (*rpc_msg.RPC_RESPONSE, error) {
+	envelope := &nats_msg.NATS_MSG_PRXOY{}
+	if err := proto.Unmarshal(data, envelope); err != nil {
+		return nil, err
+	}
+	response := envelope.GetRpcResponse()
+	if response == nil {
+		return nil, constants.ErrInvalidNatsMsgType
+	}
+	return response, nil
+}
+
+func diffResponses(expected, actual *rpc_msg.RPC_RESPONSE) string {
+	if expected == nil {
+		return ""
+	}
+	if actual == nil {
+		return "no response"
+	}
+
+	var diffs []string
+	if e, a := expected.GetStatus().GetCode(), actual.GetStatus().GetCode(); e != a {
+		diffs = append(diffs, fmt.Sprintf("code %d != %d", e, a))
+	}
+	if e, a := expected.GetStatus().GetMsg(), actual.GetStatus().GetMsg(); e != a {
+		diffs = append(diffs, fmt.Sprintf("msg %q != %q", e, a))
+	}
+	e, errE := compress.Decode(expected.GetCompression(), expected.GetResultData())
+	a, errA := compress.Decode(actual.GetCompression(), actual.GetResultData())
+	if errE != nil || errA != nil {
+		diffs = append(diffs, fmt.Sprintf("result undecodable: %v, %v", errE, errA))
+	} else if !bytes.Equal(e, a) {
+		diffs = append(diffs, fmt.Sprintf("result %x != %x", e, a))
+	}
+
+	return strings.Join(diffs, ", ")
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
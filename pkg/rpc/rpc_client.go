@@ -1,10 +1,10 @@
 package rpc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime/debug"
-	"sync"
 	"sync/atomic"
 	"time"
 
@@ -17,34 +17,91 @@ import (
 )
 
 type RPCClient struct {
-	id                     string
-	connString             string
-	connectionTimeout      time.Duration
-	maxReconnectionRetries int
-	conn                   *nats.Conn
-	dieChan                chan bool
-	topic                  *cluster.NatsSubject
-	closed                 atomic.Bool
-	seqId                  uint64
-	pendingMu              sync.Mutex
-	pending                map[uint64]concepts.IMsgReq
-	engine                 concepts.IEngine
+	id            string
+	transport     Transport
+	dieChan       chan bool
+	topic         *cluster.NatsSubject
+	closed        atomic.Bool
+	seqId         atomic.Uint64
+	pending       *pendingTable
+	sweepInterval time.Duration
+	durable       DurableStream
+	auth          *Authenticator
+	tap           atomic.Pointer[Tap]
+	breakers      *BreakerSet
+	engine        concepts.IEngine
 }
 
 type RPCClientOpt func(*RPCClient)
 
+// WithClientTransport replaces the transport picked from the connection string.
+func WithClientTransport(transport Transport) RPCClientOpt {
+	return func(rpc *RPCClient) {
+		rpc.transport = transport
+	}
+}
+
+// SetTransport replaces the transport before Init, closing the old one.
+func (rpc *RPCClient) SetTransport(transport Transport) {
+	if rpc.transport != transport {
+		rpc.transport.Close()
+	}
+	rpc.transport = transport
+}
+
+// SetPeerResolver makes the direct TCP transport of the client, if any, find
+// its peers with resolver.
+func (rpc *RPCClient) SetPeerResolver(resolver PeerResolver) {
+	if transport := tcpTransportOf(rpc.transport); transport != nil {
+		transport.SetResolver(resolver)
+	}
+}
+
+// WithSweepInterval sets how often requests past their deadline are removed
+// from the pending table.
+func WithSweepInterval(interval time.Duration) RPCClientOpt {
+	return func(rpc *RPCClient) {
+		rpc.sweepInterval = interval
+	}
+}
+
+// WithDurableStream stores notifies sent with concepts.WithDurable in stream.
+func WithDurableStream(stream DurableStream) RPCClientOpt {
+	return func(rpc *RPCClient) {
+		rpc.durable = stream
+	}
+}
+
+// WithClientAuthenticator signs the requests and checks the responses.
+func WithClientAuthenticator(auth *Authenticator) RPCClientOpt {
+	return func(rpc *RPCClient) {
+		rpc.auth = auth
+	}
+}
+
+// WithBreakerPolicy sets the policy of the per target address breakers.
+func WithBreakerPolicy(policy BreakerPolicy) RPCClientOpt {
+	return func(rpc *RPCClient) {
+		rpc.breakers.SetPolicy(policy)
+	}
+}
+
 func NewRPCClient(engine concepts.IEngine, connString, subjectName string, opts ...RPCClientOpt) *RPCClient {
 	rpcClient := &RPCClient{
-		id:                     fmt.Sprintf("RPCClient:%s", time.Now().UTC()),
-		engine:                 engine,
-		connString:             connString,
-		connectionTimeout:      time.Duration(6) * time.Second,
-		maxReconnectionRetries: 3,
-		dieChan:                make(chan bool),
-		topic:                  cluster.NewNatsSubject(subjectName, 1024),
-		pending:                make(map[uint64]concepts.IMsgReq),
+		id:            fmt.Sprintf("RPCClient:%s", time.Now().UTC()),
+		engine:        engine,
+		transport:     NewTransport(connString),
+		dieChan:       make(chan bool),
+		topic:         cluster.NewNatsSubject(subjectName, 1024),
+		pending:       newPendingTable(),
+		sweepInterval: DefaultSweepInterval,
+		breakers:      NewBreakerSet(DefaultBreakerPolicy),
 	}
 	rpcClient.closed.Store(false)
+	rpcClient.pending.onExpire = func(request concepts.IMsgReq) {
+		rpcClient.breakers.Failure(request.GetTarget().Address)
+		observeTimeout(request)
+	}
 
 	for _, opt := range opts {
 		opt(rpcClient)
@@ -54,19 +111,12 @@ func NewRPCClient(engine concepts.IEngine, connString, subjectName string, opts
 }
 
 func (rpc *RPCClient) Init() error {
-	conn, err := cluster.SetupNatsConn(
-		rpc.id,
-		rpc.connString,
-		rpc.dieChan,
-		nats.MaxReconnects(rpc.maxReconnectionRetries),
-		nats.Timeout(rpc.connectionTimeout),
-	)
+	err := rpc.transport.Connect(rpc.id, rpc.dieChan, rpc.onDisconnect)
 	if err != nil {
 		return err
 	}
-	rpc.conn = conn
 
-	rpc.topic.Subscription, err = rpc.conn.ChanSubscribe(rpc.topic.Subject, rpc.topic.Ch)
+	rpc.topic.Subscription, err = rpc.transport.ChanSubscribe(rpc.topic.Subject, rpc.topic.Ch)
 	if err != nil {
 		fmt.Printf("[remoteProcess] Subscribe fail. [subject = %s, err = %s]", rpc.topic.Subject, err)
 		return err
@@ -91,15 +141,24 @@ func (rpc *RPCClient) Run() {
 			logger.Log(logger.ErrorLevel, "nats receive", "header", natsMsg.Header, "Subject", natsMsg.Subject, "Reply", natsMsg.Reply)
 			return
 		}
-		response, err := msg.ResponseUnmarshal(natsMsg.Data)
+		data, err := rpc.open(natsMsg.Subject, natsMsg.Data)
+		if err != nil {
+			logger.Log(logger.WarnLevel, "RPCClient drop response", "subject", natsMsg.Subject, "err", err)
+			return
+		}
+		rpc.tap.Load().Record(rpc.topic.Subject, TapIn, natsMsg.Subject, data)
+		response, err := msg.ResponseUnmarshal(data)
 		if err != nil {
 			fmt.Printf("err:%+v", err)
 			return
 		}
 
-		rpc.HandleResponse(rpc.seqId, response)
+		rpc.HandleResponse(response.SeqId, response)
 	}
 
+	ticker := time.NewTicker(rpc.sweepInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case msg, ok := <-rpc.topic.Ch:
@@ -107,19 +166,35 @@ func (rpc *RPCClient) Run() {
 				return
 			}
 			process(msg)
+		case now := <-ticker.C:
+			if n := rpc.pending.sweep(now, constants.ErrRPCRequestTimeout); n > 0 {
+				logger.Log(logger.WarnLevel, "RPCClient sweep expired requests", "count", n)
+			}
 		case <-rpc.dieChan:
 			return
 		}
 	}
 }
 
+// onDisconnect fails every pending request at once, their responses can not
+// arrive on a subscription that is gone.
+func (rpc *RPCClient) onDisconnect(err error) {
+	n := rpc.pending.failAll(constants.ErrRPCConnectionLost)
+	logger.Log(logger.WarnLevel, "disconnected from nats!", "id", rpc.id, "err", err, "failed", n)
+}
+
 func (rpc *RPCClient) Send(topic string, data []byte) error {
 	if rpc.closed.Load() {
 		return constants.ErrRPCClientHasClosed
 	}
 
 	reply := rpc.getReplySubject()
-	return rpc.conn.PublishRequest(topic, reply, data)
+	rpc.tap.Load().Record(rpc.topic.Subject, TapOut, topic, data)
+	data, err := rpc.seal(topic, data)
+	if err != nil {
+		return err
+	}
+	return rpc.transport.PublishRequest(topic, reply, data)
 }
 
 func (rpc *RPCClient) SendRequest(request concepts.IMsgReq) error {
@@ -129,44 +204,143 @@ func (rpc *RPCClient) SendRequest(request concepts.IMsgReq) error {
 
 	reply := rpc.getReplySubject()
 	request.GetSender().Address = reply
+
+	if request.IsDurable() {
+		return rpc.sendDurable(request)
+	}
+
+	// a resent request gets a fresh SeqId, late replies to the previous
+	// attempt are dropped as stale. An attempt still pending timed out.
+	if previous := request.GetSeqId(); previous != 0 {
+		rpc.pending.expire(previous)
+	}
+	target := request.GetTarget().Address
+	if request.IsRequiredReply() && !rpc.breakers.Allow(target) {
+		circuitRejections.With(target).Inc()
+		return constants.ErrRPCCircuitOpen
+	}
+	seqId := rpc.seqId.Add(1)
+	request.SetSeqId(seqId)
+	if request.IsRequiredReply() {
+		rpc.pending.add(seqId, request, time.Now().Add(request.GetTimeout()))
+	}
+
+	data, err := request.Marshal()
+	if err == nil {
+		rpc.tap.Load().Record(rpc.topic.Subject, TapOut, target, data)
+		data, err = rpc.seal(target, data)
+	}
+	if err == nil {
+		if err = rpc.transport.PublishRequest(target, reply, data); err != nil {
+			// a message the transport refuses says nothing about the node
+			if request.IsRequiredReply() && !errors.Is(err, errMessageTooLarge) && !errors.Is(err, errQueueChunking) {
+				rpc.breakers.Failure(target)
+			}
+			err = fmt.Errorf("%w: %w", constants.ErrNoConnectionToServer, err)
+		}
+	}
+	if err != nil {
+		rpc.pending.remove(seqId)
+		return err
+	}
+	return nil
+}
+
+// sendDurable stores a notify until the target node acknowledged it.
+func (rpc *RPCClient) sendDurable(request concepts.IMsgReq) error {
+	if request.IsRequiredReply() {
+		return constants.ErrRPCDurableRequest
+	}
+	if rpc.durable == nil {
+		return constants.ErrRPCDurableNotConfigured
+	}
+	request.SetSeqId(rpc.seqId.Add(1))
+	subject := DurableSubject(request.GetTarget().Address)
 	data, err := request.Marshal()
+	if err == nil {
+		data, err = rpc.seal(subject, data)
+	}
 	if err != nil {
 		return err
 	}
+	return rpc.durable.Publish(subject, data)
+}
+
+// SetDurableStream stores notifies sent with concepts.WithDurable in stream.
+func (rpc *RPCClient) SetDurableStream(stream DurableStream) {
+	rpc.durable = stream
+}
+
+// SetAuthenticator signs the requests and checks the responses with auth.
+func (rpc *RPCClient) SetAuthenticator(auth *Authenticator) {
+	rpc.auth = auth
+}
+
+// SetTap records the requests sent and the responses received, nil stops
+// recording.
+func (rpc *RPCClient) SetTap(tap *Tap) {
+	rpc.tap.Store(tap)
+}
 
-	rpc.pendingMu.Lock()
-	defer rpc.pendingMu.Unlock()
-	rpc.seqId++
-	request.SetSeqId(rpc.seqId)
-	rpc.pending[rpc.seqId] = request
+func (rpc *RPCClient) Tap() *Tap {
+	return rpc.tap.Load()
+}
+
+func (rpc *RPCClient) seal(subject string, data []byte) ([]byte, error) {
+	if rpc.auth == nil {
+		return data, nil
+	}
+	return rpc.auth.Seal(subject, data)
+}
 
-	return rpc.conn.PublishRequest(request.GetTarget().Address, reply, data)
+func (rpc *RPCClient) open(subject string, data []byte) ([]byte, error) {
+	if rpc.auth == nil {
+		return data, nil
+	}
+	return rpc.auth.Open(subject, data)
 }
 
+// HandleResponse hands resp to the request that was sent with SeqId id.
 func (rpc *RPCClient) HandleResponse(id uint64, resp concepts.IMsgResp) error {
 	if rpc.closed.Load() {
 		return constants.ErrRPCClientHasClosed
 	}
 
-	rpc.pendingMu.Lock()
-	defer rpc.pendingMu.Unlock()
-	call, ok := rpc.pending[id]
+	call, ok := rpc.pending.complete(id)
 	if !ok {
-		return errors.New("invalid id")
+		return fmt.Errorf("no pending request for seqId %d", id)
 	}
-	delete(rpc.pending, id)
+	rpc.breakers.Success(call.request.GetTarget().Address)
+	observeResponse(call, resp)
 
-	call.HandleResponse(resp)
+	call.request.HandleResponse(resp)
 	return nil
 }
 
+// Breakers returns the circuit breakers of the target addresses.
+func (rpc *RPCClient) Breakers() *BreakerSet {
+	return rpc.breakers
+}
+
+// PendingStats returns the gauges of the pending request table.
+func (rpc *RPCClient) PendingStats() PendingStats {
+	return rpc.pending.stats()
+}
+
+// PendingTo is the number of requests to the server address still waiting
+// for their response.
+func (rpc *RPCClient) PendingTo(address string) int {
+	return rpc.pending.pendingTo(address)
+}
+
 func (rpc *RPCClient) Stop() {
 	if rpc.closed.Load() {
 		return
 	}
 	rpc.closed.Store(true)
+	rpc.pending.failAll(constants.ErrRPCClientHasClosed)
 	rpc.topic.Stop()
-	rpc.conn.Close()
+	rpc.transport.Close()
 }
 
 func (rpc *RPCClient) getReplySubject() string {
@@ -176,3 +350,7 @@ func (rpc *RPCClient) getReplySubject() string {
 func (rpc *RPCClient) GetAddress() string {
 	return rpc.topic.Subject
 }
+
+func (rpc *RPCClient) HealthCheck(ctx context.Context) error {
+	return rpc.transport.Status()
+}
@@ -1,45 +1,87 @@
 package rpc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"runtime/debug"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/wuqunyong/file_storage/pkg/cluster"
 	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/dedup"
+	"github.com/wuqunyong/file_storage/pkg/errs"
 	"github.com/wuqunyong/file_storage/pkg/logger"
 	"github.com/wuqunyong/file_storage/pkg/msg"
 )
 
 type RPCServer struct {
-	id                     string
-	connString             string
-	connectionTimeout      time.Duration
-	maxReconnectionRetries int
-	conn                   *nats.Conn
-	dieChan                chan bool
-	topic                  *cluster.NatsSubject
-	closed                 atomic.Bool
-	engine                 concepts.IEngine
+	id        string
+	transport Transport
+	dieChan   chan bool
+	topic     *cluster.NatsSubject
+	closed    atomic.Bool
+	engine    concepts.IEngine
+	dedup     *dedup.Cache
+	auth      *Authenticator
+	tap       atomic.Pointer[Tap]
+
+	durable       DurableStream
+	durablePolicy DurablePolicy
+	durableSub    Subscription
+
+	anySubject string
+	anyMu      sync.Mutex
+	anySub     Subscription
 }
 
 type RPCServerOpt func(*RPCServer)
 
+// WithServerTransport replaces the transport picked from the connection string.
+func WithServerTransport(transport Transport) RPCServerOpt {
+	return func(rpc *RPCServer) {
+		rpc.transport = transport
+	}
+}
+
+// WithDedup replays cached responses to requests resent with the same
+// idempotency key instead of running their handler again.
+func WithDedup(cache *dedup.Cache) RPCServerOpt {
+	return func(rpc *RPCServer) {
+		rpc.dedup = cache
+	}
+}
+
+// WithDurable consumes the durable notifies stored for this server.
+func WithDurable(stream DurableStream, policy DurablePolicy) RPCServerOpt {
+	return func(rpc *RPCServer) {
+		rpc.SetDurable(stream, policy)
+	}
+}
+
+// WithServerAuthenticator checks the requests and signs the responses.
+func WithServerAuthenticator(auth *Authenticator) RPCServerOpt {
+	return func(rpc *RPCServer) {
+		rpc.auth = auth
+	}
+}
+
 func NewRPCServer(engine concepts.IEngine, connString, subjectName string, opts ...RPCServerOpt) *RPCServer {
 	rpcServer := &RPCServer{
-		id:                     fmt.Sprintf("RPCServer:%s", time.Now().UTC()),
-		engine:                 engine,
-		connString:             connString,
-		connectionTimeout:      time.Duration(6) * time.Second,
-		maxReconnectionRetries: 3,
-		dieChan:                make(chan bool),
-		topic:                  cluster.NewNatsSubject(subjectName, 1024),
+		id:        fmt.Sprintf("RPCServer:%s", time.Now().UTC()),
+		engine:    engine,
+		transport: NewTransport(connString),
+		dieChan:   make(chan bool),
+		topic:     cluster.NewNatsSubject(subjectName, 1024),
 	}
 	rpcServer.closed.Store(false)
+	if realm, kind, _, err := concepts.DecodeAddress(subjectName); err == nil {
+		rpcServer.anySubject = concepts.GenAnyAddress(realm, kind)
+	}
 
 	for _, opt := range opts {
 		opt(rpcServer)
@@ -49,24 +91,25 @@ func NewRPCServer(engine concepts.IEngine, connString, subjectName string, opts
 }
 
 func (rpc *RPCServer) Init() error {
-	conn, err := cluster.SetupNatsConn(
-		rpc.id,
-		rpc.connString,
-		rpc.dieChan,
-		nats.MaxReconnects(rpc.maxReconnectionRetries),
-		nats.Timeout(rpc.connectionTimeout),
-	)
+	err := rpc.transport.Connect(rpc.id, rpc.dieChan, nil)
 	if err != nil {
 		return err
 	}
-	rpc.conn = conn
 
-	rpc.topic.Subscription, err = rpc.conn.ChanSubscribe(rpc.topic.Subject, rpc.topic.Ch)
+	rpc.topic.Subscription, err = rpc.transport.ChanSubscribe(rpc.topic.Subject, rpc.topic.Ch)
 	if err != nil {
 		fmt.Printf("[remoteProcess] Subscribe fail. [subject = %s, err = %s]", rpc.topic.Subject, err)
 		return err
 	}
 
+	if rpc.durable != nil {
+		subject := rpc.topic.Subject
+		rpc.durableSub, err = rpc.durable.Consume(durableName(subject), DurableSubject(subject), rpc.durablePolicy, rpc.handleDurable)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -84,11 +127,20 @@ func (rpc *RPCServer) Run() {
 			return
 		}
 
-		request, err := msg.RequestUnmarshal(natsMsg.Data)
+		data, err := rpc.open(natsMsg.Subject, natsMsg.Data)
+		if err != nil {
+			logger.Log(logger.WarnLevel, "RPCServer drop request", "subject", natsMsg.Subject, "err", err)
+			return
+		}
+		rpc.tap.Load().Record(rpc.topic.Subject, TapIn, natsMsg.Subject, data)
+		request, err := msg.RequestUnmarshal(data)
 		if err != nil {
 			logger.Log(logger.ErrorLevel, "RPCServer Recv", "err", err)
 			return
 		}
+		if natsMsg.Subject == rpc.anySubject {
+			request.TargetId = concepts.NewActorId(rpc.topic.Subject, request.TargetId.ID)
+		}
 		rpc.HandleRequest(request)
 	}
 
@@ -111,11 +163,264 @@ func (rpc *RPCServer) HandleRequest(req concepts.IMsgReq) error {
 		return errors.New("invalid")
 	}
 	request.RPCServer = rpc
-	rpc.engine.Request(request)
+
+	key := rpc.dedupKey(request)
+	if key == "" {
+		return rpc.engine.Request(request)
+	}
+	if rpc.dedup.HasStore() {
+		// the lookup in the store must not hold up the requests behind it
+		go func() {
+			if err := rpc.handleIdempotent(request, key); err != nil {
+				logger.Log(logger.ErrorLevel, "RPCServer HandleRequest", "key", request.IdempotencyKey, "err", err)
+			}
+		}()
+		return nil
+	}
+	return rpc.handleIdempotent(request, key)
+}
+
+// handleIdempotent runs the request unless the response cached for key, if
+// any, can be replayed.
+func (rpc *RPCServer) handleIdempotent(request *msg.MsgReq, key string) error {
+	// the caller gives up on the duplicate after its timeout
+	record, run := rpc.dedup.Begin(key, request.GetTimeout(), func(record *dedup.Record) {
+		rpc.replay(request, record)
+	})
+	if record != nil {
+		rpc.replay(request, record)
+	}
+	if !run {
+		return nil
+	}
+
+	err := rpc.engine.Request(request)
+	if err != nil {
+		rpc.dedup.Abort(key)
+	}
+	return err
+}
+
+func (rpc *RPCServer) SendResponse(req concepts.IMsgReq, resp concepts.IMsgResp) error {
+	if response, ok := resp.(*msg.MsgResp); ok {
+		// only the last response of a stream is replayed
+		if key := rpc.dedupKey(req); key != "" && !response.HasMore {
+			if response.Retryable {
+				// the resend runs the handler again instead of replaying the failure
+				rpc.dedup.Abort(key)
+			} else {
+				rpc.dedup.Complete(key, &dedup.Record{
+					ErrCode:   response.ErrCode,
+					ErrMsg:    response.ErrMsg,
+					Details:   response.Details,
+					ReplyData: response.ReplyData,
+				})
+			}
+		}
+		if request, ok := req.(*msg.MsgReq); ok {
+			response.Compression = request.AcceptCompression
+		}
+	}
+
+	return rpc.publish(req.GetSender().Address, resp)
+}
+
+// SetTransport replaces the transport before Init, closing the old one.
+func (rpc *RPCServer) SetTransport(transport Transport) {
+	if rpc.transport != transport {
+		rpc.transport.Close()
+	}
+	rpc.transport = transport
+}
+
+// AnySubject is the service level subject shared by the nodes of this kind.
+func (rpc *RPCServer) AnySubject() string {
+	return rpc.anySubject
+}
+
+// JoinAny starts taking a share of the requests sent to the any address of
+// this kind. The subject doubles as the name of the queue group.
+func (rpc *RPCServer) JoinAny() error {
+	rpc.anyMu.Lock()
+	defer rpc.anyMu.Unlock()
+	if rpc.anySub != nil || rpc.anySubject == "" {
+		return nil
+	}
+	sub, err := rpc.transport.QueueSubscribe(rpc.anySubject, rpc.anySubject, rpc.topic.Ch)
+	if err != nil {
+		return err
+	}
+	rpc.anySub = sub
 	return nil
 }
 
-func (rpc *RPCServer) SendResponse(subj string, response concepts.IMsgResp) error {
+// LeaveAny stops taking requests sent to the any address, the ones already
+// received are still handled.
+func (rpc *RPCServer) LeaveAny() {
+	rpc.anyMu.Lock()
+	defer rpc.anyMu.Unlock()
+	if rpc.anySub != nil {
+		rpc.anySub.Unsubscribe()
+		rpc.anySub = nil
+	}
+}
+
+// SetDurable consumes the durable notifies stored for this server, it must
+// be called before Init.
+func (rpc *RPCServer) SetDurable(stream DurableStream, policy DurablePolicy) {
+	rpc.durable = stream
+	rpc.durablePolicy = policy
+}
+
+// handleDurable runs a stored notify, it is acknowledged once its handler
+// returned without error and redelivered with backoff otherwise.
+func (rpc *RPCServer) handleDurable(m DurableMsg) {
+	data, err := rpc.open(m.Subject(), m.Data())
+	if err != nil {
+		logger.Log(logger.WarnLevel, "RPCServer drop durable notify", "subject", m.Subject(), "err", err)
+		m.Term()
+		return
+	}
+	request, err := msg.RequestUnmarshal(data)
+	if err != nil {
+		rpc.deadLetter(m, err)
+		return
+	}
+	request.RPCServer = rpc
+	request.SetAck(func(err error) {
+		rpc.settleDurable(m, err)
+	})
+	if err := rpc.engine.Request(request); err != nil {
+		request.Ack(err)
+	}
+}
+
+func (rpc *RPCServer) settleDurable(m DurableMsg, err error) {
+	if err == nil {
+		if err := m.Ack(); err != nil {
+			logger.Log(logger.WarnLevel, "RPCServer durable ack", "subject", m.Subject(), "err", err)
+		}
+		return
+	}
+
+	delivered := m.NumDelivered()
+	if limit := rpc.durablePolicy.MaxDeliver; limit > 0 && delivered >= uint64(limit) {
+		rpc.deadLetter(m, err)
+		return
+	}
+	delay := rpc.durablePolicy.delay(delivered)
+	logger.Log(logger.WarnLevel, "RPCServer durable notify failed", "subject", m.Subject(), "delivered", delivered, "retryIn", delay, "err", err)
+	if err := m.NakWithDelay(delay); err != nil {
+		logger.Log(logger.WarnLevel, "RPCServer durable nak", "subject", m.Subject(), "err", err)
+	}
+}
+
+// deadLetter moves a notify that can not be handled to the dead-letter
+// subject of the policy.
+func (rpc *RPCServer) deadLetter(m DurableMsg, cause error) {
+	logger.Log(logger.ErrorLevel, "RPCServer durable notify dead-lettered", "subject", m.Subject(), "delivered", m.NumDelivered(), "err", cause)
+	subject := rpc.durablePolicy.DeadLetter
+	if subject == "" {
+		subject = DeadLetterSubject(rpc.topic.Subject)
+	}
+	if err := rpc.durable.Publish(subject, m.Data()); err != nil {
+		logger.Log(logger.ErrorLevel, "RPCServer dead-letter publish", "subject", m.Subject(), "err", err)
+	}
+	if err := m.Term(); err != nil {
+		logger.Log(logger.WarnLevel, "RPCServer durable term", "subject", m.Subject(), "err", err)
+	}
+}
+
+func (rpc *RPCServer) SetDedup(cache *dedup.Cache) {
+	rpc.dedup = cache
+}
+
+// SetAuthenticator checks the requests and signs the responses with auth.
+func (rpc *RPCServer) SetAuthenticator(auth *Authenticator) {
+	rpc.auth = auth
+}
+
+// Authenticator returns the authenticator checking the requests, if any.
+func (rpc *RPCServer) Authenticator() *Authenticator {
+	return rpc.auth
+}
+
+// SetPeerResolver makes the direct TCP transport of the server, if any, find
+// its peers with resolver.
+func (rpc *RPCServer) SetPeerResolver(resolver PeerResolver) {
+	if transport := tcpTransportOf(rpc.transport); transport != nil {
+		transport.SetResolver(resolver)
+	}
+}
+
+// TCPAddr is the address the direct TCP transport of the server listens
+// on, empty when it has none.
+func (rpc *RPCServer) TCPAddr() string {
+	transport := tcpTransportOf(rpc.transport)
+	if transport == nil || transport.Addr() == nil {
+		return ""
+	}
+	return transport.Addr().String()
+}
+
+// SetTap records the requests received and the responses sent, nil stops
+// recording.
+func (rpc *RPCServer) SetTap(tap *Tap) {
+	rpc.tap.Store(tap)
+}
+
+func (rpc *RPCServer) Tap() *Tap {
+	return rpc.tap.Load()
+}
+
+func (rpc *RPCServer) seal(subject string, data []byte) ([]byte, error) {
+	if rpc.auth == nil {
+		return data, nil
+	}
+	return rpc.auth.Seal(subject, data)
+}
+
+func (rpc *RPCServer) open(subject string, data []byte) ([]byte, error) {
+	if rpc.auth == nil {
+		return data, nil
+	}
+	return rpc.auth.Open(subject, data)
+}
+
+func (rpc *RPCServer) dedupKey(req concepts.IMsgReq) string {
+	if rpc.dedup == nil {
+		return ""
+	}
+	request, ok := req.(*msg.MsgReq)
+	if !ok || request.IdempotencyKey == "" || request.OneWay {
+		return ""
+	}
+	return dedup.Key(request.Sender.String(), request.IdempotencyKey)
+}
+
+// replay answers a duplicate request with the response cached for its key,
+// keeping the SeqId of the duplicate so the caller can correlate it. Without
+// a record the first request did not complete while the duplicate waited,
+// which is answered with a retryable error.
+func (rpc *RPCServer) replay(request *msg.MsgReq, record *dedup.Record) {
+	var response *msg.MsgResp
+	if record == nil {
+		response = msg.NewErrorResp(request.SeqId, errs.New(errs.CODE_ServerInternalError, "duplicate request still in flight", errs.WithRetryable(true)), request.Codec)
+	} else {
+		response = msg.NewMsgResp(request.SeqId, record.ErrCode, record.ErrMsg, request.Codec)
+		response.Details = record.Details
+		response.ReplyData = record.ReplyData
+	}
+	response.Remote = true
+	response.Compression = request.AcceptCompression
+
+	err := rpc.publish(request.Sender.Address, response)
+	if err != nil {
+		logger.Log(logger.ErrorLevel, "RPCServer replay", "key", request.IdempotencyKey, "err", err)
+	}
+}
+
+func (rpc *RPCServer) publish(subj string, response concepts.IMsgResp) error {
 	if rpc.closed.Load() {
 		return errors.New("rpc client has closed")
 	}
@@ -124,7 +429,12 @@ func (rpc *RPCServer) SendResponse(subj string, response concepts.IMsgResp) erro
 	if err != nil {
 		return err
 	}
-	return rpc.conn.Publish(subj, data)
+	rpc.tap.Load().Record(rpc.topic.Subject, TapOut, subj, data)
+	data, err = rpc.seal(subj, data)
+	if err != nil {
+		return err
+	}
+	return rpc.transport.Publish(subj, data)
 }
 
 func (rpc *RPCServer) Stop() {
@@ -133,6 +443,18 @@ func (rpc *RPCServer) Stop() {
 	}
 	rpc.closed.Store(true)
 
+	rpc.LeaveAny()
+	if rpc.durableSub != nil {
+		rpc.durableSub.Unsubscribe()
+	}
+	// a stream with a connection of its own, see ConnectJetStream
+	if closer, ok := rpc.durable.(interface{ Close() }); ok {
+		closer.Close()
+	}
 	rpc.topic.Stop()
-	rpc.conn.Close()
+	rpc.transport.Close()
+}
+
+func (rpc *RPCServer) HealthCheck(ctx context.Context) error {
+	return rpc.transport.Status()
 }
@@ -0,0 +1,194 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/proto/nats_msg"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// DefaultTapPending caps the requests whose response a filtered tap still
+// waits for, the oldest are forgotten past it.
+var DefaultTapPending = 65536
+
+const (
+	TapIn  = "in"
+	TapOut = "out"
+)
+
+// TapRecord is an envelope sent or received by a node. Data is the
+// NATS_MSG_PRXOY before signing, so it replays on nodes without the keys.
+type TapRecord struct {
+	Time      time.Time `json:"time"`
+	Node      string    `json:"node"`
+	Direction string    `json:"direction"`
+	Subject   string    `json:"subject"`
+	Data      []byte    `json:"data"`
+}
+
+// Tap records the envelopes of the rpc client and server it is set on, one
+// JSON object per line. With opcodes only these requests and their
+// responses are recorded.
+type Tap struct {
+	mu      sync.Mutex
+	w       io.Writer
+	enc     *json.Encoder
+	opcodes map[uint32]bool
+	// the responses still to record, by reply subject and seqId
+	pending map[tapKey]time.Time
+}
+
+type tapKey struct {
+	subject string
+	seqId   uint64
+}
+
+type TapOption func(*Tap)
+
+// WithTapOpcodes records only the requests with these opcodes.
+func WithTapOpcodes(opcodes ...uint32) TapOption {
+	return func(t *Tap) {
+		for _, opcode := range opcodes {
+			t.opcodes[opcode] = true
+		}
+	}
+}
+
+func NewTap(w io.Writer, opts ...TapOption) *Tap {
+	t := &Tap{
+		w:       w,
+		enc:     json.NewEncoder(w),
+		opcodes: make(map[uint32]bool),
+		pending: make(map[tapKey]time.Time),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// NewFileTap writes to path, rotated past maxSize megabytes keeping
+// maxBackups old files, the defaults of lumberjack when zero.
+func NewFileTap(path string, maxSize, maxBackups int, opts ...TapOption) *Tap {
+	return NewTap(&lumberjack.Logger{
+		Filename:   path,
+		LocalTime:  true,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}, opts...)
+}
+
+// Record writes the envelope data that node sent to or received on subject.
+// A nil tap records nothing.
+func (t *Tap) Record(node, direction, subject string, data []byte) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.opcodes) > 0 && !t.match(subject, data, now) {
+		return
+	}
+	record := &TapRecord{Time: now, Node: node, Direction: direction, Subject: subject, Data: data}
+	if err := t.enc.Encode(record); err != nil {
+		logger.Log(logger.WarnLevel, "rpc tap write", "subject", subject, "err", err)
+	}
+}
+
+// match reports whether the envelope is a request with one of the opcodes or
+// the response to one.
+func (t *Tap) match(subject string, data []byte, now time.Time) bool {
+	envelope := &nats_msg.NATS_MSG_PRXOY{}
+	if err := proto.Unmarshal(data, envelope); err != nil {
+		return false
+	}
+	if request := envelope.GetRpcRequest(); request != nil {
+		if !t.opcodes[request.GetOpcodes()] {
+			return false
+		}
+		if client := request.GetClient(); client.GetRequiredReply() {
+			t.expect(tapKey{subject: replySubject(client), seqId: client.GetSeqId()}, now)
+		}
+		return true
+	}
+	if response := envelope.GetRpcResponse(); response != nil {
+		key := tapKey{subject: subject, seqId: response.GetClient().GetSeqId()}
+		if _, ok := t.pending[key]; ok {
+			delete(t.pending, key)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tap) expect(key tapKey, now time.Time) {
+	if len(t.pending) >= DefaultTapPending {
+		var oldest tapKey
+		var oldestAt time.Time
+		for k, at := range t.pending {
+			if oldestAt.IsZero() || at.Before(oldestAt) {
+				oldest, oldestAt = k, at
+			}
+		}
+		delete(t.pending, oldest)
+	}
+	t.pending[key] = now
+}
+
+// Close closes the writer of the tap when it is an io.Closer.
+func (t *Tap) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if closer, ok := t.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ReadTap decodes the records written by a tap.
+func ReadTap(r io.Reader) ([]TapRecord, error) {
+	var records []TapRecord
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var record TapRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+// ReadTapFile decodes the records of a file written by NewFileTap.
+func ReadTapFile(path string) ([]TapRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTap(f)
+}
+
+// replySubject is where the responses to the request of client are sent.
+func replySubject(client *rpc_msg.CLIENT_IDENTIFIER) string {
+	if topic := client.GetReplyTopic(); topic != "" {
+		return topic
+	}
+	stub := client.GetStub()
+	return concepts.GenClientAddress(stub.GetRealm(), stub.GetType(), stub.GetId())
+}
@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wuqunyong/file_storage/proto/nats_msg"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+	"google.golang.org/protobuf/proto"
+)
+
+const tapReply = "engine.0.1.1002.client"
+
+func tapRequest(t *testing.T, opcode uint32, seqId uint64) []byte {
+	data, err := proto.Marshal(&nats_msg.NATS_MSG_PRXOY{Msg: &nats_msg.NATS_MSG_PRXOY_RpcRequest{RpcRequest: &rpc_msg.RPC_REQUEST{
+		Client:  &rpc_msg.CLIENT_IDENTIFIER{SeqId: seqId, RequiredReply: true, ReplyTopic: tapReply},
+		Opcodes: opcode,
+	}}})
+	require.NoError(t, err)
+	return data
+}
+
+func tapResponse(t *testing.T, seqId uint64) []byte {
+	data, err := proto.Marshal(&nats_msg.NATS_MSG_PRXOY{Msg: &nats_msg.NATS_MSG_PRXOY_RpcResponse{RpcResponse: &rpc_msg.RPC_RESPONSE{
+		Client: &rpc_msg.CLIENT_IDENTIFIER{SeqId: seqId},
+	}}})
+	require.NoError(t, err)
+	return data
+}
+
+func TestTapOpcodes(t *testing.T) {
+	var buf bytes.Buffer
+	tap := NewTap(&buf, WithTapOpcodes(1))
+
+	tap.Record(authSubject, TapIn, authSubject, tapRequest(t, 1, 10))
+	tap.Record(authSubject, TapIn, authSubject, tapRequest(t, 2, 11))
+	tap.Record(authSubject, TapOut, tapReply, tapResponse(t, 11))
+	tap.Record(authSubject, TapOut, tapReply, tapResponse(t, 10))
+	// recorded once, the request is no longer pending
+	tap.Record(authSubject, TapOut, tapReply, tapResponse(t, 10))
+
+	records, err := ReadTap(&buf)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, TapIn, records[0].Direction)
+	assert.Equal(t, tapRequest(t, 1, 10), records[0].Data)
+	assert.Equal(t, TapOut, records[1].Direction)
+	assert.Equal(t, tapReply, records[1].Subject)
+	assert.Equal(t, tapResponse(t, 10), records[1].Data)
+}
+
+func TestTapAll(t *testing.T) {
+	var buf bytes.Buffer
+	tap := NewTap(&buf)
+	tap.Record(authSubject, TapIn, authSubject, []byte("not an envelope"))
+	tap.Record(authSubject, TapOut, tapReply, tapResponse(t, 1))
+
+	records, err := ReadTap(&buf)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	// a nil tap records nothing
+	var none *Tap
+	none.Record(authSubject, TapIn, authSubject, nil)
+	assert.NoError(t, none.Close())
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Transport carries encoded NATS_MSG_PRXOY frames between engines. Frames are
+// delivered as *nats.Msg whatever the transport, only Subject, Reply and
+// Data are filled in.
+type Transport interface {
+	Connect(id string, dieChan chan bool, onDisconnect func(err error)) error
+	ChanSubscribe(subject string, ch chan *nats.Msg) (Subscription, error)
+	// QueueSubscribe shares subject with the other members of queue, every
+	// frame goes to only one of them.
+	QueueSubscribe(subject, queue string, ch chan *nats.Msg) (Subscription, error)
+	Publish(subject string, data []byte) error
+	PublishRequest(subject, reply string, data []byte) error
+	// Status returns nil while frames can be sent and received.
+	Status() error
+	Close()
+}
+
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// TransportFactory creates a transport for a connection string.
+type TransportFactory func(connString string) Transport
+
+var (
+	transportMu sync.RWMutex
+	transports  = map[string]TransportFactory{
+		LoopbackScheme: func(connString string) Transport {
+			return NewLoopbackTransport(connString)
+		},
+	}
+)
+
+// RegisterTransport makes connection strings starting with scheme:// use
+// factory. Connection strings with an unknown scheme go to NATS.
+func RegisterTransport(scheme string, factory TransportFactory) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	transports[scheme] = factory
+}
+
+// NewTransport creates the transport matching the scheme of connString,
+// splitting the frames larger than its payload limit into chunks.
+func NewTransport(connString string) Transport {
+	if u, err := url.Parse(connString); err == nil {
+		transportMu.RLock()
+		factory, ok := transports[u.Scheme]
+		transportMu.RUnlock()
+		if ok {
+			return NewChunkingTransport(factory(connString))
+		}
+	}
+	return NewChunkingTransport(NewNatsTransport(connString))
+}
@@ -0,0 +1,319 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+	"github.com/wuqunyong/file_storage/proto/nats_msg"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// DefaultChunkSize leaves room for the chunk header below the 1MB
+	// default max payload of NATS.
+	DefaultChunkSize = 512 * 1024
+	// DefaultMaxMessageSize is the largest envelope sent or reassembled.
+	DefaultMaxMessageSize = 64 * 1024 * 1024
+	// DefaultMaxReassemblyBytes caps the chunks buffered by one subscription.
+	DefaultMaxReassemblyBytes = 256 * 1024 * 1024
+	// DefaultReassemblyTimeout drops the transfers not completed in time.
+	DefaultReassemblyTimeout = 30 * time.Second
+
+	errMessageTooLarge = errors.New("rpc message exceeds the max message size")
+	// every frame to a queue subject goes to a member of its own choosing,
+	// the chunks of one message would be spread over the group
+	errQueueChunking = errors.New("rpc message over the chunk size can not be sent to a queue subject")
+)
+
+// chunkField is the NATS_MSG_PRXOY field number of a chunk, the first tag
+// of every chunk frame.
+const chunkField = 104
+
+// chunkHeader is the room kept for the chunk header of a frame.
+const chunkHeader = 64
+
+// ChunkingTransport splits envelopes larger than the chunk size into
+// ordered RPC_CHUNK frames and reassembles them on the subscriptions, so
+// the rpc client and server never see the payload limit of the transport.
+// Queue group subjects only take envelopes that fit in one frame.
+type ChunkingTransport struct {
+	Transport
+
+	chunkSize      int
+	maxMessageSize int
+	maxBuffered    int
+	timeout        time.Duration
+}
+
+type ChunkOption func(*ChunkingTransport)
+
+func WithChunkSize(size int) ChunkOption {
+	return func(t *ChunkingTransport) {
+		t.chunkSize = size
+	}
+}
+
+func WithMaxMessageSize(size int) ChunkOption {
+	return func(t *ChunkingTransport) {
+		t.maxMessageSize = size
+	}
+}
+
+// WithReassembly sets the bytes a subscription may buffer for incomplete
+// messages and how long it waits for their missing chunks.
+func WithReassembly(maxBuffered int, timeout time.Duration) ChunkOption {
+	return func(t *ChunkingTransport) {
+		t.maxBuffered = maxBuffered
+		t.timeout = timeout
+	}
+}
+
+func NewChunkingTransport(inner Transport, opts ...ChunkOption) *ChunkingTransport {
+	t := &ChunkingTransport{
+		Transport:      inner,
+		chunkSize:      DefaultChunkSize,
+		maxMessageSize: DefaultMaxMessageSize,
+		maxBuffered:    DefaultMaxReassemblyBytes,
+		timeout:        DefaultReassemblyTimeout,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Unwrap returns the transport the frames go through.
+func (t *ChunkingTransport) Unwrap() Transport {
+	return t.Transport
+}
+
+// payloadLimit is the chunk size, lowered to what the server accepts when
+// the transport knows it.
+func (t *ChunkingTransport) payloadLimit() int {
+	limit := t.chunkSize
+	if limited, ok := t.Transport.(interface{ MaxPayload() int64 }); ok {
+		if allowed := int(limited.MaxPayload()) - chunkHeader; allowed > 0 && allowed < limit {
+			limit = allowed
+		}
+	}
+	return limit
+}
+
+func (t *ChunkingTransport) Publish(subject string, data []byte) error {
+	return t.PublishRequest(subject, "", data)
+}
+
+func (t *ChunkingTransport) PublishRequest(subject, reply string, data []byte) error {
+	limit := t.payloadLimit()
+	if len(data) <= limit {
+		return t.Transport.PublishRequest(subject, reply, data)
+	}
+	if len(data) > t.maxMessageSize {
+		return fmt.Errorf("%w: %d bytes", errMessageTooLarge, len(data))
+	}
+	if _, _, err := concepts.DecodeAnyAddress(subject); err == nil {
+		return fmt.Errorf("%w: %d bytes to %s, address a node instead", errQueueChunking, len(data), subject)
+	}
+
+	total := (len(data) + limit - 1) / limit
+	transferId := rand.Uint64()
+	for index := 0; index < total; index++ {
+		end := min((index+1)*limit, len(data))
+		frame, err := proto.Marshal(&nats_msg.NATS_MSG_PRXOY{
+			Msg: &nats_msg.NATS_MSG_PRXOY_RpcChunk{
+				RpcChunk: &rpc_msg.RPC_CHUNK{
+					TransferId: transferId,
+					Index:      uint32(index),
+					Total:      uint32(total),
+					TotalSize:  uint32(len(data)),
+					Data:       data[index*limit : end],
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if err := t.Transport.PublishRequest(subject, reply, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *ChunkingTransport) ChanSubscribe(subject string, ch chan *nats.Msg) (Subscription, error) {
+	return t.subscribe(ch, func(raw chan *nats.Msg) (Subscription, error) {
+		return t.Transport.ChanSubscribe(subject, raw)
+	})
+}
+
+func (t *ChunkingTransport) QueueSubscribe(subject, queue string, ch chan *nats.Msg) (Subscription, error) {
+	return t.subscribe(ch, func(raw chan *nats.Msg) (Subscription, error) {
+		return t.Transport.QueueSubscribe(subject, queue, raw)
+	})
+}
+
+// subscribe puts a reassembler between the transport and ch.
+func (t *ChunkingTransport) subscribe(ch chan *nats.Msg, subscribe func(raw chan *nats.Msg) (Subscription, error)) (Subscription, error) {
+	raw := make(chan *nats.Msg, cap(ch))
+	sub, err := subscribe(raw)
+	if err != nil {
+		return nil, err
+	}
+	r := &reassembler{
+		transport: t,
+		sub:       sub,
+		raw:       raw,
+		out:       ch,
+		done:      make(chan struct{}),
+		exited:    make(chan struct{}),
+		transfers: make(map[uint64]*transfer),
+		dropped:   make(map[uint64]time.Time),
+	}
+	go r.run()
+	return r, nil
+}
+
+type transfer struct {
+	total    uint32
+	size     uint32
+	parts    [][]byte
+	received uint32
+	bytes    int
+	deadline time.Time
+}
+
+type reassembler struct {
+	transport *ChunkingTransport
+	sub       Subscription
+	raw       chan *nats.Msg
+	out       chan *nats.Msg
+	done      chan struct{}
+	exited    chan struct{}
+	once      sync.Once
+
+	transfers map[uint64]*transfer
+	buffered  int
+	// the chunks of dropped transfers still arriving are ignored
+	dropped map[uint64]time.Time
+}
+
+// Unsubscribe returns once nothing is sent to the subscriber channel any
+// more, so that the caller may close it.
+func (r *reassembler) Unsubscribe() error {
+	var err error
+	r.once.Do(func() {
+		err = r.sub.Unsubscribe()
+		close(r.done)
+		<-r.exited
+	})
+	return err
+}
+
+func (r *reassembler) run() {
+	defer close(r.exited)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case m := <-r.raw:
+			if m = r.handle(m); m != nil {
+				select {
+				case r.out <- m:
+				case <-r.done:
+					return
+				}
+			}
+		case now := <-ticker.C:
+			r.expire(now)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// handle passes plain frames through and returns the whole message once
+// its last chunk arrived.
+func (r *reassembler) handle(m *nats.Msg) *nats.Msg {
+	if num, _, n := protowire.ConsumeTag(m.Data); n <= 0 || num != chunkField {
+		return m
+	}
+	envelope := &nats_msg.NATS_MSG_PRXOY{}
+	if err := proto.Unmarshal(m.Data, envelope); err != nil || envelope.GetRpcChunk() == nil {
+		logger.Log(logger.WarnLevel, "drop invalid chunk", "subject", m.Subject, "err", err)
+		return nil
+	}
+	chunk := envelope.GetRpcChunk()
+	if _, ok := r.dropped[chunk.TransferId]; ok {
+		return nil
+	}
+
+	current, ok := r.transfers[chunk.TransferId]
+	if !ok {
+		if chunk.Total == 0 || chunk.Total > chunk.TotalSize || int(chunk.TotalSize) > r.transport.maxMessageSize {
+			logger.Log(logger.WarnLevel, "drop oversize chunked message", "subject", m.Subject, "size", chunk.TotalSize)
+			r.dropped[chunk.TransferId] = time.Now().Add(r.transport.timeout)
+			return nil
+		}
+		current = &transfer{
+			total:    chunk.Total,
+			size:     chunk.TotalSize,
+			parts:    make([][]byte, chunk.Total),
+			deadline: time.Now().Add(r.transport.timeout),
+		}
+		r.transfers[chunk.TransferId] = current
+	}
+	if chunk.Index >= current.total || chunk.Total != current.total || current.parts[chunk.Index] != nil {
+		logger.Log(logger.WarnLevel, "drop inconsistent chunk", "subject", m.Subject, "transfer", chunk.TransferId, "index", chunk.Index)
+		return nil
+	}
+	if current.bytes+len(chunk.Data) > int(current.size) || r.buffered+len(chunk.Data) > r.transport.maxBuffered {
+		logger.Log(logger.WarnLevel, "drop chunked message over memory cap", "subject", m.Subject, "transfer", chunk.TransferId)
+		r.drop(chunk.TransferId)
+		r.dropped[chunk.TransferId] = current.deadline
+		return nil
+	}
+
+	current.parts[chunk.Index] = chunk.Data
+	current.received++
+	current.bytes += len(chunk.Data)
+	r.buffered += len(chunk.Data)
+	if current.received < current.total {
+		return nil
+	}
+
+	r.drop(chunk.TransferId)
+	data := make([]byte, 0, current.size)
+	for _, part := range current.parts {
+		data = append(data, part...)
+	}
+	return &nats.Msg{Subject: m.Subject, Reply: m.Reply, Data: data}
+}
+
+func (r *reassembler) drop(transferId uint64) {
+	if current, ok := r.transfers[transferId]; ok {
+		r.buffered -= current.bytes
+		delete(r.transfers, transferId)
+	}
+}
+
+func (r *reassembler) expire(now time.Time) {
+	for transferId, current := range r.transfers {
+		if now.After(current.deadline) {
+			logger.Log(logger.WarnLevel, "drop incomplete chunked message", "transfer", transferId, "received", current.received, "total", current.total)
+			r.drop(transferId)
+		}
+	}
+	for transferId, deadline := range r.dropped {
+		if now.After(deadline) {
+			delete(r.dropped, transferId)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newChunkingPair(t *testing.T, opts ...ChunkOption) (*ChunkingTransport, chan *nats.Msg) {
+	bus := NewLoopbackBus()
+	transport := NewChunkingTransport(NewLoopbackTransportWithBus(bus), opts...)
+	ch := make(chan *nats.Msg, 16)
+	sub, err := transport.ChanSubscribe("engine.0.1.1001.server", ch)
+	require.NoError(t, err)
+	t.Cleanup(func() { sub.Unsubscribe() })
+	return transport, ch
+}
+
+func receive(t *testing.T, ch chan *nats.Msg) *nats.Msg {
+	select {
+	case m := <-ch:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("no message")
+		return nil
+	}
+}
+
+func TestChunkingReassembles(t *testing.T) {
+	transport, ch := newChunkingPair(t, WithChunkSize(1000))
+
+	data := bytes.Repeat([]byte("0123456789"), 1050)
+	require.NoError(t, transport.PublishRequest("engine.0.1.1001.server", "engine.0.1.1002.client", data))
+	m := receive(t, ch)
+	assert.Equal(t, data, m.Data)
+	assert.Equal(t, "engine.0.1.1002.client", m.Reply)
+
+	require.NoError(t, transport.Publish("engine.0.1.1001.server", []byte("small")))
+	assert.Equal(t, []byte("small"), receive(t, ch).Data)
+}
+
+func TestChunkedRequestReply(t *testing.T) {
+	chunkSize := DefaultChunkSize
+	DefaultChunkSize = 1024
+	t.Cleanup(func() { DefaultChunkSize = chunkSize })
+
+	connString := "loopback://TestChunkedRequestReply"
+	server := NewTransport(connString)
+	requests := make(chan *nats.Msg, 64)
+	sub, err := server.ChanSubscribe("engine.0.1.1001.server", requests)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	client := NewTransport(connString)
+	replies := make(chan *nats.Msg, 64)
+	sub, err = client.ChanSubscribe("engine.0.1.1002.client", replies)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// both the request and the response span many chunks
+	request := bytes.Repeat([]byte("manifest;"), 4096)
+	require.NoError(t, client.PublishRequest("engine.0.1.1001.server", "engine.0.1.1002.client", request))
+	m := receive(t, requests)
+	require.Equal(t, request, m.Data)
+
+	response := append(m.Data, " | response"...)
+	require.NoError(t, server.Publish(m.Reply, response))
+	assert.Equal(t, response, receive(t, replies).Data)
+}
+
+func TestChunkingLimits(t *testing.T) {
+	transport, ch := newChunkingPair(t, WithChunkSize(100), WithMaxMessageSize(1000), WithReassembly(500, 50*time.Millisecond))
+
+	assert.ErrorIs(t, transport.Publish("engine.0.1.1001.server", make([]byte, 1001)), errMessageTooLarge)
+
+	// more than the subscription may buffer, the transfer is dropped
+	sender := NewChunkingTransport(NewLoopbackTransportWithBus(transport.Unwrap().(*LoopbackTransport).bus), WithChunkSize(100))
+	require.NoError(t, sender.Publish("engine.0.1.1001.server", make([]byte, 900)))
+
+	require.NoError(t, transport.Publish("engine.0.1.1001.server", bytes.Repeat([]byte{1}, 300)))
+	assert.Equal(t, bytes.Repeat([]byte{1}, 300), receive(t, ch).Data)
+	select {
+	case m := <-ch:
+		t.Fatalf("unexpected message of %d bytes", len(m.Data))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestChunkingUnsubscribe(t *testing.T) {
+	transport := NewChunkingTransport(NewLoopbackTransport("loopback://TestChunkingUnsubscribe"))
+	ch := make(chan *nats.Msg, 1)
+	sub, err := transport.ChanSubscribe("engine.0.1.1001.server", ch)
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, transport.Publish("engine.0.1.1001.server", []byte("small")))
+	}
+
+	// the subscriber may close its channel right after unsubscribing
+	require.NoError(t, sub.Unsubscribe())
+	close(ch)
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestChunkingQueueSubject(t *testing.T) {
+	transport := NewChunkingTransport(NewLoopbackTransport("loopback://TestChunkingQueueSubject"), WithChunkSize(100))
+	subject := "engine.0.1.any"
+	members := []chan *nats.Msg{make(chan *nats.Msg, 64), make(chan *nats.Msg, 64)}
+	for _, ch := range members {
+		sub, err := transport.QueueSubscribe(subject, subject, ch)
+		require.NoError(t, err)
+		t.Cleanup(func() { sub.Unsubscribe() })
+	}
+
+	// each chunk would go to a member of its own
+	assert.ErrorIs(t, transport.Publish(subject, make([]byte, 1000)), errQueueChunking)
+
+	require.NoError(t, transport.Publish(subject, []byte("small")))
+	select {
+	case m := <-members[0]:
+		assert.Equal(t, []byte("small"), m.Data)
+	case m := <-members[1]:
+		assert.Equal(t, []byte("small"), m.Data)
+	case <-time.After(time.Second):
+		t.Fatal("no message")
+	}
+	select {
+	case m := <-members[0]:
+		t.Fatalf("unexpected message of %d bytes", len(m.Data))
+	case m := <-members[1]:
+		t.Fatalf("unexpected message of %d bytes", len(m.Data))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"errors"
+
+	"github.com/nats-io/nats.go"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+)
+
+// KindTransport sends frames for the selected service kinds over a direct
+// TCP transport and everything else over a default one, e.g. NATS. Frames
+// are received from both, and replies go back the way their request came.
+type KindTransport struct {
+	fallback Transport
+	direct   *TCPTransport
+	kinds    map[uint32]bool
+}
+
+func NewKindTransport(fallback Transport, direct *TCPTransport, kinds ...uint32) *KindTransport {
+	t := &KindTransport{
+		fallback: fallback,
+		direct:   direct,
+		kinds:    make(map[uint32]bool),
+	}
+	for _, kind := range kinds {
+		t.kinds[kind] = true
+	}
+	return t
+}
+
+// tcpTransportOf is the TCP transport t sends over directly, nil if none.
+func tcpTransportOf(t Transport) *TCPTransport {
+	switch t := t.(type) {
+	case *TCPTransport:
+		return t
+	case *KindTransport:
+		return t.direct
+	case *ChunkingTransport:
+		return tcpTransportOf(t.Transport)
+	}
+	return nil
+}
+
+func (t *KindTransport) isDirect(subject string) bool {
+	if t.direct.HasRoute(subject) {
+		return true
+	}
+	_, kind, _, err := concepts.DecodeAddress(subject)
+	return err == nil && t.kinds[kind]
+}
+
+func (t *KindTransport) Connect(id string, dieChan chan bool, onDisconnect func(err error)) error {
+	if err := t.fallback.Connect(id, dieChan, onDisconnect); err != nil {
+		return err
+	}
+	return t.direct.Connect(id, dieChan, onDisconnect)
+}
+
+func (t *KindTransport) ChanSubscribe(subject string, ch chan *nats.Msg) (Subscription, error) {
+	fallback, err := t.fallback.ChanSubscribe(subject, ch)
+	if err != nil {
+		return nil, err
+	}
+	direct, err := t.direct.ChanSubscribe(subject, ch)
+	if err != nil {
+		fallback.Unsubscribe()
+		return nil, err
+	}
+	return multiSubscription{fallback, direct}, nil
+}
+
+// QueueSubscribe joins queue on the default transport only, the selected
+// kinds are reached on their exact subjects.
+func (t *KindTransport) QueueSubscribe(subject, queue string, ch chan *nats.Msg) (Subscription, error) {
+	return t.fallback.QueueSubscribe(subject, queue, ch)
+}
+
+func (t *KindTransport) Publish(subject string, data []byte) error {
+	if t.isDirect(subject) {
+		return t.direct.Publish(subject, data)
+	}
+	return t.fallback.Publish(subject, data)
+}
+
+func (t *KindTransport) PublishRequest(subject, reply string, data []byte) error {
+	if t.isDirect(subject) {
+		return t.direct.PublishRequest(subject, reply, data)
+	}
+	return t.fallback.PublishRequest(subject, reply, data)
+}
+
+func (t *KindTransport) Status() error {
+	return errors.Join(t.fallback.Status(), t.direct.Status())
+}
+
+func (t *KindTransport) Close() {
+	t.direct.Close()
+	t.fallback.Close()
+}
+
+type multiSubscription []Subscription
+
+func (s multiSubscription) Unsubscribe() error {
+	var errs []error
+	for _, sub := range s {
+		errs = append(errs, sub.Unsubscribe())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nats-io/nats.go"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+)
+
+// LoopbackScheme selects the in-process transport, e.g. "loopback://test".
+// Engines using the same host name exchange frames without any server.
+const LoopbackScheme = "loopback"
+
+var (
+	loopbackMu    sync.Mutex
+	loopbackBuses = make(map[string]*LoopbackBus)
+)
+
+// LoopbackBus routes frames between the loopback transports sharing it.
+// Like core NATS it drops frames for subscribers that fall behind.
+type LoopbackBus struct {
+	mu   sync.RWMutex
+	subs map[string][]*loopbackSubscription
+}
+
+func NewLoopbackBus() *LoopbackBus {
+	return &LoopbackBus{
+		subs: make(map[string][]*loopbackSubscription),
+	}
+}
+
+// GetLoopbackBus returns the process wide bus registered under name.
+func GetLoopbackBus(name string) *LoopbackBus {
+	loopbackMu.Lock()
+	defer loopbackMu.Unlock()
+	bus, ok := loopbackBuses[name]
+	if !ok {
+		bus = NewLoopbackBus()
+		loopbackBuses[name] = bus
+	}
+	return bus
+}
+
+func (b *LoopbackBus) subscribe(subject, queue string, ch chan *nats.Msg) *loopbackSubscription {
+	sub := &loopbackSubscription{bus: b, subject: subject, queue: queue, ch: ch}
+	b.mu.Lock()
+	b.subs[subject] = append(b.subs[subject], sub)
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *LoopbackBus) unsubscribe(sub *loopbackSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs := b.subs[sub.subject]
+	for i, value := range subs {
+		if value == sub {
+			b.subs[sub.subject] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(b.subs[sub.subject]) == 0 {
+		delete(b.subs, sub.subject)
+	}
+}
+
+func (b *LoopbackBus) publish(subject, reply string, data []byte) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var receivers []*loopbackSubscription
+	queues := make(map[string][]*loopbackSubscription)
+	for _, sub := range b.subs[subject] {
+		if sub.queue == "" {
+			receivers = append(receivers, sub)
+		} else {
+			queues[sub.queue] = append(queues[sub.queue], sub)
+		}
+	}
+	// like NATS, a random member of every queue group gets the frame
+	for _, members := range queues {
+		receivers = append(receivers, members[rand.IntN(len(members))])
+	}
+
+	for _, sub := range receivers {
+		// every subscriber decodes its own copy, as it would off the wire
+		msg := &nats.Msg{Subject: subject, Reply: reply, Data: append([]byte(nil), data...)}
+		select {
+		case sub.ch <- msg:
+		default:
+			logger.Log(logger.ErrorLevel, "loopback slow consumer", "subject", subject)
+		}
+	}
+}
+
+type loopbackSubscription struct {
+	bus     *LoopbackBus
+	subject string
+	queue   string
+	ch      chan *nats.Msg
+	closed  atomic.Bool
+}
+
+func (s *loopbackSubscription) Unsubscribe() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	s.bus.unsubscribe(s)
+	return nil
+}
+
+// LoopbackTransport exchanges frames with the engines of the same process
+// through a LoopbackBus.
+type LoopbackTransport struct {
+	bus    *LoopbackBus
+	closed atomic.Bool
+}
+
+// NewLoopbackTransport attaches to the bus named by the host of connString.
+func NewLoopbackTransport(connString string) *LoopbackTransport {
+	name := connString
+	if u, err := url.Parse(connString); err == nil {
+		name = u.Host
+	}
+	return &LoopbackTransport{bus: GetLoopbackBus(name)}
+}
+
+func NewLoopbackTransportWithBus(bus *LoopbackBus) *LoopbackTransport {
+	return &LoopbackTransport{bus: bus}
+}
+
+func (t *LoopbackTransport) Connect(id string, dieChan chan bool, onDisconnect func(err error)) error {
+	return nil
+}
+
+func (t *LoopbackTransport) ChanSubscribe(subject string, ch chan *nats.Msg) (Subscription, error) {
+	if t.closed.Load() {
+		return nil, errLoopbackClosed
+	}
+	return t.bus.subscribe(subject, "", ch), nil
+}
+
+func (t *LoopbackTransport) QueueSubscribe(subject, queue string, ch chan *nats.Msg) (Subscription, error) {
+	if t.closed.Load() {
+		return nil, errLoopbackClosed
+	}
+	return t.bus.subscribe(subject, queue, ch), nil
+}
+
+func (t *LoopbackTransport) Publish(subject string, data []byte) error {
+	return t.PublishRequest(subject, "", data)
+}
+
+func (t *LoopbackTransport) PublishRequest(subject, reply string, data []byte) error {
+	if t.closed.Load() {
+		return errLoopbackClosed
+	}
+	t.bus.publish(subject, reply, data)
+	return nil
+}
+
+func (t *LoopbackTransport) Status() error {
+	if t.closed.Load() {
+		return errLoopbackClosed
+	}
+	return nil
+}
+
+func (t *LoopbackTransport) Close() {
+	t.closed.Store(true)
+}
+
+var errLoopbackClosed = errors.New("loopback transport closed")
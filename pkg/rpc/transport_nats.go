@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/wuqunyong/file_storage/pkg/cluster"
+)
+
+// NatsTransport relays frames through a NATS server.
+type NatsTransport struct {
+	connString string
+	options    []nats.Option
+	conn       *nats.Conn
+}
+
+func NewNatsTransport(connString string, options ...nats.Option) *NatsTransport {
+	defaults := []nats.Option{
+		nats.MaxReconnects(3),
+		nats.Timeout(time.Duration(6) * time.Second),
+	}
+	return &NatsTransport{
+		connString: connString,
+		options:    append(defaults, options...),
+	}
+}
+
+func (t *NatsTransport) Connect(id string, dieChan chan bool, onDisconnect func(err error)) error {
+	options := t.options
+	if onDisconnect != nil {
+		options = append(options, nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			onDisconnect(err)
+		}))
+	}
+	conn, err := cluster.SetupNatsConn(id, t.connString, dieChan, options...)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	return nil
+}
+
+func (t *NatsTransport) ChanSubscribe(subject string, ch chan *nats.Msg) (Subscription, error) {
+	return t.conn.ChanSubscribe(subject, ch)
+}
+
+func (t *NatsTransport) QueueSubscribe(subject, queue string, ch chan *nats.Msg) (Subscription, error) {
+	return t.conn.ChanQueueSubscribe(subject, queue, ch)
+}
+
+func (t *NatsTransport) Publish(subject string, data []byte) error {
+	return t.conn.Publish(subject, data)
+}
+
+func (t *NatsTransport) PublishRequest(subject, reply string, data []byte) error {
+	return t.conn.PublishRequest(subject, reply, data)
+}
+
+func (t *NatsTransport) Status() error {
+	if t.conn == nil {
+		return errors.New("nats not connected")
+	}
+	if status := t.conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("nats %s", status)
+	}
+	return nil
+}
+
+func (t *NatsTransport) Close() {
+	if t.conn != nil {
+		t.conn.Close()
+	}
+}
+
+// MaxPayload is the largest frame the server accepts, 0 before Connect.
+func (t *NatsTransport) MaxPayload() int64 {
+	if t.conn == nil {
+		return 0
+	}
+	return t.conn.MaxPayload()
+}
+
+// Conn exposes the underlying connection, nil before Connect.
+func (t *NatsTransport) Conn() *nats.Conn {
+	return t.conn
+}
@@ -0,0 +1,653 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/wuqunyong/file_storage/pkg/cluster/discovery/registry"
+	"github.com/wuqunyong/file_storage/pkg/constants"
+	"github.com/wuqunyong/file_storage/pkg/logger"
+)
+
+// TCPScheme selects the direct peer to peer transport. "tcp://host:port"
+// listens on host:port, "tcp://" only dials out and receives its replies on
+// the connections it opened.
+const TCPScheme = "tcp"
+
+const (
+	// MetadataEngine and MetadataTCPAddress are the registry node metadata
+	// keys used to find the TCP endpoint of an engine.
+	MetadataEngine     = "engine"
+	MetadataTCPAddress = "tcp_addr"
+
+	maxFrameSize = 16 << 20
+
+	// tcpHelloSubject is the subject of the first frame on a dialed
+	// connection. Its data lists the inbox subjects of the dialing node, the
+	// only reply subjects the accepting side routes back on the connection.
+	tcpHelloSubject = "_HELLO"
+)
+
+var (
+	DefaultTCPPoolSize     = 2
+	DefaultTCPDialTimeout  = 3 * time.Second
+	DefaultTCPRetryBackoff = 100 * time.Millisecond
+	DefaultTCPMaxBackoff   = 5 * time.Second
+	// DefaultTCPWriteTimeout bounds the write of one frame to a peer that
+	// stopped reading, the request would time out by then anyway.
+	DefaultTCPWriteTimeout = constants.DefaultRPCTimeout
+
+	// DefaultPeerResolver is used by the transports created from a
+	// connection string until SetResolver, e.g. by the discovery component
+	// installing a RegistryResolver.
+	DefaultPeerResolver PeerResolver = StaticResolver{}
+
+	errTCPClosed   = errors.New("tcp transport closed")
+	errFrameTooBig = errors.New("tcp frame too big")
+)
+
+func init() {
+	RegisterTransport(TCPScheme, func(connString string) Transport {
+		return acquireTCPTransport(connString)
+	})
+}
+
+var (
+	tcpMu         sync.Mutex
+	tcpTransports = make(map[string]*TCPTransport)
+)
+
+// acquireTCPTransport shares one listening transport between the client
+// and the server of an engine, it is closed with its last user.
+func acquireTCPTransport(connString string) *TCPTransport {
+	t := NewTCPTransport(connString, DefaultPeerResolver)
+	if t.listenAddr == "" {
+		return t
+	}
+	tcpMu.Lock()
+	defer tcpMu.Unlock()
+	if shared, ok := tcpTransports[t.listenAddr]; ok && !shared.closed.Load() {
+		shared.refs++
+		return shared
+	}
+	t.shared = true
+	tcpTransports[t.listenAddr] = t
+	return t
+}
+
+// PeerResolver finds the TCP address of the engine owning a subject, e.g.
+// engine.0.1.1001 for engine.0.1.1001.server.
+type PeerResolver interface {
+	ResolvePeer(engine string) (string, error)
+}
+
+// StaticResolver maps engine names to TCP addresses.
+type StaticResolver map[string]string
+
+func (r StaticResolver) ResolvePeer(engine string) (string, error) {
+	addr, ok := r[engine]
+	if !ok {
+		return "", fmt.Errorf("no tcp address for %s", engine)
+	}
+	return addr, nil
+}
+
+// RegistryResolver looks engines up in the service registry, matching the
+// MetadataEngine of the nodes and dialing their MetadataTCPAddress.
+type RegistryResolver struct {
+	registry registry.Registry
+	mu       sync.Mutex
+	cache    map[string]string
+}
+
+func NewRegistryResolver(r registry.Registry) *RegistryResolver {
+	return &RegistryResolver{
+		registry: r,
+		cache:    make(map[string]string),
+	}
+}
+
+func (r *RegistryResolver) ResolvePeer(engine string) (string, error) {
+	r.mu.Lock()
+	addr, ok := r.cache[engine]
+	r.mu.Unlock()
+	if ok {
+		return addr, nil
+	}
+
+	services, err := r.registry.ListServices()
+	if err != nil {
+		return "", err
+	}
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			if node.Metadata[MetadataEngine] == engine && node.Metadata[MetadataTCPAddress] != "" {
+				addr = node.Metadata[MetadataTCPAddress]
+				r.mu.Lock()
+				r.cache[engine] = addr
+				r.mu.Unlock()
+				return addr, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no tcp address for %s", engine)
+}
+
+// Forget drops a cached address, e.g. after the peer moved.
+func (r *RegistryResolver) Forget(engine string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, engine)
+}
+
+// TCPTransport sends frames over persistent TCP connections. A frame is a
+// NATS_MSG_PRXOY prefixed with its subject and reply subject, so many
+// requests are multiplexed on one connection and correlated by SeqId.
+type TCPTransport struct {
+	listenAddr   string
+	resolver     PeerResolver
+	poolSize     int
+	writeTimeout time.Duration
+
+	listener net.Listener
+	closed   atomic.Bool
+	shared   bool
+	refs     int
+
+	mu      sync.RWMutex
+	subs    map[string][]*tcpSubscription
+	routes  map[string]*tcpConn
+	pools   map[string]*tcpPool
+	inbound map[*tcpConn]struct{}
+}
+
+func NewTCPTransport(connString string, resolver PeerResolver) *TCPTransport {
+	listenAddr := connString
+	if u, err := url.Parse(connString); err == nil {
+		listenAddr = u.Host
+	}
+	if resolver == nil {
+		resolver = StaticResolver{}
+	}
+	return &TCPTransport{
+		listenAddr:   listenAddr,
+		resolver:     resolver,
+		poolSize:     DefaultTCPPoolSize,
+		writeTimeout: DefaultTCPWriteTimeout,
+		refs:         1,
+		subs:         make(map[string][]*tcpSubscription),
+		routes:       make(map[string]*tcpConn),
+		pools:        make(map[string]*tcpPool),
+		inbound:      make(map[*tcpConn]struct{}),
+	}
+}
+
+// SetPoolSize sets the number of connections kept to every peer.
+func (t *TCPTransport) SetPoolSize(size int) {
+	if size > 0 {
+		t.poolSize = size
+	}
+}
+
+// SetWriteTimeout bounds the write of one frame, the connection is closed
+// when a write does not complete in time.
+func (t *TCPTransport) SetWriteTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		t.writeTimeout = timeout
+	}
+}
+
+// SetResolver replaces the resolver finding the address of the peers.
+func (t *TCPTransport) SetResolver(resolver PeerResolver) {
+	if resolver == nil {
+		resolver = StaticResolver{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resolver = resolver
+}
+
+// Addr is the address the transport listens on, nil for a dial only one.
+func (t *TCPTransport) Addr() net.Addr {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.listener == nil {
+		return nil
+	}
+	return t.listener.Addr()
+}
+
+func (t *TCPTransport) Connect(id string, dieChan chan bool, onDisconnect func(err error)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.listenAddr == "" || t.listener != nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", t.listenAddr)
+	if err != nil {
+		return err
+	}
+	t.listener = listener
+	go t.accept(listener)
+	return nil
+}
+
+func (t *TCPTransport) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !t.closed.Load() {
+				logger.Log(logger.ErrorLevel, "tcp transport accept", "err", err)
+			}
+			return
+		}
+		c := newTCPConn(conn)
+		c.inboxes = make(map[string]bool)
+		t.mu.Lock()
+		t.inbound[c] = struct{}{}
+		t.mu.Unlock()
+		go t.read(c)
+	}
+}
+
+func (t *TCPTransport) ChanSubscribe(subject string, ch chan *nats.Msg) (Subscription, error) {
+	if t.closed.Load() {
+		return nil, errTCPClosed
+	}
+	sub := &tcpSubscription{transport: t, subject: subject, ch: ch}
+	t.mu.Lock()
+	t.subs[subject] = append(t.subs[subject], sub)
+	t.mu.Unlock()
+	return sub, nil
+}
+
+// QueueSubscribe is a plain subscription, frames are only ever sent to the
+// peer they are addressed to.
+func (t *TCPTransport) QueueSubscribe(subject, queue string, ch chan *nats.Msg) (Subscription, error) {
+	return t.ChanSubscribe(subject, ch)
+}
+
+func (t *TCPTransport) Publish(subject string, data []byte) error {
+	return t.PublishRequest(subject, "", data)
+}
+
+// PublishRequest prefers the connection subject was announced as an inbox
+// on, and otherwise dials the engine owning subject.
+func (t *TCPTransport) PublishRequest(subject, reply string, data []byte) error {
+	if t.closed.Load() {
+		return errTCPClosed
+	}
+	frame, err := encodeFrame(subject, reply, data)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		conn, err := t.connFor(subject)
+		if err != nil {
+			return err
+		}
+		if err = conn.write(frame, t.writeTimeout); err == nil {
+			return nil
+		}
+		logger.Log(logger.WarnLevel, "tcp transport write", "subject", subject, "err", err)
+		t.drop(conn)
+		// a peer that stopped reading would block the next connection too
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("tcp transport: publish %s: %w", subject, err)
+		}
+	}
+	return fmt.Errorf("tcp transport: publish %s failed", subject)
+}
+
+// HasRoute reports whether subject can be answered on an open connection.
+func (t *TCPTransport) HasRoute(subject string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	conn, ok := t.routes[subject]
+	return ok && !conn.closed.Load()
+}
+
+func (t *TCPTransport) connFor(subject string) (*tcpConn, error) {
+	t.mu.RLock()
+	conn, ok := t.routes[subject]
+	resolver := t.resolver
+	t.mu.RUnlock()
+	if ok && !conn.closed.Load() {
+		return conn, nil
+	}
+
+	engine := engineOf(subject)
+	addr, err := resolver.ResolvePeer(engine)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	pool, ok := t.pools[addr]
+	if !ok {
+		pool = &tcpPool{addr: addr, conns: make([]*tcpConn, t.poolSize)}
+		t.pools[addr] = pool
+	}
+	t.mu.Unlock()
+	conn, err = pool.get(t)
+	if err != nil {
+		// the peer may have moved, resolve it again next time
+		if forgetter, ok := resolver.(interface{ Forget(string) }); ok {
+			forgetter.Forget(engine)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (t *TCPTransport) read(c *tcpConn) {
+	defer t.drop(c)
+	reader := bufio.NewReader(c.conn)
+	greeted := c.inboxes == nil
+	for {
+		subject, reply, data, err := decodeFrame(reader)
+		if err != nil {
+			if err != io.EOF && !t.closed.Load() && !c.closed.Load() {
+				logger.Log(logger.WarnLevel, "tcp transport read", "remote", c.conn.RemoteAddr(), "err", err)
+			}
+			return
+		}
+		if !greeted {
+			if subject != tcpHelloSubject {
+				logger.Log(logger.WarnLevel, "tcp transport handshake", "remote", c.conn.RemoteAddr(), "subject", subject)
+				return
+			}
+			for _, inbox := range strings.Split(string(data), "\n") {
+				if isInbox(inbox) {
+					c.inboxes[inbox] = true
+				}
+			}
+			greeted = true
+			continue
+		}
+		if subject == tcpHelloSubject {
+			continue
+		}
+		t.deliver(c, subject, reply, data)
+	}
+}
+
+// hello is the handshake frame announcing the inboxes subscribed here.
+func (t *TCPTransport) hello() ([]byte, error) {
+	t.mu.RLock()
+	var inboxes []string
+	for subject := range t.subs {
+		if isInbox(subject) {
+			inboxes = append(inboxes, subject)
+		}
+	}
+	t.mu.RUnlock()
+	return encodeFrame(tcpHelloSubject, "", []byte(strings.Join(inboxes, "\n")))
+}
+
+// isInbox reports whether subject is the reply subject of a node, e.g.
+// engine.0.1.1002.client.
+func isInbox(subject string) bool {
+	return strings.HasSuffix(subject, ".client")
+}
+
+func (t *TCPTransport) deliver(c *tcpConn, subject, reply string, data []byte) {
+	// only the inboxes the peer announced are routed back to it, and a
+	// live route is never taken over by another connection
+	if c.inboxes[reply] {
+		t.mu.Lock()
+		if route, ok := t.routes[reply]; !ok || route.closed.Load() {
+			t.routes[reply] = c
+		}
+		t.mu.Unlock()
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, sub := range t.subs[subject] {
+		msg := &nats.Msg{Subject: subject, Reply: reply, Data: data}
+		select {
+		case sub.ch <- msg:
+		default:
+			logger.Log(logger.ErrorLevel, "tcp transport slow consumer", "subject", subject)
+		}
+	}
+}
+
+func (t *TCPTransport) drop(c *tcpConn) {
+	c.close()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.inbound, c)
+	for subject, conn := range t.routes {
+		if conn == c {
+			delete(t.routes, subject)
+		}
+	}
+}
+
+func (t *TCPTransport) Status() error {
+	if t.closed.Load() {
+		return errTCPClosed
+	}
+	return nil
+}
+
+func (t *TCPTransport) Close() {
+	if t.shared {
+		tcpMu.Lock()
+		t.refs--
+		last := t.refs == 0
+		if last && tcpTransports[t.listenAddr] == t {
+			delete(tcpTransports, t.listenAddr)
+		}
+		tcpMu.Unlock()
+		if !last {
+			return
+		}
+	}
+	if t.closed.Swap(true) {
+		return
+	}
+	t.mu.Lock()
+	if t.listener != nil {
+		t.listener.Close()
+	}
+	var conns []*tcpConn
+	for c := range t.inbound {
+		conns = append(conns, c)
+	}
+	for _, pool := range t.pools {
+		pool.mu.Lock()
+		for _, c := range pool.conns {
+			if c != nil {
+				conns = append(conns, c)
+			}
+		}
+		pool.mu.Unlock()
+	}
+	t.mu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+type tcpSubscription struct {
+	transport *TCPTransport
+	subject   string
+	ch        chan *nats.Msg
+}
+
+func (s *tcpSubscription) Unsubscribe() error {
+	t := s.transport
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	subs := t.subs[s.subject]
+	for i, value := range subs {
+		if value == s {
+			t.subs[s.subject] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+type tcpConn struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	closed  atomic.Bool
+	// inboxes the peer announced on an accepted connection, nil on a
+	// dialed one
+	inboxes map[string]bool
+}
+
+func newTCPConn(conn net.Conn) *tcpConn {
+	return &tcpConn{conn: conn}
+}
+
+// write sends frame within timeout. A frame written in part leaves the
+// stream unusable, the caller closes the connection on any error.
+func (c *tcpConn) write(frame []byte, timeout time.Duration) error {
+	if c.closed.Load() {
+		return errTCPClosed
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func (c *tcpConn) close() {
+	if !c.closed.Swap(true) {
+		c.conn.Close()
+	}
+}
+
+// tcpPool keeps a fixed number of connections to one peer, redialing
+// broken ones on demand with a growing backoff.
+type tcpPool struct {
+	addr    string
+	mu      sync.Mutex
+	conns   []*tcpConn
+	next    int
+	backoff time.Duration
+	retryAt time.Time
+}
+
+func (p *tcpPool) get(t *TCPTransport) (*tcpConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	index := p.next % len(p.conns)
+	p.next++
+	if c := p.conns[index]; c != nil && !c.closed.Load() {
+		return c, nil
+	}
+
+	if time.Now().Before(p.retryAt) {
+		// another live connection is better than waiting for the redial
+		for _, c := range p.conns {
+			if c != nil && !c.closed.Load() {
+				return c, nil
+			}
+		}
+		return nil, fmt.Errorf("tcp peer %s unavailable", p.addr)
+	}
+
+	conn, err := net.DialTimeout("tcp", p.addr, DefaultTCPDialTimeout)
+	if err != nil {
+		if p.backoff == 0 {
+			p.backoff = DefaultTCPRetryBackoff
+		} else {
+			p.backoff = min(p.backoff*2, DefaultTCPMaxBackoff)
+		}
+		p.retryAt = time.Now().Add(p.backoff)
+		return nil, err
+	}
+	p.backoff = 0
+	p.retryAt = time.Time{}
+
+	c := newTCPConn(conn)
+	hello, err := t.hello()
+	if err == nil {
+		err = c.write(hello, t.writeTimeout)
+	}
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	p.conns[index] = c
+	go t.read(c)
+	return c, nil
+}
+
+// engineOf strips the role of a subject, engine.0.1.1001.server becomes
+// engine.0.1.1001.
+func engineOf(subject string) string {
+	if i := strings.LastIndexByte(subject, '.'); i > 0 {
+		return subject[:i]
+	}
+	return subject
+}
+
+func encodeFrame(subject, reply string, data []byte) ([]byte, error) {
+	size := 2 + len(subject) + 2 + len(reply) + len(data)
+	if size > maxFrameSize || len(subject) > 0xffff || len(reply) > 0xffff {
+		return nil, errFrameTooBig
+	}
+	frame := make([]byte, 0, 4+size)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(size))
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(subject)))
+	frame = append(frame, subject...)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(reply)))
+	frame = append(frame, reply...)
+	frame = append(frame, data...)
+	return frame, nil
+}
+
+func decodeFrame(reader io.Reader) (subject, reply string, data []byte, err error) {
+	var header [4]byte
+	if _, err = io.ReadFull(reader, header[:]); err != nil {
+		return
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize || size < 4 {
+		err = errFrameTooBig
+		return
+	}
+	body := make([]byte, size)
+	if _, err = io.ReadFull(reader, body); err != nil {
+		return
+	}
+
+	subjectLen := int(binary.BigEndian.Uint16(body))
+	if 2+subjectLen+2 > len(body) {
+		err = io.ErrUnexpectedEOF
+		return
+	}
+	subject = string(body[2 : 2+subjectLen])
+	body = body[2+subjectLen:]
+	replyLen := int(binary.BigEndian.Uint16(body))
+	if 2+replyLen > len(body) {
+		err = io.ErrUnexpectedEOF
+		return
+	}
+	reply = string(body[2 : 2+replyLen])
+	data = body[2+replyLen:]
+	return
+}
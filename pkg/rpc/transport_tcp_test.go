@@ -0,0 +1,168 @@
+package rpc
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	frame, err := encodeFrame("engine.0.1.1001.server", "engine.0.1.1002.client", []byte("payload"))
+	require.NoError(t, err)
+
+	subject, reply, data, err := decodeFrame(bytes.NewReader(frame))
+	require.NoError(t, err)
+	assert.Equal(t, "engine.0.1.1001.server", subject)
+	assert.Equal(t, "engine.0.1.1002.client", reply)
+	assert.Equal(t, []byte("payload"), data)
+
+	_, err = encodeFrame("s", "", make([]byte, maxFrameSize))
+	assert.ErrorIs(t, err, errFrameTooBig)
+}
+
+func newTCPServer(t *testing.T, addr string) (*TCPTransport, chan *nats.Msg) {
+	server := NewTCPTransport("tcp://"+addr, nil)
+	require.NoError(t, server.Connect("server", nil, nil))
+	ch := make(chan *nats.Msg, 16)
+	_, err := server.ChanSubscribe("engine.0.1.1001.server", ch)
+	require.NoError(t, err)
+	return server, ch
+}
+
+func TestTCPTransportReplyAndRedial(t *testing.T) {
+	server, requests := newTCPServer(t, "127.0.0.1:0")
+	addr := server.Addr().String()
+
+	client := NewTCPTransport("tcp://", StaticResolver{"engine.0.1.1001": addr})
+	require.NoError(t, client.Connect("client", nil, nil))
+	defer client.Close()
+	replies := make(chan *nats.Msg, 16)
+	_, err := client.ChanSubscribe("engine.0.1.1002.client", replies)
+	require.NoError(t, err)
+
+	// the reply goes back on the connection the request came in on
+	require.NoError(t, client.PublishRequest("engine.0.1.1001.server", "engine.0.1.1002.client", []byte("ping")))
+	request := <-requests
+	assert.Equal(t, []byte("ping"), request.Data)
+	assert.True(t, server.HasRoute(request.Reply))
+	require.NoError(t, server.Publish(request.Reply, []byte("pong")))
+	select {
+	case reply := <-replies:
+		assert.Equal(t, []byte("pong"), reply.Data)
+	case <-time.After(time.Second):
+		t.Fatal("reply not delivered")
+	}
+
+	// a restarted peer is dialed again
+	server.Close()
+	assert.Eventually(t, func() bool {
+		return client.Publish("engine.0.1.1001.server", []byte("lost")) != nil
+	}, time.Second, 10*time.Millisecond)
+	server, requests = newTCPServer(t, addr)
+	defer server.Close()
+	assert.Eventually(t, func() bool {
+		return client.Publish("engine.0.1.1001.server", []byte("again")) == nil
+	}, 3*time.Second, 50*time.Millisecond)
+	select {
+	case request := <-requests:
+		assert.Equal(t, []byte("again"), request.Data)
+	case <-time.After(time.Second):
+		t.Fatal("request not delivered after redial")
+	}
+}
+
+func TestTCPTransportWriteTimeout(t *testing.T) {
+	// the peer accepts but never reads
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := NewTCPTransport("tcp://", StaticResolver{"engine.0.1.1001": listener.Addr().String()})
+	client.SetWriteTimeout(100 * time.Millisecond)
+	defer client.Close()
+
+	data := make([]byte, 4<<20)
+	start := time.Now()
+	for err == nil {
+		err = client.Publish("engine.0.1.1001.server", data)
+		require.Less(t, time.Since(start), 5*time.Second)
+	}
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestTCPTransportRoutes(t *testing.T) {
+	server, requests := newTCPServer(t, "127.0.0.1:0")
+	defer server.Close()
+	addr := server.Addr().String()
+	dial := func(inboxes string) net.Conn {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		if inboxes != "" {
+			hello, err := encodeFrame(tcpHelloSubject, "", []byte(inboxes))
+			require.NoError(t, err)
+			_, err = conn.Write(hello)
+			require.NoError(t, err)
+		}
+		return conn
+	}
+	send := func(conn net.Conn, reply string) {
+		frame, err := encodeFrame("engine.0.1.1001.server", reply, []byte("ping"))
+		require.NoError(t, err)
+		_, err = conn.Write(frame)
+		require.NoError(t, err)
+	}
+	receive := func() *nats.Msg {
+		select {
+		case m := <-requests:
+			return m
+		case <-time.After(time.Second):
+			t.Fatal("request not delivered")
+			return nil
+		}
+	}
+
+	// a connection without handshake is dropped
+	send(dial(""), "engine.0.1.1002.client")
+	select {
+	case m := <-requests:
+		t.Fatalf("unexpected request %v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// replies to inboxes the peer did not announce are not routed to it
+	rogue := dial("engine.0.1.1003.client")
+	send(rogue, "engine.0.1.1002.client")
+	receive()
+	assert.False(t, server.HasRoute("engine.0.1.1002.client"))
+
+	owner := dial("engine.0.1.1002.client")
+	send(owner, "engine.0.1.1002.client")
+	receive()
+	assert.True(t, server.HasRoute("engine.0.1.1002.client"))
+
+	// and a live route is not taken over
+	impostor := dial("engine.0.1.1002.client")
+	send(impostor, "engine.0.1.1002.client")
+	receive()
+	require.NoError(t, server.Publish("engine.0.1.1002.client", []byte("pong")))
+	owner.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, data, err := decodeFrame(owner)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("pong"), data)
+}
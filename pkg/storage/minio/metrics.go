@@ -0,0 +1,19 @@
+package minio
+
+import "github.com/wuqunyong/file_storage/pkg/metrics"
+
+var (
+	uploadsTotal = metrics.NewCounterVec("storage_uploads_total",
+		"Uploads by stage (initiated or completed) and result (ok or error).", "stage", "result")
+	uploadBytes = metrics.NewCounterVec("storage_upload_bytes_total",
+		"Bytes of the initiated uploads, as declared by the clients.")
+	downloadsTotal = metrics.NewCounterVec("storage_downloads_total",
+		"Download urls handed out, by result (ok or error).", "result")
+)
+
+func resultOf(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
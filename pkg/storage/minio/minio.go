@@ -84,7 +84,30 @@ func (m *Minio) initMinio(ctx context.Context) error {
 		}
 	}
 	if m.conf.PublicRead {
-		policy := fmt.Sprintf(
+		if err = m.setBucketPolicy(ctx, true); err != nil {
+			return err
+		}
+	}
+	m.location, err = m.client.GetBucketLocation(ctx, m.conf.Bucket)
+	if err != nil {
+		return err
+	}
+
+	m.init = true
+	return nil
+}
+
+// SetPublicRead switches the anonymous read/write bucket policy on or off.
+func (m *Minio) SetPublicRead(ctx context.Context, publicRead bool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.setBucketPolicy(ctx, publicRead)
+}
+
+func (m *Minio) setBucketPolicy(ctx context.Context, publicRead bool) error {
+	policy := ""
+	if publicRead {
+		policy = fmt.Sprintf(
 			`{
 					"Version": "2012-10-17",
 					"Statement": [
@@ -106,26 +129,39 @@ func (m *Minio) initMinio(ctx context.Context) error {
 						}
 					]
 				}`, m.conf.Bucket)
-		if err = m.client.SetBucketPolicy(ctx, m.conf.Bucket, policy); err != nil {
-			return err
-		}
 	}
-	m.location, err = m.client.GetBucketLocation(ctx, m.conf.Bucket)
-	if err != nil {
+	if err := m.client.SetBucketPolicy(ctx, m.conf.Bucket, policy); err != nil {
 		return err
 	}
+	m.conf.PublicRead = publicRead
+	return nil
+}
 
-	m.init = true
+// HealthCheck reports whether the bucket is reachable.
+func (m *Minio) HealthCheck(ctx context.Context) error {
+	exists, err := m.client.BucketExists(ctx, m.conf.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s not found", m.conf.Bucket)
+	}
 	return nil
 }
 
+func (m *Minio) Config() Config {
+	return m.conf
+}
+
 func (m *Minio) InitiateUpload(ctx context.Context, hash string, size int64, expire time.Duration) (string, error) {
 	// Pre-signed upload
 	key := path.Join(tempPath, fmt.Sprintf("%s_%d.presigned", hash, size))
 	rawURL, err := m.client.PresignedPutObject(ctx, m.bucket, key, expire)
+	uploadsTotal.With("initiated", resultOf(err)).Inc()
 	if err != nil {
 		return "", err
 	}
+	uploadBytes.With().Add(float64(size))
 	return rawURL.String(), nil
 }
 
@@ -137,6 +173,7 @@ func (m *Minio) CompleteUpload(ctx context.Context, dst, src string) (*controlle
 		Bucket: m.bucket,
 		Object: src,
 	})
+	uploadsTotal.With("completed", resultOf(err)).Inc()
 	if err != nil {
 		return nil, err
 	}
@@ -157,6 +194,7 @@ func (m *Minio) AccessURL(ctx context.Context, name string, expire time.Duration
 	}
 
 	url, err := m.client.PresignedGetObject(ctx, m.bucket, name, expire, reqParams)
+	downloadsTotal.With(resultOf(err)).Inc()
 	if err != nil {
 		return "", err
 	}
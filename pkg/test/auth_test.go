@@ -0,0 +1,67 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+func newTestAuthenticator(t *testing.T, keyId string) *rpc.Authenticator {
+	keyring, err := rpc.NewHMACKeyring(keyId, map[string][]byte{
+		"node1": []byte("node1-secret"),
+		"node2": []byte("node2-secret"),
+	})
+	if err != nil {
+		t.Fatalf("keyring err:%v", err)
+	}
+	return rpc.NewAuthenticator(keyring)
+}
+
+func TestSignedRequest(t *testing.T) {
+	connString := "loopback://TestSignedRequest"
+	serverAuth := newTestAuthenticator(t, "node1")
+	server := actor.NewEngine(0, 1, 1001, connString)
+	server.SetAuthenticator(serverAuth)
+	server.MustInit()
+	server.SpawnActor(&ActorService{
+		Actor: actor.NewActor("1", server),
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("start err:%s", err)
+	}
+	defer server.Stop()
+
+	signed := actor.NewEngine(0, 2, 1002, connString)
+	signed.SetAuthenticator(newTestAuthenticator(t, "node2"))
+	signed.MustInit()
+	defer signed.Stop()
+	signed.Start()
+
+	unsigned := actor.NewEngine(0, 2, 1003, connString)
+	unsigned.MustInit()
+	defer unsigned.Stop()
+	unsigned.Start()
+
+	target := concepts.NewActorId("engine.0.1.1001.server", "1")
+	echo := &common_msg.EchoRequest{Value1: 1, Value2: "signed"}
+	obj1, err := actor.SendRequest[common_msg.EchoResponse](&ActorClient{Actor: actor.NewActor("1", signed)}, target, 1, echo)
+	if err != nil {
+		t.Fatalf("signed request failure, err:%v", err)
+	}
+	if obj1.Value2 != "signed | response" {
+		t.Fatal("unexpected response value")
+	}
+
+	_, err = actor.SendRequest[common_msg.EchoResponse](&ActorClient{Actor: actor.NewActor("1", unsigned)}, target, 1, echo, concepts.WithTimeout(200*time.Millisecond))
+	if err == nil {
+		t.Fatal("unsigned request was handled")
+	}
+
+	if stats := serverAuth.Stats(); stats.Accepted != 1 || stats.Unsigned != 1 {
+		t.Fatalf("unexpected stats:%+v", stats)
+	}
+}
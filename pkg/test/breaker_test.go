@@ -0,0 +1,65 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/rpc"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+func TestBreakerEjectsHangingNode(t *testing.T) {
+	sweepInterval := rpc.DefaultSweepInterval
+	rpc.DefaultSweepInterval = 10 * time.Millisecond
+	t.Cleanup(func() { rpc.DefaultSweepInterval = sweepInterval })
+
+	connString := "loopback://TestBreakerEjectsHangingNode"
+	newLoopbackServer(t, connString)
+	engine := actor.NewEngine(0, 2, 1002, connString)
+	engine.SetBreakerPolicy(rpc.BreakerPolicy{
+		Window:       time.Minute,
+		MinRequests:  2,
+		FailureRatio: 0.5,
+		OpenTimeout:  time.Minute,
+	})
+	engine.MustInit()
+	defer engine.Stop()
+	engine.Start()
+	actorObj1 := &ActorClient{
+		Actor: actor.NewActor("1", engine),
+	}
+
+	// nobody serves engine.0.1.1009, every request times out
+	hanging := concepts.NewActorId("engine.0.1.1009.server", "1")
+	echo := &common_msg.EchoRequest{Value1: 1, Value2: "hello"}
+	for i := 0; i < 2; i++ {
+		_, err := actor.SendRequest[common_msg.EchoResponse](actorObj1, hanging, 1, echo, concepts.WithTimeout(20*time.Millisecond))
+		if err == nil {
+			t.Fatal("request to a missing node succeeded")
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for !engine.Ejected(hanging.Address) {
+		if time.Now().After(deadline) {
+			t.Fatal("node not ejected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	_, err := actor.SendRequest[common_msg.EchoResponse](actorObj1, hanging, 1, echo)
+	if err == nil || err.Code() != errs.CODE_CircuitOpen {
+		t.Fatalf("unexpected err:%v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("open circuit did not fail fast:%v", elapsed)
+	}
+
+	// the healthy node is not affected
+	if _, err := actor.SendRequest[common_msg.EchoResponse](actorObj1, concepts.NewActorId("engine.0.1.1001.server", "1"), 1, echo); err != nil {
+		t.Fatalf("opcode 1 failure, err:%v", err)
+	}
+}
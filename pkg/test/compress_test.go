@@ -0,0 +1,42 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/compress"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+func TestCompressedRequest(t *testing.T) {
+	if err := compress.Configure(compress.Zstd, 1024); err != nil {
+		t.Fatalf("configure err:%v", err)
+	}
+	t.Cleanup(func() { compress.Configure(compress.None, 0) })
+
+	connString := "loopback://TestCompressedRequest"
+	newLoopbackServer(t, connString)
+	engine := actor.NewEngine(0, 2, 1002, connString)
+	engine.MustInit()
+	defer engine.Stop()
+	engine.Start()
+	actorObj1 := &ActorClient{
+		Actor: actor.NewActor("1", engine),
+	}
+
+	before := compress.GetStats()
+	echo := &common_msg.EchoRequest{Value1: 1, Value2: strings.Repeat("manifest;", 4096)}
+	obj1, err := actor.SendRequest[common_msg.EchoResponse](actorObj1, concepts.NewActorId("engine.0.1.1001.server", "1"), 1, echo)
+	if err != nil {
+		t.Fatalf("opcode 1 failure, err:%v", err)
+	}
+	if obj1.Value2 != echo.Value2+" | response" {
+		t.Fatal("unexpected response value")
+	}
+	// both the request and the response went compressed
+	if stats := compress.GetStats(); stats.Messages < before.Messages+2 || stats.Ratio() >= 1 {
+		t.Fatalf("unexpected stats:%+v", stats)
+	}
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/gateway"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
+)
+
+type fakeSession struct {
+	delivered chan *rpc_msg.PRC_DeMultiplexer_Forward
+}
+
+func (s *fakeSession) Deliver(message *rpc_msg.PRC_DeMultiplexer_Forward) error {
+	s.delivered <- message
+	return nil
+}
+
+func (s *fakeSession) wait(t *testing.T) *rpc_msg.PRC_DeMultiplexer_Forward {
+	t.Helper()
+	select {
+	case message := <-s.delivered:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("nothing delivered to the session")
+		return nil
+	}
+}
+
+func TestGatewayForward(t *testing.T) {
+	connString := "loopback://TestGatewayForward"
+
+	backend := actor.NewEngine(0, 1, 1001, connString)
+	service := gateway.NewService(backend)
+	roles := make(chan *rpc_msg.RoleIdentifier, 1)
+	service.Handle(2001, func(ctx context.Context, message *gateway.Message) ([]byte, errs.CodeError) {
+		roles <- message.Role
+		message.Info.ResponseOpcode = 2002
+		return append([]byte("echo:"), message.Body...), nil
+	})
+	service.Handle(2003, func(ctx context.Context, message *gateway.Message) ([]byte, errs.CodeError) {
+		return nil, errs.NewCodeError(errors.New("denied"), errs.CODE_NoPermissionError)
+	})
+	backend.MustInit()
+	backend.MustSpawnActors(service)
+	if err := backend.Start(); err != nil {
+		t.Fatalf("start err:%s", err)
+	}
+	defer backend.Stop()
+
+	routes, err := gateway.NewRouteTable(gateway.Route{Min: 2000, Max: 2999, Kind: 1})
+	if err != nil {
+		t.Fatalf("routes err:%v", err)
+	}
+	gw := actor.NewEngine(0, 2, 1002, connString)
+	mux := gateway.NewMultiplexer(gw, "gateway.test", routes)
+	gw.MustInit()
+	gw.MustSpawnActors(mux)
+	if err := gw.Start(); err != nil {
+		t.Fatalf("start err:%s", err)
+	}
+	defer gw.Stop()
+
+	session := &fakeSession{delivered: make(chan *rpc_msg.PRC_DeMultiplexer_Forward, 4)}
+	sessionId := mux.Attach(session)
+	forward := func(seq, opcode uint32, body string) error {
+		info := &rpc_msg.ClientMessageInfo{SessionId: sessionId, SeqNum: seq, Opcode: opcode, ConnetionType: gateway.ConnectionWS}
+		return mux.Forward(nil, 42, info, []byte(body))
+	}
+
+	if err := forward(7, 2001, "hello"); err != nil {
+		t.Fatalf("forward err:%v", err)
+	}
+	reply := session.wait(t)
+	if string(reply.BodyMsg) != "echo:hello" || reply.Info.SeqNum != 7 || reply.Info.Opcode != 2002 || reply.Status != nil {
+		t.Fatalf("unexpected reply:%v", reply)
+	}
+	role := <-roles
+	if role.UserId != 42 || role.GwId.Type != 2 || role.GwId.Id != 1002 || role.GwId.ActorId != "gateway.test" {
+		t.Fatalf("unexpected role:%v", role)
+	}
+
+	// the backend pushes to the session it saw before
+	if err := service.Push(role, 2100, []byte("news")); err != nil {
+		t.Fatalf("push err:%v", err)
+	}
+	push := session.wait(t)
+	if string(push.BodyMsg) != "news" || push.Info.SeqNum != 0 || push.Info.Opcode != 2100 {
+		t.Fatalf("unexpected push:%v", push)
+	}
+
+	if err := forward(8, 2003, ""); err != nil {
+		t.Fatalf("forward err:%v", err)
+	}
+	if reply := session.wait(t); reply.Status.GetCode() != errs.CODE_NoPermissionError || reply.Info.SeqNum != 8 {
+		t.Fatalf("unexpected reply:%v", reply)
+	}
+
+	if err := forward(9, 2500, ""); err != nil {
+		t.Fatalf("forward err:%v", err)
+	}
+	if reply := session.wait(t); reply.Status.GetCode() != uint32(rpc_msg.RPC_CODE_CODE_OpcodeUnregister) {
+		t.Fatalf("unexpected reply:%v", reply)
+	}
+
+	if err := forward(10, 3000, ""); !errors.Is(err, gateway.ErrNoRoute) {
+		t.Fatalf("unexpected err:%v", err)
+	}
+
+	// replies to a closed session are dropped
+	mux.Detach(sessionId)
+	if err := forward(11, 2001, "late"); err != nil {
+		t.Fatalf("forward err:%v", err)
+	}
+	<-roles
+	select {
+	case message := <-session.delivered:
+		t.Fatalf("delivered to a closed session:%v", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,43 @@
+package test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/metrics"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	connString := "loopback://TestMetricsEndpoint"
+	newLoopbackServer(t, connString)
+	engine := actor.NewEngine(0, 2, 1002, connString)
+	engine.MustInit()
+	defer engine.Stop()
+	engine.Start()
+	actorObj1 := &ActorClient{
+		Actor: actor.NewActor("1", engine),
+	}
+
+	echo := &common_msg.EchoRequest{Value1: 1, Value2: "hello"}
+	if _, err := actor.SendRequest[common_msg.EchoResponse](actorObj1, concepts.NewActorId("engine.0.1.1001.server", "1"), 1, echo); err != nil {
+		t.Fatalf("opcode 1 failure, err:%v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`actor_requests_total{opcode="1",origin="remote"}`,
+		`rpc_client_request_duration_seconds_count{opcode="1"}`,
+		`rpc_pending_requests{engine="engine.0.2.1002.server"} 0`,
+		`actor_count{engine="engine.0.2.1002.server"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %s in:\n%s", want, body)
+		}
+	}
+}
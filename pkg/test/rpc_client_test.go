@@ -8,7 +8,6 @@ import (
 	"sync/atomic"
 	"syscall"
 	"testing"
-	"time"
 
 	"github.com/wuqunyong/file_storage/pkg/actor"
 	"github.com/wuqunyong/file_storage/pkg/concepts"
@@ -35,8 +34,20 @@ func (actor *ActorClient) OnShutdown() {
 
 }
 
+// natsURL skips tests that need a real NATS server unless NATS_URL is set.
+func natsURL(t *testing.T) string {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL not set")
+	}
+	return url
+}
+
 func TestClient1(t *testing.T) {
-	engine := actor.NewEngine(0, 1, 1002, "nats://127.0.0.1:4222")
+	connString := "loopback://TestClient1"
+	newLoopbackServer(t, connString)
+
+	engine := actor.NewEngine(0, 1, 1002, connString)
 
 	engine.MustInit()
 	defer engine.Stop()
@@ -50,40 +61,40 @@ func TestClient1(t *testing.T) {
 	echo := &common_msg.EchoRequest{Value1: 123456, Value2: "小明"}
 	obj1, err := actor.SendRequest[common_msg.EchoResponse](actorObj1, concepts.NewActorId("engine.0.1.1001.server", "1"), 1, echo)
 	if err != nil {
-		logger.Log(logger.InfoLevel, "opcode 1 failure", "request", echo, "response", obj1, "err", err)
-	} else {
-		logger.Log(logger.InfoLevel, "opcode 1 success", "request", echo, "response", obj1)
+		t.Fatalf("opcode 1 failure, err:%v", err)
+	}
+	logger.Log(logger.InfoLevel, "opcode 1 success", "request", echo, "response", obj1)
+	if obj1.Value1 != 123457 || obj1.Value2 != "小明 | response" {
+		t.Fatalf("unexpected response:%v", obj1)
 	}
 
 	echo.Value1 = 0
 	obj2, err := actor.SendRequest[common_msg.EchoResponse](actorObj1, concepts.NewActorId("engine.0.1.1001.server", "1"), 2, echo)
-	if err != nil {
-		logger.Log(logger.InfoLevel, "opcode 2 failure", "request", echo, "response", obj2, "err", err)
-	} else {
-		logger.Log(logger.InfoLevel, "opcode 2 success", "request", echo, "response", obj2)
+	if err == nil || err.Code() != 123 {
+		t.Fatalf("opcode 2 must fail with 123, response:%v, err:%v", obj2, err)
 	}
+	logger.Log(logger.InfoLevel, "opcode 2 failure", "request", echo, "response", obj2, "err", err)
 
 	echoObj := &rpc_msg.RPC_EchoTestRequest{Value1: 12345678, Value2: "小明"}
 	//engine.1.4.1.serve
 	echoResponse, err := actor.SendRequest[rpc_msg.RPC_EchoTestResponse](actorObj1, concepts.NewActorId("engine.0.1.1001.server", "1"), 1001, echoObj)
 	// echoResponse, err := actor.SendRequest[rpc_msg.RPC_EchoTestResponse](actorObj1, concepts.NewActorId("engine.1.4.1.server", "C++"), 1001, echoObj)
 	if err != nil {
-		logger.Log(logger.InfoLevel, "opcode 1001 failure", "request", echoObj, "response", echoResponse, "err", err)
-	} else {
-		logger.Log(logger.InfoLevel, "opcode 1001 success", "request", echoObj, "response", echoResponse)
+		t.Fatalf("opcode 1001 failure, err:%v", err)
+	}
+	logger.Log(logger.InfoLevel, "opcode 1001 success", "request", echoObj, "response", echoResponse)
+	if echoResponse.Value1 != echoObj.Value1 {
+		t.Fatalf("unexpected response:%v", echoResponse)
 	}
 
 	sendErr := actor.SendNotify(actorObj1, concepts.NewActorId("engine.0.1.1001.server", "1"), 1002, echoObj)
 	if sendErr != nil {
-		logger.Log(logger.InfoLevel, "opcode 1002 failure", "notify", echoObj, "err", sendErr)
+		t.Fatalf("opcode 1002 failure, err:%v", sendErr)
 	}
-
-	time.Sleep(time.Duration(1800) * time.Second)
-	time.Sleep(time.Duration(1800) * time.Second)
 }
 
 func TestClientRegister(t *testing.T) {
-	engine := actor.NewEngine(1, 4, 1, "nats://127.0.0.1:4222")
+	engine := actor.NewEngine(1, 4, 1, natsURL(t))
 
 	engine.MustInit()
 
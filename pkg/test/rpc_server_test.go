@@ -73,10 +73,27 @@ func (actor *ActorService) NotifyTest(ctx context.Context, notify *rpc_msg.RPC_E
 	logger.Log(logger.InfoLevel, "NotifyTest", "notify", notify)
 }
 
+// newLoopbackServer starts engine.0.1.1001 with ActorService "1" on the
+// in-process transport named connString.
+func newLoopbackServer(t *testing.T, connString string) *actor.Engine {
+	engine := actor.NewEngine(0, 1, 1001, connString)
+	engine.MustInit()
+	actorObj1 := &ActorService{
+		Actor: actor.NewActor("1", engine),
+	}
+	engine.SpawnActor(actorObj1)
+	if err := engine.Start(); err != nil {
+		t.Fatalf("start err:%s", err)
+	}
+	t.Cleanup(engine.Stop)
+	return engine
+}
+
 func TestServer1(t *testing.T) {
 	//http://127.0.0.1:8222/connz?subs=true
+	connString := natsURL(t)
 
-	engine := actor.NewEngine(0, 1, 1001, "nats://127.0.0.1:4222")
+	engine := actor.NewEngine(0, 1, 1001, connString)
 	engine.MustInit()
 	actorObj1 := &ActorService{
 		Actor: actor.NewActor("1", engine),
@@ -95,17 +112,22 @@ func TestServer2(t *testing.T) {
 	//nats pub identify.server.1.2.3 "hello world"
 	engine := actor.NewEngine(0, 1, 1001, "")
 	sServerAddress := concepts.GenServerAddress(0, 1, 1001)
-	rpcServer := rpc.NewRPCServer(engine, "nats://127.0.0.1:4222", sServerAddress)
+	rpcServer := rpc.NewRPCServer(engine, "loopback://TestServer2", sServerAddress)
 	err := rpcServer.Init()
 	if err != nil {
 		t.Fatalf("err:%s", err)
 	}
 	go rpcServer.Run()
+	if err := rpcServer.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("err:%s", err)
+	}
 
-	time.Sleep(time.Duration(10) * time.Second)
+	time.Sleep(time.Duration(100) * time.Millisecond)
 
 	rpcServer.Stop()
+	if err := rpcServer.HealthCheck(context.Background()); err == nil {
+		t.Fatal("stopped server must be unhealthy")
+	}
 
 	fmt.Printf("rpc closed")
-	time.Sleep(time.Duration(6) * time.Second)
 }
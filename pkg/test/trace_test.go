@@ -0,0 +1,153 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wuqunyong/file_storage/pkg/actor"
+	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/errs"
+	"github.com/wuqunyong/file_storage/pkg/trace"
+	"github.com/wuqunyong/file_storage/proto/common_msg"
+)
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.Span
+}
+
+func (r *spanRecorder) Export(span *trace.Span) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+	return nil
+}
+
+// find returns the span named name whose parent is parent.
+func (r *spanRecorder) find(parent trace.SpanContext, name string) *trace.Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, span := range r.spans {
+		if span.Name == name && span.Context.TraceID == parent.TraceID && span.ParentID == parent.SpanID {
+			return span
+		}
+	}
+	return nil
+}
+
+func (r *spanRecorder) wait(t *testing.T, parent trace.SpanContext, name string) *trace.Span {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if span := r.find(parent, name); span != nil {
+			return span
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("span %q under %s not exported", name, parent.Traceparent())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// ActorForwarder answers opcode 7 by asking engine.0.1.1001 with opcode 1,
+// without handing its context on.
+type ActorForwarder struct {
+	*actor.Actor
+}
+
+func (a *ActorForwarder) OnInit() error {
+	return a.Register(7, a.Forward)
+}
+
+func (a *ActorForwarder) OnShutdown() {
+}
+
+func (a *ActorForwarder) Forward(ctx context.Context, request *common_msg.EchoRequest, response *common_msg.EchoResponse) errs.CodeError {
+	reply, err := actor.SendRequest[common_msg.EchoResponse](a, concepts.NewActorId("engine.0.1.1001.server", "1"), 1, request)
+	if err != nil {
+		return err
+	}
+	response.Value1 = reply.Value1
+	response.Value2 = reply.Value2
+	return nil
+}
+
+func TestTracePropagation(t *testing.T) {
+	recorder := &spanRecorder{}
+	trace.SetExporter(recorder)
+	t.Cleanup(func() { trace.SetExporter(nil) })
+
+	connString := "loopback://TestTracePropagation"
+	newLoopbackServer(t, connString)
+	engine := actor.NewEngine(0, 2, 1002, connString)
+	engine.MustInit()
+	defer engine.Stop()
+	forwarder := &ActorForwarder{Actor: actor.NewActor("forwarder", engine)}
+	engine.SpawnActor(forwarder)
+	engine.Start()
+	actorObj1 := &ActorClient{
+		Actor: actor.NewActor("1", engine),
+	}
+
+	root := trace.Start(trace.SpanContext{}, "test")
+	ctx := trace.ContextWithSpanContext(context.Background(), root.Context)
+	echo := &common_msg.EchoRequest{Value1: 1, Value2: "hello"}
+	if _, err := actor.SendRequest[common_msg.EchoResponse](actorObj1, forwarder.ActorId(), 7, echo, concepts.WithContext(ctx)); err != nil {
+		t.Fatalf("opcode 7 failure, err:%v", err)
+	}
+	root.Finish()
+
+	// test -> request 7 -> actor 7 -> handler -> request 1 -> (nats) -> actor 1
+	request7 := recorder.wait(t, root.Context, "request 7")
+	actor7 := recorder.wait(t, request7.Context, "actor 7")
+	recorder.wait(t, actor7.Context, "inbox.wait")
+	handler7 := recorder.wait(t, actor7.Context, "handler")
+	request1 := recorder.wait(t, handler7.Context, "request 1")
+	actor1 := recorder.wait(t, request1.Context, "actor 1")
+	wait1 := recorder.wait(t, actor1.Context, "inbox.wait")
+	handler1 := recorder.wait(t, actor1.Context, "handler")
+
+	if !wait1.End.Equal(handler1.Start) {
+		t.Fatalf("inbox wait ends at %v, handler starts at %v", wait1.End, handler1.Start)
+	}
+	if request1.Err() != "" || actor1.Err() != "" {
+		t.Fatalf("unexpected errors:%q %q", request1.Err(), actor1.Err())
+	}
+	if got := actor1.Attributes()["sender"]; got != forwarder.ActorId().String() {
+		t.Fatalf("unexpected sender:%s", got)
+	}
+}
+
+func TestTraceError(t *testing.T) {
+	recorder := &spanRecorder{}
+	trace.SetExporter(recorder)
+	t.Cleanup(func() { trace.SetExporter(nil) })
+
+	connString := "loopback://TestTraceError"
+	newLoopbackServer(t, connString)
+	engine := actor.NewEngine(0, 2, 1002, connString)
+	engine.MustInit()
+	defer engine.Stop()
+	engine.Start()
+	actorObj1 := &ActorClient{
+		Actor: actor.NewActor("1", engine),
+	}
+
+	root := trace.Start(trace.SpanContext{}, "test")
+	ctx := trace.ContextWithSpanContext(context.Background(), root.Context)
+	echo := &common_msg.EchoRequest{Value1: 0}
+	if _, err := actor.SendRequest[common_msg.EchoResponse](actorObj1, concepts.NewActorId("engine.0.1.1001.server", "1"), 2, echo, concepts.WithContext(ctx)); err == nil {
+		t.Fatal("opcode 2 succeeded")
+	}
+
+	request := recorder.wait(t, root.Context, "request 2")
+	if request.Err() == "" {
+		t.Fatal("failed request span without error")
+	}
+	server := recorder.wait(t, request.Context, "actor 2")
+	if server.Err() == "" {
+		t.Fatal("failed handler span without error")
+	}
+}
@@ -0,0 +1,74 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// record is one line written by JSONExporter.
+type record struct {
+	TraceId    string            `json:"traceId"`
+	SpanId     string            `json:"spanId"`
+	ParentId   string            `json:"parentId,omitempty"`
+	Name       string            `json:"name"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	DurationUs int64             `json:"durationUs"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// JSONExporter writes every span as a JSON object on its own line.
+type JSONExporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	encoder *json.Encoder
+}
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w, encoder: json.NewEncoder(w)}
+}
+
+// NewFileExporter appends the spans to the file at path, creating it if
+// needed.
+func NewFileExporter(path string) (*JSONExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONExporter(file), nil
+}
+
+func (e *JSONExporter) Export(span *Span) error {
+	r := record{
+		TraceId:    span.Context.TraceID.String(),
+		SpanId:     span.Context.SpanID.String(),
+		Name:       span.Name,
+		Start:      span.Start,
+		End:        span.End,
+		DurationUs: span.Duration().Microseconds(),
+		Attributes: span.Attributes(),
+		Error:      span.Err(),
+	}
+	if span.ParentID != (SpanID{}) {
+		r.ParentId = span.ParentID.String()
+	}
+	if len(r.Attributes) == 0 {
+		r.Attributes = nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.encoder.Encode(&r)
+}
+
+// Close closes the underlying writer when it is a closer.
+func (e *JSONExporter) Close() error {
+	if closer, ok := e.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
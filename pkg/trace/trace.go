@@ -0,0 +1,240 @@
+// Package trace follows a request across the actors and the rpc hops with W3C
+// trace context (https://www.w3.org/TR/trace-context/) and hands the finished
+// spans to an Exporter.
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// FlagSampled marks a trace whose spans are exported.
+const FlagSampled byte = 0x01
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// SpanContext is the part of a span that crosses actor and node boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent formats sc as a traceparent header, empty when sc is not valid.
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	buf := make([]byte, 0, 55)
+	buf = append(buf, "00-"...)
+	buf = hex.AppendEncode(buf, sc.TraceID[:])
+	buf = append(buf, '-')
+	buf = hex.AppendEncode(buf, sc.SpanID[:])
+	buf = append(buf, '-')
+	buf = hex.AppendEncode(buf, []byte{sc.Flags})
+	return string(buf)
+}
+
+// ParseTraceparent reads a traceparent header. Versions after 00 are
+// accepted as long as they start with the fields of version 00.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(value) > 55 && (value[:2] == "00" || value[55] != '-') {
+		return sc, ErrInvalidTraceparent
+	}
+
+	var version [1]byte
+	var flags [1]byte
+	if _, err := hex.Decode(version[:], []byte(value[:2])); err != nil || version[0] == 0xff {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(value[3:35])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(value[36:52])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(flags[:], []byte(value[53:55])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+type contextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying sc, requests sent
+// with it become children of sc.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, if any.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	sc, _ := ctx.Value(contextKey{}).(SpanContext)
+	return sc
+}
+
+// Span is a timed operation of a trace.
+type Span struct {
+	Name     string
+	Context  SpanContext
+	ParentID SpanID
+	Start    time.Time
+	End      time.Time
+
+	mu         sync.Mutex
+	attributes map[string]string
+	err        string
+	ended      atomic.Bool
+}
+
+// Start starts a span now, see StartAt.
+func Start(parent SpanContext, name string) *Span {
+	return StartAt(parent, name, time.Now())
+}
+
+// StartAt starts a child of parent at start, or the root span of a new
+// sampled trace when parent is not valid.
+func StartAt(parent SpanContext, name string, start time.Time) *Span {
+	span := &Span{Name: name, Start: start}
+	if parent.IsValid() {
+		span.Context.TraceID = parent.TraceID
+		span.Context.Flags = parent.Flags
+		span.ParentID = parent.SpanID
+	} else {
+		fillRandom(span.Context.TraceID[:])
+		span.Context.Flags = FlagSampled
+	}
+	fillRandom(span.Context.SpanID[:])
+	return span
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+// Attributes returns a copy of the attributes of s.
+func (s *Span) Attributes() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attributes := make(map[string]string, len(s.attributes))
+	for k, v := range s.attributes {
+		attributes[k] = v
+	}
+	return attributes
+}
+
+// SetError marks the span as failed, nil is ignored.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+func (s *Span) Err() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Finish ends the span now, see FinishAt.
+func (s *Span) Finish() {
+	s.FinishAt(time.Now())
+}
+
+// FinishAt ends the span at end and exports it when its trace is sampled.
+// Only the first call counts.
+func (s *Span) FinishAt(end time.Time) {
+	if s == nil || s.ended.Swap(true) {
+		return
+	}
+	s.End = end
+	if !s.Context.IsSampled() {
+		return
+	}
+	if e := exporter.Load(); e != nil {
+		(*e).Export(s)
+	}
+}
+
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Exporter receives every finished span of the sampled traces.
+type Exporter interface {
+	Export(span *Span) error
+}
+
+var exporter atomic.Pointer[Exporter]
+
+// SetExporter replaces the exporter of this process, nil drops the spans.
+// The context is still propagated without an exporter.
+func SetExporter(e Exporter) {
+	if e == nil {
+		exporter.Store(nil)
+		return
+	}
+	exporter.Store(&e)
+}
+
+func fillRandom(b []byte) {
+	for {
+		for i := 0; i < len(b); i += 8 {
+			v := rand.Uint64()
+			for j := i; j < len(b) && j < i+8; j++ {
+				b[j] = byte(v)
+				v >>= 8
+			}
+		}
+		// an all zero id is invalid
+		for _, c := range b {
+			if c != 0 {
+				return
+			}
+		}
+	}
+}
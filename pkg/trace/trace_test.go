@@ -0,0 +1,101 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceparent(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(value)
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.IsSampled())
+	assert.Equal(t, value, sc.Traceparent())
+
+	// a later version may append fields
+	sc, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	require.NoError(t, err)
+	assert.False(t, sc.IsSampled())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(invalid)
+		assert.ErrorIs(t, err, ErrInvalidTraceparent, invalid)
+	}
+	assert.Empty(t, SpanContext{}.Traceparent())
+}
+
+func TestStart(t *testing.T) {
+	root := Start(SpanContext{}, "root")
+	assert.True(t, root.Context.IsValid())
+	assert.True(t, root.Context.IsSampled())
+	assert.Equal(t, SpanID{}, root.ParentID)
+
+	child := Start(root.Context, "child")
+	assert.Equal(t, root.Context.TraceID, child.Context.TraceID)
+	assert.Equal(t, root.Context.SpanID, child.ParentID)
+	assert.NotEqual(t, root.Context.SpanID, child.Context.SpanID)
+
+	ctx := ContextWithSpanContext(context.Background(), child.Context)
+	assert.Equal(t, child.Context, SpanContextFromContext(ctx))
+	assert.False(t, SpanContextFromContext(context.Background()).IsValid())
+}
+
+func TestJSONExporter(t *testing.T) {
+	var buf bytes.Buffer
+	SetExporter(NewJSONExporter(&buf))
+	defer SetExporter(nil)
+
+	start := time.Unix(1700000000, 0).UTC()
+	root := StartAt(SpanContext{}, "root", start)
+	child := StartAt(root.Context, "child", start)
+	child.SetAttribute("opcode", "1")
+	child.SetError(errors.New("boom"))
+	child.FinishAt(start.Add(1500 * time.Microsecond))
+	child.FinishAt(start.Add(time.Hour))
+	root.FinishAt(start.Add(2 * time.Millisecond))
+
+	// the spans of a trace that is not sampled are dropped
+	unsampled := StartAt(SpanContext{TraceID: root.Context.TraceID, SpanID: root.Context.SpanID}, "dropped", start)
+	unsampled.Finish()
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var got record
+	require.NoError(t, json.Unmarshal(lines[0], &got))
+	assert.Equal(t, record{
+		TraceId:    root.Context.TraceID.String(),
+		SpanId:     child.Context.SpanID.String(),
+		ParentId:   root.Context.SpanID.String(),
+		Name:       "child",
+		Start:      start,
+		End:        start.Add(1500 * time.Microsecond),
+		DurationUs: 1500,
+		Attributes: map[string]string{"opcode": "1"},
+		Error:      "boom",
+	}, got)
+
+	got = record{}
+	require.NoError(t, json.Unmarshal(lines[1], &got))
+	assert.Equal(t, "root", got.Name)
+	assert.Empty(t, got.ParentId)
+	assert.Empty(t, got.Error)
+}
@@ -0,0 +1,53 @@
+package ws
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthApi struct {
+	ws LongConnServer
+}
+
+func NewHealthApi(ws LongConnServer) *HealthApi {
+	return &HealthApi{
+		ws: ws,
+	}
+}
+
+// Livez only tells that the process still serves requests.
+func (o *HealthApi) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Healthz aggregates the health checks of the engine and its components.
+func (o *HealthApi) Healthz(c *gin.Context) {
+	engine := o.ws.GetEngine()
+	if engine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "engine not set"})
+		return
+	}
+	report := engine.CheckHealth(c.Request.Context())
+	code := http.StatusOK
+	if !report.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
+
+// Readyz fails as soon as the engine shuts down, so load balancers drain
+// the node before its components go away.
+func (o *HealthApi) Readyz(c *gin.Context) {
+	engine := o.ws.GetEngine()
+	if engine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "engine not set"})
+		return
+	}
+	report := engine.CheckHealth(c.Request.Context())
+	code := http.StatusOK
+	if !report.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
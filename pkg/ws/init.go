@@ -1,8 +1,13 @@
 package ws
 
+import "github.com/wuqunyong/file_storage/pkg/gateway"
+
 type Config struct {
 	HttpPort          string
 	HttpsPort         string
 	ServerCertificate string
 	ServerPrivateKey  string
+	// Routes forwards the client messages without a local handler to the
+	// backend services, see gateway.RouteTable.
+	Routes []gateway.Route
 }
@@ -0,0 +1,9 @@
+package ws
+
+import "github.com/wuqunyong/file_storage/pkg/metrics"
+
+var (
+	wsSessions = metrics.NewGaugeVec("ws_sessions", "Open websocket sessions.")
+	wsBytes    = metrics.NewCounterVec("ws_bytes_total", "Websocket message bytes, by direction (in or out).", "direction")
+	wsMessages = metrics.NewCounterVec("ws_messages_total", "Websocket messages, by direction (in or out).", "direction")
+)
@@ -5,6 +5,7 @@ import (
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	docs "github.com/wuqunyong/file_storage/docs"
+	"github.com/wuqunyong/file_storage/pkg/metrics"
 )
 
 func newGinRouter(ws LongConnServer) *gin.Engine {
@@ -22,6 +23,12 @@ func newGinRouter(ws LongConnServer) *gin.Engine {
 	objectGroup.POST("/complete_multipart_upload", t.CompleteMultipartUpload)
 	objectGroup.POST("/access_url", t.AccessURL)
 
+	h := NewHealthApi(ws)
+	r.GET("/livez", h.Livez)
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	r.GET("/ws", t.WSHandler)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
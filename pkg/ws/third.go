@@ -1,11 +1,14 @@
 package ws
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/wuqunyong/file_storage/pkg/component/storage"
 	"github.com/wuqunyong/file_storage/pkg/storage/controller"
 	"github.com/wuqunyong/file_storage/pkg/storage/minio"
 )
@@ -32,16 +35,11 @@ func NewThirdApi(ws LongConnServer) *ThirdApi {
 // @Success 200 {string} Helloworld
 // @Router /example/helloworld [get]
 func (o *ThirdApi) InitiateMultipartUpload(c *gin.Context) {
-
-	var conf minio.Config
-	conf.Endpoint = "http://192.168.56.102:10005"
-	conf.Bucket = "openimtest"
-	conf.AccessKeyID = "root"
-	conf.SecretAccessKey = "openIM123"
-	conf.PublicRead = true
-	minioCli, err := minio.NewMinio(c, conf)
+	minioCli, err := o.getMinio()
 	if err != nil {
 		log.Printf("err:%s", err)
+		c.JSON(http.StatusServiceUnavailable, err.Error())
+		return
 	}
 
 	url, _ := minioCli.InitiateUpload(c, "hello_mp4", 1024, time.Duration(3600)*time.Second)
@@ -51,15 +49,11 @@ func (o *ThirdApi) InitiateMultipartUpload(c *gin.Context) {
 }
 
 func (o *ThirdApi) CompleteMultipartUpload(c *gin.Context) {
-	var conf minio.Config
-	conf.Endpoint = "http://192.168.56.102:10005"
-	conf.Bucket = "openimtest"
-	conf.AccessKeyID = "root"
-	conf.SecretAccessKey = "openIM123"
-	conf.PublicRead = true
-	minioCli, err := minio.NewMinio(c, conf)
+	minioCli, err := o.getMinio()
 	if err != nil {
 		log.Printf("err:%s", err)
+		c.JSON(http.StatusServiceUnavailable, err.Error())
+		return
 	}
 
 	url, _ := minioCli.CompleteUpload(c, "hello_mp4_1024.mp4", "openimtest/temp/hello_mp4_1024.presigned")
@@ -69,15 +63,11 @@ func (o *ThirdApi) CompleteMultipartUpload(c *gin.Context) {
 }
 
 func (o *ThirdApi) AccessURL(c *gin.Context) {
-	var conf minio.Config
-	conf.Endpoint = "http://192.168.56.102:10005"
-	conf.Bucket = "openimtest"
-	conf.AccessKeyID = "root"
-	conf.SecretAccessKey = "openIM123"
-	conf.PublicRead = true
-	minioCli, err := minio.NewMinio(c, conf)
+	minioCli, err := o.getMinio()
 	if err != nil {
 		log.Printf("err:%s", err)
+		c.JSON(http.StatusServiceUnavailable, err.Error())
+		return
 	}
 
 	req := controller.AccessURLOpt{}
@@ -91,6 +81,18 @@ func (o *ThirdApi) AccessURL(c *gin.Context) {
 	c.JSON(http.StatusOK, "AccessURL OK")
 }
 
+func (o *ThirdApi) getMinio() (*minio.Minio, error) {
+	engine := o.ws.GetEngine()
+	if engine == nil {
+		return nil, errors.New("engine not set")
+	}
+	component, ok := engine.GetComponent(storage.ComponentName).(*storage.StorageComponent)
+	if !ok || component.GetMinio() == nil {
+		return nil, errors.New("storage component not configured")
+	}
+	return component.GetMinio(), nil
+}
+
 func (o *ThirdApi) WSHandler(c *gin.Context) {
 	connContext := newContext(c.Writer, c.Request)
 
@@ -112,7 +114,7 @@ func (o *ThirdApi) WSHandler(c *gin.Context) {
 		return
 	}
 
-	client.ResetClient(connContext, wsLongConn, o.ws, "123", clientHandler)
+	client.ResetClient(connContext, wsLongConn, o.ws, fmt.Sprintf("ws.%d", connContext.ConnID), clientHandler)
 
 	// Register the client with the server and start message processing
 	o.ws.Register(client)
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +16,9 @@ import (
 	"github.com/wuqunyong/file_storage/pkg/actor"
 	"github.com/wuqunyong/file_storage/pkg/common"
 	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/gateway"
+	"github.com/wuqunyong/file_storage/pkg/trace"
+	"github.com/wuqunyong/file_storage/proto/rpc_msg"
 )
 
 const (
@@ -83,6 +87,9 @@ type Client struct {
 	hbCancel       context.CancelFunc
 	concepts.IActor
 	msgHandler concepts.IClientHandler
+	// sessionId tags the messages forwarded by the multiplexer, 0 when the
+	// server has none
+	sessionId uint64
 }
 
 func (c *Client) ResetClient(ctx *UserConnContext, conn LongConn, longConnServer LongConnServer, id string, handler concepts.IClientHandler) {
@@ -95,6 +102,7 @@ func (c *Client) ResetClient(ctx *UserConnContext, conn LongConn, longConnServer
 	c.hbCtx, c.hbCancel = context.WithCancel(context.Background())
 	c.IActor = actor.NewActor(id, longConnServer.GetEngine())
 	c.msgHandler = handler
+	c.sessionId = 0
 }
 
 func (c *Client) Init() error {
@@ -106,10 +114,44 @@ func (c *Client) Init() error {
 }
 
 func (c *Client) Launch() {
+	if mux := c.longConnServer.Multiplexer(); mux != nil {
+		c.sessionId = mux.Attach(c)
+	}
 	go c.readMessage()
 	c.longConnServer.GetEngine().SpawnActor(c)
 }
 
+// Forward hands request to the backend service owning its opcode, it
+// reports false when the opcode has no route.
+func (c *Client) Forward(request *common.Req) bool {
+	mux := c.longConnServer.Multiplexer()
+	if mux == nil || request.Opcode < 0 || !mux.Routed(uint32(request.Opcode)) {
+		return false
+	}
+	userId, _ := strconv.ParseUint(c.UserID, 10, 64)
+	info := &rpc_msg.ClientMessageInfo{
+		SessionId:     c.sessionId,
+		SeqNum:        uint32(request.RequestId),
+		Opcode:        uint32(request.Opcode),
+		ConnetionType: gateway.ConnectionWS,
+	}
+	if err := mux.Forward(c, userId, info, request.Data); err != nil {
+		resp := common.NewResp(request.RequestId, int32(rpc_msg.RPC_CODE_CODE_RouteSendToServerError), err.Error())
+		resp.Opcode = request.Opcode
+		c.writeTextMsg(resp)
+	}
+	return true
+}
+
+// Deliver writes a reply or a push of a backend service.
+func (c *Client) Deliver(message *rpc_msg.PRC_DeMultiplexer_Forward) error {
+	info := message.GetInfo()
+	resp := common.NewResp(int32(info.GetSeqNum()), int32(message.GetStatus().GetCode()), message.GetStatus().GetMsg())
+	resp.Opcode = int32(info.GetOpcode())
+	resp.Data = message.GetBodyMsg()
+	return c.writeTextMsg(resp)
+}
+
 func (c *Client) GetActor() concepts.IActor {
 	return c.IActor
 }
@@ -158,10 +200,18 @@ func (c *Client) readMessage() {
 
 			fmt.Printf("Unmarshal jsonReq: %+v", jsonReq)
 
+			// a missing or malformed traceparent starts a new trace
+			parent, _ := trace.ParseTraceparent(jsonReq.TraceParent)
+			span := trace.Start(parent, fmt.Sprintf("ws %d", jsonReq.Opcode))
+			span.SetAttribute("remote", c.ctx.RemoteAddr)
 			task := func() {
 				c.msgHandler.CallFunc(&jsonReq)
+				span.Finish()
+			}
+			if err := c.GetActor().PostTaskContext(trace.ContextWithSpanContext(context.Background(), span.Context), task); err != nil {
+				span.SetError(err)
+				span.Finish()
 			}
-			c.GetActor().PostTask(task)
 
 		case PingMessage:
 			c.writePongMsg("")
@@ -185,6 +235,9 @@ func (c *Client) close() {
 	c.IActor.Stop()
 	c.conn.Close()
 	c.hbCancel() // Close server-initiated heartbeat.
+	if mux := c.longConnServer.Multiplexer(); mux != nil && c.sessionId != 0 {
+		mux.Detach(c.sessionId)
+	}
 	c.longConnServer.UnRegister(c)
 }
 
@@ -53,11 +53,21 @@ func (d *GWebSocket) GenerateLongConn(w http.ResponseWriter, r *http.Request) er
 
 func (d *GWebSocket) WriteMessage(messageType int, message []byte) error {
 	// d.setSendConn(d.conn)
-	return d.conn.WriteMessage(messageType, message)
+	err := d.conn.WriteMessage(messageType, message)
+	if err == nil {
+		wsMessages.With("out").Inc()
+		wsBytes.With("out").Add(float64(len(message)))
+	}
+	return err
 }
 
 func (d *GWebSocket) ReadMessage() (int, []byte, error) {
-	return d.conn.ReadMessage()
+	messageType, message, err := d.conn.ReadMessage()
+	if err == nil {
+		wsMessages.With("in").Inc()
+		wsBytes.With("in").Add(float64(len(message)))
+	}
+	return messageType, message, err
 }
 
 func (d *GWebSocket) Close() error {
@@ -138,6 +138,9 @@ func (handler *ClientHandler) Init() (err error) {
 }
 
 func (handler *ClientHandler) CallFunc(request *common.Req) {
+	if handler.msgHandler.GetHandler(request.Opcode) == nil && handler.client.Forward(request) {
+		return
+	}
 	response := handler.msgHandler.CallFunc(handler.client, request)
 	handler.client.writeTextMsg(response)
 }
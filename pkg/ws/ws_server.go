@@ -1,12 +1,15 @@
 package ws
 
 import (
+	"crypto/tls"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"sync"
 
 	"github.com/wuqunyong/file_storage/pkg/concepts"
+	"github.com/wuqunyong/file_storage/pkg/gateway"
 )
 
 type LongConnServer interface {
@@ -15,9 +18,13 @@ type LongConnServer interface {
 	UnRegister(c *Client)
 	GetClient() *Client
 	GetEngine() concepts.IEngine
+	Multiplexer() *gateway.Multiplexer
 	Encoder
 }
 
+// MultiplexerActor is the id of the actor forwarding the client messages.
+const MultiplexerActor = "gateway.ws"
+
 type WsServer struct {
 	config     Config
 	engine     concepts.IEngine
@@ -27,6 +34,7 @@ type WsServer struct {
 	registerChan   chan *Client
 	unregisterChan chan *Client
 	clientPool     sync.Pool
+	mux            *gateway.Multiplexer
 
 	Encoder
 }
@@ -55,17 +63,59 @@ func (ws *WsServer) SetEngine(engine concepts.IEngine) {
 	ws.engine = engine
 }
 
+// Init spawns the multiplexer when routes are configured.
+func (ws *WsServer) Init() error {
+	if len(ws.config.Routes) == 0 || ws.mux != nil {
+		return nil
+	}
+	routes, err := gateway.NewRouteTable(ws.config.Routes...)
+	if err != nil {
+		return err
+	}
+	mux := gateway.NewMultiplexer(ws.engine, MultiplexerActor, routes)
+	if _, err := ws.engine.SpawnActor(mux); err != nil {
+		return err
+	}
+	ws.mux = mux
+	return nil
+}
+
+// Multiplexer forwards the client messages, nil without routes.
+func (ws *WsServer) Multiplexer() *gateway.Multiplexer {
+	return ws.mux
+}
+
+// Run binds the configured port before returning, so that a port already
+// in use or an invalid certificate is reported to the caller.
 func (ws *WsServer) Run() error {
 	var (
 		client *Client
 	)
 
-	if ws.config.ServerCertificate != "" && ws.config.ServerPrivateKey != "" {
-		go ws.runHTTPS()
-	} else {
-		go ws.runHTTP()
+	useTLS := ws.config.ServerCertificate != "" && ws.config.ServerPrivateKey != ""
+	address := ws.config.HttpPort
+	if useTLS {
+		address = ws.config.HttpsPort
 	}
 
+	httpServer := &http.Server{Handler: newGinRouter(ws)}
+	if useTLS {
+		cert, err := tls.LoadX509KeyPair(ws.config.ServerCertificate, ws.config.ServerPrivateKey)
+		if err != nil {
+			return fmt.Errorf("load certificate: %w", err)
+		}
+		httpServer.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", address, err)
+	}
+	ws.httpServer = httpServer
+
+	ws.wg.Add(1)
+	go ws.serve(listener, useTLS)
+
 	go func() {
 		for {
 			select {
@@ -93,66 +143,36 @@ func (ws *WsServer) GetClient() *Client {
 }
 
 func (ws *WsServer) registerClient(client *Client) {
-
+	wsSessions.With().Inc()
 }
 
 func (ws *WsServer) unregisterClient(client *Client) {
+	wsSessions.With().Dec()
 }
 
-func (ws *WsServer) startHTTP() {
+func (ws *WsServer) serve(listener net.Listener, useTLS bool) {
 	defer func() {
 		ws.wg.Done()
 		log.Printf("Stopped HTTP server")
 	}()
 
-	listener, err := net.Listen("tcp", ws.config.HttpPort)
-	if err != nil {
-		log.Fatalf("Failed to create HTTP server: %v", err)
-	}
-
-	router := newGinRouter(ws)
-	ws.httpServer = &http.Server{Handler: router}
-
-	log.Printf("Starting HTTP server at %s", listener.Addr())
-	err = ws.httpServer.Serve(listener)
-	if err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Failed to start HTTP server: %v", err)
-	}
-}
-
-func (ws *WsServer) startHTTPS() {
-	defer func() {
-		ws.wg.Done()
-		log.Printf("Stopped HTTPS server")
-	}()
-
-	listener, err := net.Listen("tcp", ws.config.HttpsPort)
-	if err != nil {
-		log.Fatalf("Failed to create HTTPS server: %v", err)
+	var err error
+	if useTLS {
+		log.Printf("Starting HTTPS server at %s", listener.Addr())
+		err = ws.httpServer.ServeTLS(listener, "", "")
+	} else {
+		log.Printf("Starting HTTP server at %s", listener.Addr())
+		err = ws.httpServer.Serve(listener)
 	}
-
-	router := newGinRouter(ws)
-	ws.httpServer = &http.Server{Handler: router}
-
-	log.Printf("Starting HTTPS server on port %s", ws.config.HttpsPort)
-	err = ws.httpServer.ServeTLS(listener, ws.config.ServerCertificate, ws.config.ServerPrivateKey)
 	if err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Failed to start HTTPS server: %v", err)
+		log.Printf("HTTP server stopped with error: %v", err)
 	}
 }
 
-func (ws *WsServer) runHTTP() {
-	ws.wg.Add(1)
-	go ws.startHTTP()
-	ws.wg.Wait()
-}
-
-func (ws *WsServer) runHTTPS() {
-	ws.wg.Add(1)
-	go ws.startHTTPS()
-	ws.wg.Wait()
-}
-
 func (ws *WsServer) Stop() {
+	if ws.httpServer == nil {
+		return
+	}
 	ws.httpServer.Close()
+	ws.wg.Wait()
 }
@@ -32,6 +32,8 @@ type NATS_MSG_PRXOY struct {
 	//	*NATS_MSG_PRXOY_RpcResponse
 	//	*NATS_MSG_PRXOY_MultiplexerForward
 	//	*NATS_MSG_PRXOY_DemultiplexerForward
+	//	*NATS_MSG_PRXOY_RpcChunk
+	//	*NATS_MSG_PRXOY_RpcSigned
 	Msg isNATS_MSG_PRXOY_Msg `protobuf_oneof:"msg"`
 }
 
@@ -102,6 +104,20 @@ func (x *NATS_MSG_PRXOY) GetDemultiplexerForward() *rpc_msg.PRC_DeMultiplexer_Fo
 	return nil
 }
 
+func (x *NATS_MSG_PRXOY) GetRpcChunk() *rpc_msg.RPC_CHUNK {
+	if x, ok := x.GetMsg().(*NATS_MSG_PRXOY_RpcChunk); ok {
+		return x.RpcChunk
+	}
+	return nil
+}
+
+func (x *NATS_MSG_PRXOY) GetRpcSigned() *rpc_msg.RPC_SIGNED {
+	if x, ok := x.GetMsg().(*NATS_MSG_PRXOY_RpcSigned); ok {
+		return x.RpcSigned
+	}
+	return nil
+}
+
 type isNATS_MSG_PRXOY_Msg interface {
 	isNATS_MSG_PRXOY_Msg()
 }
@@ -122,6 +138,14 @@ type NATS_MSG_PRXOY_DemultiplexerForward struct {
 	DemultiplexerForward *rpc_msg.PRC_DeMultiplexer_Forward `protobuf:"bytes,103,opt,name=demultiplexer_forward,json=demultiplexerForward,proto3,oneof"`
 }
 
+type NATS_MSG_PRXOY_RpcChunk struct {
+	RpcChunk *rpc_msg.RPC_CHUNK `protobuf:"bytes,104,opt,name=rpc_chunk,json=rpcChunk,proto3,oneof"`
+}
+
+type NATS_MSG_PRXOY_RpcSigned struct {
+	RpcSigned *rpc_msg.RPC_SIGNED `protobuf:"bytes,105,opt,name=rpc_signed,json=rpcSigned,proto3,oneof"`
+}
+
 func (*NATS_MSG_PRXOY_RpcRequest) isNATS_MSG_PRXOY_Msg() {}
 
 func (*NATS_MSG_PRXOY_RpcResponse) isNATS_MSG_PRXOY_Msg() {}
@@ -130,6 +154,10 @@ func (*NATS_MSG_PRXOY_MultiplexerForward) isNATS_MSG_PRXOY_Msg() {}
 
 func (*NATS_MSG_PRXOY_DemultiplexerForward) isNATS_MSG_PRXOY_Msg() {}
 
+func (*NATS_MSG_PRXOY_RpcChunk) isNATS_MSG_PRXOY_Msg() {}
+
+func (*NATS_MSG_PRXOY_RpcSigned) isNATS_MSG_PRXOY_Msg() {}
+
 var File_proto_nats_msg_nats_msg_proto protoreflect.FileDescriptor
 
 var file_proto_nats_msg_nats_msg_proto_rawDesc = []byte{
@@ -137,7 +165,7 @@ var file_proto_nats_msg_nats_msg_proto_rawDesc = []byte{
 	0x2f, 0x6e, 0x61, 0x74, 0x73, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
 	0x08, 0x6e, 0x61, 0x74, 0x73, 0x5f, 0x6d, 0x73, 0x67, 0x1a, 0x1b, 0x70, 0x72, 0x6f, 0x74, 0x6f,
 	0x2f, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2f, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xbc, 0x02, 0x0a, 0x0e, 0x4e, 0x41, 0x54, 0x53, 0x5f,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xa5, 0x03, 0x0a, 0x0e, 0x4e, 0x41, 0x54, 0x53, 0x5f,
 	0x4d, 0x53, 0x47, 0x5f, 0x50, 0x52, 0x58, 0x4f, 0x59, 0x12, 0x37, 0x0a, 0x0b, 0x72, 0x70, 0x63,
 	0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x64, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14,
 	0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x52, 0x50, 0x43, 0x5f, 0x52, 0x45, 0x51,
@@ -156,12 +184,19 @@ var file_proto_nats_msg_nats_msg_proto_rawDesc = []byte{
 	0x28, 0x0b, 0x32, 0x22, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x50, 0x52, 0x43,
 	0x5f, 0x44, 0x65, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65, 0x78, 0x65, 0x72, 0x5f, 0x46,
 	0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x48, 0x00, 0x52, 0x14, 0x64, 0x65, 0x6d, 0x75, 0x6c, 0x74,
-	0x69, 0x70, 0x6c, 0x65, 0x78, 0x65, 0x72, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x42, 0x05,
-	0x0a, 0x03, 0x6d, 0x73, 0x67, 0x42, 0x3b, 0x5a, 0x39, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
-	0x63, 0x6f, 0x6d, 0x2f, 0x77, 0x75, 0x71, 0x75, 0x6e, 0x79, 0x6f, 0x6e, 0x67, 0x2f, 0x66, 0x69,
-	0x6c, 0x65, 0x5f, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x2f, 0x6e, 0x61, 0x74, 0x73, 0x5f, 0x6d, 0x73, 0x67, 0x3b, 0x6e, 0x61, 0x74, 0x73, 0x5f, 0x6d,
-	0x73, 0x67, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x69, 0x70, 0x6c, 0x65, 0x78, 0x65, 0x72, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x12, 0x31,
+	0x0a, 0x09, 0x72, 0x70, 0x63, 0x5f, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x18, 0x68, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x12, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x52, 0x50, 0x43, 0x5f,
+	0x43, 0x48, 0x55, 0x4e, 0x4b, 0x48, 0x00, 0x52, 0x08, 0x72, 0x70, 0x63, 0x43, 0x68, 0x75, 0x6e,
+	0x6b, 0x12, 0x34, 0x0a, 0x0a, 0x72, 0x70, 0x63, 0x5f, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x18,
+	0x69, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e,
+	0x52, 0x50, 0x43, 0x5f, 0x53, 0x49, 0x47, 0x4e, 0x45, 0x44, 0x48, 0x00, 0x52, 0x09, 0x72, 0x70,
+	0x63, 0x53, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x42, 0x05, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x42, 0x3b,
+	0x5a, 0x39, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x77, 0x75, 0x71,
+	0x75, 0x6e, 0x79, 0x6f, 0x6e, 0x67, 0x2f, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x73, 0x74, 0x6f, 0x72,
+	0x61, 0x67, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x6e, 0x61, 0x74, 0x73, 0x5f, 0x6d,
+	0x73, 0x67, 0x3b, 0x6e, 0x61, 0x74, 0x73, 0x5f, 0x6d, 0x73, 0x67, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -183,17 +218,21 @@ var file_proto_nats_msg_nats_msg_proto_goTypes = []interface{}{
 	(*rpc_msg.RPC_RESPONSE)(nil),              // 2: rpc_msg.RPC_RESPONSE
 	(*rpc_msg.RPC_Multiplexer_Forward)(nil),   // 3: rpc_msg.RPC_Multiplexer_Forward
 	(*rpc_msg.PRC_DeMultiplexer_Forward)(nil), // 4: rpc_msg.PRC_DeMultiplexer_Forward
+	(*rpc_msg.RPC_CHUNK)(nil),                 // 5: rpc_msg.RPC_CHUNK
+	(*rpc_msg.RPC_SIGNED)(nil),                // 6: rpc_msg.RPC_SIGNED
 }
 var file_proto_nats_msg_nats_msg_proto_depIdxs = []int32{
 	1, // 0: nats_msg.NATS_MSG_PRXOY.rpc_request:type_name -> rpc_msg.RPC_REQUEST
 	2, // 1: nats_msg.NATS_MSG_PRXOY.rpc_response:type_name -> rpc_msg.RPC_RESPONSE
 	3, // 2: nats_msg.NATS_MSG_PRXOY.multiplexer_forward:type_name -> rpc_msg.RPC_Multiplexer_Forward
 	4, // 3: nats_msg.NATS_MSG_PRXOY.demultiplexer_forward:type_name -> rpc_msg.PRC_DeMultiplexer_Forward
-	4, // [4:4] is the sub-list for method output_type
-	4, // [4:4] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	5, // 4: nats_msg.NATS_MSG_PRXOY.rpc_chunk:type_name -> rpc_msg.RPC_CHUNK
+	6, // 5: nats_msg.NATS_MSG_PRXOY.rpc_signed:type_name -> rpc_msg.RPC_SIGNED
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_proto_nats_msg_nats_msg_proto_init() }
@@ -220,6 +259,8 @@ func file_proto_nats_msg_nats_msg_proto_init() {
 		(*NATS_MSG_PRXOY_RpcResponse)(nil),
 		(*NATS_MSG_PRXOY_MultiplexerForward)(nil),
 		(*NATS_MSG_PRXOY_DemultiplexerForward)(nil),
+		(*NATS_MSG_PRXOY_RpcChunk)(nil),
+		(*NATS_MSG_PRXOY_RpcSigned)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -23,17 +23,19 @@ const (
 type RPC_OPCODES int32
 
 const (
-	RPC_OPCODES_RPC_None               RPC_OPCODES = 0
-	RPC_OPCODES_RPC_MysqlDescTable     RPC_OPCODES = 401
-	RPC_OPCODES_RPC_MysqlQuery         RPC_OPCODES = 402
-	RPC_OPCODES_RPC_MysqlInsert        RPC_OPCODES = 403
-	RPC_OPCODES_RPC_MysqlUpdate        RPC_OPCODES = 404
-	RPC_OPCODES_RPC_MysqlDelete        RPC_OPCODES = 405
-	RPC_OPCODES_RPC_MysqlQueryByFilter RPC_OPCODES = 406
-	RPC_OPCODES_RPC_MysqlMultiQuery    RPC_OPCODES = 407
-	RPC_OPCODES_RPC_MysqlQueryAll      RPC_OPCODES = 408
-	RPC_OPCODES_RPC_MysqlStatement     RPC_OPCODES = 409
-	RPC_OPCODES_RPC_RegisterInstance   RPC_OPCODES = 410
+	RPC_OPCODES_RPC_None                 RPC_OPCODES = 0
+	RPC_OPCODES_RPC_MysqlDescTable       RPC_OPCODES = 401
+	RPC_OPCODES_RPC_MysqlQuery           RPC_OPCODES = 402
+	RPC_OPCODES_RPC_MysqlInsert          RPC_OPCODES = 403
+	RPC_OPCODES_RPC_MysqlUpdate          RPC_OPCODES = 404
+	RPC_OPCODES_RPC_MysqlDelete          RPC_OPCODES = 405
+	RPC_OPCODES_RPC_MysqlQueryByFilter   RPC_OPCODES = 406
+	RPC_OPCODES_RPC_MysqlMultiQuery      RPC_OPCODES = 407
+	RPC_OPCODES_RPC_MysqlQueryAll        RPC_OPCODES = 408
+	RPC_OPCODES_RPC_MysqlStatement       RPC_OPCODES = 409
+	RPC_OPCODES_RPC_RegisterInstance     RPC_OPCODES = 410
+	RPC_OPCODES_RPC_MultiplexerForward   RPC_OPCODES = 411 // 网关 -> 后端服务, RPC_Multiplexer_Forward
+	RPC_OPCODES_RPC_DeMultiplexerForward RPC_OPCODES = 412 // 后端服务 -> 网关, PRC_DeMultiplexer_Forward
 )
 
 // Enum value maps for RPC_OPCODES.
@@ -50,19 +52,23 @@ var (
 		408: "RPC_MysqlQueryAll",
 		409: "RPC_MysqlStatement",
 		410: "RPC_RegisterInstance",
+		411: "RPC_MultiplexerForward",
+		412: "RPC_DeMultiplexerForward",
 	}
 	RPC_OPCODES_value = map[string]int32{
-		"RPC_None":               0,
-		"RPC_MysqlDescTable":     401,
-		"RPC_MysqlQuery":         402,
-		"RPC_MysqlInsert":        403,
-		"RPC_MysqlUpdate":        404,
-		"RPC_MysqlDelete":        405,
-		"RPC_MysqlQueryByFilter": 406,
-		"RPC_MysqlMultiQuery":    407,
-		"RPC_MysqlQueryAll":      408,
-		"RPC_MysqlStatement":     409,
-		"RPC_RegisterInstance":   410,
+		"RPC_None":                 0,
+		"RPC_MysqlDescTable":       401,
+		"RPC_MysqlQuery":           402,
+		"RPC_MysqlInsert":          403,
+		"RPC_MysqlUpdate":          404,
+		"RPC_MysqlDelete":          405,
+		"RPC_MysqlQueryByFilter":   406,
+		"RPC_MysqlMultiQuery":      407,
+		"RPC_MysqlQueryAll":        408,
+		"RPC_MysqlStatement":       409,
+		"RPC_RegisterInstance":     410,
+		"RPC_MultiplexerForward":   411,
+		"RPC_DeMultiplexerForward": 412,
 	}
 )
 
@@ -590,11 +596,17 @@ type RPC_REQUEST struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Client       *CLIENT_IDENTIFIER `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"`                                  // 调用方
-	Server       *SERVER_IDENTIFIER `protobuf:"bytes,2,opt,name=server,proto3" json:"server,omitempty"`                                  // 被调用方
-	ServerStream bool               `protobuf:"varint,3,opt,name=server_stream,json=serverStream,proto3" json:"server_stream,omitempty"` // 是否连续响应
-	Opcodes      uint32             `protobuf:"varint,4,opt,name=opcodes,proto3" json:"opcodes,omitempty"`
-	ArgsData     []byte             `protobuf:"bytes,5,opt,name=args_data,json=argsData,proto3" json:"args_data,omitempty"`
+	Client            *CLIENT_IDENTIFIER `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"`                                  // 调用方
+	Server            *SERVER_IDENTIFIER `protobuf:"bytes,2,opt,name=server,proto3" json:"server,omitempty"`                                  // 被调用方
+	ServerStream      bool               `protobuf:"varint,3,opt,name=server_stream,json=serverStream,proto3" json:"server_stream,omitempty"` // 是否连续响应
+	Opcodes           uint32             `protobuf:"varint,4,opt,name=opcodes,proto3" json:"opcodes,omitempty"`
+	ArgsData          []byte             `protobuf:"bytes,5,opt,name=args_data,json=argsData,proto3" json:"args_data,omitempty"`
+	Priority          int32              `protobuf:"varint,6,opt,name=priority,proto3" json:"priority,omitempty"`                                             // 优先级，数值越大越先处理
+	IdempotencyKey    string             `protobuf:"bytes,7,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`            // 幂等键
+	CodecType         uint32             `protobuf:"varint,8,opt,name=codec_type,json=codecType,proto3" json:"codec_type,omitempty"`                          // 参数编码类型
+	Compression       uint32             `protobuf:"varint,9,opt,name=compression,proto3" json:"compression,omitempty"`                                       // args_data 压缩算法
+	AcceptCompression uint32             `protobuf:"varint,10,opt,name=accept_compression,json=acceptCompression,proto3" json:"accept_compression,omitempty"` // 响应可用的压缩算法
+	Traceparent       string             `protobuf:"bytes,11,opt,name=traceparent,proto3" json:"traceparent,omitempty"`                                       // 链路追踪上下文 (W3C traceparent)
 }
 
 func (x *RPC_REQUEST) Reset() {
@@ -664,13 +676,57 @@ func (x *RPC_REQUEST) GetArgsData() []byte {
 	return nil
 }
 
+func (x *RPC_REQUEST) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *RPC_REQUEST) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *RPC_REQUEST) GetCodecType() uint32 {
+	if x != nil {
+		return x.CodecType
+	}
+	return 0
+}
+
+func (x *RPC_REQUEST) GetCompression() uint32 {
+	if x != nil {
+		return x.Compression
+	}
+	return 0
+}
+
+func (x *RPC_REQUEST) GetAcceptCompression() uint32 {
+	if x != nil {
+		return x.AcceptCompression
+	}
+	return 0
+}
+
+func (x *RPC_REQUEST) GetTraceparent() string {
+	if x != nil {
+		return x.Traceparent
+	}
+	return ""
+}
+
 type STATUS struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Code uint32 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
-	Msg  string `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	Code      uint32            `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Msg       string            `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	Details   map[string]string `protobuf:"bytes,3,rep,name=details,proto3" json:"details,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // 错误详情
+	Retryable bool              `protobuf:"varint,4,opt,name=retryable,proto3" json:"retryable,omitempty"`                                                                                    // 调用方是否可以重试
 }
 
 func (x *STATUS) Reset() {
@@ -719,17 +775,32 @@ func (x *STATUS) GetMsg() string {
 	return ""
 }
 
+func (x *STATUS) GetDetails() map[string]string {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+func (x *STATUS) GetRetryable() bool {
+	if x != nil {
+		return x.Retryable
+	}
+	return false
+}
+
 type RPC_RESPONSE struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Client     *CLIENT_IDENTIFIER `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"` // 调用方（与请求时一致）
-	Server     *SERVER_IDENTIFIER `protobuf:"bytes,2,opt,name=server,proto3" json:"server,omitempty"` // 被调用方
-	Status     *STATUS            `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
-	HasMore    bool               `protobuf:"varint,4,opt,name=has_more,json=hasMore,proto3" json:"has_more,omitempty"`
-	Offset     uint32             `protobuf:"varint,5,opt,name=offset,proto3" json:"offset,omitempty"`
-	ResultData []byte             `protobuf:"bytes,6,opt,name=result_data,json=resultData,proto3" json:"result_data,omitempty"`
+	Client      *CLIENT_IDENTIFIER `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"` // 调用方（与请求时一致）
+	Server      *SERVER_IDENTIFIER `protobuf:"bytes,2,opt,name=server,proto3" json:"server,omitempty"` // 被调用方
+	Status      *STATUS            `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	HasMore     bool               `protobuf:"varint,4,opt,name=has_more,json=hasMore,proto3" json:"has_more,omitempty"`
+	Offset      uint32             `protobuf:"varint,5,opt,name=offset,proto3" json:"offset,omitempty"`
+	ResultData  []byte             `protobuf:"bytes,6,opt,name=result_data,json=resultData,proto3" json:"result_data,omitempty"`
+	Compression uint32             `protobuf:"varint,7,opt,name=compression,proto3" json:"compression,omitempty"` // result_data 压缩算法
 }
 
 func (x *RPC_RESPONSE) Reset() {
@@ -806,6 +877,173 @@ func (x *RPC_RESPONSE) GetResultData() []byte {
 	return nil
 }
 
+func (x *RPC_RESPONSE) GetCompression() uint32 {
+	if x != nil {
+		return x.Compression
+	}
+	return 0
+}
+
+// 超过最大负载的 NATS_MSG_PRXOY 按顺序拆分的分片
+type RPC_CHUNK struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TransferId uint64 `protobuf:"varint,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"` // 同一消息的分片共享
+	Index      uint32 `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	Total      uint32 `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	TotalSize  uint32 `protobuf:"varint,4,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"` // 整个消息的字节数
+	Data       []byte `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *RPC_CHUNK) Reset() {
+	*x = RPC_CHUNK{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RPC_CHUNK) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPC_CHUNK) ProtoMessage() {}
+
+func (x *RPC_CHUNK) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPC_CHUNK.ProtoReflect.Descriptor instead.
+func (*RPC_CHUNK) Descriptor() ([]byte, []int) {
+	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RPC_CHUNK) GetTransferId() uint64 {
+	if x != nil {
+		return x.TransferId
+	}
+	return 0
+}
+
+func (x *RPC_CHUNK) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *RPC_CHUNK) GetTotal() uint32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *RPC_CHUNK) GetTotalSize() uint32 {
+	if x != nil {
+		return x.TotalSize
+	}
+	return 0
+}
+
+func (x *RPC_CHUNK) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// 带签名的 NATS_MSG_PRXOY, 接收方校验签名、时间戳和 nonce 后再处理
+type RPC_SIGNED struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Payload   []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`          // 原始的 NATS_MSG_PRXOY
+	KeyId     string `protobuf:"bytes,2,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"` // HMAC 共享密钥或 ed25519 节点公钥的标识
+	Timestamp int64  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`     // 签名时间, unix 毫秒
+	Nonce     []byte `protobuf:"bytes,4,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Signature []byte `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *RPC_SIGNED) Reset() {
+	*x = RPC_SIGNED{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RPC_SIGNED) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPC_SIGNED) ProtoMessage() {}
+
+func (x *RPC_SIGNED) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPC_SIGNED.ProtoReflect.Descriptor instead.
+func (*RPC_SIGNED) Descriptor() ([]byte, []int) {
+	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RPC_SIGNED) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *RPC_SIGNED) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
+func (x *RPC_SIGNED) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *RPC_SIGNED) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *RPC_SIGNED) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
 type RPC_Multiplexer_Forward struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -819,7 +1057,7 @@ type RPC_Multiplexer_Forward struct {
 func (x *RPC_Multiplexer_Forward) Reset() {
 	*x = RPC_Multiplexer_Forward{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[9]
+		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -832,7 +1070,7 @@ func (x *RPC_Multiplexer_Forward) String() string {
 func (*RPC_Multiplexer_Forward) ProtoMessage() {}
 
 func (x *RPC_Multiplexer_Forward) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[9]
+	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -845,7 +1083,7 @@ func (x *RPC_Multiplexer_Forward) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RPC_Multiplexer_Forward.ProtoReflect.Descriptor instead.
 func (*RPC_Multiplexer_Forward) Descriptor() ([]byte, []int) {
-	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{9}
+	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *RPC_Multiplexer_Forward) GetRole() *RoleIdentifier {
@@ -877,12 +1115,13 @@ type PRC_DeMultiplexer_Forward struct {
 	Role    *RoleIdentifier    `protobuf:"bytes,1,opt,name=role,proto3" json:"role,omitempty"`
 	Info    *ClientMessageInfo `protobuf:"bytes,2,opt,name=info,proto3" json:"info,omitempty"`
 	BodyMsg []byte             `protobuf:"bytes,3,opt,name=body_msg,json=bodyMsg,proto3" json:"body_msg,omitempty"`
+	Status  *STATUS            `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"` // 回复的错误码, 推送时为空
 }
 
 func (x *PRC_DeMultiplexer_Forward) Reset() {
 	*x = PRC_DeMultiplexer_Forward{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[10]
+		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -895,7 +1134,7 @@ func (x *PRC_DeMultiplexer_Forward) String() string {
 func (*PRC_DeMultiplexer_Forward) ProtoMessage() {}
 
 func (x *PRC_DeMultiplexer_Forward) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[10]
+	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -908,7 +1147,7 @@ func (x *PRC_DeMultiplexer_Forward) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PRC_DeMultiplexer_Forward.ProtoReflect.Descriptor instead.
 func (*PRC_DeMultiplexer_Forward) Descriptor() ([]byte, []int) {
-	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{10}
+	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *PRC_DeMultiplexer_Forward) GetRole() *RoleIdentifier {
@@ -932,6 +1171,13 @@ func (x *PRC_DeMultiplexer_Forward) GetBodyMsg() []byte {
 	return nil
 }
 
+func (x *PRC_DeMultiplexer_Forward) GetStatus() *STATUS {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
 type RPC_EchoTestRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -944,7 +1190,7 @@ type RPC_EchoTestRequest struct {
 func (x *RPC_EchoTestRequest) Reset() {
 	*x = RPC_EchoTestRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[11]
+		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[13]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -957,7 +1203,7 @@ func (x *RPC_EchoTestRequest) String() string {
 func (*RPC_EchoTestRequest) ProtoMessage() {}
 
 func (x *RPC_EchoTestRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[11]
+	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[13]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -970,7 +1216,7 @@ func (x *RPC_EchoTestRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RPC_EchoTestRequest.ProtoReflect.Descriptor instead.
 func (*RPC_EchoTestRequest) Descriptor() ([]byte, []int) {
-	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{11}
+	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *RPC_EchoTestRequest) GetValue1() uint64 {
@@ -999,7 +1245,7 @@ type RPC_EchoTestResponse struct {
 func (x *RPC_EchoTestResponse) Reset() {
 	*x = RPC_EchoTestResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[12]
+		mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[14]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1012,7 +1258,7 @@ func (x *RPC_EchoTestResponse) String() string {
 func (*RPC_EchoTestResponse) ProtoMessage() {}
 
 func (x *RPC_EchoTestResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[12]
+	mi := &file_proto_rpc_msg_rpc_msg_proto_msgTypes[14]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1025,7 +1271,7 @@ func (x *RPC_EchoTestResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RPC_EchoTestResponse.ProtoReflect.Descriptor instead.
 func (*RPC_EchoTestResponse) Descriptor() ([]byte, []int) {
-	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{12}
+	return file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *RPC_EchoTestResponse) GetValue1() uint64 {
@@ -1092,8 +1338,8 @@ var file_proto_rpc_msg_rpc_msg_proto_rawDesc = []byte{
 	0x70, 0x69, 0x63, 0x22, 0x39, 0x0a, 0x11, 0x53, 0x45, 0x52, 0x56, 0x45, 0x52, 0x5f, 0x49, 0x44,
 	0x45, 0x4e, 0x54, 0x49, 0x46, 0x49, 0x45, 0x52, 0x12, 0x24, 0x0a, 0x04, 0x73, 0x74, 0x75, 0x62,
 	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67,
-	0x2e, 0x43, 0x48, 0x41, 0x4e, 0x4e, 0x45, 0x4c, 0x52, 0x04, 0x73, 0x74, 0x75, 0x62, 0x22, 0xd1,
-	0x01, 0x0a, 0x0b, 0x52, 0x50, 0x43, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x12, 0x32,
+	0x2e, 0x43, 0x48, 0x41, 0x4e, 0x4e, 0x45, 0x4c, 0x52, 0x04, 0x73, 0x74, 0x75, 0x62, 0x22, 0xa8,
+	0x03, 0x0a, 0x0b, 0x52, 0x50, 0x43, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x12, 0x32,
 	0x0a, 0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
 	0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f,
 	0x49, 0x44, 0x45, 0x4e, 0x54, 0x49, 0x46, 0x49, 0x45, 0x52, 0x52, 0x06, 0x63, 0x6c, 0x69, 0x65,
@@ -1106,104 +1352,153 @@ var file_proto_rpc_msg_rpc_msg_proto_rawDesc = []byte{
 	0x70, 0x63, 0x6f, 0x64, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x6f, 0x70,
 	0x63, 0x6f, 0x64, 0x65, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x61, 0x72, 0x67, 0x73, 0x5f, 0x64, 0x61,
 	0x74, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x61, 0x72, 0x67, 0x73, 0x44, 0x61,
-	0x74, 0x61, 0x22, 0x2e, 0x0a, 0x06, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x12, 0x12, 0x0a, 0x04,
-	0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65,
-	0x12, 0x10, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d,
-	0x73, 0x67, 0x22, 0xf3, 0x01, 0x0a, 0x0c, 0x52, 0x50, 0x43, 0x5f, 0x52, 0x45, 0x53, 0x50, 0x4f,
-	0x4e, 0x53, 0x45, 0x12, 0x32, 0x0a, 0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x43, 0x4c,
-	0x49, 0x45, 0x4e, 0x54, 0x5f, 0x49, 0x44, 0x45, 0x4e, 0x54, 0x49, 0x46, 0x49, 0x45, 0x52, 0x52,
-	0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12, 0x32, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x76, 0x65,
-	0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73,
-	0x67, 0x2e, 0x53, 0x45, 0x52, 0x56, 0x45, 0x52, 0x5f, 0x49, 0x44, 0x45, 0x4e, 0x54, 0x49, 0x46,
-	0x49, 0x45, 0x52, 0x52, 0x06, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x12, 0x27, 0x0a, 0x06, 0x73,
-	0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x72, 0x70,
-	0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x52, 0x06, 0x73, 0x74,
-	0x61, 0x74, 0x75, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x68, 0x61, 0x73, 0x5f, 0x6d, 0x6f, 0x72, 0x65,
-	0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x68, 0x61, 0x73, 0x4d, 0x6f, 0x72, 0x65, 0x12,
-	0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52,
-	0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65, 0x73, 0x75, 0x6c,
-	0x74, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x72, 0x65,
-	0x73, 0x75, 0x6c, 0x74, 0x44, 0x61, 0x74, 0x61, 0x22, 0x91, 0x01, 0x0a, 0x17, 0x52, 0x50, 0x43,
-	0x5f, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65, 0x78, 0x65, 0x72, 0x5f, 0x46, 0x6f, 0x72,
-	0x77, 0x61, 0x72, 0x64, 0x12, 0x2b, 0x0a, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x52, 0x6f, 0x6c,
-	0x65, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x52, 0x04, 0x72, 0x6f, 0x6c,
-	0x65, 0x12, 0x2e, 0x0a, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x1a, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74,
-	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x04, 0x69, 0x6e, 0x66,
-	0x6f, 0x12, 0x19, 0x0a, 0x08, 0x62, 0x6f, 0x64, 0x79, 0x5f, 0x6d, 0x73, 0x67, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x0c, 0x52, 0x07, 0x62, 0x6f, 0x64, 0x79, 0x4d, 0x73, 0x67, 0x22, 0x93, 0x01, 0x0a,
-	0x19, 0x50, 0x52, 0x43, 0x5f, 0x44, 0x65, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65, 0x78,
-	0x65, 0x72, 0x5f, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x12, 0x2b, 0x0a, 0x04, 0x72, 0x6f,
-	0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d,
-	0x73, 0x67, 0x2e, 0x52, 0x6f, 0x6c, 0x65, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65,
-	0x72, 0x52, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x12, 0x2e, 0x0a, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e,
-	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x6e, 0x66,
-	0x6f, 0x52, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x12, 0x19, 0x0a, 0x08, 0x62, 0x6f, 0x64, 0x79, 0x5f,
-	0x6d, 0x73, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x62, 0x6f, 0x64, 0x79, 0x4d,
-	0x73, 0x67, 0x22, 0x45, 0x0a, 0x13, 0x52, 0x50, 0x43, 0x5f, 0x45, 0x63, 0x68, 0x6f, 0x54, 0x65,
-	0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c,
-	0x75, 0x65, 0x31, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x31, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x32, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x32, 0x22, 0x46, 0x0a, 0x14, 0x52, 0x50, 0x43,
-	0x5f, 0x45, 0x63, 0x68, 0x6f, 0x54, 0x65, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
-	0x65, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x31, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x04, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x31, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c,
-	0x75, 0x65, 0x32, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x32, 0x2a, 0x8e, 0x02, 0x0a, 0x0b, 0x52, 0x50, 0x43, 0x5f, 0x4f, 0x50, 0x43, 0x4f, 0x44, 0x45,
-	0x53, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x50, 0x43, 0x5f, 0x4e, 0x6f, 0x6e, 0x65, 0x10, 0x00, 0x12,
-	0x17, 0x0a, 0x12, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x44, 0x65, 0x73, 0x63,
-	0x54, 0x61, 0x62, 0x6c, 0x65, 0x10, 0x91, 0x03, 0x12, 0x13, 0x0a, 0x0e, 0x52, 0x50, 0x43, 0x5f,
-	0x4d, 0x79, 0x73, 0x71, 0x6c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x10, 0x92, 0x03, 0x12, 0x14, 0x0a,
-	0x0f, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x49, 0x6e, 0x73, 0x65, 0x72, 0x74,
-	0x10, 0x93, 0x03, 0x12, 0x14, 0x0a, 0x0f, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c,
-	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x10, 0x94, 0x03, 0x12, 0x14, 0x0a, 0x0f, 0x52, 0x50, 0x43,
-	0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x10, 0x95, 0x03, 0x12,
-	0x1b, 0x0a, 0x16, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x51, 0x75, 0x65, 0x72,
-	0x79, 0x42, 0x79, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x10, 0x96, 0x03, 0x12, 0x18, 0x0a, 0x13,
-	0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x51, 0x75,
-	0x65, 0x72, 0x79, 0x10, 0x97, 0x03, 0x12, 0x16, 0x0a, 0x11, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79,
-	0x73, 0x71, 0x6c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x41, 0x6c, 0x6c, 0x10, 0x98, 0x03, 0x12, 0x17,
-	0x0a, 0x12, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x53, 0x74, 0x61, 0x74, 0x65,
-	0x6d, 0x65, 0x6e, 0x74, 0x10, 0x99, 0x03, 0x12, 0x19, 0x0a, 0x14, 0x52, 0x50, 0x43, 0x5f, 0x52,
-	0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x10,
-	0x9a, 0x03, 0x2a, 0xe3, 0x03, 0x0a, 0x08, 0x52, 0x50, 0x43, 0x5f, 0x43, 0x4f, 0x44, 0x45, 0x12,
-	0x0b, 0x0a, 0x07, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4f, 0x6b, 0x10, 0x00, 0x12, 0x11, 0x0a, 0x0d,
-	0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4f, 0x6b, 0x5f, 0x41, 0x73, 0x79, 0x6e, 0x63, 0x10, 0x01, 0x12,
-	0x10, 0x0a, 0x0c, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x10,
-	0x64, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x55, 0x6e, 0x72, 0x65, 0x67, 0x69,
-	0x73, 0x74, 0x65, 0x72, 0x10, 0x65, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x50,
-	0x61, 0x72, 0x73, 0x65, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x66, 0x12, 0x18, 0x0a, 0x14, 0x43,
-	0x4f, 0x44, 0x45, 0x5f, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x50,
-	0x6f, 0x73, 0x74, 0x10, 0x67, 0x12, 0x1d, 0x0a, 0x19, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x52, 0x6f,
-	0x75, 0x74, 0x65, 0x4e, 0x6f, 0x74, 0x4c, 0x69, 0x6e, 0x6b, 0x54, 0x6f, 0x53, 0x65, 0x72, 0x76,
-	0x65, 0x72, 0x10, 0x68, 0x12, 0x1f, 0x0a, 0x1b, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x52, 0x6f, 0x75,
-	0x74, 0x65, 0x53, 0x65, 0x6e, 0x64, 0x54, 0x6f, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x45, 0x72,
-	0x72, 0x6f, 0x72, 0x10, 0x69, 0x12, 0x19, 0x0a, 0x15, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4f, 0x70,
-	0x63, 0x6f, 0x64, 0x65, 0x55, 0x6e, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x10, 0x6a,
-	0x12, 0x17, 0x0a, 0x13, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x4d,
-	0x73, 0x67, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x6b, 0x12, 0x18, 0x0a, 0x14, 0x43, 0x4f, 0x44,
-	0x45, 0x5f, 0x4c, 0x6f, 0x67, 0x69, 0x63, 0x54, 0x68, 0x72, 0x65, 0x61, 0x64, 0x4e, 0x75, 0x6c,
-	0x6c, 0x10, 0x6c, 0x12, 0x19, 0x0a, 0x15, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4e, 0x6f, 0x74, 0x52,
-	0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x10, 0x6d, 0x12, 0x10,
-	0x0a, 0x0c, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4e, 0x6f, 0x74, 0x53, 0x65, 0x6e, 0x64, 0x10, 0x6e,
-	0x12, 0x18, 0x0a, 0x14, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4c, 0x6f, 0x61, 0x64, 0x46, 0x72, 0x6f,
-	0x6d, 0x44, 0x62, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x6f, 0x12, 0x16, 0x0a, 0x12, 0x43, 0x4f,
-	0x44, 0x45, 0x5f, 0x44, 0x69, 0x72, 0x74, 0x79, 0x46, 0x6c, 0x61, 0x67, 0x5a, 0x65, 0x72, 0x6f,
-	0x10, 0x70, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x51, 0x75, 0x65, 0x72, 0x79,
-	0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x71, 0x12, 0x1f, 0x0a, 0x1b, 0x43, 0x4f, 0x44, 0x45, 0x5f,
-	0x54, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x4e, 0x6f, 0x74, 0x45, 0x78, 0x69, 0x73,
-	0x74, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x72, 0x12, 0x1e, 0x0a, 0x1a, 0x43, 0x4f, 0x44, 0x45,
-	0x5f, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x51, 0x75, 0x65, 0x72, 0x79, 0x53, 0x51,
-	0x4c, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x73, 0x12, 0x1e, 0x0a, 0x1a, 0x43, 0x4f, 0x44, 0x45,
-	0x5f, 0x4e, 0x6f, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x64, 0x52, 0x65, 0x73, 0x75, 0x6c,
-	0x74, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x74, 0x42, 0x39, 0x5a, 0x37, 0x67, 0x69, 0x74, 0x68,
-	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x77, 0x75, 0x71, 0x75, 0x6e, 0x79, 0x6f, 0x6e, 0x67,
-	0x2f, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x2f, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x2f, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x3b, 0x72, 0x70, 0x63, 0x5f,
-	0x6d, 0x73, 0x67, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x74, 0x61, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x27,
+	0x0a, 0x0f, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x5f, 0x6b, 0x65,
+	0x79, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x69, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74,
+	0x65, 0x6e, 0x63, 0x79, 0x4b, 0x65, 0x79, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x6f, 0x64, 0x65, 0x63,
+	0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x63, 0x6f, 0x64,
+	0x65, 0x63, 0x54, 0x79, 0x70, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x63, 0x6f, 0x6d,
+	0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x2d, 0x0a, 0x12, 0x61, 0x63, 0x63, 0x65,
+	0x70, 0x74, 0x5f, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x11, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x43, 0x6f, 0x6d, 0x70,
+	0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x20, 0x0a, 0x0b, 0x74, 0x72, 0x61, 0x63, 0x65,
+	0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x74, 0x72,
+	0x61, 0x63, 0x65, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x22, 0xc0, 0x01, 0x0a, 0x06, 0x53, 0x54,
+	0x41, 0x54, 0x55, 0x53, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x12, 0x36, 0x0a, 0x07, 0x64, 0x65,
+	0x74, 0x61, 0x69, 0x6c, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x72, 0x70,
+	0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x2e, 0x44, 0x65, 0x74,
+	0x61, 0x69, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x64, 0x65, 0x74, 0x61, 0x69,
+	0x6c, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x72, 0x65, 0x74, 0x72, 0x79, 0x61, 0x62, 0x6c, 0x65, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x72, 0x65, 0x74, 0x72, 0x79, 0x61, 0x62, 0x6c, 0x65,
+	0x1a, 0x3a, 0x0a, 0x0c, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x95, 0x02, 0x0a,
+	0x0c, 0x52, 0x50, 0x43, 0x5f, 0x52, 0x45, 0x53, 0x50, 0x4f, 0x4e, 0x53, 0x45, 0x12, 0x32, 0x0a,
+	0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x5f, 0x49,
+	0x44, 0x45, 0x4e, 0x54, 0x49, 0x46, 0x49, 0x45, 0x52, 0x52, 0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x12, 0x32, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x53, 0x45, 0x52, 0x56,
+	0x45, 0x52, 0x5f, 0x49, 0x44, 0x45, 0x4e, 0x54, 0x49, 0x46, 0x49, 0x45, 0x52, 0x52, 0x06, 0x73,
+	0x65, 0x72, 0x76, 0x65, 0x72, 0x12, 0x27, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e,
+	0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x19,
+	0x0a, 0x08, 0x68, 0x61, 0x73, 0x5f, 0x6d, 0x6f, 0x72, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x07, 0x68, 0x61, 0x73, 0x4d, 0x6f, 0x72, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66,
+	0x73, 0x65, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65,
+	0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x5f, 0x64, 0x61, 0x74, 0x61,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x44, 0x61,
+	0x74, 0x61, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x22, 0x8b, 0x01, 0x0a, 0x09, 0x52, 0x50, 0x43, 0x5f, 0x43, 0x48, 0x55,
+	0x4e, 0x4b, 0x12, 0x1f, 0x0a, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x65, 0x72, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x65,
+	0x72, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x74,
+	0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x12,
+	0x1d, 0x0a, 0x0a, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x09, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x12,
+	0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61,
+	0x74, 0x61, 0x22, 0x8f, 0x01, 0x0a, 0x0a, 0x52, 0x50, 0x43, 0x5f, 0x53, 0x49, 0x47, 0x4e, 0x45,
+	0x44, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x15, 0x0a, 0x06, 0x6b,
+	0x65, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6b, 0x65, 0x79,
+	0x49, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74,
+	0x75, 0x72, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x22, 0x91, 0x01, 0x0a, 0x17, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x75, 0x6c,
+	0x74, 0x69, 0x70, 0x6c, 0x65, 0x78, 0x65, 0x72, 0x5f, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64,
+	0x12, 0x2b, 0x0a, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x52, 0x6f, 0x6c, 0x65, 0x49, 0x64, 0x65,
+	0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x52, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x12, 0x2e, 0x0a,
+	0x04, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x72, 0x70,
+	0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x12, 0x19, 0x0a,
+	0x08, 0x62, 0x6f, 0x64, 0x79, 0x5f, 0x6d, 0x73, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x07, 0x62, 0x6f, 0x64, 0x79, 0x4d, 0x73, 0x67, 0x22, 0xbc, 0x01, 0x0a, 0x19, 0x50, 0x52, 0x43,
+	0x5f, 0x44, 0x65, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65, 0x78, 0x65, 0x72, 0x5f, 0x46,
+	0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x12, 0x2b, 0x0a, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x52,
+	0x6f, 0x6c, 0x65, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x52, 0x04, 0x72,
+	0x6f, 0x6c, 0x65, 0x12, 0x2e, 0x0a, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x43, 0x6c, 0x69, 0x65,
+	0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x04, 0x69,
+	0x6e, 0x66, 0x6f, 0x12, 0x19, 0x0a, 0x08, 0x62, 0x6f, 0x64, 0x79, 0x5f, 0x6d, 0x73, 0x67, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x62, 0x6f, 0x64, 0x79, 0x4d, 0x73, 0x67, 0x12, 0x27,
+	0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f,
+	0x2e, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x2e, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x52,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x45, 0x0a, 0x13, 0x52, 0x50, 0x43, 0x5f, 0x45,
+	0x63, 0x68, 0x6f, 0x54, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16,
+	0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x31, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x31, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x32,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x32, 0x22, 0x46,
+	0x0a, 0x14, 0x52, 0x50, 0x43, 0x5f, 0x45, 0x63, 0x68, 0x6f, 0x54, 0x65, 0x73, 0x74, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x31,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x31, 0x12, 0x16,
+	0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x32, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x32, 0x2a, 0xca, 0x02, 0x0a, 0x0b, 0x52, 0x50, 0x43, 0x5f, 0x4f,
+	0x50, 0x43, 0x4f, 0x44, 0x45, 0x53, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x50, 0x43, 0x5f, 0x4e, 0x6f,
+	0x6e, 0x65, 0x10, 0x00, 0x12, 0x17, 0x0a, 0x12, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71,
+	0x6c, 0x44, 0x65, 0x73, 0x63, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x10, 0x91, 0x03, 0x12, 0x13, 0x0a,
+	0x0e, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x10,
+	0x92, 0x03, 0x12, 0x14, 0x0a, 0x0f, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x49,
+	0x6e, 0x73, 0x65, 0x72, 0x74, 0x10, 0x93, 0x03, 0x12, 0x14, 0x0a, 0x0f, 0x52, 0x50, 0x43, 0x5f,
+	0x4d, 0x79, 0x73, 0x71, 0x6c, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x10, 0x94, 0x03, 0x12, 0x14,
+	0x0a, 0x0f, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x44, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x10, 0x95, 0x03, 0x12, 0x1b, 0x0a, 0x16, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71,
+	0x6c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x42, 0x79, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x10, 0x96,
+	0x03, 0x12, 0x18, 0x0a, 0x13, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x4d, 0x75,
+	0x6c, 0x74, 0x69, 0x51, 0x75, 0x65, 0x72, 0x79, 0x10, 0x97, 0x03, 0x12, 0x16, 0x0a, 0x11, 0x52,
+	0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x41, 0x6c, 0x6c,
+	0x10, 0x98, 0x03, 0x12, 0x17, 0x0a, 0x12, 0x52, 0x50, 0x43, 0x5f, 0x4d, 0x79, 0x73, 0x71, 0x6c,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x10, 0x99, 0x03, 0x12, 0x19, 0x0a, 0x14,
+	0x52, 0x50, 0x43, 0x5f, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x49, 0x6e, 0x73, 0x74,
+	0x61, 0x6e, 0x63, 0x65, 0x10, 0x9a, 0x03, 0x12, 0x1b, 0x0a, 0x16, 0x52, 0x50, 0x43, 0x5f, 0x4d,
+	0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65, 0x78, 0x65, 0x72, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72,
+	0x64, 0x10, 0x9b, 0x03, 0x12, 0x1d, 0x0a, 0x18, 0x52, 0x50, 0x43, 0x5f, 0x44, 0x65, 0x4d, 0x75,
+	0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65, 0x78, 0x65, 0x72, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64,
+	0x10, 0x9c, 0x03, 0x2a, 0xe3, 0x03, 0x0a, 0x08, 0x52, 0x50, 0x43, 0x5f, 0x43, 0x4f, 0x44, 0x45,
+	0x12, 0x0b, 0x0a, 0x07, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4f, 0x6b, 0x10, 0x00, 0x12, 0x11, 0x0a,
+	0x0d, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4f, 0x6b, 0x5f, 0x41, 0x73, 0x79, 0x6e, 0x63, 0x10, 0x01,
+	0x12, 0x10, 0x0a, 0x0c, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74,
+	0x10, 0x64, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x55, 0x6e, 0x72, 0x65, 0x67,
+	0x69, 0x73, 0x74, 0x65, 0x72, 0x10, 0x65, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x4f, 0x44, 0x45, 0x5f,
+	0x50, 0x61, 0x72, 0x73, 0x65, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x66, 0x12, 0x18, 0x0a, 0x14,
+	0x43, 0x4f, 0x44, 0x45, 0x5f, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72,
+	0x50, 0x6f, 0x73, 0x74, 0x10, 0x67, 0x12, 0x1d, 0x0a, 0x19, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x52,
+	0x6f, 0x75, 0x74, 0x65, 0x4e, 0x6f, 0x74, 0x4c, 0x69, 0x6e, 0x6b, 0x54, 0x6f, 0x53, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x10, 0x68, 0x12, 0x1f, 0x0a, 0x1b, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x52, 0x6f,
+	0x75, 0x74, 0x65, 0x53, 0x65, 0x6e, 0x64, 0x54, 0x6f, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x45,
+	0x72, 0x72, 0x6f, 0x72, 0x10, 0x69, 0x12, 0x19, 0x0a, 0x15, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4f,
+	0x70, 0x63, 0x6f, 0x64, 0x65, 0x55, 0x6e, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x10,
+	0x6a, 0x12, 0x17, 0x0a, 0x13, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65,
+	0x4d, 0x73, 0x67, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x6b, 0x12, 0x18, 0x0a, 0x14, 0x43, 0x4f,
+	0x44, 0x45, 0x5f, 0x4c, 0x6f, 0x67, 0x69, 0x63, 0x54, 0x68, 0x72, 0x65, 0x61, 0x64, 0x4e, 0x75,
+	0x6c, 0x6c, 0x10, 0x6c, 0x12, 0x19, 0x0a, 0x15, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4e, 0x6f, 0x74,
+	0x52, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x10, 0x6d, 0x12,
+	0x10, 0x0a, 0x0c, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4e, 0x6f, 0x74, 0x53, 0x65, 0x6e, 0x64, 0x10,
+	0x6e, 0x12, 0x18, 0x0a, 0x14, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x4c, 0x6f, 0x61, 0x64, 0x46, 0x72,
+	0x6f, 0x6d, 0x44, 0x62, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x6f, 0x12, 0x16, 0x0a, 0x12, 0x43,
+	0x4f, 0x44, 0x45, 0x5f, 0x44, 0x69, 0x72, 0x74, 0x79, 0x46, 0x6c, 0x61, 0x67, 0x5a, 0x65, 0x72,
+	0x6f, 0x10, 0x70, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x51, 0x75, 0x65, 0x72,
+	0x79, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x71, 0x12, 0x1f, 0x0a, 0x1b, 0x43, 0x4f, 0x44, 0x45,
+	0x5f, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x4e, 0x6f, 0x74, 0x45, 0x78, 0x69,
+	0x73, 0x74, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x72, 0x12, 0x1e, 0x0a, 0x1a, 0x43, 0x4f, 0x44,
+	0x45, 0x5f, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x51, 0x75, 0x65, 0x72, 0x79, 0x53,
+	0x51, 0x4c, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x73, 0x12, 0x1e, 0x0a, 0x1a, 0x43, 0x4f, 0x44,
+	0x45, 0x5f, 0x4e, 0x6f, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x64, 0x52, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x10, 0x74, 0x42, 0x39, 0x5a, 0x37, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x77, 0x75, 0x71, 0x75, 0x6e, 0x79, 0x6f, 0x6e,
+	0x67, 0x2f, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x72, 0x70, 0x63, 0x5f, 0x6d, 0x73, 0x67, 0x3b, 0x72, 0x70, 0x63,
+	0x5f, 0x6d, 0x73, 0x67, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1219,7 +1514,7 @@ func file_proto_rpc_msg_rpc_msg_proto_rawDescGZIP() []byte {
 }
 
 var file_proto_rpc_msg_rpc_msg_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_proto_rpc_msg_rpc_msg_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_proto_rpc_msg_rpc_msg_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
 var file_proto_rpc_msg_rpc_msg_proto_goTypes = []interface{}{
 	(RPC_OPCODES)(0),                  // 0: rpc_msg.RPC_OPCODES
 	(RPC_CODE)(0),                     // 1: rpc_msg.RPC_CODE
@@ -1232,10 +1527,13 @@ var file_proto_rpc_msg_rpc_msg_proto_goTypes = []interface{}{
 	(*RPC_REQUEST)(nil),               // 8: rpc_msg.RPC_REQUEST
 	(*STATUS)(nil),                    // 9: rpc_msg.STATUS
 	(*RPC_RESPONSE)(nil),              // 10: rpc_msg.RPC_RESPONSE
-	(*RPC_Multiplexer_Forward)(nil),   // 11: rpc_msg.RPC_Multiplexer_Forward
-	(*PRC_DeMultiplexer_Forward)(nil), // 12: rpc_msg.PRC_DeMultiplexer_Forward
-	(*RPC_EchoTestRequest)(nil),       // 13: rpc_msg.RPC_EchoTestRequest
-	(*RPC_EchoTestResponse)(nil),      // 14: rpc_msg.RPC_EchoTestResponse
+	(*RPC_CHUNK)(nil),                 // 11: rpc_msg.RPC_CHUNK
+	(*RPC_SIGNED)(nil),                // 12: rpc_msg.RPC_SIGNED
+	(*RPC_Multiplexer_Forward)(nil),   // 13: rpc_msg.RPC_Multiplexer_Forward
+	(*PRC_DeMultiplexer_Forward)(nil), // 14: rpc_msg.PRC_DeMultiplexer_Forward
+	(*RPC_EchoTestRequest)(nil),       // 15: rpc_msg.RPC_EchoTestRequest
+	(*RPC_EchoTestResponse)(nil),      // 16: rpc_msg.RPC_EchoTestResponse
+	nil,                               // 17: rpc_msg.STATUS.DetailsEntry
 }
 var file_proto_rpc_msg_rpc_msg_proto_depIdxs = []int32{
 	2,  // 0: rpc_msg.RoleIdentifier.gw_id:type_name -> rpc_msg.CHANNEL
@@ -1244,18 +1542,20 @@ var file_proto_rpc_msg_rpc_msg_proto_depIdxs = []int32{
 	2,  // 3: rpc_msg.SERVER_IDENTIFIER.stub:type_name -> rpc_msg.CHANNEL
 	6,  // 4: rpc_msg.RPC_REQUEST.client:type_name -> rpc_msg.CLIENT_IDENTIFIER
 	7,  // 5: rpc_msg.RPC_REQUEST.server:type_name -> rpc_msg.SERVER_IDENTIFIER
-	6,  // 6: rpc_msg.RPC_RESPONSE.client:type_name -> rpc_msg.CLIENT_IDENTIFIER
-	7,  // 7: rpc_msg.RPC_RESPONSE.server:type_name -> rpc_msg.SERVER_IDENTIFIER
-	9,  // 8: rpc_msg.RPC_RESPONSE.status:type_name -> rpc_msg.STATUS
-	5,  // 9: rpc_msg.RPC_Multiplexer_Forward.role:type_name -> rpc_msg.RoleIdentifier
-	4,  // 10: rpc_msg.RPC_Multiplexer_Forward.info:type_name -> rpc_msg.ClientMessageInfo
-	5,  // 11: rpc_msg.PRC_DeMultiplexer_Forward.role:type_name -> rpc_msg.RoleIdentifier
-	4,  // 12: rpc_msg.PRC_DeMultiplexer_Forward.info:type_name -> rpc_msg.ClientMessageInfo
-	13, // [13:13] is the sub-list for method output_type
-	13, // [13:13] is the sub-list for method input_type
-	13, // [13:13] is the sub-list for extension type_name
-	13, // [13:13] is the sub-list for extension extendee
-	0,  // [0:13] is the sub-list for field type_name
+	17, // 6: rpc_msg.STATUS.details:type_name -> rpc_msg.STATUS.DetailsEntry
+	6,  // 7: rpc_msg.RPC_RESPONSE.client:type_name -> rpc_msg.CLIENT_IDENTIFIER
+	7,  // 8: rpc_msg.RPC_RESPONSE.server:type_name -> rpc_msg.SERVER_IDENTIFIER
+	9,  // 9: rpc_msg.RPC_RESPONSE.status:type_name -> rpc_msg.STATUS
+	5,  // 10: rpc_msg.RPC_Multiplexer_Forward.role:type_name -> rpc_msg.RoleIdentifier
+	4,  // 11: rpc_msg.RPC_Multiplexer_Forward.info:type_name -> rpc_msg.ClientMessageInfo
+	5,  // 12: rpc_msg.PRC_DeMultiplexer_Forward.role:type_name -> rpc_msg.RoleIdentifier
+	4,  // 13: rpc_msg.PRC_DeMultiplexer_Forward.info:type_name -> rpc_msg.ClientMessageInfo
+	9,  // 14: rpc_msg.PRC_DeMultiplexer_Forward.status:type_name -> rpc_msg.STATUS
+	15, // [15:15] is the sub-list for method output_type
+	15, // [15:15] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
 }
 
 func init() { file_proto_rpc_msg_rpc_msg_proto_init() }
@@ -1373,7 +1673,7 @@ func file_proto_rpc_msg_rpc_msg_proto_init() {
 			}
 		}
 		file_proto_rpc_msg_rpc_msg_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RPC_Multiplexer_Forward); i {
+			switch v := v.(*RPC_CHUNK); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1385,7 +1685,7 @@ func file_proto_rpc_msg_rpc_msg_proto_init() {
 			}
 		}
 		file_proto_rpc_msg_rpc_msg_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PRC_DeMultiplexer_Forward); i {
+			switch v := v.(*RPC_SIGNED); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1397,7 +1697,7 @@ func file_proto_rpc_msg_rpc_msg_proto_init() {
 			}
 		}
 		file_proto_rpc_msg_rpc_msg_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RPC_EchoTestRequest); i {
+			switch v := v.(*RPC_Multiplexer_Forward); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1409,6 +1709,30 @@ func file_proto_rpc_msg_rpc_msg_proto_init() {
 			}
 		}
 		file_proto_rpc_msg_rpc_msg_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PRC_DeMultiplexer_Forward); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_rpc_msg_rpc_msg_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RPC_EchoTestRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_rpc_msg_rpc_msg_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*RPC_EchoTestResponse); i {
 			case 0:
 				return &v.state
@@ -1427,7 +1751,7 @@ func file_proto_rpc_msg_rpc_msg_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_proto_rpc_msg_rpc_msg_proto_rawDesc,
 			NumEnums:      2,
-			NumMessages:   13,
+			NumMessages:   16,
 			NumExtensions: 0,
 			NumServices:   0,
 		},